@@ -1,16 +1,40 @@
 package app
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/bagasadiii/gofood-clone/handler"
+	"github.com/bagasadiii/gofood-clone/health"
 	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/tracing"
+	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/gorilla/mux"
 )
 
 type HandlerDependencies struct {
-	UserEndpoint     handler.UserHandlerImpl
-	MerchantEndpoint handler.MerchantHandlerImpl
-	DriverEndpoint   handler.DriverHandlerImpl
-	Middleware       middleware.JWTServiceImpl
+	UserEndpoint          handler.UserHandlerImpl
+	MerchantEndpoint      handler.MerchantHandlerImpl
+	DriverEndpoint        handler.DriverHandlerImpl
+	MenuEndpoint          handler.MenuHandlerImpl
+	OrderEndpoint         handler.OrderHandlerImpl
+	MerchantOrderEndpoint handler.MerchantOrderHandlerImpl
+	TwoFactorEndpoint     handler.TwoFactorHandlerImpl
+	AdminEndpoint         handler.AdminHandlerImpl
+	Middleware            middleware.JWTServiceImpl
+	Health                *health.Checker
+	RateLimits            RateLimits
+	// Idempotency makes a route safe to retry with an Idempotency-Key
+	// header. Nil leaves the header ignored.
+	Idempotency mux.MiddlewareFunc
+}
+
+// RateLimits throttles each class of route. A nil entry leaves that class
+// unthrottled.
+type RateLimits struct {
+	Auth          mux.MiddlewareFunc
+	Authenticated mux.MiddlewareFunc
+	Public        mux.MiddlewareFunc
 }
 type Router struct {
 	deps HandlerDependencies
@@ -22,20 +46,103 @@ func NewRouter(deps HandlerDependencies) *Router {
 
 func (ar *Router) Route() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(tracing.Middleware, middleware.Metrics)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.ErrorResponse(w, r, fmt.Errorf("route %s: %w", r.URL.Path, utils.ErrNotFound))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.ErrorResponse(w, r, utils.ErrMethodNotAllowed)
+	})
 
-	r.HandleFunc("/api/v1/register", ar.deps.UserEndpoint.RegisterHandler).Methods("POST")
-	r.HandleFunc("/api/v1/login", ar.deps.UserEndpoint.LoginHandler).Methods("POST")
-	r.HandleFunc("/api/v1/u/{username}", ar.deps.UserEndpoint.GetUserHandler).Methods("GET")
+	r.HandleFunc("/healthz", ar.deps.Health.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", ar.deps.Health.ReadinessHandler).Methods("GET")
 
-	r.HandleFunc("/api/v1/m/{username}", ar.deps.MerchantEndpoint.GetMerchantHandler).Methods("GET")
-	r.HandleFunc("/api/v1/d/{username}", ar.deps.DriverEndpoint.GetDriverHandler).Methods("GET")
+	auth := r.PathPrefix("/api/v1").Subrouter()
+	use(auth, ar.deps.RateLimits.Auth)
+	auth.HandleFunc("/register", ar.deps.UserEndpoint.RegisterHandler).Methods("POST")
+	auth.HandleFunc("/login", ar.deps.UserEndpoint.LoginHandler).Methods("POST")
+	auth.HandleFunc("/login/2fa", ar.deps.TwoFactorEndpoint.VerifyLoginHandler).Methods("POST")
+
+	public := r.PathPrefix("/api/v1").Subrouter()
+	public.Use(ar.deps.Middleware.OptionalContext)
+	use(public, ar.deps.RateLimits.Public)
+	public.HandleFunc("/u/{username}", ar.deps.UserEndpoint.GetUserHandler).Methods("GET")
+	public.HandleFunc("/m/{username}", ar.deps.MerchantEndpoint.GetMerchantHandler).Methods("GET")
+	public.HandleFunc("/m/{username}/schedule", ar.deps.MerchantEndpoint.GetScheduleHandler).Methods("GET")
+	public.HandleFunc("/d/{username}", ar.deps.DriverEndpoint.GetDriverHandler).Methods("GET")
+	public.HandleFunc("/menus/{menu_id}", ar.deps.MenuEndpoint.GetMenuHandler).Methods("GET")
+	public.HandleFunc("/openapi.json", SpecHandler).Methods("GET")
 
 	protected := r.PathPrefix("/api/v1").Subrouter()
 	protected.Use(ar.deps.Middleware.ValidateContext)
+	use(protected, ar.deps.RateLimits.Authenticated)
+	protected.HandleFunc("/2fa/setup", ar.deps.TwoFactorEndpoint.SetupTOTPHandler).Methods("POST")
+	protected.HandleFunc("/2fa/enable", ar.deps.TwoFactorEndpoint.EnableTOTPHandler).Methods("POST")
+	protected.HandleFunc("/2fa/disable", ar.deps.TwoFactorEndpoint.DisableTOTPHandler).Methods("POST")
+
+	protected.HandleFunc("/u/{username}", ar.deps.UserEndpoint.UpdateUserHandler).Methods("PATCH")
+	protected.HandleFunc("/u/{username}", ar.deps.UserEndpoint.DeleteUserHandler).Methods("DELETE")
+	protected.HandleFunc("/u/{username}/export", ar.deps.UserEndpoint.ExportUserHandler).Methods("GET")
+
 	protected.HandleFunc("/m/{username}", ar.deps.MerchantEndpoint.CreateMerchantHandler).Methods("POST")
 	protected.HandleFunc("/m/{username}", ar.deps.MerchantEndpoint.UpdateMerchantHandler).Methods("PATCH")
+	protected.HandleFunc("/m/{username}/application", ar.deps.MerchantEndpoint.GetMerchantApplicationHandler).Methods("GET")
+	protected.HandleFunc("/m/{username}/schedule", ar.deps.MerchantEndpoint.SetScheduleHandler).Methods("PUT")
+	protected.HandleFunc("/m/{username}/orders", ar.deps.MerchantOrderEndpoint.ListOrdersHandler).Methods("GET")
+	protected.HandleFunc("/m/{username}/orders/{order_id}/accept", ar.deps.MerchantOrderEndpoint.AcceptOrderHandler).Methods("POST")
+	protected.HandleFunc("/m/{username}/orders/{order_id}/reject", ar.deps.MerchantOrderEndpoint.RejectOrderHandler).Methods("POST")
+	protected.HandleFunc("/m/{username}/orders/{order_id}/ready", ar.deps.MerchantOrderEndpoint.MarkOrderReadyHandler).Methods("POST")
+	protected.HandleFunc("/m/{username}/orders/{order_id}/items/{order_item_id}/unavailable", ar.deps.MerchantOrderEndpoint.MarkItemUnavailableHandler).Methods("POST")
+	protected.Handle("/m/{username}/orders/{order_id}/cancel", ar.idempotent(ar.deps.MerchantOrderEndpoint.CancelOrderHandler)).Methods("POST")
+	protected.HandleFunc("/m/{username}/menus", ar.deps.MenuEndpoint.CreateMenuHandler).Methods("POST")
+	protected.HandleFunc("/m/{username}/menus/{menu_id}", ar.deps.MenuEndpoint.UpdateMenuHandler).Methods("PATCH")
+	protected.HandleFunc("/m/{username}/menus/{menu_id}", ar.deps.MenuEndpoint.DeleteMenuHandler).Methods("DELETE")
 
 	protected.HandleFunc("/d/{username}", ar.deps.DriverEndpoint.CreateDriverHandler).Methods("POST")
 	protected.HandleFunc("/d/{username}", ar.deps.DriverEndpoint.UpdateDriverHandler).Methods("PATCH")
+	protected.HandleFunc("/d/{username}/application", ar.deps.DriverEndpoint.GetDriverApplicationHandler).Methods("GET")
+	protected.HandleFunc("/d/{username}/documents", ar.deps.DriverEndpoint.UploadDriverDocumentHandler).Methods("POST")
+	protected.HandleFunc("/d/{username}/documents", ar.deps.DriverEndpoint.ListDriverDocumentsHandler).Methods("GET")
+	protected.HandleFunc("/d/{username}/documents/{document_id}", ar.deps.DriverEndpoint.DownloadDriverDocumentHandler).Methods("GET")
+	protected.Handle("/d/{username}/orders/{order_id}/cancel", ar.idempotent(ar.deps.OrderEndpoint.DriverCancelOrderHandler)).Methods("POST")
+
+	protected.Handle("/orders", ar.idempotent(ar.deps.OrderEndpoint.CreateOrderHandler)).Methods("POST")
+	protected.HandleFunc("/orders/{order_id}", ar.deps.OrderEndpoint.GetOrderHandler).Methods("GET")
+	protected.HandleFunc("/orders/{order_id}/items/{order_item_id}/substitution", ar.deps.OrderEndpoint.RespondSubstitutionHandler).Methods("POST")
+	protected.Handle("/orders/{order_id}/cancel", ar.idempotent(ar.deps.OrderEndpoint.CancelOrderHandler)).Methods("POST")
+	protected.Handle("/orders/{order_id}/disputes", ar.idempotent(ar.deps.OrderEndpoint.OpenDisputeHandler)).Methods("POST")
+
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireRole("admin"))
+	admin.HandleFunc("/users", ar.deps.AdminEndpoint.ListUsersHandler).Methods("GET")
+	admin.HandleFunc("/users/{username}", ar.deps.AdminEndpoint.GetUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{username}/suspend", ar.deps.AdminEndpoint.SuspendUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{username}/unsuspend", ar.deps.AdminEndpoint.UnsuspendUserHandler).Methods("POST")
+	admin.Handle("/users/{username}/wallet", ar.idempotent(ar.deps.AdminEndpoint.AdjustBalanceHandler)).Methods("POST")
+	admin.HandleFunc("/merchants", ar.deps.AdminEndpoint.ListMerchantsHandler).Methods("GET")
+	admin.HandleFunc("/merchants/{username}", ar.deps.AdminEndpoint.GetMerchantHandler).Methods("GET")
+	admin.HandleFunc("/merchants/{username}/approve", ar.deps.AdminEndpoint.ApproveMerchantHandler).Methods("POST")
+	admin.HandleFunc("/merchants/{username}/reject", ar.deps.AdminEndpoint.RejectMerchantHandler).Methods("POST")
+	admin.HandleFunc("/drivers", ar.deps.AdminEndpoint.ListDriversHandler).Methods("GET")
+	admin.HandleFunc("/drivers/{username}", ar.deps.AdminEndpoint.GetDriverHandler).Methods("GET")
+	admin.HandleFunc("/drivers/{username}/approve", ar.deps.AdminEndpoint.ApproveDriverHandler).Methods("POST")
+	admin.HandleFunc("/drivers/{username}/reject", ar.deps.AdminEndpoint.RejectDriverHandler).Methods("POST")
+	admin.HandleFunc("/disputes", ar.deps.AdminEndpoint.ListDisputesHandler).Methods("GET")
+	admin.Handle("/disputes/{dispute_id}/resolve", ar.idempotent(ar.deps.AdminEndpoint.ResolveDisputeHandler)).Methods("POST")
 	return r
 }
+
+// idempotent wraps a handler that moves money with the Idempotency-Key
+// middleware.
+func (ar *Router) idempotent(h http.HandlerFunc) http.Handler {
+	if ar.deps.Idempotency == nil {
+		return h
+	}
+	return ar.deps.Idempotency(h)
+}
+
+func use(r *mux.Router, mw mux.MiddlewareFunc) {
+	if mw != nil {
+		r.Use(mw)
+	}
+}
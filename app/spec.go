@@ -0,0 +1,336 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bagasadiii/gofood-clone/health"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/openapi"
+	"github.com/bagasadiii/gofood-clone/utils"
+)
+
+const (
+	SpecPath   = "/api/v1/openapi.json"
+	bearerAuth = "bearerAuth"
+)
+
+// access is the middleware class a route sits behind in Route.
+type access int
+
+const (
+	accessNone access = iota
+	accessAuth
+	accessPublic
+	accessProtected
+	accessAdmin
+)
+
+// endpoint describes one route for the OpenAPI document. Every route
+// registered in Route needs an entry; TestSpecCoversRoutes enforces it.
+type endpoint struct {
+	method  string
+	path    string
+	id      string
+	tag     string
+	summary string
+	access  access
+	query   []openapi.Parameter
+	body    any
+	status  int
+	res     any
+	// idempotent routes accept an Idempotency-Key header.
+	idempotent bool
+	// content overrides the JSON envelope for bodies that are not JSON.
+	reqContent map[string]openapi.MediaType
+	resContent map[string]openapi.MediaType
+}
+
+var maxKeyLength = middleware.MaxIdempotencyKeyLength
+
+var listQuery = []openapi.Parameter{
+	{Name: "q", In: "query", Description: "Case-insensitive search on names and usernames.", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "status", In: "query", Description: "Only return records with this status.", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "limit", In: "query", Description: "Page size, 20 by default and at most 100.", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "offset", In: "query", Description: "Number of records to skip.", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+func endpoints() []endpoint {
+	userListQuery := append([]openapi.Parameter{
+		{Name: "role", In: "query", Description: "Only return users with this role.", Schema: &openapi.Schema{Type: "string"}},
+	}, listQuery...)
+	orderListQuery := append([]openapi.Parameter{
+		{Name: "status", In: "query", Description: "Only return orders in these comma-separated statuses: " + strings.Join(model.OrderStatuses, ", ") + ".", Schema: &openapi.Schema{Type: "string"}},
+	}, listQuery[2:]...)
+	disputeListQuery := listQuery[1:]
+
+	return []endpoint{
+		{method: "GET", path: "/healthz", id: "liveness", tag: "health", summary: "Liveness probe",
+			status: http.StatusOK, res: health.Report{}},
+		{method: "GET", path: "/readyz", id: "readiness", tag: "health", summary: "Readiness probe; 503 while a dependency is down or the server is draining",
+			status: http.StatusOK, res: health.Report{}},
+		{method: "GET", path: SpecPath, id: "getOpenAPI", tag: "meta", summary: "This document", access: accessPublic,
+			status: http.StatusOK, resContent: openapi.JSONContent(&openapi.Schema{Type: "object"})},
+
+		{method: "POST", path: "/api/v1/register", id: "register", tag: "auth", summary: "Create an account", access: accessAuth,
+			body: model.RegisterReq{}, status: http.StatusCreated, res: model.UserResp{}},
+		{method: "POST", path: "/api/v1/login", id: "login", tag: "auth", summary: "Log in; returns a token or a two-factor challenge", access: accessAuth,
+			body: model.LoginReq{}, status: http.StatusOK, res: model.LoginRes{}},
+		{method: "POST", path: "/api/v1/login/2fa", id: "verifyLogin", tag: "auth", summary: "Complete a two-factor login", access: accessAuth,
+			body: model.TOTPLoginReq{}, status: http.StatusOK, res: model.LoginRes{}},
+
+		{method: "POST", path: "/api/v1/2fa/setup", id: "setupTOTP", tag: "two-factor", summary: "Generate a TOTP secret", access: accessProtected,
+			status: http.StatusOK, res: model.TOTPSetupRes{}},
+		{method: "POST", path: "/api/v1/2fa/enable", id: "enableTOTP", tag: "two-factor", summary: "Confirm the TOTP secret and enable two-factor login", access: accessProtected,
+			body: model.TOTPCodeReq{}, status: http.StatusOK, res: model.RecoveryCodesRes{}},
+		{method: "POST", path: "/api/v1/2fa/disable", id: "disableTOTP", tag: "two-factor", summary: "Disable two-factor login", access: accessProtected,
+			body: model.TOTPCodeReq{}, status: http.StatusOK, res: model.TOTPStatusRes{}},
+
+		{method: "GET", path: "/api/v1/u/{username}", id: "getUser", tag: "users", summary: "User profile; email and phone are shown to the owner and admins only", access: accessPublic,
+			status: http.StatusOK, res: model.UserResp{}},
+		{method: "PATCH", path: "/api/v1/u/{username}", id: "updateUser", tag: "users", summary: "Update your profile (JSON merge patch)", access: accessProtected,
+			body: model.UpdateUserReq{}, status: http.StatusOK, res: model.UserResp{}},
+		{method: "DELETE", path: "/api/v1/u/{username}", id: "deleteUser", tag: "users", summary: "Delete your account", access: accessProtected,
+			status: http.StatusOK, res: model.UserDeletedRes{}},
+		{method: "GET", path: "/api/v1/u/{username}/export", id: "exportUser", tag: "users", summary: "Download everything stored about your account: profile, merchant and driver profiles, documents, orders and wallet history. Addresses are not stored yet", access: accessProtected,
+			status: http.StatusOK, res: model.UserExport{}},
+
+		{method: "GET", path: "/api/v1/m/{username}", id: "getMerchant", tag: "merchants", summary: "Approved merchant profile", access: accessPublic,
+			status: http.StatusOK, res: model.MerchantRes{}},
+		{method: "POST", path: "/api/v1/m/{username}", id: "createMerchant", tag: "merchants", summary: "Apply as a merchant; the profile stays pending until reviewed", access: accessProtected,
+			body: model.Merchant{}, status: http.StatusOK, res: model.MerchantRes{}},
+		{method: "PATCH", path: "/api/v1/m/{username}", id: "updateMerchant", tag: "merchants", summary: "Update your merchant profile (JSON merge patch)", access: accessProtected,
+			body: model.UpdateMerchantReq{}, status: http.StatusOK, res: model.MerchantRes{}},
+		{method: "GET", path: "/api/v1/m/{username}/application", id: "getMerchantApplication", tag: "merchants", summary: "Review status of your merchant application", access: accessProtected,
+			status: http.StatusOK, res: model.Application{}},
+		{method: "GET", path: "/api/v1/m/{username}/schedule", id: "getMerchantSchedule", tag: "merchants", summary: "Opening hours, time zone and delivery slot capacity of an approved merchant", access: accessPublic,
+			status: http.StatusOK, res: model.MerchantSchedule{}},
+		{method: "PUT", path: "/api/v1/m/{username}/schedule", id: "setMerchantSchedule", tag: "merchants", summary: "Replace your opening hours, time zone and slot capacity; no hours means always open", access: accessProtected,
+			body: model.MerchantSchedule{}, status: http.StatusOK, res: model.MerchantSchedule{}},
+		{method: "GET", path: "/api/v1/m/{username}/orders", id: "listMerchantOrders", tag: "orders", summary: "Your orders, newest first", access: accessProtected,
+			query: orderListQuery, status: http.StatusOK, res: []model.Order{}},
+		{method: "POST", path: "/api/v1/m/{username}/orders/{order_id}/accept", id: "acceptOrder", tag: "orders", summary: "Accept a placed order with an estimated preparation time", access: accessProtected,
+			body: model.AcceptOrderReq{}, status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/m/{username}/orders/{order_id}/reject", id: "rejectOrder", tag: "orders", summary: "Reject a placed order and refund the customer", access: accessProtected,
+			body: model.RejectOrderReq{}, status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/m/{username}/orders/{order_id}/ready", id: "markOrderReady", tag: "orders", summary: "Mark an accepted order ready for pickup", access: accessProtected,
+			status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/m/{username}/orders/{order_id}/items/{order_item_id}/unavailable", id: "markItemUnavailable", tag: "orders", summary: "Offer a substitute for an item you cannot make, or refund it; an order left empty is rejected", access: accessProtected,
+			body: model.ItemUnavailableReq{}, status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/m/{username}/orders/{order_id}/cancel", id: "merchantCancelOrder", tag: "orders", summary: "Cancel an accepted order with a reason code and refund the customer in full; reject placed orders instead", access: accessProtected,
+			body: model.MerchantCancelReq{}, status: http.StatusOK, res: model.Order{}, idempotent: true},
+		{method: "POST", path: "/api/v1/m/{username}/menus", id: "createMenu", tag: "menus", summary: "Add a menu item", access: accessProtected,
+			body: model.Menu{}, status: http.StatusCreated, res: model.MenuRes{}},
+		{method: "PATCH", path: "/api/v1/m/{username}/menus/{menu_id}", id: "updateMenu", tag: "menus", summary: "Update a menu item (JSON merge patch)", access: accessProtected,
+			body: model.UpdateMenuReq{}, status: http.StatusOK, res: model.MenuRes{}},
+		{method: "DELETE", path: "/api/v1/m/{username}/menus/{menu_id}", id: "deleteMenu", tag: "menus", summary: "Remove a menu item", access: accessProtected,
+			status: http.StatusOK, res: model.MenuDeletedRes{}},
+		{method: "GET", path: "/api/v1/menus/{menu_id}", id: "getMenu", tag: "menus", summary: "Menu item", access: accessPublic,
+			status: http.StatusOK, res: model.MenuRes{}},
+
+		{method: "POST", path: "/api/v1/orders", id: "createOrder", tag: "orders", summary: "Order from an open merchant, or pre-order for a 15-minute delivery slot; paid from your wallet", access: accessProtected,
+			body: model.CreateOrderReq{}, status: http.StatusCreated, res: model.Order{}, idempotent: true},
+		{method: "GET", path: "/api/v1/orders/{order_id}", id: "getOrder", tag: "orders", summary: "Order details, for its customer, its merchant and admins", access: accessProtected,
+			status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/orders/{order_id}/items/{order_item_id}/substitution", id: "respondSubstitution", tag: "orders", summary: "Accept or decline a substitute; declining refunds the item, a cheaper substitute refunds the difference", access: accessProtected,
+			body: model.SubstitutionReply{}, status: http.StatusOK, res: model.Order{}},
+		{method: "POST", path: "/api/v1/orders/{order_id}/cancel", id: "cancelOrder", tag: "orders", summary: "Cancel your order; free until the merchant accepts it, after that the cancellation fee is kept from the refund", access: accessProtected,
+			status: http.StatusOK, res: model.Order{}, idempotent: true},
+		{method: "POST", path: "/api/v1/orders/{order_id}/disputes", id: "openDispute", tag: "orders", summary: "Report missing or wrong items of a ready order for an admin to review", access: accessProtected,
+			body: model.OpenDisputeReq{}, status: http.StatusCreated, res: model.Dispute{}, idempotent: true},
+
+		{method: "GET", path: "/api/v1/d/{username}", id: "getDriver", tag: "drivers", summary: "Approved driver profile; license, income and status are shown to the owner and admins only", access: accessPublic,
+			status: http.StatusOK, res: model.DriverRes{}},
+		{method: "POST", path: "/api/v1/d/{username}", id: "createDriver", tag: "drivers", summary: "Apply as a driver; the profile stays pending until reviewed", access: accessProtected,
+			body: model.Driver{}, status: http.StatusCreated, res: model.DriverRes{}},
+		{method: "PATCH", path: "/api/v1/d/{username}", id: "updateDriver", tag: "drivers", summary: "Update your driver profile (JSON merge patch)", access: accessProtected,
+			body: model.UpdateDriverReq{}, status: http.StatusOK, res: model.DriverRes{}},
+		{method: "GET", path: "/api/v1/d/{username}/application", id: "getDriverApplication", tag: "drivers", summary: "Review status of your driver application", access: accessProtected,
+			status: http.StatusOK, res: model.Application{}},
+		{method: "POST", path: "/api/v1/d/{username}/documents", id: "uploadDriverDocument", tag: "drivers", summary: "Upload a license or vehicle registration (at most 10 MiB)", access: accessProtected,
+			reqContent: map[string]openapi.MediaType{"multipart/form-data": {Schema: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"file": {Type: "string", Format: "binary"},
+					"kind": {Type: "string", Enum: []string{model.DocumentKindLicense, model.DocumentKindVehicleRegistration}},
+				},
+				Required: []string{"file", "kind"},
+			}}},
+			status: http.StatusCreated, res: model.DriverDocument{}},
+		{method: "GET", path: "/api/v1/d/{username}/documents", id: "listDriverDocuments", tag: "drivers", summary: "List your uploaded documents", access: accessProtected,
+			status: http.StatusOK, res: []model.DriverDocument{}},
+		{method: "GET", path: "/api/v1/d/{username}/documents/{document_id}", id: "downloadDriverDocument", tag: "drivers", summary: "Download a document", access: accessProtected,
+			status: http.StatusOK, resContent: map[string]openapi.MediaType{"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}},
+		{method: "POST", path: "/api/v1/d/{username}/orders/{order_id}/cancel", id: "driverCancelOrder", tag: "orders", summary: "Give up the delivery of an order assigned to you with a reason code; the customer is refunded in full", access: accessProtected,
+			body: model.DriverCancelReq{}, status: http.StatusOK, res: model.Order{}, idempotent: true},
+
+		{method: "GET", path: "/api/v1/admin/users", id: "adminListUsers", tag: "admin", summary: "List users", access: accessAdmin,
+			query: userListQuery, status: http.StatusOK, res: []model.AdminUserRes{}},
+		{method: "GET", path: "/api/v1/admin/users/{username}", id: "adminGetUser", tag: "admin", summary: "User details", access: accessAdmin,
+			status: http.StatusOK, res: model.AdminUserRes{}},
+		{method: "POST", path: "/api/v1/admin/users/{username}/suspend", id: "adminSuspendUser", tag: "admin", summary: "Suspend a user", access: accessAdmin,
+			body: model.ModerationReq{}, status: http.StatusOK, res: model.AdminUserRes{}},
+		{method: "POST", path: "/api/v1/admin/users/{username}/unsuspend", id: "adminUnsuspendUser", tag: "admin", summary: "Lift a suspension", access: accessAdmin,
+			body: model.ModerationReq{}, status: http.StatusOK, res: model.AdminUserRes{}},
+		{method: "POST", path: "/api/v1/admin/users/{username}/wallet", id: "adminAdjustBalance", tag: "admin", summary: "Credit or debit a wallet", access: accessAdmin,
+			body: model.WalletAdjustReq{}, status: http.StatusOK, res: model.WalletTransaction{}, idempotent: true},
+		{method: "GET", path: "/api/v1/admin/merchants", id: "adminListMerchants", tag: "admin", summary: "List merchants", access: accessAdmin,
+			query: listQuery, status: http.StatusOK, res: []model.AdminMerchantRes{}},
+		{method: "GET", path: "/api/v1/admin/merchants/{username}", id: "adminGetMerchant", tag: "admin", summary: "Merchant details", access: accessAdmin,
+			status: http.StatusOK, res: model.AdminMerchantRes{}},
+		{method: "POST", path: "/api/v1/admin/merchants/{username}/approve", id: "adminApproveMerchant", tag: "admin", summary: "Approve a merchant application", access: accessAdmin,
+			body: model.ReviewReq{}, status: http.StatusOK, res: model.AdminMerchantRes{}},
+		{method: "POST", path: "/api/v1/admin/merchants/{username}/reject", id: "adminRejectMerchant", tag: "admin", summary: "Reject a merchant application", access: accessAdmin,
+			body: model.ReviewReq{}, status: http.StatusOK, res: model.AdminMerchantRes{}},
+		{method: "GET", path: "/api/v1/admin/drivers", id: "adminListDrivers", tag: "admin", summary: "List drivers", access: accessAdmin,
+			query: listQuery, status: http.StatusOK, res: []model.AdminDriverRes{}},
+		{method: "GET", path: "/api/v1/admin/drivers/{username}", id: "adminGetDriver", tag: "admin", summary: "Driver details", access: accessAdmin,
+			status: http.StatusOK, res: model.AdminDriverRes{}},
+		{method: "POST", path: "/api/v1/admin/drivers/{username}/approve", id: "adminApproveDriver", tag: "admin", summary: "Approve a driver application", access: accessAdmin,
+			body: model.ReviewReq{}, status: http.StatusOK, res: model.AdminDriverRes{}},
+		{method: "POST", path: "/api/v1/admin/drivers/{username}/reject", id: "adminRejectDriver", tag: "admin", summary: "Reject a driver application", access: accessAdmin,
+			body: model.ReviewReq{}, status: http.StatusOK, res: model.AdminDriverRes{}},
+		{method: "GET", path: "/api/v1/admin/disputes", id: "adminListDisputes", tag: "admin", summary: "List order disputes, oldest first", access: accessAdmin,
+			query: disputeListQuery, status: http.StatusOK, res: []model.Dispute{}},
+		{method: "POST", path: "/api/v1/admin/disputes/{dispute_id}/resolve", id: "adminResolveDispute", tag: "admin", summary: "Resolve a dispute, refunding at most what the order has left to refund to the customer's wallet", access: accessAdmin,
+			body: model.ResolveDisputeReq{}, status: http.StatusOK, res: model.Dispute{}, idempotent: true},
+	}
+}
+
+// Spec builds the OpenAPI document for the routes registered by Route.
+func Spec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "gofood-clone API",
+		Version: "v1",
+		Description: "Apart from this document and file downloads, every response is wrapped in an envelope with the HTTP status in code. " +
+			"Successful responses carry the payload in data; failures carry an error object " +
+			"with a stable code, a message, per-field details for validation failures and the request ID.",
+	})
+	doc.Components.SecuritySchemes[bearerAuth] = openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Token returned by /api/v1/login or /api/v1/login/2fa.",
+	}
+	doc.Components.Schemas["ErrorResponse"] = envelope("error", doc.SchemaOf(utils.APIError{}))
+
+	tags := map[string]bool{}
+	for _, e := range endpoints() {
+		if !tags[e.tag] {
+			tags[e.tag] = true
+			doc.Tags = append(doc.Tags, openapi.Tag{Name: e.tag})
+		}
+		doc.Add(e.method, e.path, operation(doc, e))
+	}
+	return doc
+}
+
+func operation(doc *openapi.Document, e endpoint) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: e.id,
+		Summary:     e.summary,
+		Tags:        []string{e.tag},
+		Parameters:  e.query,
+		Responses:   map[string]*openapi.Response{},
+	}
+	switch {
+	case e.reqContent != nil:
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: e.reqContent}
+	case e.body != nil:
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSONContent(doc.SchemaOf(e.body))}
+	}
+
+	success := &openapi.Response{Description: openapi.StatusText(e.status)}
+	switch {
+	case e.resContent != nil:
+		success.Content = e.resContent
+	case e.res != nil:
+		success.Content = openapi.JSONContent(envelope("data", doc.SchemaOf(e.res)))
+	}
+	op.Responses[strconv.Itoa(e.status)] = success
+
+	errs := []int{}
+	if op.RequestBody != nil || len(e.query) > 0 {
+		errs = append(errs, http.StatusBadRequest)
+	}
+	if e.idempotent {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: middleware.IdempotencyKeyHeader,
+			In:   "header",
+			Description: "Client-chosen key, at most 255 characters, that makes the request safe to retry. " +
+				"A retry with the same key and body gets the original response with Idempotent-Replayed: true, " +
+				"even if that was a server error; check the outcome before retrying with a new key.",
+			Schema: &openapi.Schema{Type: "string", MaxLength: &maxKeyLength},
+		})
+		success.Headers = map[string]openapi.Header{
+			middleware.IdempotentReplayHeader: {Description: "Set when the response is a replay.", Schema: &openapi.Schema{Type: "string", Enum: []string{"true"}}},
+		}
+		errs = append(errs, http.StatusConflict, http.StatusUnprocessableEntity)
+	}
+	switch {
+	case e.access >= accessProtected:
+		op.Security = []openapi.SecurityRequirement{{bearerAuth: {}}}
+		errs = append(errs, http.StatusUnauthorized, http.StatusForbidden)
+	case e.access == accessPublic:
+		// A token is optional; when one is sent it must be valid.
+		op.Security = []openapi.SecurityRequirement{{}, {bearerAuth: {}}}
+		errs = append(errs, http.StatusUnauthorized)
+	}
+	if strings.Contains(e.path, "{") {
+		errs = append(errs, http.StatusNotFound)
+	}
+	if e.access != accessNone {
+		errs = append(errs, http.StatusTooManyRequests)
+	}
+	if e.path == "/readyz" {
+		errs = append(errs, http.StatusServiceUnavailable)
+	}
+	for _, status := range errs {
+		op.Responses[strconv.Itoa(status)] = errorResponse(status)
+	}
+	op.Responses["default"] = errorResponse(0)
+	return op
+}
+
+// envelope wraps payload in the utils.APIResp shape under key.
+func envelope(key string, payload *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"code":    {Type: "integer", Description: "HTTP status code."},
+			"message": {Type: "string", Description: "HTTP status text."},
+			key:       payload,
+		},
+		Required: []string{"code", "message", key},
+	}
+}
+
+func errorResponse(status int) *openapi.Response {
+	desc := "Unexpected error"
+	if status != 0 {
+		desc = openapi.StatusText(status)
+	}
+	return &openapi.Response{Description: desc, Content: openapi.JSONContent(openapi.Ref("ErrorResponse"))}
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// SpecHandler serves the OpenAPI document, built once on first request.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	specOnce.Do(func() {
+		var err error
+		specJSON, err = json.Marshal(Spec())
+		if err != nil {
+			panic(err)
+		}
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/handler"
+	"github.com/bagasadiii/gofood-clone/health"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// newTestRouter builds the real route table. The handlers have no services
+// behind them, which is fine as long as no request reaches them.
+func newTestRouter() *mux.Router {
+	logger := zap.NewNop()
+	return NewRouter(HandlerDependencies{
+		UserEndpoint:          handler.NewUserHandler(nil, logger),
+		MerchantEndpoint:      handler.NewMerchantHandler(nil, logger),
+		DriverEndpoint:        handler.NewDriverHandler(nil, logger),
+		MenuEndpoint:          handler.NewMenuHandler(nil, logger),
+		OrderEndpoint:         handler.NewOrderHandler(nil, logger),
+		MerchantOrderEndpoint: handler.NewMerchantOrderHandler(nil, logger),
+		TwoFactorEndpoint:     handler.NewTwoFactorHandler(nil, logger),
+		AdminEndpoint:         handler.NewAdminHandler(nil, logger),
+		Middleware:            middleware.NewJWTService([]byte("spec-test-secret-key-of-32-bytes!"), time.Hour, nil, logger),
+		Health:                health.NewChecker(logger),
+	}).Route()
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	spec := Spec()
+	routes := 0
+	err := newTestRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Subrouter prefixes carry no methods.
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes++
+			if !spec.Has(method, path) {
+				t.Errorf("%s %s is routed but missing from the OpenAPI spec", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operations := 0
+	for _, item := range spec.Paths {
+		operations += len(item)
+	}
+	if operations != routes {
+		t.Errorf("spec has %d operations for %d routes; remove operations that are no longer routed", operations, routes)
+	}
+}
+
+func TestSpecIsServed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas         map[string]json.RawMessage `json:"schemas"`
+			SecuritySchemes map[string]json.RawMessage `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI == "" || len(doc.Paths) == 0 {
+		t.Fatalf("empty document: %s", rec.Body.String()[:min(200, rec.Body.Len())])
+	}
+	if _, ok := doc.Components.SecuritySchemes[bearerAuth]; !ok {
+		t.Error("bearer security scheme missing")
+	}
+	for _, name := range []string{"RegisterReq", "MerchantRes", "MenuRes", "ErrorResponse"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+}
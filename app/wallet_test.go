@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/handler"
+	"github.com/bagasadiii/gofood-clone/health"
+	"github.com/bagasadiii/gofood-clone/idempotency"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// failingAuditRepo applies every wallet adjustment and then fails to write
+// the audit row, the way a commit that fails after the balance update would
+// look to the caller: money moved, yet the request gets a 500.
+type failingAuditRepo struct {
+	repository.AdminRepoImpl
+	user    model.AdminUserRes
+	balance int64
+	calls   int
+}
+
+func (fr *failingAuditRepo) GetAdminUserRepo(_ context.Context, username string) (*model.AdminUserRes, error) {
+	if username != fr.user.Username {
+		return nil, utils.ErrNotFound
+	}
+	user := fr.user
+	return &user, nil
+}
+
+func (fr *failingAuditRepo) AdjustBalanceRepo(_ context.Context, new *model.WalletTransaction, _ *model.AuditLog) error {
+	fr.calls++
+	fr.balance += new.Amount
+	return fmt.Errorf("failed to insert audit log: %w", utils.ErrDatabase)
+}
+
+type activeAccounts struct{}
+
+func (activeAccounts) GetAccountStatusRepo(context.Context, uuid.UUID) (string, error) {
+	return model.UserStatusActive, nil
+}
+
+func TestWalletRetryAfterServerError(t *testing.T) {
+	logger := zap.NewNop()
+	repo := &failingAuditRepo{user: model.AdminUserRes{UserID: uuid.New(), Username: "bob"}}
+	jwt := middleware.NewJWTService([]byte("wallet-test-secret-key-of-32byte"), time.Hour, activeAccounts{}, logger)
+	router := NewRouter(HandlerDependencies{
+		UserEndpoint:          handler.NewUserHandler(nil, logger),
+		MerchantEndpoint:      handler.NewMerchantHandler(nil, logger),
+		DriverEndpoint:        handler.NewDriverHandler(nil, logger),
+		MenuEndpoint:          handler.NewMenuHandler(nil, logger),
+		OrderEndpoint:         handler.NewOrderHandler(nil, logger),
+		MerchantOrderEndpoint: handler.NewMerchantOrderHandler(nil, logger),
+		TwoFactorEndpoint:     handler.NewTwoFactorHandler(nil, logger),
+		AdminEndpoint:         handler.NewAdminHandler(service.NewAdminService(repo, nil, logger), logger),
+		Middleware:            jwt,
+		Health:                health.NewChecker(logger),
+		Idempotency:           middleware.NewIdempotency(idempotency.NewMemoryStore(), time.Hour, logger).HandleOnce,
+	}).Route()
+	token, err := jwt.CreateToken(&middleware.TokenClaims{UserID: uuid.New(), Role: "admin", Username: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		req := httptest.NewRequest("POST", "/api/v1/admin/users/bob/wallet", strings.NewReader(`{"amount":5000,"reason":"refund for order 42"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(middleware.IdempotencyKeyHeader, "refund-42")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: status = %d, want 500", attempt, rec.Code)
+		}
+		if replayed := rec.Header().Get(middleware.IdempotentReplayHeader) == "true"; replayed != (attempt > 1) {
+			t.Errorf("attempt %d: replayed = %v", attempt, replayed)
+		}
+	}
+	if repo.calls != 1 || repo.balance != 5000 {
+		t.Errorf("adjustment ran %d times for a balance of %d, want once", repo.calls, repo.balance)
+	}
+}
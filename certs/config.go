@@ -0,0 +1,71 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ParseMinVersion maps "1.2" or "1.3" to its tls constant.
+func ParseMinVersion(v string) (uint16, error) {
+	switch v {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, want 1.2 or 1.3", v)
+	}
+}
+
+// ParseCipherSuites maps IANA suite names such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 to their IDs. Only suites Go
+// considers secure are accepted. An empty list keeps Go's defaults.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ServerConfig returns a tls.Config that serves certificates from r.
+// cipherSuites only applies to TLS 1.2; TLS 1.3 suites are not configurable.
+func ServerConfig(r *Reloader, minVersion uint16, cipherSuites []uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// RedirectHandler sends every request to the same host and path over HTTPS.
+// httpsPort is omitted from the target when it is 443 or empty.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,34 @@
+package certs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		port         string
+		method       string
+		target       string
+		wantCode     int
+		wantLocation string
+	}{
+		{"default port", "443", http.MethodGet, "http://example.com:8080/api/v1/menus?page=2", http.StatusMovedPermanently, "https://example.com/api/v1/menus?page=2"},
+		{"custom port", "8443", http.MethodGet, "http://example.com/healthz", http.StatusMovedPermanently, "https://example.com:8443/healthz"},
+		{"post keeps method", "", http.MethodPost, "http://example.com/api/v1/login", http.StatusPermanentRedirect, "https://example.com/api/v1/login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RedirectHandler(tt.port).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
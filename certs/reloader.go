@@ -0,0 +1,121 @@
+// Package certs serves TLS certificates from disk and picks up rotated files
+// without a restart.
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reloader hands out the certificate in certFile/keyFile and reloads the pair
+// when either file changes. Changes are noticed on the first handshake after
+// interval has passed since the last check, or immediately via Reload.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	zap      *zap.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version fileVersion
+	checked time.Time
+}
+
+// fileVersion identifies the on-disk state of the pair.
+type fileVersion struct {
+	certMod  time.Time
+	certSize int64
+	keyMod   time.Time
+	keySize  int64
+}
+
+// NewReloader loads the pair once and fails if it is unusable.
+func NewReloader(certFile, keyFile string, interval time.Duration, zap *zap.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		zap:      zap,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the pair from disk now. On failure the current certificate
+// stays in use.
+func (r *Reloader) Reload() error {
+	version, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate %s: %w", r.certFile, err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.version = version
+	r.checked = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.checked) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	current := r.version
+	r.mu.Unlock()
+
+	version, err := r.stat()
+	if err != nil {
+		r.zap.Warn("failed to check certificate files", zap.Error(err))
+		return
+	}
+	if version == current {
+		return
+	}
+	// A rotation that has written only one of the files fails to load here
+	// and is retried on the next check.
+	if err := r.Reload(); err != nil {
+		r.zap.Warn("keeping previous certificate", zap.Error(err))
+		return
+	}
+	r.zap.Info("certificate reloaded", zap.String("cert_file", r.certFile))
+}
+
+func (r *Reloader) stat() (fileVersion, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{
+		certMod:  certInfo.ModTime(),
+		certSize: certInfo.Size(),
+		keyMod:   keyInfo.ModTime(),
+		keySize:  keyInfo.Size(),
+	}, nil
+}
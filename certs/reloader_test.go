@@ -0,0 +1,217 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// writeSelfSigned writes a self-signed certificate for localhost with the
+// given serial number and returns the certificate and key paths.
+func writeSelfSigned(t *testing.T, dir string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch moves the modification time of both files forward so the change is
+// seen even on filesystems with coarse timestamps.
+func touch(t *testing.T, files ...string) {
+	t.Helper()
+	future := time.Now().Add(time.Minute)
+	for _, f := range files {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// servedSerial performs a TLS handshake against a listener using r and
+// returns the serial number of the certificate the server presented.
+func servedSerial(t *testing.T, r *Reloader) int64 {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", ServerConfig(r, tls.VersionTLS12, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestReloaderServesInitialCertificate(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t, t.TempDir(), 1)
+	r, err := NewReloader(certFile, keyFile, time.Minute, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedSerial(t, r); got != 1 {
+		t.Fatalf("serial = %d, want 1", got)
+	}
+}
+
+func TestNewReloaderRejectsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), 0, zap.NewNop())
+	if err == nil {
+		t.Fatal("expected an error for missing files")
+	}
+}
+
+func TestReloaderPicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, 1)
+	r, err := NewReloader(certFile, keyFile, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSelfSigned(t, dir, 2)
+	touch(t, certFile, keyFile)
+
+	if got := servedSerial(t, r); got != 2 {
+		t.Fatalf("serial = %d, want 2 after rotation", got)
+	}
+}
+
+func TestReloaderWaitsForInterval(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, 1)
+	r, err := NewReloader(certFile, keyFile, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSelfSigned(t, dir, 2)
+	touch(t, certFile, keyFile)
+	if got := servedSerial(t, r); got != 1 {
+		t.Fatalf("serial = %d, want 1 before the interval passes", got)
+	}
+
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedSerial(t, r); got != 2 {
+		t.Fatalf("serial = %d, want 2 after Reload", got)
+	}
+}
+
+func TestReloaderKeepsCertificateOnBadRotation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, 1)
+	r, err := NewReloader(certFile, keyFile, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the certificate has been replaced, so it no longer matches the key.
+	otherCert, _ := writeSelfSigned(t, t.TempDir(), 2)
+	data, err := os.ReadFile(otherCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, certFile)
+
+	if got := servedSerial(t, r); got != 1 {
+		t.Fatalf("serial = %d, want the previous certificate 1", got)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected Reload to fail for a mismatched pair")
+	}
+}
+
+func TestParseMinVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMinVersion(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMinVersion(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("ids = %v", ids)
+	}
+	if ids, err := ParseCipherSuites(nil); err != nil || ids != nil {
+		t.Fatalf("empty list = %v, %v; want defaults", ids, err)
+	}
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Fatal("expected insecure suite to be rejected")
+	}
+}
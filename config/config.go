@@ -0,0 +1,476 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/certs"
+	"github.com/bagasadiii/gofood-clone/jobs"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/ratelimit"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// MinSecretKeyLength is the shortest accepted JWT signing key, matching the
+// 256-bit output of HS256.
+const MinSecretKeyLength = 32
+
+const redacted = "[REDACTED]"
+
+// Config is the complete runtime configuration. Values are layered from
+// defaults, an optional YAML file, environment variables and finally
+// command-line flags, each overriding the one before.
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Auth        AuthConfig        `yaml:"auth"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Log         LogConfig         `yaml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Storage     StorageConfig     `yaml:"storage"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Jobs        JobsConfig        `yaml:"jobs"`
+	Orders      OrdersConfig      `yaml:"orders"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	AdminAddr       string        `yaml:"admin_addr"`
+	DrainDelay      time.Duration `yaml:"drain_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// TrustedProxies lists the reverse proxies, as addresses or CIDR
+	// ranges, whose X-Forwarded-For header is used to find the client.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	MinVersion     string        `yaml:"min_version"`
+	CipherSuites   []string      `yaml:"cipher_suites"`
+	RedirectAddr   string        `yaml:"redirect_addr"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url"`
+}
+
+type AuthConfig struct {
+	SecretKey  string        `yaml:"secret_key"`
+	TokenTTL   time.Duration `yaml:"token_ttl"`
+	BcryptCost int           `yaml:"bcrypt_cost"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type LogConfig struct {
+	File  string `yaml:"file"`
+	Level string `yaml:"level"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+}
+
+type StorageConfig struct {
+	UploadDir string `yaml:"upload_dir"`
+}
+
+// RateLimitConfig sets a token bucket for each class of route. Store is
+// "memory" for a single instance or "postgres" to share limits between
+// replicas.
+type RateLimitConfig struct {
+	Store         string      `yaml:"store"`
+	Auth          LimitConfig `yaml:"auth"`
+	Authenticated LimitConfig `yaml:"authenticated"`
+	Public        LimitConfig `yaml:"public"`
+}
+
+// LimitConfig allows bursts of Limit requests, refilled evenly over Period.
+// A zero Limit turns the policy off.
+type LimitConfig struct {
+	Limit  int           `yaml:"limit"`
+	Period time.Duration `yaml:"period"`
+}
+
+// IdempotencyConfig sets how long the response to a request sent with an
+// Idempotency-Key is kept for replay.
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// OrdersConfig sets how long before its delivery slot a pre-order is handed
+// to the merchant, which is also the shortest notice a pre-order can be
+// placed at, and how long the merchant has to accept an order before it is
+// rejected and refunded. CancelFee is kept from the refund when a customer
+// cancels an order the merchant has already accepted.
+type OrdersConfig struct {
+	ReleaseLead  time.Duration `yaml:"release_lead"`
+	AcceptWindow time.Duration `yaml:"accept_window"`
+	CancelFee    int64         `yaml:"cancel_fee"`
+}
+
+// JobsConfig tunes the background job workers. Workers set to 0 stops this
+// instance from running jobs; it can still enqueue them.
+type JobsConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Retention    time.Duration `yaml:"retention"`
+}
+
+func (j JobsConfig) Options() jobs.Config {
+	return jobs.Config{
+		Workers:      j.Workers,
+		PollInterval: j.PollInterval,
+		Timeout:      j.Timeout,
+		MaxAttempts:  j.MaxAttempts,
+		Retention:    j.Retention,
+	}
+}
+
+func (l LimitConfig) Policy(name string) ratelimit.Policy {
+	return ratelimit.Policy{Name: name, Limit: l.Limit, Period: l.Period}
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			AdminAddr:       "127.0.0.1:9090",
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		TLS: TLSConfig{
+			MinVersion:     "1.2",
+			ReloadInterval: time.Minute,
+		},
+		Auth: AuthConfig{
+			TokenTTL:   24 * time.Hour,
+			BcryptCost: bcrypt.DefaultCost,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:5173"},
+		},
+		Log: LogConfig{
+			File:  "./logs/app.log",
+			Level: "debug",
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+		},
+		Storage: StorageConfig{
+			UploadDir: "./uploads",
+		},
+		RateLimit: RateLimitConfig{
+			Store:         "memory",
+			Auth:          LimitConfig{Limit: 10, Period: time.Minute},
+			Authenticated: LimitConfig{Limit: 120, Period: time.Minute},
+			Public:        LimitConfig{Limit: 60, Period: time.Minute},
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		Jobs: JobsConfig{
+			Workers:      4,
+			PollInterval: time.Second,
+			Timeout:      5 * time.Minute,
+			MaxAttempts:  10,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Orders: OrdersConfig{
+			ReleaseLead:  45 * time.Minute,
+			AcceptWindow: 10 * time.Minute,
+			CancelFee:    5000,
+		},
+	}
+}
+
+// Load builds the configuration from args, the environment and the YAML file
+// named by -config or CONFIG_FILE, then validates it. Subcommands pass extra
+// to register their own flags on the same flag set.
+func Load(name string, args []string, extra ...func(*flag.FlagSet)) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	addr := fs.String("addr", "", "API listen address")
+	adminAddr := fs.String("admin-addr", "", "admin listen address for metrics and readiness detail")
+	logFile := fs.String("log-file", "", "log file path")
+	logLevel := fs.String("log-level", "", "log level (debug, info, warn, error)")
+	traceExporter := fs.String("trace-exporter", "", "trace exporter (none, stdout, otlp)")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file; enables HTTPS")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	tlsRedirect := fs.String("tls-redirect-addr", "", "plain HTTP listen address that redirects to HTTPS")
+	for _, register := range extra {
+		register(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	setString(&cfg.Server.Addr, *addr)
+	setString(&cfg.Server.AdminAddr, *adminAddr)
+	setString(&cfg.Log.File, *logFile)
+	setString(&cfg.Log.Level, *logLevel)
+	setString(&cfg.Tracing.Exporter, *traceExporter)
+	setString(&cfg.TLS.CertFile, *tlsCert)
+	setString(&cfg.TLS.KeyFile, *tlsKey)
+	setString(&cfg.TLS.RedirectAddr, *tlsRedirect)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Server.Addr, os.Getenv("HTTP_ADDR"))
+	setString(&c.Server.AdminAddr, os.Getenv("ADMIN_ADDR"))
+	setString(&c.Database.URL, os.Getenv("DATABASELINK"))
+	setString(&c.Auth.SecretKey, os.Getenv("SECRETKEY"))
+	setString(&c.Log.File, os.Getenv("LOG_FILE"))
+	setString(&c.Log.Level, os.Getenv("LOG_LEVEL"))
+	setString(&c.Tracing.Exporter, os.Getenv("TRACE_EXPORTER"))
+	setString(&c.Storage.UploadDir, os.Getenv("UPLOAD_DIR"))
+	setString(&c.TLS.CertFile, os.Getenv("TLS_CERT_FILE"))
+	setString(&c.TLS.KeyFile, os.Getenv("TLS_KEY_FILE"))
+	setString(&c.TLS.MinVersion, os.Getenv("TLS_MIN_VERSION"))
+	setString(&c.TLS.RedirectAddr, os.Getenv("TLS_REDIRECT_ADDR"))
+	if suites := os.Getenv("TLS_CIPHER_SUITES"); suites != "" {
+		c.TLS.CipherSuites = splitList(suites)
+	}
+	setString(&c.RateLimit.Store, os.Getenv("RATE_LIMIT_STORE"))
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		c.Server.TrustedProxies = splitList(proxies)
+	}
+	if origins := os.Getenv("CORS_ORIGINS"); origins != "" {
+		c.CORS.AllowedOrigins = splitList(origins)
+	}
+
+	var errs []error
+	for env, dst := range map[string]*time.Duration{
+		"DRAIN_DELAY":         &c.Server.DrainDelay,
+		"SHUTDOWN_TIMEOUT":    &c.Server.ShutdownTimeout,
+		"TOKEN_TTL":           &c.Auth.TokenTTL,
+		"TLS_RELOAD_INTERVAL": &c.TLS.ReloadInterval,
+		"IDEMPOTENCY_TTL":     &c.Idempotency.TTL,
+		"JOB_TIMEOUT":         &c.Jobs.Timeout,
+		"ORDER_RELEASE_LEAD":  &c.Orders.ReleaseLead,
+		"ORDER_ACCEPT_WINDOW": &c.Orders.AcceptWindow,
+	} {
+		if v := os.Getenv(env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+				continue
+			}
+			*dst = d
+		}
+	}
+	if v := os.Getenv("BCRYPT_COST"); v != "" {
+		cost, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("BCRYPT_COST: %w", err))
+		} else {
+			c.Auth.BcryptCost = cost
+		}
+	}
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("JOB_WORKERS: %w", err))
+		} else {
+			c.Jobs.Workers = workers
+		}
+	}
+	if v := os.Getenv("ORDER_CANCEL_FEE"); v != "" {
+		fee, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ORDER_CANCEL_FEE: %w", err))
+		} else {
+			c.Orders.CancelFee = fee
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Auth.SecretKey == "" {
+		errs = append(errs, errors.New("SECRETKEY is required"))
+	} else if len(c.Auth.SecretKey) < MinSecretKeyLength {
+		errs = append(errs, fmt.Errorf("SECRETKEY must be at least %d characters", MinSecretKeyLength))
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("DATABASELINK is required"))
+	}
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server address is required"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("drain delay cannot be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if _, err := middleware.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		errs = append(errs, err)
+	}
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("TLS needs both a certificate and a key file"))
+		}
+		if _, err := certs.ParseMinVersion(c.TLS.MinVersion); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := certs.ParseCipherSuites(c.TLS.CipherSuites); err != nil {
+			errs = append(errs, err)
+		}
+		if c.TLS.ReloadInterval < 0 {
+			errs = append(errs, errors.New("TLS reload interval cannot be negative"))
+		}
+	} else if c.TLS.RedirectAddr != "" {
+		errs = append(errs, errors.New("TLS redirect address set without a certificate"))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("token TTL must be positive"))
+	}
+	if c.Auth.BcryptCost < bcrypt.DefaultCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.DefaultCost, bcrypt.MaxCost))
+	}
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log level: %w", err))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace exporter %q", c.Tracing.Exporter))
+	}
+	if c.Storage.UploadDir == "" {
+		errs = append(errs, errors.New("upload directory is required"))
+	}
+	switch c.RateLimit.Store {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("unknown rate limit store %q", c.RateLimit.Store))
+	}
+	for _, limit := range []struct {
+		name string
+		LimitConfig
+	}{
+		{"auth", c.RateLimit.Auth},
+		{"authenticated", c.RateLimit.Authenticated},
+		{"public", c.RateLimit.Public},
+	} {
+		if limit.Limit < 0 || (limit.Limit > 0 && limit.Period <= 0) {
+			errs = append(errs, fmt.Errorf("%s rate limit needs a non-negative limit and a positive period", limit.name))
+		}
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency TTL must be positive"))
+	}
+	if c.Jobs.Workers < 0 {
+		errs = append(errs, errors.New("job workers must not be negative"))
+	}
+	if c.Jobs.PollInterval <= 0 || c.Jobs.Timeout <= 0 || c.Jobs.Retention <= 0 {
+		errs = append(errs, errors.New("job poll interval, timeout and retention must be positive"))
+	}
+	if c.Jobs.MaxAttempts < 1 {
+		errs = append(errs, errors.New("job max attempts must be at least 1"))
+	}
+	if c.Orders.ReleaseLead <= 0 || c.Orders.AcceptWindow <= 0 {
+		errs = append(errs, errors.New("order release lead and accept window must be positive"))
+	}
+	if c.Orders.CancelFee < 0 {
+		errs = append(errs, errors.New("order cancel fee must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy that is safe to print: the signing key is hidden and
+// any password in the database URL is masked.
+func (c Config) Redacted() Config {
+	if c.Auth.SecretKey != "" {
+		c.Auth.SecretKey = redacted
+	}
+	c.Database.URL = redactURL(c.Database.URL)
+	c.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+	c.Server.TrustedProxies = append([]string(nil), c.Server.TrustedProxies...)
+	return c
+}
+
+var dsnPassword = regexp.MustCompile(`(password=)(\S+)`)
+
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(raw, "${1}"+redacted)
+}
+
+// YAML renders the configuration in the same shape the config file uses.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func setString(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
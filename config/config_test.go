@@ -0,0 +1,198 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecretKey = "config-test-secret-key-32-bytes!"
+
+// clearEnv unsets every variable Load reads so the host environment cannot
+// leak into a test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{
+		"CONFIG_FILE", "HTTP_ADDR", "ADMIN_ADDR", "DATABASELINK", "SECRETKEY", "LOG_FILE", "LOG_LEVEL",
+		"TRACE_EXPORTER", "UPLOAD_DIR", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_MIN_VERSION",
+		"TLS_REDIRECT_ADDR", "TLS_CIPHER_SUITES", "RATE_LIMIT_STORE", "TRUSTED_PROXIES", "CORS_ORIGINS",
+		"DRAIN_DELAY", "SHUTDOWN_TIMEOUT", "TOKEN_TTL", "TLS_RELOAD_INTERVAL", "IDEMPOTENCY_TTL",
+		"JOB_TIMEOUT", "BCRYPT_COST", "JOB_WORKERS", "ORDER_RELEASE_LEAD", "ORDER_ACCEPT_WINDOW",
+		"ORDER_CANCEL_FEE",
+	} {
+		t.Setenv(env, "")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SECRETKEY", testSecretKey)
+	t.Setenv("DATABASELINK", "postgres://localhost/gofood")
+	path := writeFile(t, `
+server:
+  addr: ":7000"
+  admin_addr: "127.0.0.1:7001"
+  drain_delay: 1s
+log:
+  level: info
+  file: ./yaml.log
+`)
+	t.Setenv("ADMIN_ADDR", "127.0.0.1:8001")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("DRAIN_DELAY", "2s")
+
+	cfg, err := Load("test", []string{"-config", path, "-log-level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"default shutdown timeout", cfg.Server.ShutdownTimeout, 10 * time.Second},
+		{"yaml addr over default", cfg.Server.Addr, ":7000"},
+		{"yaml log file over default", cfg.Log.File, "./yaml.log"},
+		{"env admin addr over yaml", cfg.Server.AdminAddr, "127.0.0.1:8001"},
+		{"env drain delay over yaml", cfg.Server.DrainDelay, 2 * time.Second},
+		{"flag log level over env", cfg.Log.Level, "error"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SECRETKEY", testSecretKey)
+	t.Setenv("DATABASELINK", "postgres://localhost/gofood")
+	t.Setenv("CONFIG_FILE", writeFile(t, "server:\n  addr: \":7000\"\n"))
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":7000" {
+		t.Errorf("addr = %q, want the CONFIG_FILE value", cfg.Server.Addr)
+	}
+}
+
+func TestLoadRejectsUnknownYAMLKeys(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SECRETKEY", testSecretKey)
+	t.Setenv("DATABASELINK", "postgres://localhost/gofood")
+	path := writeFile(t, "server:\n  adress: \":7000\"\n")
+
+	_, err := Load("test", []string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), "adress") {
+		t.Errorf("Load with a misspelt key = %v, want an error naming it", err)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SECRETKEY", testSecretKey)
+	t.Setenv("DATABASELINK", "postgres://localhost/gofood")
+	t.Setenv("TOKEN_TTL", "a day")
+	t.Setenv("JOB_WORKERS", "four")
+	t.Setenv("ORDER_CANCEL_FEE", "5k")
+
+	_, err := Load("test", nil)
+	for _, env := range []string{"TOKEN_TTL", "JOB_WORKERS", "ORDER_CANCEL_FEE"} {
+		if err == nil || !strings.Contains(err.Error(), env) {
+			t.Errorf("Load = %v, want an error for %s", err, env)
+		}
+	}
+}
+
+func TestValidateSecretKey(t *testing.T) {
+	tests := []struct {
+		name, key, want string
+	}{
+		{"missing", "", "SECRETKEY is required"},
+		{"short", strings.Repeat("k", MinSecretKeyLength-1), "SECRETKEY must be at least 32 characters"},
+		{"long enough", strings.Repeat("k", MinSecretKeyLength), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Database.URL = "postgres://localhost/gofood"
+			cfg.Auth.SecretKey = tt.key
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Addr = ""
+	cfg.Jobs.MaxAttempts = 0
+	cfg.Orders.AcceptWindow = 0
+	cfg.Orders.CancelFee = -1
+	err := cfg.Validate()
+	for _, want := range []string{"SECRETKEY", "DATABASELINK", "server address", "job max attempts", "accept window", "cancel fee"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	tests := []struct {
+		name, url, want string
+	}{
+		{"url", "postgres://gofood:hunter2@db:5432/gofood?sslmode=disable", "postgres://gofood:xxxxx@db:5432/gofood?sslmode=disable"},
+		{"url without password", "postgres://gofood@db/gofood", "postgres://gofood@db/gofood"},
+		{"key value", "host=db user=gofood password=hunter2 dbname=gofood", "host=db user=gofood password=[REDACTED] dbname=gofood"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Auth.SecretKey = testSecretKey
+			cfg.Database.URL = tt.url
+			cfg.Server.TrustedProxies = []string{"10.0.0.0/8"}
+
+			red := cfg.Redacted()
+			if red.Database.URL != tt.want {
+				t.Errorf("database URL = %q, want %q", red.Database.URL, tt.want)
+			}
+			if red.Auth.SecretKey != redacted {
+				t.Errorf("secret key = %q, want it hidden", red.Auth.SecretKey)
+			}
+			out, err := red.YAML()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(out), "hunter2") || strings.Contains(string(out), testSecretKey) {
+				t.Errorf("redacted YAML leaks a secret:\n%s", out)
+			}
+
+			// The copy does not share slices with the original.
+			red.Server.TrustedProxies[0] = "0.0.0.0/0"
+			if cfg.Database.URL != tt.url || cfg.Auth.SecretKey != testSecretKey || cfg.Server.TrustedProxies[0] != "10.0.0.0/8" {
+				t.Errorf("Redacted changed the original: %+v", cfg)
+			}
+		})
+	}
+}
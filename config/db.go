@@ -3,34 +3,37 @@ package config
 import (
 	"context"
 	"log"
-	"os"
 	"time"
 
+	"github.com/bagasadiii/gofood-clone/migrations"
+	"github.com/bagasadiii/gofood-clone/tracing"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitDB() *pgxpool.Pool {
-	dbUrl := os.Getenv("DATABASELINK")
+func InitDB(dbUrl string) *pgxpool.Pool {
 	var pool *pgxpool.Pool
-	var err error
+	poolConfig, err := pgxpool.ParseConfig(dbUrl)
+	if err != nil {
+		log.Fatalf("Failed to parse database link: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
 	for i := 0; i < 5; i++ {
-		pool, err = pgxpool.New(context.Background(), dbUrl)
+		pool, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 		if err == nil {
 			if err = pool.Ping(context.Background()); err == nil {
 				log.Println("Database connected successfully")
 				break
 			}
+			pool.Close()
 		}
 		log.Printf("Retrying database connection... (%d/5)\n", i+1)
 		time.Sleep(2 * time.Second)
 	}
-	table, err := os.ReadFile("table.sql")
 	if err != nil {
-		log.Fatalf("Failed to get query file: %v", err)
+		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	_, err = pool.Exec(context.Background(), string(table))
-	if err != nil {
-		log.Fatalf("Failed to create table query: %v", err)
+	if err := migrations.Apply(context.Background(), pool); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 	return pool
 }
@@ -1,13 +1,21 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func NewLogger() *zap.Logger {
+// NewLogger writes JSON to the configured file and colored text to stdout,
+// both at the configured level.
+func NewLogger(cfg LogConfig) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
 	consoleEncoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
 		LevelKey:       "level",
@@ -36,14 +44,17 @@ func NewLogger() *zap.Logger {
 	}
 	fileEncoder := zapcore.NewJSONEncoder(fileEncoderConfig)
 
-	logFile, err := os.OpenFile("./logs/app.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	logFile, err := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		panic("Failed to open log file: " + err.Error())
+		return nil, fmt.Errorf("open log file: %w", err)
 	}
 
 	core := zapcore.NewTee(
-		zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), zapcore.DebugLevel),
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
+		zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), level),
+		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level),
 	)
 
 	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
@@ -52,5 +63,5 @@ func NewLogger() *zap.Logger {
 
 	zap.ReplaceGlobals(logger)
 
-	return logger
-}
\ No newline at end of file
+	return logger, nil
+}
@@ -0,0 +1,188 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+// apiClient is a typed client for the HTTP API. Transport and decoding
+// failures end the test; API errors are returned for the test to check.
+type apiClient struct {
+	t       *testing.T
+	baseURL string
+	http    *http.Client
+	token   string
+	header  http.Header
+}
+
+// envelope mirrors utils.APIResp with the payload left undecoded.
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   *utils.APIError `json:"error"`
+}
+
+// as returns a copy of the client that sends token as its bearer token.
+func (c *apiClient) as(token string) *apiClient {
+	copy := *c
+	copy.token = token
+	return &copy
+}
+
+// withHeader returns a copy of the client that also sends the given header.
+func (c *apiClient) withHeader(key, value string) *apiClient {
+	copy := *c
+	copy.header = c.header.Clone()
+	if copy.header == nil {
+		copy.header = http.Header{}
+	}
+	copy.header.Set(key, value)
+	return &copy
+}
+
+func (c *apiClient) do(method, path string, body, out any) *utils.APIError {
+	c.t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, &reqBody)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(res.Body).Decode(&env); err != nil {
+		c.t.Fatalf("%s %s: decode response: %v", method, path, err)
+	}
+	if env.Error != nil {
+		env.Error.Status = res.StatusCode
+		return env.Error
+	}
+	if res.StatusCode >= 300 {
+		c.t.Fatalf("%s %s: status %d without an error body", method, path, res.StatusCode)
+	}
+	if out != nil {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			c.t.Fatalf("%s %s: decode data: %v", method, path, err)
+		}
+	}
+	return nil
+}
+
+// ok fails the test when the API returned an error.
+func ok(t *testing.T, err *utils.APIError) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("API error %d %s: %s", err.Status, err.Code, err.Message)
+	}
+}
+
+func (c *apiClient) Register(req model.RegisterReq) (*model.UserResp, *utils.APIError) {
+	var res model.UserResp
+	return &res, c.do(http.MethodPost, "/api/v1/register", req, &res)
+}
+
+func (c *apiClient) Login(username, password string) (*model.LoginRes, *utils.APIError) {
+	var res model.LoginRes
+	err := c.do(http.MethodPost, "/api/v1/login", model.LoginReq{Username: username, Password: password}, &res)
+	return &res, err
+}
+
+func (c *apiClient) GetUser(username string) (*model.UserResp, *utils.APIError) {
+	var res model.UserResp
+	return &res, c.do(http.MethodGet, "/api/v1/u/"+username, nil, &res)
+}
+
+func (c *apiClient) CreateMerchant(username string, req model.Merchant) (*model.MerchantRes, *utils.APIError) {
+	var res model.MerchantRes
+	return &res, c.do(http.MethodPost, "/api/v1/m/"+username, req, &res)
+}
+
+func (c *apiClient) GetMerchant(username string) (*model.MerchantRes, *utils.APIError) {
+	var res model.MerchantRes
+	return &res, c.do(http.MethodGet, "/api/v1/m/"+username, nil, &res)
+}
+
+func (c *apiClient) GetMerchantApplication(username string) (*model.Application, *utils.APIError) {
+	var res model.Application
+	return &res, c.do(http.MethodGet, "/api/v1/m/"+username+"/application", nil, &res)
+}
+
+func (c *apiClient) ApproveMerchant(username string) (*model.AdminMerchantRes, *utils.APIError) {
+	var res model.AdminMerchantRes
+	return &res, c.do(http.MethodPost, "/api/v1/admin/merchants/"+username+"/approve", model.ReviewReq{}, &res)
+}
+
+func (c *apiClient) CreateMenu(username string, req model.Menu) (*model.MenuRes, *utils.APIError) {
+	var res model.MenuRes
+	return &res, c.do(http.MethodPost, "/api/v1/m/"+username+"/menus", req, &res)
+}
+
+func (c *apiClient) GetMenu(id uuid.UUID) (*model.MenuRes, *utils.APIError) {
+	var res model.MenuRes
+	return &res, c.do(http.MethodGet, "/api/v1/menus/"+id.String(), nil, &res)
+}
+
+func (c *apiClient) UpdateMenu(username string, id uuid.UUID, req map[string]any) (*model.MenuRes, *utils.APIError) {
+	var res model.MenuRes
+	return &res, c.do(http.MethodPatch, "/api/v1/m/"+username+"/menus/"+id.String(), req, &res)
+}
+
+func (c *apiClient) DeleteMenu(username string, id uuid.UUID) *utils.APIError {
+	return c.do(http.MethodDelete, "/api/v1/m/"+username+"/menus/"+id.String(), nil, nil)
+}
+
+func (c *apiClient) CreateDriver(username string, req model.Driver) (*model.DriverRes, *utils.APIError) {
+	var res model.DriverRes
+	return &res, c.do(http.MethodPost, "/api/v1/d/"+username, req, &res)
+}
+
+func (c *apiClient) GetDriver(username string) (*model.DriverRes, *utils.APIError) {
+	var res model.DriverRes
+	return &res, c.do(http.MethodGet, "/api/v1/d/"+username, nil, &res)
+}
+
+func (c *apiClient) ApproveDriver(username string) (*model.AdminDriverRes, *utils.APIError) {
+	var res model.AdminDriverRes
+	return &res, c.do(http.MethodPost, "/api/v1/admin/drivers/"+username+"/approve", model.ReviewReq{}, &res)
+}
+
+func (c *apiClient) AdminGetUser(username string) (*model.AdminUserRes, *utils.APIError) {
+	var res model.AdminUserRes
+	return &res, c.do(http.MethodGet, "/api/v1/admin/users/"+username, nil, &res)
+}
+
+func (c *apiClient) AdjustBalance(username string, req model.WalletAdjustReq) (*model.WalletTransaction, *utils.APIError) {
+	var res model.WalletTransaction
+	return &res, c.do(http.MethodPost, "/api/v1/admin/users/"+username+"/wallet", req, &res)
+}
+
+// status flattens an API error for table-driven checks.
+func status(err *utils.APIError) (int, string) {
+	if err == nil {
+		return http.StatusOK, ""
+	}
+	return err.Status, err.Code
+}
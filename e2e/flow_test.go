@@ -0,0 +1,285 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/google/uuid"
+)
+
+// signUp registers an account and returns a client logged in as it.
+func signUp(t *testing.T, c *apiClient, username, role, phone string) *apiClient {
+	t.Helper()
+	_, err := c.Register(model.RegisterReq{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: username + "-password",
+		Role:     role,
+		Phone:    phone,
+	})
+	ok(t, err)
+	res, err := c.Login(username, username+"-password")
+	ok(t, err)
+	if res.Token == "" {
+		t.Fatalf("login %s: no token", username)
+	}
+	return c.as(res.Token)
+}
+
+func asAdmin(t *testing.T, h *harness) *apiClient {
+	t.Helper()
+	h.loadFixture(t, "admin")
+	c := h.client(t)
+	res, err := c.Login("admin", "admin-password")
+	ok(t, err)
+	return c.as(res.Token)
+}
+
+func TestMerchantFlow(t *testing.T) {
+	h := newHarness(t)
+	admin := asAdmin(t, h)
+	anon := h.client(t)
+	owner := signUp(t, anon, "warungbudi", "merchant", "081234567801")
+
+	_, err := owner.CreateMerchant("warungbudi", model.Merchant{
+		Name:        "Warung Budi",
+		Address:     "Jl. Merdeka 1",
+		Category:    "indonesian",
+		Description: "Nasi goreng and friends",
+	})
+	ok(t, err)
+	app, err := owner.GetMerchantApplication("warungbudi")
+	ok(t, err)
+	if app.Status != "pending" {
+		t.Fatalf("application status = %q, want pending", app.Status)
+	}
+	if _, err := anon.GetMerchant("warungbudi"); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("pending merchant visible: %+v", err)
+	}
+
+	approved, err := admin.ApproveMerchant("warungbudi")
+	ok(t, err)
+	if approved.Status != "approved" {
+		t.Fatalf("approved status = %q", approved.Status)
+	}
+	merchant, err := anon.GetMerchant("warungbudi")
+	ok(t, err)
+	if merchant.Name != "Warung Budi" || merchant.Category != "indonesian" ||
+		merchant.Owner != "warungbudi" || merchant.Description != "Nasi goreng and friends" {
+		t.Fatalf("merchant = %+v", merchant)
+	}
+	if merchant.Status != "" {
+		t.Errorf("public merchant view shows status %q", merchant.Status)
+	}
+
+	menu, err := owner.CreateMenu("warungbudi", model.Menu{
+		Name:     "Nasi Goreng",
+		Price:    25000,
+		Category: "rice",
+		Stock:    10,
+	})
+	ok(t, err)
+	if menu.MenuID == uuid.Nil {
+		t.Fatal("created menu has no id")
+	}
+	got, err := anon.GetMenu(menu.MenuID)
+	ok(t, err)
+	if got.Name != "Nasi Goreng" || got.Price != 25000 || got.Stock != 10 {
+		t.Fatalf("menu = %+v", got)
+	}
+
+	updated, err := owner.UpdateMenu("warungbudi", menu.MenuID, map[string]any{"price": 27000})
+	ok(t, err)
+	if updated.Price != 27000 || updated.Name != "Nasi Goreng" {
+		t.Fatalf("updated menu = %+v", updated)
+	}
+	ok(t, owner.DeleteMenu("warungbudi", menu.MenuID))
+	if _, err := anon.GetMenu(menu.MenuID); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("deleted menu still visible: %+v", err)
+	}
+}
+
+func TestDriverFlow(t *testing.T) {
+	h := newHarness(t)
+	admin := asAdmin(t, h)
+	anon := h.client(t)
+	driver := signUp(t, anon, "sitidriver", "driver", "081234567802")
+
+	created, err := driver.CreateDriver("sitidriver", model.Driver{
+		Name:    "Siti",
+		License: "B1234XYZ",
+		Area:    "Jakarta",
+	})
+	ok(t, err)
+	if created.License != "B1234XYZ" || created.Status != "pending" {
+		t.Fatalf("created driver = %+v", created)
+	}
+	approved, err := admin.ApproveDriver("sitidriver")
+	ok(t, err)
+	if approved.Status != "approved" {
+		t.Fatalf("approved status = %q", approved.Status)
+	}
+	got, err := anon.GetDriver("sitidriver")
+	ok(t, err)
+	if got.Name != "Siti" || got.Area != "Jakarta" || got.Username != "sitidriver" {
+		t.Fatalf("driver = %+v", got)
+	}
+	if got.License != "" || got.Income != nil {
+		t.Errorf("public driver view shows license %q or income", got.License)
+	}
+	own, err := driver.GetDriver("sitidriver")
+	ok(t, err)
+	if own.License != "B1234XYZ" || own.Income == nil {
+		t.Errorf("owner driver view = %+v", own)
+	}
+}
+
+func TestProfileVisibility(t *testing.T) {
+	h := newHarness(t)
+	admin := asAdmin(t, h)
+	anon := h.client(t)
+	res, err := anon.Register(model.RegisterReq{
+		Username: "budi",
+		Email:    "budi@example.com",
+		Password: "budi-password",
+		Role:     "user",
+		Phone:    "081234567806",
+	})
+	ok(t, err)
+	if res.Username != "budi" || res.Phone != "081234567806" || res.Email != "budi@example.com" {
+		t.Fatalf("register = %+v", res)
+	}
+	owner := signUp(t, anon, "siti", "user", "081234567807")
+	stranger := signUp(t, anon, "joko", "user", "081234567808")
+
+	for name, c := range map[string]*apiClient{"anonymous": anon, "stranger": stranger} {
+		got, err := c.GetUser("siti")
+		ok(t, err)
+		if got.Phone != "" || got.Email != "" {
+			t.Errorf("%s sees phone %q and email %q", name, got.Phone, got.Email)
+		}
+	}
+	for name, c := range map[string]*apiClient{"owner": owner, "admin": admin} {
+		got, err := c.GetUser("siti")
+		ok(t, err)
+		if got.Phone != "081234567807" || got.Email != "siti@example.com" {
+			t.Errorf("%s view = %+v", name, got)
+		}
+	}
+
+	_, err = anon.as("not-a-token").GetUser("siti")
+	if code, _ := status(err); code != http.StatusUnauthorized {
+		t.Errorf("invalid token on a public route = %d, want 401", code)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := newHarness(t)
+	anon := h.client(t)
+	owner := signUp(t, anon, "ownerone", "merchant", "081234567803")
+	other := signUp(t, anon, "ownertwo", "merchant", "081234567804")
+	customer := signUp(t, anon, "customer", "user", "081234567805")
+
+	for username, c := range map[string]*apiClient{"ownerone": owner, "ownertwo": other} {
+		_, err := c.CreateMerchant(username, model.Merchant{
+			Name:        username,
+			Address:     "Jl. Sudirman 2",
+			Category:    "snacks",
+			Description: "Snacks",
+		})
+		ok(t, err)
+	}
+	menu, err := owner.CreateMenu("ownerone", model.Menu{Name: "Pisang Goreng", Price: 5000, Category: "snacks"})
+	ok(t, err)
+
+	tests := []struct {
+		name   string
+		call   func() (int, string)
+		status int
+		code   string
+	}{
+		{"duplicate username", func() (int, string) {
+			_, err := anon.Register(model.RegisterReq{
+				Username: "ownerone", Email: "again@example.com", Password: "password123",
+				Role: "user", Phone: "081234567806",
+			})
+			return status(err)
+		}, http.StatusConflict, "already_exists"},
+		{"invalid registration", func() (int, string) {
+			_, err := anon.Register(model.RegisterReq{Username: "x", Email: "nope", Password: "short", Role: "admin"})
+			return status(err)
+		}, http.StatusBadRequest, "validation_failed"},
+		{"wrong password", func() (int, string) {
+			_, err := anon.Login("ownerone", "not-the-password")
+			return status(err)
+		}, http.StatusUnauthorized, "invalid_credentials"},
+		{"no token", func() (int, string) {
+			_, err := anon.CreateMenu("ownerone", model.Menu{Name: "Tahu", Category: "snacks"})
+			return status(err)
+		}, http.StatusUnauthorized, "unauthorized"},
+		{"menu for another merchant", func() (int, string) {
+			_, err := other.CreateMenu("ownerone", model.Menu{Name: "Tahu", Category: "snacks"})
+			return status(err)
+		}, http.StatusForbidden, "forbidden"},
+		{"edit another merchant's menu", func() (int, string) {
+			_, err := other.UpdateMenu("ownertwo", menu.MenuID, map[string]any{"price": 1})
+			return status(err)
+		}, http.StatusNotFound, "not_found"},
+		{"customer creates menu", func() (int, string) {
+			_, err := customer.CreateMenu("customer", model.Menu{Name: "Tahu", Category: "snacks"})
+			return status(err)
+		}, http.StatusForbidden, "forbidden"},
+		{"missing menu", func() (int, string) {
+			_, err := anon.GetMenu(uuid.New())
+			return status(err)
+		}, http.StatusNotFound, "not_found"},
+		{"admin route as merchant", func() (int, string) {
+			_, err := owner.ApproveMerchant("ownertwo")
+			return status(err)
+		}, http.StatusForbidden, "forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStatus, gotCode := tt.call()
+			if gotStatus != tt.status || gotCode != tt.code {
+				t.Fatalf("got %d %s, want %d %s", gotStatus, gotCode, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestIdempotentTopUp(t *testing.T) {
+	h := newHarness(t)
+	admin := asAdmin(t, h)
+	signUp(t, h.client(t), "budi", "user", "081234567809")
+
+	topUp := model.WalletAdjustReq{Amount: 50000, Reason: "promo credit"}
+	keyed := admin.withHeader("Idempotency-Key", "topup-budi-1")
+	first, err := keyed.AdjustBalance("budi", topUp)
+	ok(t, err)
+	retry, err := keyed.AdjustBalance("budi", topUp)
+	ok(t, err)
+	if retry.TransactionID != first.TransactionID {
+		t.Errorf("retry created transaction %s, want replay of %s", retry.TransactionID, first.TransactionID)
+	}
+	user, err := admin.AdminGetUser("budi")
+	ok(t, err)
+	if user.Balance != 50000 {
+		t.Errorf("balance = %d after a retried top-up, want 50000", user.Balance)
+	}
+
+	_, err = keyed.AdjustBalance("budi", model.WalletAdjustReq{Amount: 90000, Reason: "promo credit"})
+	if code, errCode := status(err); code != http.StatusUnprocessableEntity || errCode != "idempotency_key_reused" {
+		t.Errorf("reused key with another body = %d %s, want 422 idempotency_key_reused", code, errCode)
+	}
+
+	// Without a key every request is processed.
+	_, err = admin.AdjustBalance("budi", topUp)
+	ok(t, err)
+	user, err = admin.AdminGetUser("budi")
+	ok(t, err)
+	if user.Balance != 100000 {
+		t.Errorf("balance = %d, want 100000", user.Balance)
+	}
+}
@@ -0,0 +1,96 @@
+// Package e2e drives the full HTTP API against a throwaway Postgres. Each test
+// gets its own schema with every migration applied; the tests are skipped when
+// no database is available (see repotest.Main).
+package e2e
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/app"
+	"github.com/bagasadiii/gofood-clone/handler"
+	"github.com/bagasadiii/gofood-clone/health"
+	"github.com/bagasadiii/gofood-clone/idempotency"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/repository/repotest"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testSecretKey = "e2e-secret-key-that-is-at-least-32-bytes"
+
+func TestMain(m *testing.M) {
+	repotest.Main(m)
+}
+
+type harness struct {
+	db     *pgxpool.Pool
+	server *httptest.Server
+}
+
+// newHarness wires the application the same way serve does, minus the
+// listeners, and serves it from an httptest.Server.
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	db := repotest.Postgres(t)
+	logger := zap.NewNop()
+
+	userRepo := repository.NewUserRepo(db, logger)
+	jwtService := middleware.NewJWTService([]byte(testSecretKey), time.Hour, userRepo, logger)
+	auditRepo := repository.NewAuditRepo(db, logger)
+	loginGuard := service.NewLoginGuard(repository.NewLoginAttemptRepo(db, logger), auditRepo, logger)
+	documentStore, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := service.NewUserService(userRepo, loginGuard, documentStore, logger, jwtService, bcrypt.MinCost)
+	twoFactorService := service.NewTwoFactorService(repository.NewTwoFactorRepo(db, logger), loginGuard, logger, jwtService)
+
+	checker := health.NewChecker(logger)
+	checker.Register("database", 0, health.Database(db))
+	checker.Register("migrations", 0, health.Migrations(db))
+
+	router := app.NewRouter(app.HandlerDependencies{
+		UserEndpoint:          handler.NewUserHandler(userService, logger),
+		MerchantEndpoint:      handler.NewMerchantHandler(service.NewMerchantService(repository.NewMerchantRepo(db, logger), logger), logger),
+		DriverEndpoint:        handler.NewDriverHandler(service.NewDriverService(repository.NewDriverRepo(db, logger), documentStore, logger), logger),
+		MenuEndpoint:          handler.NewMenuHandler(service.NewMenuService(repository.NewMenuRepo(db, logger), logger), logger),
+		OrderEndpoint:         handler.NewOrderHandler(service.NewOrderService(repository.NewOrderRepo(db, logger), repository.NewMerchantRepo(db, logger), time.Hour, 10*time.Minute, 5000, logger), logger),
+		MerchantOrderEndpoint: handler.NewMerchantOrderHandler(service.NewMerchantOrderService(repository.NewOrderRepo(db, logger), repository.NewMerchantRepo(db, logger), logger), logger),
+		TwoFactorEndpoint:     handler.NewTwoFactorHandler(twoFactorService, logger),
+		AdminEndpoint:         handler.NewAdminHandler(service.NewAdminService(repository.NewAdminRepo(db, logger), repository.NewOrderRepo(db, logger), logger), logger),
+		Middleware:            jwtService,
+		Health:                checker,
+		Idempotency:           middleware.NewIdempotency(idempotency.NewPostgresStore(db), time.Hour, logger).HandleOnce,
+	})
+	root := middleware.RequestID(logger)(middleware.Recover(logger)(router.Route()))
+
+	server := httptest.NewServer(root)
+	t.Cleanup(server.Close)
+	return &harness{db: db, server: server}
+}
+
+// client returns an unauthenticated API client for the server.
+func (h *harness) client(t *testing.T) *apiClient {
+	return &apiClient{t: t, baseURL: h.server.URL, http: h.server.Client()}
+}
+
+// loadFixture runs testdata/<name>.sql against the test schema.
+func (h *harness) loadFixture(t *testing.T, name string) {
+	t.Helper()
+	sql, err := os.ReadFile(filepath.Join("testdata", name+".sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.db.Exec(context.Background(), string(sql)); err != nil {
+		t.Fatalf("load fixture %s: %v", name, err)
+	}
+}
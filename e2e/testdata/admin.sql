@@ -0,0 +1,8 @@
+-- An administrator who can approve merchants and drivers. The password is
+-- "admin-password", hashed at bcrypt's minimum cost.
+INSERT INTO users (user_id, username, email, password, role, created_at, phone, balance, name)
+VALUES (
+  '00000000-0000-4000-8000-000000000001', 'admin', 'admin@example.com',
+  '$2a$04$px/SgyGLMpQRGgcxV7wlgeUsp1usmoYUySciv.lvTUppwqcANETiW',
+  'admin', CURRENT_TIMESTAMP, '081200000001', 0, 'Administrator'
+);
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type AdminHandlerImpl interface {
+	ListUsersHandler(w http.ResponseWriter, r *http.Request)
+	GetUserHandler(w http.ResponseWriter, r *http.Request)
+	SuspendUserHandler(w http.ResponseWriter, r *http.Request)
+	UnsuspendUserHandler(w http.ResponseWriter, r *http.Request)
+	ListMerchantsHandler(w http.ResponseWriter, r *http.Request)
+	GetMerchantHandler(w http.ResponseWriter, r *http.Request)
+	ApproveMerchantHandler(w http.ResponseWriter, r *http.Request)
+	RejectMerchantHandler(w http.ResponseWriter, r *http.Request)
+	ListDriversHandler(w http.ResponseWriter, r *http.Request)
+	GetDriverHandler(w http.ResponseWriter, r *http.Request)
+	ApproveDriverHandler(w http.ResponseWriter, r *http.Request)
+	RejectDriverHandler(w http.ResponseWriter, r *http.Request)
+	AdjustBalanceHandler(w http.ResponseWriter, r *http.Request)
+	ListDisputesHandler(w http.ResponseWriter, r *http.Request)
+	ResolveDisputeHandler(w http.ResponseWriter, r *http.Request)
+}
+type AdminHandler struct {
+	service service.AdminServiceImpl
+	zap     *zap.Logger
+}
+
+func NewAdminHandler(service service.AdminServiceImpl, zap *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		service: service,
+		zap:     zap,
+	}
+}
+
+func (ah *AdminHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.ListUsersService(r.Context(), listFilter(r))
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.GetUserService(r.Context(), mux.Vars(r)["username"])
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) SuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	ah.setUserStatus(w, r, model.UserStatusSuspended)
+}
+
+func (ah *AdminHandler) UnsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	ah.setUserStatus(w, r, model.UserStatusActive)
+}
+
+func (ah *AdminHandler) setUserStatus(w http.ResponseWriter, r *http.Request, status string) {
+	var input model.ModerationReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), ah.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	res, err := ah.service.SetUserStatusService(r.Context(), username, status, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), ah.zap).Info("User status changed", zap.String("username", username), zap.String("status", status))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ListMerchantsHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.ListMerchantsService(r.Context(), listFilter(r))
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) GetMerchantHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.GetMerchantService(r.Context(), mux.Vars(r)["username"])
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ApproveMerchantHandler(w http.ResponseWriter, r *http.Request) {
+	ah.reviewMerchant(w, r, model.ReviewStatusApproved)
+}
+
+func (ah *AdminHandler) RejectMerchantHandler(w http.ResponseWriter, r *http.Request) {
+	ah.reviewMerchant(w, r, model.ReviewStatusRejected)
+}
+
+func (ah *AdminHandler) reviewMerchant(w http.ResponseWriter, r *http.Request, status string) {
+	var input model.ReviewReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), ah.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	res, err := ah.service.ReviewMerchantService(r.Context(), username, status, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), ah.zap).Info("Merchant reviewed", zap.String("owner", username), zap.String("status", status))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ListDriversHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.ListDriversService(r.Context(), listFilter(r))
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) GetDriverHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.GetDriverService(r.Context(), mux.Vars(r)["username"])
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ApproveDriverHandler(w http.ResponseWriter, r *http.Request) {
+	ah.reviewDriver(w, r, model.ReviewStatusApproved)
+}
+
+func (ah *AdminHandler) RejectDriverHandler(w http.ResponseWriter, r *http.Request) {
+	ah.reviewDriver(w, r, model.ReviewStatusRejected)
+}
+
+func (ah *AdminHandler) reviewDriver(w http.ResponseWriter, r *http.Request, status string) {
+	var input model.ReviewReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), ah.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	res, err := ah.service.ReviewDriverService(r.Context(), username, status, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), ah.zap).Info("Driver reviewed", zap.String("username", username), zap.String("status", status))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) AdjustBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.WalletAdjustReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), ah.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	res, err := ah.service.AdjustBalanceService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), ah.zap).Info("Balance adjusted", zap.String("username", username), zap.Int64("amount", input.Amount))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ListDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	filter := model.DisputeFilter{Status: query.Get("status"), Limit: limit, Offset: offset}
+	res, err := ah.service.ListDisputesService(r.Context(), &filter)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdminHandler) ResolveDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	disputeID, err := uuid.Parse(mux.Vars(r)["dispute_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.ResolveDisputeReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), ah.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := ah.service.ResolveDisputeService(r.Context(), disputeID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), ah.zap).Info("Dispute resolved", zap.String("dispute_id", disputeID.String()), zap.Int64("refund", input.Refund))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func listFilter(r *http.Request) *model.ListFilter {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	return &model.ListFilter{
+		Query:  query.Get("q"),
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+		Limit:  limit,
+		Offset: offset,
+	}
+}
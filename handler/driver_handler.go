@@ -1,13 +1,14 @@
 package handler
 
 import (
-	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
-	"time"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/service"
 	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
@@ -16,8 +17,14 @@ type DriverHandlerImpl interface {
 	CreateDriverHandler(w http.ResponseWriter, r *http.Request)
 	GetDriverHandler(w http.ResponseWriter, r *http.Request)
 	UpdateDriverHandler(w http.ResponseWriter, r *http.Request)
+	GetDriverApplicationHandler(w http.ResponseWriter, r *http.Request)
+	UploadDriverDocumentHandler(w http.ResponseWriter, r *http.Request)
+	ListDriverDocumentsHandler(w http.ResponseWriter, r *http.Request)
+	DownloadDriverDocumentHandler(w http.ResponseWriter, r *http.Request)
 }
 
+const maxDocumentSize = 10 << 20
+
 type DriverHandler struct {
 	service service.DriverServiceImpl
 	zap     *zap.Logger
@@ -32,51 +39,109 @@ func NewDriverHandler(service service.DriverServiceImpl, zap *zap.Logger) *Drive
 
 func (dh *DriverHandler) CreateDriverHandler(w http.ResponseWriter, r *http.Request) {
 	var input model.Driver
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		dh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), dh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	if err := dh.service.CreateDriverService(r.Context(), &input); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	driver, err := dh.service.CreateDriverService(r.Context(), &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	dh.zap.Info("Driver created", zap.String("name", input.Name))
-	utils.JSONResponse(w, http.StatusCreated, map[string]string{
-		"name":    input.Name,
-		"license": input.License,
-		"area":    input.Area,
-		"created": time.Now().String(),
-	})
+	utils.Logger(r.Context(), dh.zap).Info("Driver created", zap.String("name", driver.Name))
+	utils.JSONResponse(w, http.StatusCreated, model.NewDriverRes(driver, model.OwnerView))
 }
 
 func (dh *DriverHandler) GetDriverHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
-	res, err := dh.service.GetDriverService(r.Context(), username)
+	driver, err := dh.service.GetDriverService(r.Context(), username)
 	if err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+		utils.ErrorResponse(w, r, err)
+		return
 	}
-	dh.zap.Info("user fetched", zap.String("username", username))
-	utils.JSONResponse(w, http.StatusOK, res)
+	utils.Logger(r.Context(), dh.zap).Info("user fetched", zap.String("username", username))
+	utils.JSONResponse(w, http.StatusOK, model.NewDriverRes(driver, model.ViewFor(r.Context(), username)))
 }
 
 func (dh *DriverHandler) UpdateDriverHandler(w http.ResponseWriter, r *http.Request) {
-	var input model.Driver
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		dh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	var input model.UpdateDriverReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), dh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	if err := dh.service.UpdateDriverService(r.Context(), &input); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	username := mux.Vars(r)["username"]
+	driver, err := dh.service.UpdateDriverService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	dh.zap.Info("Driver updated", zap.String("Driver", input.Name))
-	utils.JSONResponse(w, http.StatusOK, &input)
+	utils.Logger(r.Context(), dh.zap).Info("Driver updated", zap.String("Driver", username))
+	utils.JSONResponse(w, http.StatusOK, model.NewDriverRes(driver, model.OwnerView))
+}
+
+func (dh *DriverHandler) GetDriverApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	res, err := dh.service.GetDriverApplicationService(r.Context(), username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (dh *DriverHandler) UploadDriverDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.Logger(r.Context(), dh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	defer file.Close()
+	input := model.DriverDocumentUpload{
+		Kind:     r.FormValue("kind"),
+		FileName: header.Filename,
+		Body:     file,
+	}
+	username := mux.Vars(r)["username"]
+	res, err := dh.service.UploadDriverDocumentService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
+
+func (dh *DriverHandler) ListDriverDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	res, err := dh.service.ListDriverDocumentsService(r.Context(), username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (dh *DriverHandler) DownloadDriverDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	documentID, err := uuid.Parse(vars["document_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	doc, body, err := dh.service.OpenDriverDocumentService(r.Context(), vars["username"], documentID)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, body); err != nil {
+		utils.Logger(r.Context(), dh.zap).Error("failed to stream driver document", zap.Error(err))
+	}
 }
@@ -1,16 +1,22 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/service"
 	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
+type MenuHandlerImpl interface {
+	CreateMenuHandler(w http.ResponseWriter, r *http.Request)
+	GetMenuHandler(w http.ResponseWriter, r *http.Request)
+	UpdateMenuHandler(w http.ResponseWriter, r *http.Request)
+	DeleteMenuHandler(w http.ResponseWriter, r *http.Request)
+}
 type MenuHandler struct {
 	service service.MenuServiceImpl
 	zap     *zap.Logger
@@ -25,19 +31,69 @@ func NewMenuHandler(service service.MenuServiceImpl, zap *zap.Logger) *MenuHandl
 
 func (mh *MenuHandler) CreateMenuHandler(w http.ResponseWriter, r *http.Request) {
 	var input model.Menu
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		mh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), mh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
 	vars := mux.Vars(r)
 	username := vars["username"]
-	if err := mh.service.CreateMenuService(r.Context(), &input, username); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	menu, err := mh.service.CreateMenuService(r.Context(), &input, username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), mh.zap).Info("menu created", zap.String("menu_id", menu.MenuID.String()))
+	utils.JSONResponse(w, http.StatusCreated, model.NewMenuRes(menu))
+}
+
+func (mh *MenuHandler) GetMenuHandler(w http.ResponseWriter, r *http.Request) {
+	menuID, err := uuid.Parse(mux.Vars(r)["menu_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	res, err := mh.service.GetMenuService(r.Context(), menuID)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (mh *MenuHandler) UpdateMenuHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	menuID, err := uuid.Parse(vars["menu_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.UpdateMenuReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), mh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := mh.service.UpdateMenuService(r.Context(), vars["username"], menuID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), mh.zap).Info("menu updated", zap.String("menu_id", menuID.String()))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (mh *MenuHandler) DeleteMenuHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	menuID, err := uuid.Parse(vars["menu_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	if err := mh.service.DeleteMenuService(r.Context(), vars["username"], menuID); err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	mh.zap.Info("menu created", zap.Any("menu", &input))
-	utils.JSONResponse(w, http.StatusCreated, &input)
+	utils.Logger(r.Context(), mh.zap).Info("menu deleted", zap.String("menu_id", menuID.String()))
+	utils.JSONResponse(w, http.StatusOK, &model.MenuDeletedRes{MenuID: menuID})
 }
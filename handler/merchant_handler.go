@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/bagasadiii/gofood-clone/model"
@@ -15,6 +14,9 @@ type MerchantHandlerImpl interface {
 	CreateMerchantHandler(w http.ResponseWriter, r *http.Request)
 	UpdateMerchantHandler(w http.ResponseWriter, r *http.Request)
 	GetMerchantHandler(w http.ResponseWriter, r *http.Request)
+	GetMerchantApplicationHandler(w http.ResponseWriter, r *http.Request)
+	GetScheduleHandler(w http.ResponseWriter, r *http.Request)
+	SetScheduleHandler(w http.ResponseWriter, r *http.Request)
 }
 type MerchantHandler struct {
 	service service.MerchantServiceImpl
@@ -30,52 +32,81 @@ func NewMerchantHandler(service service.MerchantServiceImpl, zap *zap.Logger) *M
 
 func (mh *MerchantHandler) CreateMerchantHandler(w http.ResponseWriter, r *http.Request) {
 	var input model.Merchant
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		mh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), mh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	if err := mh.service.CreateMerchantService(r.Context(), &input); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	merchant, err := mh.service.CreateMerchantService(r.Context(), &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	mh.zap.Info("Merchant Created", zap.String("merchant", input.Name))
-	utils.JSONResponse(w, http.StatusOK, map[string]string{
-		"name":        input.Name,
-		"address":     input.Address,
-		"category":    input.Category,
-		"description": input.Description,
-	})
+	utils.Logger(r.Context(), mh.zap).Info("Merchant Created", zap.String("merchant", merchant.Owner))
+	utils.JSONResponse(w, http.StatusOK, model.NewMerchantRes(merchant, model.OwnerView))
 }
 
 func (mh *MerchantHandler) GetMerchantHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
-	res, err := mh.service.GetMerchantService(r.Context(), username)
+	merchant, err := mh.service.GetMerchantService(r.Context(), username)
 	if err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	mh.zap.Info("User fetched", zap.String("Merchant", res.Name))
-	utils.JSONResponse(w, http.StatusOK, res)
+	utils.Logger(r.Context(), mh.zap).Info("User fetched", zap.String("Merchant", merchant.Name))
+	utils.JSONResponse(w, http.StatusOK, model.NewMerchantRes(merchant, model.ViewFor(r.Context(), username)))
 }
 
 func (mh *MerchantHandler) UpdateMerchantHandler(w http.ResponseWriter, r *http.Request) {
-	var input model.Merchant
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		mh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	var input model.UpdateMerchantReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), mh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	merchant, err := mh.service.UpdateMerchantService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), mh.zap).Info("Merchant updated", zap.String("merchant", username))
+	utils.JSONResponse(w, http.StatusOK, model.NewMerchantRes(merchant, model.OwnerView))
+}
+
+func (mh *MerchantHandler) GetMerchantApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	res, err := mh.service.GetMerchantApplicationService(r.Context(), username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (mh *MerchantHandler) GetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	res, err := mh.service.GetMerchantScheduleService(r.Context(), username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (mh *MerchantHandler) SetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.MerchantSchedule
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), mh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	if err := mh.service.UpdateMerchantService(r.Context(), &input); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	username := mux.Vars(r)["username"]
+	res, err := mh.service.SetMerchantScheduleService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	mh.zap.Info("Merchant updated", zap.String("merchant", input.Name))
-	utils.JSONResponse(w, http.StatusOK, &input)
+	utils.JSONResponse(w, http.StatusOK, res)
 }
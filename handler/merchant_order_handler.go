@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type MerchantOrderHandlerImpl interface {
+	ListOrdersHandler(w http.ResponseWriter, r *http.Request)
+	AcceptOrderHandler(w http.ResponseWriter, r *http.Request)
+	RejectOrderHandler(w http.ResponseWriter, r *http.Request)
+	MarkOrderReadyHandler(w http.ResponseWriter, r *http.Request)
+	MarkItemUnavailableHandler(w http.ResponseWriter, r *http.Request)
+	CancelOrderHandler(w http.ResponseWriter, r *http.Request)
+}
+type MerchantOrderHandler struct {
+	service service.MerchantOrderServiceImpl
+	zap     *zap.Logger
+}
+
+func NewMerchantOrderHandler(service service.MerchantOrderServiceImpl, zap *zap.Logger) *MerchantOrderHandler {
+	return &MerchantOrderHandler{
+		service: service,
+		zap:     zap,
+	}
+}
+
+// ListOrdersHandler takes the statuses to show as a comma-separated status
+// query parameter, e.g. ?status=placed,accepted.
+func (moh *MerchantOrderHandler) ListOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	filter := model.OrderFilter{Limit: limit, Offset: offset}
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	res, err := moh.service.ListMerchantOrdersService(r.Context(), mux.Vars(r)["username"], &filter)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (moh *MerchantOrderHandler) AcceptOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.AcceptOrderReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), moh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := moh.service.AcceptOrderService(r.Context(), mux.Vars(r)["username"], orderID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (moh *MerchantOrderHandler) RejectOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.RejectOrderReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), moh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := moh.service.RejectOrderService(r.Context(), mux.Vars(r)["username"], orderID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (moh *MerchantOrderHandler) MarkOrderReadyHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	res, err := moh.service.MarkOrderReadyService(r.Context(), mux.Vars(r)["username"], orderID)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (moh *MerchantOrderHandler) MarkItemUnavailableHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID, err := uuid.Parse(vars["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	itemID, err := uuid.Parse(vars["order_item_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.ItemUnavailableReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), moh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := moh.service.MarkItemUnavailableService(r.Context(), vars["username"], orderID, itemID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (moh *MerchantOrderHandler) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.MerchantCancelReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), moh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := moh.service.CancelOrderService(r.Context(), mux.Vars(r)["username"], orderID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
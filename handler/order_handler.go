@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type OrderHandlerImpl interface {
+	CreateOrderHandler(w http.ResponseWriter, r *http.Request)
+	GetOrderHandler(w http.ResponseWriter, r *http.Request)
+	RespondSubstitutionHandler(w http.ResponseWriter, r *http.Request)
+	CancelOrderHandler(w http.ResponseWriter, r *http.Request)
+	DriverCancelOrderHandler(w http.ResponseWriter, r *http.Request)
+	OpenDisputeHandler(w http.ResponseWriter, r *http.Request)
+}
+type OrderHandler struct {
+	service service.OrderServiceImpl
+	zap     *zap.Logger
+}
+
+func NewOrderHandler(service service.OrderServiceImpl, zap *zap.Logger) *OrderHandler {
+	return &OrderHandler{
+		service: service,
+		zap:     zap,
+	}
+}
+
+func (oh *OrderHandler) CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.CreateOrderReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), oh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	order, err := oh.service.CreateOrderService(r.Context(), &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, order)
+}
+
+func (oh *OrderHandler) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	res, err := oh.service.GetOrderService(r.Context(), orderID)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (oh *OrderHandler) RespondSubstitutionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID, err := uuid.Parse(vars["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	itemID, err := uuid.Parse(vars["order_item_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.SubstitutionReply
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), oh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := oh.service.RespondSubstitutionService(r.Context(), orderID, itemID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (oh *OrderHandler) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	res, err := oh.service.CancelOrderService(r.Context(), orderID)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (oh *OrderHandler) DriverCancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID, err := uuid.Parse(vars["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.DriverCancelReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), oh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := oh.service.DriverCancelOrderService(r.Context(), vars["username"], orderID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (oh *OrderHandler) OpenDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(mux.Vars(r)["order_id"])
+	if err != nil {
+		utils.ErrorResponse(w, r, utils.ErrBadRequest)
+		return
+	}
+	var input model.OpenDisputeReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), oh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := oh.service.OpenDisputeService(r.Context(), orderID, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"go.uber.org/zap"
+)
+
+type TwoFactorHandlerImpl interface {
+	SetupTOTPHandler(w http.ResponseWriter, r *http.Request)
+	EnableTOTPHandler(w http.ResponseWriter, r *http.Request)
+	DisableTOTPHandler(w http.ResponseWriter, r *http.Request)
+	VerifyLoginHandler(w http.ResponseWriter, r *http.Request)
+}
+type TwoFactorHandler struct {
+	service service.TwoFactorServiceImpl
+	zap     *zap.Logger
+}
+
+func NewTwoFactorHandler(service service.TwoFactorServiceImpl, zap *zap.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		service: service,
+		zap:     zap,
+	}
+}
+
+func (th *TwoFactorHandler) SetupTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := th.service.SetupTOTPService(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), th.zap).Info("TOTP setup started")
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (th *TwoFactorHandler) EnableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.TOTPCodeReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), th.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := th.service.EnableTOTPService(r.Context(), &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (th *TwoFactorHandler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.TOTPCodeReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), th.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	if err := th.service.DisableTOTPService(r.Context(), &input); err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, &model.TOTPStatusRes{TOTPEnabled: false})
+}
+
+func (th *TwoFactorHandler) VerifyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.TOTPLoginReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), th.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	res, err := th.service.VerifyLoginService(r.Context(), &input, utils.ClientIP(r))
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), th.zap).Info(http.StatusText(http.StatusOK), zap.String("User logged in", res.Username))
+	utils.JSONResponse(w, http.StatusOK, res)
+}
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/bagasadiii/gofood-clone/model"
@@ -15,6 +14,9 @@ type UserHandlerImpl interface {
 	RegisterHandler(w http.ResponseWriter, r *http.Request)
 	LoginHandler(w http.ResponseWriter, r *http.Request)
 	GetUserHandler(w http.ResponseWriter, r *http.Request)
+	UpdateUserHandler(w http.ResponseWriter, r *http.Request)
+	DeleteUserHandler(w http.ResponseWriter, r *http.Request)
+	ExportUserHandler(w http.ResponseWriter, r *http.Request)
 }
 type UserHandler struct {
 	service service.UserServiceImpl
@@ -30,68 +32,96 @@ func NewUserHandler(service service.UserServiceImpl, zap *zap.Logger) *UserHandl
 
 func (uh *UserHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		uh.zap.Error(http.StatusText(http.StatusMethodNotAllowed))
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, nil)
+		utils.Logger(r.Context(), uh.zap).Error(http.StatusText(http.StatusMethodNotAllowed))
+		utils.ErrorResponse(w, r, utils.ErrMethodNotAllowed)
 		return
 	}
 	var input model.RegisterReq
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		uh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), uh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	if err := uh.service.RegisterService(r.Context(), &input); err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+	user, err := uh.service.RegisterService(r.Context(), &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	uh.zap.Info(http.StatusText(http.StatusCreated), zap.String("user_registered", input.Username))
-	utils.JSONResponse(w, http.StatusCreated, map[string]string{
-		"username": input.Username,
-		"email":    input.Email,
-		"role":     input.Role,
-		"Phone":    input.Phone,
-		"name":     input.Username,
-	})
+	utils.Logger(r.Context(), uh.zap).Info(http.StatusText(http.StatusCreated), zap.String("user_registered", user.Username))
+	utils.JSONResponse(w, http.StatusCreated, model.NewUserResp(user, model.OwnerView))
 }
 
 func (uh *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		uh.zap.Error(http.StatusText(http.StatusMethodNotAllowed))
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, nil)
+		utils.Logger(r.Context(), uh.zap).Error(http.StatusText(http.StatusMethodNotAllowed))
+		utils.ErrorResponse(w, r, utils.ErrMethodNotAllowed)
 		return
 	}
 	var input model.LoginReq
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		uh.zap.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), uh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	token, err := uh.service.LoginService(r.Context(), &input)
+	resp, err := uh.service.LoginService(r.Context(), &input, utils.ClientIP(r))
 	if err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	resp := map[string]string{
-		"username": input.Username,
-		"token":    token,
-	}
-	uh.zap.Info(http.StatusText(http.StatusOK), zap.String("User logged in", input.Username))
+	utils.Logger(r.Context(), uh.zap).Info(http.StatusText(http.StatusOK), zap.String("User logged in", input.Username))
 	utils.JSONResponse(w, http.StatusOK, resp)
 }
 
 func (uh *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
-	resp, err := uh.service.GetUserService(r.Context(), username)
+	user, err := uh.service.GetUserService(r.Context(), username)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), uh.zap).Info("User fetched", zap.String("Username", username))
+	utils.JSONResponse(w, http.StatusOK, model.NewUserResp(user, model.ViewFor(r.Context(), username)))
+}
+
+func (uh *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input model.UpdateUserReq
+	if err := utils.DecodeJSON(r, &input); err != nil {
+		utils.Logger(r.Context(), uh.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	vars := mux.Vars(r)
+	username := vars["username"]
+	user, err := uh.service.UpdateUserService(r.Context(), username, &input)
+	if err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), uh.zap).Info("User updated", zap.String("Username", username))
+	utils.JSONResponse(w, http.StatusOK, model.NewUserResp(user, model.OwnerView))
+}
+
+func (uh *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	username := vars["username"]
+	if err := uh.service.DeleteUserService(r.Context(), username); err != nil {
+		utils.ErrorResponse(w, r, err)
+		return
+	}
+	utils.Logger(r.Context(), uh.zap).Info("User deleted", zap.String("Username", username))
+	utils.JSONResponse(w, http.StatusOK, &model.UserDeletedRes{Username: username})
+}
+
+func (uh *UserHandler) ExportUserHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	username := vars["username"]
+	resp, err := uh.service.ExportUserService(r.Context(), username)
 	if err != nil {
-		status, errIs := utils.ErrCheck(err)
-		utils.JSONResponse(w, status, errIs)
+		utils.ErrorResponse(w, r, err)
 		return
 	}
-	uh.zap.Info("User fetched", zap.String("Username", username))
+	utils.Logger(r.Context(), uh.zap).Info("User exported", zap.String("Username", username))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+username+`-export.json"`)
 	utils.JSONResponse(w, http.StatusOK, resp)
 }
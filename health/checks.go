@@ -0,0 +1,30 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/gofood-clone/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database pings the pool.
+func Database(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return db.Ping(ctx)
+	}
+}
+
+// Migrations fails until the schema is at the version this binary expects.
+func Migrations(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		current, err := migrations.Current(ctx, db)
+		if err != nil {
+			return err
+		}
+		if latest := migrations.Latest(); current < latest {
+			return fmt.Errorf("schema at version %d, want %d", current, latest)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,145 @@
+// Package health serves the liveness and readiness endpoints.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check reports an error when a dependency is not usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name    string
+	check   Check
+	timeout time.Duration
+}
+
+// Checker runs the registered readiness checks. It reports not ready once
+// Drain is called, so load balancers stop routing before shutdown.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+	zap      *zap.Logger
+}
+
+func NewChecker(zap *zap.Logger) *Checker {
+	return &Checker{zap: zap}
+}
+
+// Register adds a readiness check. A zero timeout uses the default of two
+// seconds.
+func (c *Checker) Register(name string, timeout time.Duration, check Check) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check, timeout: timeout})
+}
+
+// Drain marks the process as shutting down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+type Report struct {
+	Status   string                 `json:"status"`
+	Draining bool                   `json:"draining,omitempty"`
+	Checks   map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Run executes every check concurrently, each under its own timeout.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, nc.timeout)
+			defer cancel()
+			start := time.Now()
+			err := nc.check(checkCtx)
+			result := CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[nc.name] = result
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusDown
+		report.Draining = true
+	}
+	return report
+}
+
+// LivenessHandler reports that the process is up and serving HTTP.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, Report{Status: StatusUp})
+}
+
+// ReadinessHandler returns 200 when every check passes and 503 otherwise.
+// It is served publicly, so checks report only up or down; why a check
+// failed is logged and available from DetailHandler.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	for name, result := range report.Checks {
+		if result.Status != StatusUp {
+			utils.Logger(r.Context(), c.zap).Warn("readiness check failed", zap.String("check", name), zap.String("error", result.Error))
+		}
+		report.Checks[name] = CheckResult{Status: result.Status}
+	}
+	writeReport(w, report)
+}
+
+// DetailHandler is ReadinessHandler with each check's error and duration.
+// It belongs on the admin listener only.
+func (c *Checker) DetailHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.Run(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	utils.JSONResponse(w, status, report)
+}
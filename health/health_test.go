@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestReadinessHidesCheckErrors(t *testing.T) {
+	c := NewChecker(zap.NewNop())
+	c.Register("database", 0, func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	})
+	c.Register("migrations", 0, func(ctx context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	c.ReadinessHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("public report leaks the check error: %s", rec.Body)
+	}
+	var res struct {
+		Data Report `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	report := res.Data
+	want := map[string]CheckResult{"database": {Status: StatusDown}, "migrations": {Status: StatusUp}}
+	if len(report.Checks) != len(want) || report.Checks["database"] != want["database"] || report.Checks["migrations"] != want["migrations"] {
+		t.Errorf("checks = %+v, want %+v", report.Checks, want)
+	}
+
+	rec = httptest.NewRecorder()
+	c.DetailHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("detail report = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReadinessDraining(t *testing.T) {
+	c := NewChecker(zap.NewNop())
+	c.Drain()
+	rec := httptest.NewRecorder()
+	c.ReadinessHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"draining":true`) {
+		t.Errorf("draining report = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,55 @@
+// Package idempotency records the response to a request sent with an
+// idempotency key so that a retry of the same request is answered from the
+// record instead of being processed again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Response is a recorded response, replayed verbatim on retries.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Record is the state of a key that was already claimed. Response is nil
+// while the request that claimed it is still being handled.
+type Record struct {
+	RequestHash string
+	Response    *Response
+}
+
+// Store keeps idempotency records per user. Implementations must be safe for
+// concurrent use, and Claim must be atomic so that only one of several
+// concurrent requests with the same key gets to run.
+type Store interface {
+	// Claim reserves key for a request with the given hash until lockedUntil.
+	// It returns nil if the key was free or its record had expired, and the
+	// existing record otherwise.
+	Claim(ctx context.Context, userID uuid.UUID, key, requestHash string, now, lockedUntil time.Time) (*Record, error)
+	// Complete stores the response for a claimed key and keeps it until
+	// expiresAt.
+	Complete(ctx context.Context, userID uuid.UUID, key string, res *Response, expiresAt time.Time) error
+	// Release drops a claim that has no response, so the request can be
+	// retried at once.
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+}
+
+// Hash fingerprints a request by method, path and body. A key reused with a
+// different fingerprint is a client error rather than a retry.
+func Hash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
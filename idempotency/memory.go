@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sweepInterval is how often expired records are dropped from a MemoryStore.
+const sweepInterval = time.Minute
+
+type memoryKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+// MemoryStore keeps records in process memory. A retry is only recognised by
+// the instance that saw the first request, so it suits tests and single
+// instance deployments.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[memoryKey]*memoryRecord
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[memoryKey]*memoryRecord)}
+}
+
+func (ms *MemoryStore) Claim(_ context.Context, userID uuid.UUID, key, requestHash string, now, lockedUntil time.Time) (*Record, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sweep(now)
+	mk := memoryKey{userID: userID, key: key}
+	if rec, ok := ms.records[mk]; ok && rec.expiresAt.After(now) {
+		existing := rec.Record
+		return &existing, nil
+	}
+	ms.records[mk] = &memoryRecord{Record: Record{RequestHash: requestHash}, expiresAt: lockedUntil}
+	return nil, nil
+}
+
+func (ms *MemoryStore) Complete(_ context.Context, userID uuid.UUID, key string, res *Response, expiresAt time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	rec, ok := ms.records[memoryKey{userID: userID, key: key}]
+	if !ok || rec.Response != nil {
+		return nil
+	}
+	stored := *res
+	stored.Body = append([]byte(nil), res.Body...)
+	rec.Response = &stored
+	rec.expiresAt = expiresAt
+	return nil
+}
+
+func (ms *MemoryStore) Release(_ context.Context, userID uuid.UUID, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	mk := memoryKey{userID: userID, key: key}
+	if rec, ok := ms.records[mk]; ok && rec.Response == nil {
+		delete(ms.records, mk)
+	}
+	return nil
+}
+
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.swept) < sweepInterval {
+		return
+	}
+	ms.swept = now
+	for mk, rec := range ms.records {
+		if !rec.expiresAt.After(now) {
+			delete(ms.records, mk)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps records in the idempotency_keys table so that a retry
+// is recognised whichever replica it reaches.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (ps *PostgresStore) Claim(ctx context.Context, userID uuid.UUID, key, requestHash string, now, lockedUntil time.Time) (*Record, error) {
+	// The upsert only takes over a row that has expired, so of two
+	// concurrent requests exactly one gets a row back.
+	var claimed bool
+	err := ps.db.QueryRow(ctx, `
+    INSERT INTO idempotency_keys AS ik (user_id, key, request_hash, created_at, expires_at)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (user_id, key) DO UPDATE SET
+      request_hash = EXCLUDED.request_hash,
+      status = NULL,
+      content_type = NULL,
+      body = NULL,
+      created_at = EXCLUDED.created_at,
+      expires_at = EXCLUDED.expires_at
+    WHERE ik.expires_at <= $4
+    RETURNING TRUE
+    `, userID, key, requestHash, now, lockedUntil).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	} else if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w: %w", utils.ErrDatabase, err)
+	}
+
+	var rec Record
+	var status *int
+	var contentType *string
+	var body []byte
+	err = ps.db.QueryRow(ctx, `
+    SELECT request_hash, status, content_type, body FROM idempotency_keys
+    WHERE user_id = $1 AND key = $2
+    `, userID, key).Scan(&rec.RequestHash, &status, &contentType, &body)
+	if err == pgx.ErrNoRows {
+		// Released between the two statements; the first request failed
+		// and is presumably being retried as well.
+		return &Record{RequestHash: requestHash}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read idempotency key: %w: %w", utils.ErrDatabase, err)
+	}
+	if status != nil {
+		rec.Response = &Response{Status: *status, Body: body}
+		if contentType != nil {
+			rec.Response.ContentType = *contentType
+		}
+	}
+	return &rec, nil
+}
+
+func (ps *PostgresStore) Complete(ctx context.Context, userID uuid.UUID, key string, res *Response, expiresAt time.Time) error {
+	_, err := ps.db.Exec(ctx, `
+    UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5, expires_at = $6
+    WHERE user_id = $1 AND key = $2 AND status IS NULL
+    `, userID, key, res.Status, res.ContentType, res.Body, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record idempotent response: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+func (ps *PostgresStore) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	_, err := ps.db.Exec(ctx, `
+    DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status IS NULL
+    `, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+// PruneJob periodically deletes expired records. Claim already ignores them,
+// so this only keeps the table small.
+type PruneJob struct{}
+
+func (PruneJob) Kind() string { return "prune_idempotency_keys" }
+
+func (ps *PostgresStore) Prune(ctx context.Context, _ PruneJob) error {
+	_, err := ps.db.Exec(ctx, `
+    DELETE FROM idempotency_keys WHERE expires_at < $1
+    `, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to prune idempotency keys: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
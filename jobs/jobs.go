@@ -0,0 +1,72 @@
+// Package jobs runs background work from a queue kept in Postgres. Jobs are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED, so any number of instances
+// can work the same queue without handing a job to two workers. Failed jobs
+// are retried with exponential backoff, jobs can be deduplicated by a unique
+// key, and periodic jobs are enqueued from cron expressions.
+package jobs
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Args is the payload of a job. It is stored as JSON, and Kind names the
+// handler that processes it, so it must stay stable across releases.
+type Args interface {
+	Kind() string
+}
+
+// EnqueueOptions adjusts a single job. The zero value runs the job as soon as
+// a worker is free with the queue's default number of attempts.
+type EnqueueOptions struct {
+	// RunAt delays the job until the given time.
+	RunAt time.Time
+	// UniqueKey deduplicates jobs of the same kind: while one with this key
+	// is queued or running, enqueueing another is a no-op.
+	UniqueKey string
+	// MaxAttempts overrides Config.MaxAttempts.
+	MaxAttempts int
+}
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as not worth retrying; the job fails at
+// once instead of using up its remaining attempts.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+const (
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+)
+
+// backoff returns the delay before retrying after the given number of
+// attempts: 10s doubling up to an hour, with 20% jitter so that jobs which
+// failed together do not retry together.
+func backoff(attempts int) time.Duration {
+	d := backoffMax
+	if attempts < 20 {
+		d = min(backoffBase<<max(attempts-1, 0), backoffMax)
+	}
+	jitter := time.Duration(rand.Int64N(int64(d)/5+1)) - d/10
+	return d + jitter
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type countArgs struct {
+	N int `json:"n"`
+}
+
+func (countArgs) Kind() string { return "count" }
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{5, 160 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{19, time.Hour},
+		// Large counts must not overflow the shift.
+		{64, time.Hour},
+		{1000, time.Hour},
+	}
+	for _, tt := range tests {
+		lo, hi := tt.want-tt.want/10, tt.want+tt.want/10
+		for range 200 {
+			if d := backoff(tt.attempts); d < lo || d > hi {
+				t.Fatalf("backoff(%d) = %s, want within 10%% of %s", tt.attempts, d, tt.want)
+			}
+		}
+	}
+}
+
+func TestHandleDecodesPayload(t *testing.T) {
+	q := NewQueue(nil, Config{}, zap.NewNop())
+	var got []int
+	Handle(q, func(_ context.Context, args countArgs) error {
+		got = append(got, args.N)
+		return nil
+	})
+
+	if err := q.call(context.Background(), &job{kind: "count", payload: []byte(`{"n":3}`)}); err != nil || len(got) != 1 || got[0] != 3 {
+		t.Fatalf("call = %v with handler args %v, want 3", err, got)
+	}
+	// A payload that no longer decodes will not decode on a retry either.
+	err := q.call(context.Background(), &job{kind: "count", payload: []byte(`{"n":"three"}`)})
+	if err == nil || !isPermanent(err) || len(got) != 1 {
+		t.Errorf("bad payload = %v after %d handler calls, want a permanent error without calling it", err, len(got))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("second handler for the same kind did not panic")
+		}
+	}()
+	Handle(q, func(context.Context, countArgs) error { return nil })
+}
+
+func TestPermanent(t *testing.T) {
+	cause := errors.New("merchant deleted")
+	tests := []struct {
+		name      string
+		handler   func(context.Context, countArgs) error
+		kind      string
+		permanent bool
+	}{
+		{"permanent", func(context.Context, countArgs) error { return Permanent(cause) }, "count", true},
+		{"wrapped permanent", func(context.Context, countArgs) error { return fmt.Errorf("notify: %w", Permanent(cause)) }, "count", true},
+		{"plain error", func(context.Context, countArgs) error { return cause }, "count", false},
+		{"panic", func(context.Context, countArgs) error { panic("boom") }, "count", false},
+		// A newer release may know the kind, so it is retried.
+		{"unknown kind", nil, "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(nil, Config{}, zap.NewNop())
+			if tt.handler != nil {
+				Handle(q, tt.handler)
+			}
+			err := q.call(context.Background(), &job{kind: tt.kind, payload: []byte(`{}`)})
+			if err == nil || isPermanent(err) != tt.permanent {
+				t.Fatalf("call = %v, want permanent = %v", err, tt.permanent)
+			}
+			if tt.permanent && !errors.Is(err, cause) {
+				t.Errorf("permanent error = %q, want it to wrap the cause", err)
+			}
+		})
+	}
+}
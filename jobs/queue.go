@@ -0,0 +1,380 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// Config tunes the workers of one instance.
+type Config struct {
+	// Workers is how many jobs run at once.
+	Workers int
+	// PollInterval is how often an idle instance looks for due jobs.
+	PollInterval time.Duration
+	// Timeout bounds a single attempt. A job whose worker has not reported
+	// back shortly after that is assumed lost and handed to another worker.
+	Timeout time.Duration
+	// MaxAttempts is the default number of attempts before a job fails.
+	MaxAttempts int
+	// Retention is how long finished jobs are kept for inspection.
+	Retention time.Duration
+}
+
+// scheduleInterval is how often due periodic jobs are enqueued. Cron
+// expressions have minute resolution, so runs start up to this late.
+const scheduleInterval = 15 * time.Second
+
+// bookkeepingTimeout bounds the queries that record the outcome of a job.
+// They use their own context so a job cancelled by Drain is still released.
+const bookkeepingTimeout = 10 * time.Second
+
+type handlerFunc func(ctx context.Context, payload []byte) error
+
+type schedule struct {
+	spec     string
+	schedule cron.Schedule
+	args     Args
+}
+
+// Queue enqueues jobs and, once started, runs them. Handlers and schedules
+// must be registered before Start.
+type Queue struct {
+	store     *store
+	cfg       Config
+	zap       *zap.Logger
+	handlers  map[string]handlerFunc
+	schedules map[string]schedule
+
+	stop      chan struct{}
+	stopOnce  sync.Once
+	loops     sync.WaitGroup
+	running   sync.WaitGroup
+	jobCtx    context.Context
+	cancel    context.CancelFunc
+	started   atomic.Bool
+	draining  atomic.Bool
+	lastPoll  atomic.Int64
+	lastPrune time.Time
+}
+
+func NewQueue(db *pgxpool.Pool, cfg Config, zap *zap.Logger) *Queue {
+	jobCtx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		store:     &store{db: db},
+		cfg:       cfg,
+		zap:       zap,
+		handlers:  make(map[string]handlerFunc),
+		schedules: make(map[string]schedule),
+		stop:      make(chan struct{}),
+		jobCtx:    jobCtx,
+		cancel:    cancel,
+	}
+}
+
+// Handle registers fn as the handler for jobs of kind T. It panics if the
+// kind already has a handler.
+func Handle[T Args](q *Queue, fn func(ctx context.Context, args T) error) {
+	var zero T
+	kind := zero.Kind()
+	if _, ok := q.handlers[kind]; ok {
+		panic(fmt.Sprintf("jobs: handler for %q registered twice", kind))
+	}
+	q.handlers[kind] = func(ctx context.Context, payload []byte) error {
+		var args T
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return Permanent(fmt.Errorf("failed to decode %s payload: %w", kind, err))
+		}
+		return fn(ctx, args)
+	}
+}
+
+// Schedule enqueues args on the standard five-field cron spec (descriptors
+// such as @hourly are accepted). However many instances run, each slot is
+// enqueued once, and a run is skipped while the previous one is still queued
+// or running.
+func (q *Queue) Schedule(spec string, args Args) error {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for %s: %w", spec, args.Kind(), err)
+	}
+	q.schedules[args.Kind()] = schedule{spec: spec, schedule: sched, args: args}
+	return nil
+}
+
+// Enqueue adds a job. It reports false when opts.UniqueKey matched a job
+// that is already queued or running.
+func (q *Queue) Enqueue(ctx context.Context, args Args, opts *EnqueueOptions) (bool, error) {
+	job, err := q.newJob(args, opts, time.Now())
+	if err != nil {
+		return false, err
+	}
+	inserted, err := q.store.insert(ctx, q.store.db, job)
+	if err != nil {
+		utils.Logger(ctx, q.zap).Error("Failed to enqueue job", zap.String("kind", job.kind), zap.Error(err))
+		return false, err
+	}
+	return inserted, nil
+}
+
+func (q *Queue) newJob(args Args, opts *EnqueueOptions, now time.Time) (*job, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", args.Kind(), err)
+	}
+	if opts == nil {
+		opts = &EnqueueOptions{}
+	}
+	j := &job{
+		kind:        args.Kind(),
+		payload:     payload,
+		uniqueKey:   opts.UniqueKey,
+		maxAttempts: q.cfg.MaxAttempts,
+		runAt:       now,
+	}
+	if opts.MaxAttempts > 0 {
+		j.maxAttempts = opts.MaxAttempts
+	}
+	if opts.RunAt.After(now) {
+		j.runAt = opts.RunAt
+	}
+	return j, nil
+}
+
+// Start launches the workers and the scheduler. It returns at once.
+func (q *Queue) Start() {
+	if !q.started.CompareAndSwap(false, true) {
+		return
+	}
+	q.lastPoll.Store(time.Now().UnixNano())
+	q.loops.Add(2)
+	go q.work()
+	go q.scheduleLoop()
+	q.zap.Info("Job workers started", zap.Int("workers", q.cfg.Workers))
+}
+
+// Drain stops claiming jobs and waits for the running ones. Jobs still
+// running when ctx is done are cancelled and put back on the queue without
+// counting the attempt, so another instance picks them up.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.draining.Store(true)
+	q.stopOnce.Do(func() { close(q.stop) })
+	q.loops.Wait()
+
+	finished := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-finished
+		return fmt.Errorf("jobs cancelled before finishing: %w", ctx.Err())
+	}
+}
+
+// Check fails readiness when the workers have not polled the queue for a
+// while, which means the database is unreachable or the loop is stuck. An
+// instance whose workers are all busy has nothing to poll for and counts as
+// healthy.
+func (q *Queue) Check(ctx context.Context) error {
+	if !q.started.Load() || q.draining.Load() {
+		return nil
+	}
+	last := time.Unix(0, q.lastPoll.Load())
+	if since := time.Since(last); since > 3*q.cfg.PollInterval+bookkeepingTimeout {
+		return fmt.Errorf("job queue last polled %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// work claims due jobs whenever a worker is free and runs each in its own
+// goroutine.
+func (q *Queue) work() {
+	defer q.loops.Done()
+	slots := make(chan struct{}, q.cfg.Workers)
+	freed := make(chan struct{}, q.cfg.Workers)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-timer.C:
+		case <-freed:
+		}
+
+		free := cap(slots) - len(slots)
+		claimed := 0
+		if free > 0 {
+			jobs, err := q.claim(free)
+			if err != nil {
+				q.zap.Error("Failed to claim jobs", zap.Error(err))
+			}
+			for _, j := range jobs {
+				slots <- struct{}{}
+				q.running.Add(1)
+				go func() {
+					defer q.running.Done()
+					q.run(j)
+					<-slots
+					select {
+					case freed <- struct{}{}:
+					default:
+					}
+				}()
+			}
+			claimed = len(jobs)
+		} else {
+			// Every worker is busy, possibly for as long as the job
+			// timeout; the loop itself is alive.
+			q.lastPoll.Store(time.Now().UnixNano())
+		}
+
+		// A full batch suggests more jobs are due, so look again as soon as
+		// a worker frees up instead of waiting for the next poll.
+		next := q.cfg.PollInterval
+		if free > 0 && claimed == free {
+			next = 0
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+func (q *Queue) claim(limit int) ([]*job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bookkeepingTimeout)
+	defer cancel()
+	now := time.Now()
+	jobs, err := q.store.claim(ctx, now, now.Add(q.cfg.Timeout+bookkeepingTimeout), limit)
+	if err != nil {
+		return nil, err
+	}
+	q.lastPoll.Store(now.UnixNano())
+	return jobs, nil
+}
+
+func (q *Queue) run(j *job) {
+	log := q.zap.With(zap.String("job_id", j.id.String()), zap.String("kind", j.kind), zap.Int("attempt", j.attempts))
+	ctx, cancel := context.WithTimeout(q.jobCtx, q.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := q.call(ctx, j)
+	log = log.With(zap.Duration("duration", time.Since(start)))
+
+	bctx, bcancel := context.WithTimeout(context.Background(), bookkeepingTimeout)
+	defer bcancel()
+	var outcome string
+	var dbErr error
+	switch {
+	case err == nil:
+		outcome = "done"
+		dbErr = q.store.complete(bctx, j, time.Now())
+		log.Info("Job done")
+	case q.draining.Load() && errors.Is(q.jobCtx.Err(), context.Canceled):
+		outcome = "released"
+		dbErr = q.store.release(bctx, j)
+		log.Warn("Job cancelled by shutdown, released", zap.Error(err))
+	case isPermanent(err) || j.attempts >= j.maxAttempts:
+		outcome = "failed"
+		dbErr = q.store.fail(bctx, j, err, time.Now())
+		log.Error("Job failed", zap.Error(err))
+	default:
+		outcome = "retry"
+		retryAt := time.Now().Add(backoff(j.attempts))
+		dbErr = q.store.retry(bctx, j, err, retryAt)
+		log.Warn("Job errored, will retry", zap.Time("retry_at", retryAt), zap.Error(err))
+	}
+	if dbErr != nil {
+		// The lease runs out and the job is claimed again.
+		log.Error("Failed to record job outcome", zap.String("outcome", outcome), zap.Error(dbErr))
+	}
+	metrics.JobsProcessed.WithLabelValues(j.kind, outcome).Inc()
+}
+
+// call runs the handler, turning a panic into an error so a bad job cannot
+// take the process down.
+func (q *Queue) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	h, ok := q.handlers[j.kind]
+	if !ok {
+		// Possibly enqueued by a newer release during a rolling deploy, so
+		// retry rather than fail at once.
+		return fmt.Errorf("no handler registered for %q", j.kind)
+	}
+	return h(ctx, j.payload)
+}
+
+func (q *Queue) scheduleLoop() {
+	defer q.loops.Done()
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		q.tick(time.Now())
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick enqueues the periodic jobs that are due and, once an hour, deletes
+// finished jobs past the retention period.
+func (q *Queue) tick(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), bookkeepingTimeout)
+	defer cancel()
+	for name, s := range q.schedules {
+		j, err := q.newJob(s.args, &EnqueueOptions{UniqueKey: "schedule"}, now)
+		if err != nil {
+			q.zap.Error("Failed to build scheduled job", zap.String("schedule", name), zap.Error(err))
+			continue
+		}
+		enqueued, err := q.store.fire(ctx, name, s.spec, s.schedule, j, now)
+		if err != nil {
+			q.zap.Error("Failed to enqueue scheduled job", zap.String("schedule", name), zap.Error(err))
+			continue
+		}
+		if enqueued {
+			q.zap.Info("Scheduled job enqueued", zap.String("schedule", name))
+		}
+	}
+
+	if now.Sub(q.lastPrune) < time.Hour {
+		return
+	}
+	q.lastPrune = now
+	n, err := q.store.prune(ctx, now.Add(-q.cfg.Retention))
+	if err != nil {
+		q.zap.Error("Failed to prune finished jobs", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		q.zap.Info("Pruned finished jobs", zap.Int64("count", n))
+	}
+}
@@ -0,0 +1,333 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/repository/repotest"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	repotest.Main(m)
+}
+
+var testConfig = Config{
+	Workers:      2,
+	PollInterval: 10 * time.Millisecond,
+	Timeout:      time.Minute,
+	MaxAttempts:  5,
+	Retention:    time.Hour,
+}
+
+type jobRow struct {
+	status    string
+	attempts  int
+	lastError string
+	runAt     time.Time
+}
+
+func readJob(t *testing.T, db *pgxpool.Pool, id uuid.UUID) jobRow {
+	t.Helper()
+	var row jobRow
+	err := db.QueryRow(context.Background(), `
+    SELECT status, attempts, COALESCE(last_error, ''), run_at FROM jobs WHERE job_id = $1
+    `, id).Scan(&row.status, &row.attempts, &row.lastError, &row.runAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+// onlyJob returns the id of the single job of kind.
+func onlyJob(t *testing.T, db *pgxpool.Pool, kind string) uuid.UUID {
+	t.Helper()
+	var id uuid.UUID
+	if err := db.QueryRow(context.Background(), `SELECT job_id FROM jobs WHERE kind = $1`, kind).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// waitFor polls cond until it holds or a few seconds have passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClaimSkipsLockedJobs(t *testing.T) {
+	db := repotest.Postgres(t)
+	queues := []*Queue{NewQueue(db, testConfig, zap.NewNop()), NewQueue(db, testConfig, zap.NewNop())}
+	const total = 40
+	for i := range total {
+		if _, err := queues[0].Enqueue(context.Background(), countArgs{N: i}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both queues claim small batches at once until the table is drained;
+	// no job may be handed out twice.
+	var mu sync.Mutex
+	claimed := map[uuid.UUID]int{}
+	var wg sync.WaitGroup
+	for _, q := range queues {
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					jobs, err := q.claim(3)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if len(jobs) == 0 {
+						return
+					}
+					mu.Lock()
+					for _, j := range jobs {
+						claimed[j.id]++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(claimed) != total {
+		t.Errorf("claimed %d distinct jobs, want %d", len(claimed), total)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %s claimed %d times", id, n)
+		}
+	}
+}
+
+func TestClaimAfterLeaseExpires(t *testing.T) {
+	db := repotest.Postgres(t)
+	q := NewQueue(db, testConfig, zap.NewNop())
+	if _, err := q.Enqueue(context.Background(), countArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	first, err := q.store.claim(ctx, now, now.Add(time.Minute), 10)
+	if err != nil || len(first) != 1 || first[0].attempts != 1 {
+		t.Fatalf("first claim = %v, %v", first, err)
+	}
+	// While the lease holds nobody else gets the job.
+	if again, err := q.store.claim(ctx, now.Add(30*time.Second), now.Add(time.Minute), 10); err != nil || len(again) != 0 {
+		t.Fatalf("claim during the lease = %v, %v", again, err)
+	}
+	// Once it runs out, the job is assumed lost and handed out again.
+	second, err := q.store.claim(ctx, now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+	if err != nil || len(second) != 1 || second[0].id != first[0].id || second[0].attempts != 2 {
+		t.Fatalf("claim after the lease = %v, %v", second, err)
+	}
+
+	// The worker that lost the lease cannot record its outcome over the
+	// attempt that took over.
+	if err := q.store.complete(ctx, first[0], now); err != nil {
+		t.Fatal(err)
+	}
+	if row := readJob(t, db, first[0].id); row.status != StatusRunning || row.attempts != 2 {
+		t.Errorf("after a stale completion = %+v, want still running", row)
+	}
+	if err := q.store.complete(ctx, second[0], now); err != nil {
+		t.Fatal(err)
+	}
+	if row := readJob(t, db, first[0].id); row.status != StatusDone {
+		t.Errorf("after completion = %+v, want done", row)
+	}
+}
+
+func TestRunOutcomes(t *testing.T) {
+	db := repotest.Postgres(t)
+	q := NewQueue(db, testConfig, zap.NewNop())
+	Handle(q, func(_ context.Context, args countArgs) error {
+		switch args.N {
+		case 1:
+			return errors.New("temporarily down")
+		case 2:
+			return Permanent(errors.New("merchant deleted"))
+		}
+		return nil
+	})
+	ctx := context.Background()
+	for n, key := range []string{"ok", "retry", "permanent"} {
+		if _, err := q.Enqueue(ctx, countArgs{N: n}, &EnqueueOptions{UniqueKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A payload the handler cannot decode.
+	if _, err := db.Exec(ctx, `
+    INSERT INTO jobs (job_id, kind, payload, status, max_attempts, run_at, created_at)
+    VALUES ($1, 'count', '{"n":"three"}', 'pending', 5, NOW(), NOW())
+    `, uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := q.claim(10)
+	if err != nil || len(jobs) != 4 {
+		t.Fatalf("claim = %d jobs, %v", len(jobs), err)
+	}
+	start := time.Now()
+	for _, j := range jobs {
+		q.run(j)
+	}
+
+	for _, j := range jobs {
+		row := readJob(t, db, j.id)
+		switch {
+		case j.uniqueKey == "ok":
+			if row.status != StatusDone {
+				t.Errorf("successful job = %+v", row)
+			}
+		case j.uniqueKey == "retry":
+			// Retried later with the attempt counted.
+			if row.status != StatusPending || row.attempts != 1 || row.lastError != "temporarily down" || row.runAt.Before(start.Add(backoffBase/2)) {
+				t.Errorf("failed job = %+v, want it pending with backoff", row)
+			}
+		default:
+			// Permanent errors fail at once, with attempts to spare.
+			if row.status != StatusFailed || row.attempts != 1 || row.lastError == "" {
+				t.Errorf("permanently failed job %q = %+v", j.payload, row)
+			}
+		}
+	}
+}
+
+func TestDrainReleasesRunningJobs(t *testing.T) {
+	db := repotest.Postgres(t)
+	q := NewQueue(db, testConfig, zap.NewNop())
+	started := make(chan struct{})
+	Handle(q, func(ctx context.Context, _ countArgs) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if _, err := q.Enqueue(context.Background(), countArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job never started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain = %v, want the deadline to cut the job short", err)
+	}
+	// The interrupted attempt is not counted, so another instance runs the
+	// job from scratch.
+	if row := readJob(t, db, onlyJob(t, db, "count")); row.status != StatusPending || row.attempts != 0 {
+		t.Errorf("after drain = %+v, want pending with no attempts", row)
+	}
+}
+
+func TestCheckWhileWorkersBusy(t *testing.T) {
+	db := repotest.Postgres(t)
+	cfg := testConfig
+	cfg.Workers = 1
+	q := NewQueue(db, cfg, zap.NewNop())
+	started := make(chan struct{})
+	block := make(chan struct{})
+	Handle(q, func(context.Context, countArgs) error {
+		close(started)
+		<-block
+		return nil
+	})
+	if _, err := q.Enqueue(context.Background(), countArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Drain(context.Background())
+	defer close(block)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job never started")
+	}
+
+	// Pretend the last claim was long ago: with the only worker busy the
+	// loop must still vouch for itself.
+	q.lastPoll.Store(time.Now().Add(-time.Hour).UnixNano())
+	waitFor(t, "a heartbeat", func() bool { return q.Check(context.Background()) == nil })
+}
+
+func TestScheduleEnqueuesEachSlotOnce(t *testing.T) {
+	db := repotest.Postgres(t)
+	queues := make([]*Queue, 4)
+	for i := range queues {
+		queues[i] = NewQueue(db, testConfig, zap.NewNop())
+		if err := queues[i].Schedule("* * * * *", countArgs{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		var n int
+		if err := db.QueryRow(context.Background(), `SELECT COUNT(*) FROM jobs WHERE kind = 'count'`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// The first tick only registers the schedule for the next minute.
+	now := time.Now().Truncate(time.Minute)
+	queues[0].tick(now)
+	if n := count(); n != 0 {
+		t.Fatalf("jobs after registering = %d, want 0", n)
+	}
+
+	// Every instance sees the slot come due at once.
+	due := now.Add(90 * time.Second)
+	var wg sync.WaitGroup
+	for _, q := range queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.tick(due)
+		}()
+	}
+	wg.Wait()
+	if n := count(); n != 1 {
+		t.Fatalf("jobs after the slot came due = %d, want 1", n)
+	}
+	for _, q := range queues {
+		q.tick(due)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("jobs after ticking the same slot again = %d, want 1", n)
+	}
+
+	// The next slot is skipped while this run is still queued, and enqueued
+	// once it has finished.
+	queues[1].tick(due.Add(time.Minute))
+	if n := count(); n != 1 {
+		t.Errorf("jobs while the last run is queued = %d, want 1", n)
+	}
+	if _, err := db.Exec(context.Background(), `UPDATE jobs SET status = 'done', finished_at = NOW()`); err != nil {
+		t.Fatal(err)
+	}
+	queues[2].tick(due.Add(2 * time.Minute))
+	if n := count(); n != 2 {
+		t.Errorf("jobs after the next slot = %d, want 2", n)
+	}
+}
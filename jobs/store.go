@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/robfig/cron/v3"
+)
+
+// job is a row of the jobs table as seen by a worker.
+type job struct {
+	id          uuid.UUID
+	kind        string
+	payload     []byte
+	uniqueKey   string
+	attempts    int
+	maxAttempts int
+	runAt       time.Time
+}
+
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type store struct {
+	db *pgxpool.Pool
+}
+
+func (s *store) insert(ctx context.Context, db execer, j *job) (bool, error) {
+	var uniqueKey *string
+	if j.uniqueKey != "" {
+		uniqueKey = &j.uniqueKey
+	}
+	tag, err := db.Exec(ctx, `
+    INSERT INTO jobs (job_id, kind, payload, unique_key, status, max_attempts, run_at, created_at)
+    VALUES ($1, $2, $3, $4, 'pending', $5, $6, NOW())
+    ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running')
+    DO NOTHING
+    `, uuid.New(), j.kind, j.payload, uniqueKey, j.maxAttempts, j.runAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert job: %w: %w", utils.ErrDatabase, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// claim leases up to limit due jobs. Running jobs whose lease has run out
+// belong to a worker that died and are claimed again.
+func (s *store) claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]*job, error) {
+	rows, err := s.db.Query(ctx, `
+    UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = $2
+    WHERE job_id IN (
+      SELECT job_id FROM jobs
+      WHERE (status = 'pending' AND run_at <= $1)
+        OR (status = 'running' AND locked_until < $1)
+      ORDER BY run_at
+      LIMIT $3
+      FOR UPDATE SKIP LOCKED
+    )
+    RETURNING job_id, kind, payload, COALESCE(unique_key, ''), attempts, max_attempts, run_at
+    `, now, lockedUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w: %w", utils.ErrDatabase, err)
+	}
+	jobs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*job, error) {
+		var j job
+		err := row.Scan(&j.id, &j.kind, &j.payload, &j.uniqueKey, &j.attempts, &j.maxAttempts, &j.runAt)
+		return &j, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan jobs: %w: %w", utils.ErrDatabase, err)
+	}
+	return jobs, nil
+}
+
+// The outcome updates match on attempts as well as status, so a worker that
+// overran its lease cannot overwrite the result of the attempt that took the
+// job over.
+
+func (s *store) complete(ctx context.Context, j *job, now time.Time) error {
+	_, err := s.db.Exec(ctx, `
+    UPDATE jobs SET status = 'done', locked_until = NULL, finished_at = $3
+    WHERE job_id = $1 AND status = 'running' AND attempts = $2
+    `, j.id, j.attempts, now)
+	if err != nil {
+		return fmt.Errorf("failed to complete job: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+func (s *store) retry(ctx context.Context, j *job, cause error, runAt time.Time) error {
+	_, err := s.db.Exec(ctx, `
+    UPDATE jobs SET status = 'pending', locked_until = NULL, run_at = $3, last_error = $4
+    WHERE job_id = $1 AND status = 'running' AND attempts = $2
+    `, j.id, j.attempts, runAt, cause.Error())
+	if err != nil {
+		return fmt.Errorf("failed to reschedule job: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+func (s *store) fail(ctx context.Context, j *job, cause error, now time.Time) error {
+	_, err := s.db.Exec(ctx, `
+    UPDATE jobs SET status = 'failed', locked_until = NULL, finished_at = $3, last_error = $4
+    WHERE job_id = $1 AND status = 'running' AND attempts = $2
+    `, j.id, j.attempts, now, cause.Error())
+	if err != nil {
+		return fmt.Errorf("failed to fail job: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+// release puts a job interrupted by shutdown back without counting the
+// attempt.
+func (s *store) release(ctx context.Context, j *job) error {
+	_, err := s.db.Exec(ctx, `
+    UPDATE jobs SET status = 'pending', locked_until = NULL, attempts = attempts - 1
+    WHERE job_id = $1 AND status = 'running' AND attempts = $2
+    `, j.id, j.attempts)
+	if err != nil {
+		return fmt.Errorf("failed to release job: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
+
+// fire enqueues j if the schedule is due. Advancing next_run_at and
+// inserting the job happen in one transaction, so exactly one instance
+// enqueues each slot. A changed spec restarts the schedule from now.
+func (s *store) fire(ctx context.Context, name, spec string, sched cron.Schedule, j *job, now time.Time) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w: %w", utils.ErrDatabase, err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+    INSERT INTO job_schedules AS js (name, spec, next_run_at)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (name) DO UPDATE SET spec = EXCLUDED.spec, next_run_at = EXCLUDED.next_run_at
+    WHERE js.spec <> EXCLUDED.spec
+    `, name, spec, sched.Next(now))
+	if err != nil {
+		return false, fmt.Errorf("failed to register schedule: %w: %w", utils.ErrDatabase, err)
+	}
+
+	var due bool
+	err = tx.QueryRow(ctx, `
+    UPDATE job_schedules SET next_run_at = $2
+    WHERE name = $1 AND next_run_at <= $3
+    RETURNING TRUE
+    `, name, sched.Next(now), now).Scan(&due)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to advance schedule: %w: %w", utils.ErrDatabase, err)
+	}
+
+	inserted, err := s.insert(ctx, tx, j)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit schedule: %w: %w", utils.ErrDatabase, err)
+	}
+	return inserted, nil
+}
+
+// prune deletes finished jobs older than before.
+func (s *store) prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.db.Exec(ctx, `
+    DELETE FROM jobs WHERE status IN ('done', 'failed') AND finished_at < $1
+    `, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune jobs: %w: %w", utils.ErrDatabase, err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -1,82 +1,63 @@
 package main
 
 import (
-	"context"
-	"net/http"
+	"flag"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
-	"github.com/bagasadiii/gofood-clone/app"
 	"github.com/bagasadiii/gofood-clone/config"
-	"github.com/bagasadiii/gofood-clone/handler"
-	"github.com/bagasadiii/gofood-clone/middleware"
-	"github.com/bagasadiii/gofood-clone/repository"
-	"github.com/bagasadiii/gofood-clone/service"
 	"github.com/joho/godotenv"
-	"github.com/rs/cors"
-	"go.uber.org/zap"
 )
 
-func main() {
-	godotenv.Load(".env")
-	secretKey := os.Getenv("SECRETKEY")
-	db := config.InitDB()
-	logger := config.NewLogger()
-
-	jwtService := middleware.NewJWTService([]byte(secretKey), logger)
-	userRepo := repository.NewUserRepo(db, logger)
-	userService := service.NewUserService(userRepo, logger, jwtService)
-	userHandler := handler.NewUserHandler(userService, logger)
-
-	merchantRepo := repository.NewMerchantRepo(db, logger)
-	merchantService := service.NewMerchantService(merchantRepo, logger)
-	merchantHandler := handler.NewMerchantHandler(merchantService, logger)
+const usage = `Usage:
+  gofood-clone [flags]                 run the API server
+  gofood-clone config print [flags]    print the effective configuration with secrets redacted
+  gofood-clone seed [flags]            fill the database with generated development data
 
-	driverRepo := repository.NewDriverRepo(db, logger)
-	driverService := service.NewDriverService(driverRepo, logger)
-	driverHandler := handler.NewDriverHandler(driverService, logger)
+Run "gofood-clone -h" to list the flags.
+`
 
-	dependencies := app.HandlerDependencies{
-		UserEndpoint:     userHandler,
-		MerchantEndpoint: merchantHandler,
-		DriverEndpoint:   driverHandler,
-		Middleware:       jwtService,
-	}
-
-	app := app.NewRouter(dependencies)
-	cors := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	}).Handler
+func main() {
+	godotenv.Load(".env")
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: cors(app.Route()),
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		serve(loadConfig("gofood-clone", args))
+		return
 	}
-
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
-
-	go func() {
-		logger.Info("Server is starting on :8080...")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Could not listen on :8080: %v\n", zap.Error(err))
+	switch args[0] {
+	case "config":
+		if len(args) < 2 || args[1] != "print" {
+			fatalf("unknown config command\n\n%s", usage)
 		}
-	}()
-
-	<-done
-	logger.Info("Server is shutting down...")
+		printConfig(loadConfig("config print", args[2:]))
+	case "seed":
+		runSeed(args[1:])
+	case "help":
+		fmt.Print(usage)
+	default:
+		fatalf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func loadConfig(name string, args []string, extra ...func(*flag.FlagSet)) *config.Config {
+	cfg, err := config.Load(name, args, extra...)
+	if err != nil {
+		fatalf("Invalid configuration:\n%v", err)
+	}
+	return cfg
+}
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+func printConfig(cfg *config.Config) {
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		fatalf("Failed to render configuration: %v", err)
 	}
+	os.Stdout.Write(out)
+}
 
-	logger.Info("Server exited gracefully")
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
 }
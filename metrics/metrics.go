@@ -0,0 +1,124 @@
+// Package metrics holds the Prometheus collectors exported on the admin
+// listener.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gofood"
+
+// Registry is kept separate from the global default so only collectors
+// registered here are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Successful user registrations by role.",
+	}, []string{"role"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by step (password or totp) and outcome.",
+	}, []string{"step", "outcome"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+
+	IdempotentReplays = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "idempotent_replays_total",
+		Help:      "Responses replayed for retried requests with an Idempotency-Key.",
+	})
+
+	Orders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_total",
+		Help:      "Orders entering each status, counted when created as placed or scheduled and on every later transition.",
+	}, []string{"status"})
+
+	JobsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "Background job attempts by kind and outcome (done, retry, failed or released).",
+	}, []string{"kind", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		Registrations,
+		Logins,
+		RateLimited,
+		IdempotentReplays,
+		Orders,
+		JobsProcessed,
+	)
+}
+
+// Handler serves the metrics in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Login steps.
+const (
+	LoginStepPassword = "password"
+	LoginStepTOTP     = "totp"
+)
+
+// ObserveLogin counts a finished login step by its outcome.
+func ObserveLogin(step string, res *model.LoginRes, err error) {
+	Logins.WithLabelValues(step, loginOutcome(res, err)).Inc()
+}
+
+func loginOutcome(res *model.LoginRes, err error) string {
+	switch {
+	case err == nil && res != nil && res.TwoFactorRequired:
+		return "two_factor_required"
+	case err == nil:
+		return "success"
+	case errors.Is(err, utils.ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, utils.ErrTooManyAttempts):
+		return "locked"
+	case errors.Is(err, utils.ErrAccountSuspended):
+		return "suspended"
+	case errors.Is(err, utils.ErrBadRequest), errors.Is(err, utils.ErrUnauthorized):
+		return "rejected"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+)
+
+// ParseTrustedProxies parses the addresses and CIDR ranges of reverse proxies
+// whose forwarding headers may be believed.
+func ParseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if prefix, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", s)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientIP resolves the real client address for utils.ClientIP. Forwarding
+// headers are only read when the peer is a trusted proxy: X-Forwarded-For is
+// walked from the right, skipping trusted hops, and the first other address
+// wins, with X-Real-IP as the fallback. Headers from anyone else are ignored
+// so clients cannot choose their own address.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer := utils.ClientIP(r)
+			ip := peer
+			if isTrusted(trusted, peer) {
+				ip = forwardedFor(trusted, r.Header)
+				if ip == "" {
+					ip = peer
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(utils.WithClientIP(r.Context(), ip)))
+		})
+	}
+}
+
+func forwardedFor(trusted []netip.Prefix, h http.Header) string {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Anything left of a malformed entry cannot be trusted.
+			return ""
+		}
+		if !isTrusted(trusted, hop) {
+			return hop
+		}
+	}
+	if real := strings.TrimSpace(h.Get("X-Real-IP")); net.ParseIP(real) != nil {
+		return real
+	}
+	return ""
+}
+
+func isTrusted(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.7", "::ffff:172.16.0.1", "fd00::/8", "10.1.2.3/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "172.16.0.1/32", "fd00::/8", "10.0.0.0/8"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("prefixes = %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, prefix, want[i])
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"proxy.internal"}); err == nil {
+		t.Error("hostname accepted as a trusted proxy")
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"single hop", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"rightmost untrusted wins", []string{"198.51.100.1, 203.0.113.7, 10.0.0.2"}, "", "203.0.113.7"},
+		{"spoofed left entries ignored", []string{"1.1.1.1", "203.0.113.7, 10.0.0.3, 10.0.0.2"}, "", "203.0.113.7"},
+		{"multiple headers", []string{"203.0.113.7", "10.0.0.2"}, "", "203.0.113.7"},
+		{"malformed hop stops the walk", []string{"203.0.113.7, garbage, 10.0.0.2"}, "198.51.100.9", ""},
+		{"port is malformed", []string{"203.0.113.7:1234"}, "", ""},
+		{"all trusted falls back to X-Real-IP", []string{"10.0.0.3, 10.0.0.2"}, "198.51.100.9", "198.51.100.9"},
+		{"all trusted without X-Real-IP", []string{"10.0.0.3, 10.0.0.2"}, "", ""},
+		{"bad X-Real-IP ignored", nil, "not-an-ip", ""},
+		{"no headers", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tt.xff {
+				h.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				h.Set("X-Real-IP", tt.realIP)
+			}
+			if got := forwardedFor(trusted, h); got != tt.want {
+				t.Errorf("forwardedFor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		trusted bool
+		remote  string
+		xff     string
+		want    string
+	}{
+		{"untrusted peer keeps its address", true, "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"trusted peer forwards", true, "10.0.0.2:4321", "198.51.100.1", "198.51.100.1"},
+		{"trusted IPv6 peer forwards", true, "[::1]:4321", "198.51.100.1", "198.51.100.1"},
+		{"trusted peer without header", true, "10.0.0.2:4321", "", "10.0.0.2"},
+		{"trusted peer with malformed header", true, "10.0.0.2:4321", "198.51.100.1, bogus", "10.0.0.2"},
+		{"all-trusted chain falls back to the peer", true, "10.0.0.2:4321", "10.0.0.5", "10.0.0.2"},
+		{"no trusted proxies configured", false, "10.0.0.2:4321", "198.51.100.1", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := trusted
+			if !tt.trusted {
+				list = nil
+			}
+			var got string
+			h := ClientIP(list)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = utils.ClientIP(r)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/idempotency"
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader marks a response served from the record of an
+	// earlier request.
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	MaxIdempotencyKeyLength = 255
+
+	// maxIdempotentBody caps the request body read for hashing. The routes
+	// that take a key all accept small JSON bodies.
+	maxIdempotentBody = 1 << 20
+	// idempotencyLock is how long a claim blocks retries when the instance
+	// handling it dies before recording a response.
+	idempotencyLock = time.Minute
+	// idempotencyWriteTimeout bounds recording or releasing a key once the
+	// handler is done. The writes outlive the request: a client that has
+	// gone away is the one most likely to retry.
+	idempotencyWriteTimeout = 5 * time.Second
+)
+
+type Idempotency struct {
+	store idempotency.Store
+	ttl   time.Duration
+	zap   *zap.Logger
+}
+
+func NewIdempotency(store idempotency.Store, ttl time.Duration, zap *zap.Logger) *Idempotency {
+	return &Idempotency{
+		store: store,
+		ttl:   ttl,
+		zap:   zap,
+	}
+}
+
+// Handle makes POST and PATCH requests that carry an Idempotency-Key header
+// safe to retry. The first request with a key runs and its response is kept
+// for the TTL; a retry with the same method, path and body gets that response
+// again with Idempotent-Replayed set. A retry while the first request is still
+// running gets 409, and reusing a key for a different request gets 422.
+// Keys are scoped to the user, so Handle must run after ValidateContext.
+//
+// Server errors and panics are not recorded, so a request that failed with a
+// 5xx can be retried with the same key. Use HandleOnce where a failed request
+// may still have had an effect.
+func (id *Idempotency) Handle(next http.Handler) http.Handler {
+	return id.handle(next, false)
+}
+
+// HandleOnce is Handle for routes that move money. Once the handler has
+// started, its response is recorded whatever the status, and a panic is
+// recorded as a 500, so a retry with the same key never runs it again: a
+// server error may have come after the money moved. Unlike the rate
+// limiter, a store failure rejects the request.
+func (id *Idempotency) HandleOnce(next http.Handler) http.Handler {
+	return id.handle(next, true)
+}
+
+func (id *Idempotency) handle(next http.Handler, once bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			utils.ErrorResponse(w, r, utils.ErrBadRequest)
+			return
+		}
+		ctxValue, err := utils.CheckContextValue(r.Context())
+		if err != nil {
+			utils.ErrorResponse(w, r, utils.ErrUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			utils.Logger(r.Context(), id.zap).Warn(utils.ErrBadRequest.Error(), zap.Error(err))
+			utils.ErrorResponse(w, r, utils.ErrBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := idempotency.Hash(r.Method, r.URL.Path, body)
+
+		// A money route holds its claim for the whole TTL, so that if the
+		// response is never recorded a retry gets 409 rather than a rerun.
+		lock := idempotencyLock
+		if once {
+			lock = id.ttl
+		}
+		now := time.Now()
+		rec, err := id.store.Claim(r.Context(), ctxValue.UserID, key, hash, now, now.Add(lock))
+		if err != nil {
+			utils.Logger(r.Context(), id.zap).Error("idempotency store failed", zap.Error(err))
+			utils.ErrorResponse(w, r, utils.ErrInternal)
+			return
+		}
+		if rec != nil {
+			id.answer(w, r, rec, hash)
+			return
+		}
+
+		capture := &capturingWriter{responseRecorder: newResponseRecorder(w)}
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if !once {
+				// The handler panicked; let the retry run again.
+				id.release(r, ctxValue, key)
+				return
+			}
+			// Answer the panic here so that the 500 is what gets recorded,
+			// then let Recover log it.
+			v := recover()
+			if !capture.wroteHeader {
+				utils.ErrorResponse(capture, r, utils.ErrInternal)
+			}
+			id.complete(r, ctxValue, key, capture)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		next.ServeHTTP(capture, r)
+		completed = true
+
+		if !once && capture.status >= http.StatusInternalServerError {
+			id.release(r, ctxValue, key)
+			return
+		}
+		id.complete(r, ctxValue, key, capture)
+	})
+}
+
+func (id *Idempotency) answer(w http.ResponseWriter, r *http.Request, rec *idempotency.Record, hash string) {
+	switch {
+	case rec.RequestHash != hash:
+		utils.Logger(r.Context(), id.zap).Warn(utils.ErrIdempotencyReused.Error())
+		utils.ErrorResponse(w, r, utils.ErrIdempotencyReused)
+	case rec.Response == nil:
+		utils.Logger(r.Context(), id.zap).Warn(utils.ErrRequestInProgress.Error())
+		utils.ErrorResponse(w, r, utils.ErrRequestInProgress)
+	default:
+		metrics.IdempotentReplays.Inc()
+		utils.Logger(r.Context(), id.zap).Info("replaying idempotent response", zap.Int("status", rec.Response.Status))
+		if rec.Response.ContentType != "" {
+			w.Header().Set("Content-Type", rec.Response.ContentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(rec.Response.Body)))
+		w.Header().Set(IdempotentReplayHeader, "true")
+		w.WriteHeader(rec.Response.Status)
+		w.Write(rec.Response.Body)
+	}
+}
+
+func (id *Idempotency) complete(r *http.Request, ctxValue *utils.ContextValues, key string, capture *capturingWriter) {
+	ctx, cancel := bookkeepingContext(r)
+	defer cancel()
+	err := id.store.Complete(ctx, ctxValue.UserID, key, &idempotency.Response{
+		Status:      capture.status,
+		ContentType: capture.Header().Get("Content-Type"),
+		Body:        capture.body.Bytes(),
+	}, time.Now().Add(id.ttl))
+	if err != nil {
+		// The response has been sent; a retry will see the claim until
+		// the lock lapses, which errs on the side of not running twice.
+		utils.Logger(r.Context(), id.zap).Error("failed to record idempotent response", zap.Error(err))
+	}
+}
+
+func (id *Idempotency) release(r *http.Request, ctxValue *utils.ContextValues, key string) {
+	ctx, cancel := bookkeepingContext(r)
+	defer cancel()
+	if err := id.store.Release(ctx, ctxValue.UserID, key); err != nil {
+		utils.Logger(r.Context(), id.zap).Error("failed to release idempotency key", zap.Error(err))
+	}
+}
+
+// bookkeepingContext keeps the request's values but not its cancellation,
+// so a client disconnecting mid-request cannot leave its key claimed.
+func bookkeepingContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyWriteTimeout)
+}
+
+// capturingWriter keeps a copy of the response body for the record.
+type capturingWriter struct {
+	*responseRecorder
+	body bytes.Buffer
+}
+
+func (cw *capturingWriter) Write(b []byte) (int, error) {
+	n, err := cw.responseRecorder.Write(b)
+	cw.body.Write(b[:n])
+	return n, err
+}
@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/idempotency"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var idempotentUser = utils.ContextValues{UserID: uuid.New(), Username: "alice", Role: "admin"}
+
+// countingHandler answers with status and counts its calls; it panics
+// instead when status is 0.
+type countingHandler struct {
+	status int
+	calls  int
+}
+
+func (ch *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ch.calls++
+	if ch.status == 0 {
+		panic("boom")
+	}
+	utils.JSONResponse(w, ch.status, map[string]int{"call": ch.calls})
+}
+
+// sendIdempotent posts body with key through h, wrapped in Recover as it is
+// in the server.
+func sendIdempotent(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/admin/users/bob/wallet", strings.NewReader(body))
+	req = req.WithContext(utils.WithContextValues(req.Context(), idempotentUser))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	Recover(zap.NewNop())(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	for _, once := range []bool{false, true} {
+		id := NewIdempotency(idempotency.NewMemoryStore(), time.Hour, zap.NewNop())
+		next := &countingHandler{status: http.StatusCreated}
+		h := id.Handle(next)
+		if once {
+			h = id.HandleOnce(next)
+		}
+
+		first := sendIdempotent(h, "k1", `{"amount":100}`)
+		second := sendIdempotent(h, "k1", `{"amount":100}`)
+		if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+			t.Fatalf("once=%v: statuses = %d, %d", once, first.Code, second.Code)
+		}
+		if next.calls != 1 {
+			t.Errorf("once=%v: handler ran %d times, want 1", once, next.calls)
+		}
+		if second.Body.String() != first.Body.String() || second.Header().Get(IdempotentReplayHeader) != "true" {
+			t.Errorf("once=%v: replay = %q with headers %v, want %q", once, second.Body, second.Header(), first.Body)
+		}
+		if first.Header().Get(IdempotentReplayHeader) != "" {
+			t.Errorf("once=%v: first response marked as a replay", once)
+		}
+
+		// A request without a key is not deduplicated.
+		sendIdempotent(h, "", `{"amount":100}`)
+		if next.calls != 2 {
+			t.Errorf("once=%v: request without a key did not run", once)
+		}
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	id := NewIdempotency(store, time.Hour, zap.NewNop())
+	next := &countingHandler{status: http.StatusOK}
+	body := `{"amount":100}`
+
+	now := time.Now()
+	hash := idempotency.Hash("POST", "/api/v1/admin/users/bob/wallet", []byte(body))
+	if rec, err := store.Claim(context.Background(), idempotentUser.UserID, "k1", hash, now, now.Add(time.Minute)); rec != nil || err != nil {
+		t.Fatalf("Claim = %+v, %v", rec, err)
+	}
+
+	rec := sendIdempotent(id.HandleOnce(next), "k1", body)
+	if rec.Code != http.StatusConflict || next.calls != 0 {
+		t.Errorf("retry during the first request = %d after %d calls, want 409 without running", rec.Code, next.calls)
+	}
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	id := NewIdempotency(idempotency.NewMemoryStore(), time.Hour, zap.NewNop())
+	next := &countingHandler{status: http.StatusOK}
+	h := id.HandleOnce(next)
+
+	sendIdempotent(h, "k1", `{"amount":100}`)
+	rec := sendIdempotent(h, "k1", `{"amount":1000}`)
+	if rec.Code != http.StatusUnprocessableEntity || next.calls != 1 {
+		t.Errorf("key reused with another body = %d after %d calls, want 422 without running", rec.Code, next.calls)
+	}
+
+	// Keys are per user.
+	other := &countingHandler{status: http.StatusOK}
+	req := httptest.NewRequest("POST", "/api/v1/admin/users/bob/wallet", strings.NewReader(`{"amount":100}`))
+	req = req.WithContext(utils.WithContextValues(req.Context(), utils.ContextValues{UserID: uuid.New(), Username: "carol", Role: "admin"}))
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	id.HandleOnce(other).ServeHTTP(httptest.NewRecorder(), req)
+	if other.calls != 1 {
+		t.Error("another user's key blocked the request")
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	id := NewIdempotency(idempotency.NewMemoryStore(), time.Hour, zap.NewNop())
+	next := &countingHandler{status: http.StatusOK}
+	rec := sendIdempotent(id.HandleOnce(next), strings.Repeat("k", MaxIdempotencyKeyLength+1), `{}`)
+	if rec.Code != http.StatusBadRequest || next.calls != 0 {
+		t.Errorf("long key = %d after %d calls, want 400 without running", rec.Code, next.calls)
+	}
+}
+
+func TestIdempotencyReleasesOnFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"server error", http.StatusInternalServerError},
+		{"panic", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := NewIdempotency(idempotency.NewMemoryStore(), time.Hour, zap.NewNop())
+			next := &countingHandler{status: tt.status}
+			h := id.Handle(next)
+
+			if rec := sendIdempotent(h, "k1", `{}`); rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500", rec.Code)
+			}
+			next.status = http.StatusOK
+			rec := sendIdempotent(h, "k1", `{}`)
+			if rec.Code != http.StatusOK || next.calls != 2 || rec.Header().Get(IdempotentReplayHeader) != "" {
+				t.Errorf("retry = %d after %d calls, want the handler to run again", rec.Code, next.calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyOnceRecordsFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"server error", http.StatusInternalServerError},
+		{"panic", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := NewIdempotency(idempotency.NewMemoryStore(), time.Hour, zap.NewNop())
+			next := &countingHandler{status: tt.status}
+			h := id.HandleOnce(next)
+
+			first := sendIdempotent(h, "k1", `{}`)
+			if first.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500", first.Code)
+			}
+			next.status = http.StatusOK
+			rec := sendIdempotent(h, "k1", `{}`)
+			if rec.Code != http.StatusInternalServerError || next.calls != 1 || rec.Header().Get(IdempotentReplayHeader) != "true" {
+				t.Errorf("retry = %d after %d calls, want the 500 replayed", rec.Code, next.calls)
+			}
+			if rec.Body.String() != first.Body.String() {
+				t.Errorf("replayed body = %q, want %q", rec.Body, first.Body)
+			}
+		})
+	}
+}
+
+// cancelAwareStore fails writes on a cancelled context, as a database
+// store does.
+type cancelAwareStore struct {
+	idempotency.Store
+}
+
+func (s cancelAwareStore) Complete(ctx context.Context, userID uuid.UUID, key string, res *idempotency.Response, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Complete(ctx, userID, key, res, expiresAt)
+}
+
+func (s cancelAwareStore) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Release(ctx, userID, key)
+}
+
+// disconnectingHandler cancels the request context, as a client hanging up
+// does, before answering with status.
+type disconnectingHandler struct {
+	countingHandler
+	cancel context.CancelFunc
+}
+
+func (dh *disconnectingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dh.cancel()
+	dh.countingHandler.ServeHTTP(w, r)
+}
+
+func TestIdempotencyClientDisconnects(t *testing.T) {
+	tests := []struct {
+		name   string
+		once   bool
+		status int
+		want   int
+		calls  int
+	}{
+		{"recorded", false, http.StatusCreated, http.StatusCreated, 1},
+		{"recorded once", true, http.StatusCreated, http.StatusCreated, 1},
+		{"money route failure recorded", true, http.StatusInternalServerError, http.StatusInternalServerError, 1},
+		{"failure released", false, http.StatusInternalServerError, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := NewIdempotency(cancelAwareStore{idempotency.NewMemoryStore()}, time.Hour, zap.NewNop())
+			ctx, cancel := context.WithCancel(context.Background())
+			next := &disconnectingHandler{countingHandler: countingHandler{status: tt.status}, cancel: cancel}
+			h := id.Handle(next)
+			if tt.once {
+				h = id.HandleOnce(next)
+			}
+			req := httptest.NewRequest("POST", "/api/v1/admin/users/bob/wallet", strings.NewReader(`{}`))
+			req = req.WithContext(utils.WithContextValues(ctx, idempotentUser))
+			req.Header.Set(IdempotencyKeyHeader, "k1")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// The retry must be answered, never told the first request is
+			// still in progress.
+			next.status = http.StatusOK
+			next.cancel = func() {}
+			rec := sendIdempotent(h, "k1", `{}`)
+			if rec.Code != tt.want || next.calls != tt.calls {
+				t.Errorf("retry = %d after %d calls, want %d after %d", rec.Code, next.calls, tt.want, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/ratelimit"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// RateLimitHeaders are the response headers set by RateLimiter, listed so
+// CORS can expose them to browsers.
+var RateLimitHeaders = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"}
+
+// RateLimitKey picks the bucket a request is counted against.
+type RateLimitKey func(r *http.Request) string
+
+// KeyByIP counts requests per client address.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + utils.ClientIP(r)
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the client
+// address. It must run after ValidateContext to see the user.
+func KeyByUser(r *http.Request) string {
+	if ctxValue, err := utils.CheckContextValue(r.Context()); err == nil {
+		return "user:" + ctxValue.UserID.String()
+	}
+	return KeyByIP(r)
+}
+
+type RateLimiter struct {
+	store ratelimit.Store
+	zap   *zap.Logger
+}
+
+func NewRateLimiter(store ratelimit.Store, zap *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		store: store,
+		zap:   zap,
+	}
+}
+
+// Limit throttles requests under policy, keyed by key. Every response carries
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy;
+// rejected requests get 429 with Retry-After. If the store fails the request
+// is let through, since an outage of the limiter should not take the API down.
+func (rl *RateLimiter) Limit(policy ratelimit.Policy, key RateLimitKey) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !policy.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := rl.store.Take(r.Context(), policy.Name+":"+key(r), policy, time.Now())
+			if err != nil {
+				utils.Logger(r.Context(), rl.zap).Error("rate limit store failed", zap.String("policy", policy.Name), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			h.Set("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+ceilSeconds(policy.Period))
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				metrics.RateLimited.WithLabelValues(policy.Name).Inc()
+				utils.Logger(r.Context(), rl.zap).Warn("rate limited", zap.String("policy", policy.Name))
+				utils.ErrorResponse(w, r, utils.ErrRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
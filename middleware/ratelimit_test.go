@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/ratelimit"
+	"go.uber.org/zap"
+)
+
+// fixedStore answers every Take with res, or err when set, and records the
+// keys it was asked for.
+type fixedStore struct {
+	res  ratelimit.Result
+	err  error
+	keys []string
+}
+
+func (fs *fixedStore) Take(_ context.Context, key string, _ ratelimit.Policy, _ time.Time) (ratelimit.Result, error) {
+	fs.keys = append(fs.keys, key)
+	return fs.res, fs.err
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func serveLimited(store ratelimit.Store, policy ratelimit.Policy) *httptest.ResponseRecorder {
+	rl := NewRateLimiter(store, zap.NewNop())
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	rec := httptest.NewRecorder()
+	rl.Limit(policy, KeyByIP)(okHandler).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimitHeaders(t *testing.T) {
+	policy := ratelimit.Policy{Name: "public", Limit: 60, Period: time.Minute}
+	tests := []struct {
+		name   string
+		res    ratelimit.Result
+		status int
+		want   map[string]string
+	}{
+		{
+			name:   "allowed",
+			res:    ratelimit.Result{Allowed: true, Limit: 60, Remaining: 41, Reset: 18500 * time.Millisecond},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"RateLimit-Limit": "60", "RateLimit-Remaining": "41", "RateLimit-Reset": "19",
+				"RateLimit-Policy": "60;w=60", "Retry-After": "",
+			},
+		},
+		{
+			name:   "rejected",
+			res:    ratelimit.Result{Limit: 60, Remaining: 0, Reset: time.Minute, RetryAfter: 200 * time.Millisecond},
+			status: http.StatusTooManyRequests,
+			want: map[string]string{
+				"RateLimit-Limit": "60", "RateLimit-Remaining": "0", "RateLimit-Reset": "60",
+				"RateLimit-Policy": "60;w=60", "Retry-After": "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fixedStore{res: tt.res}
+			rec := serveLimited(store, policy)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			for header, want := range tt.want {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if len(store.keys) != 1 || store.keys[0] != "public:ip:203.0.113.7" {
+				t.Errorf("store keys = %v", store.keys)
+			}
+		})
+	}
+}
+
+func TestLimitFailsOpen(t *testing.T) {
+	store := &fixedStore{err: errors.New("connection refused")}
+	rec := serveLimited(store, ratelimit.Policy{Name: "public", Limit: 1, Period: time.Second})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("store error gave %d with headers %v, want the request through untouched", rec.Code, rec.Header())
+	}
+}
+
+func TestLimitDisabledPolicy(t *testing.T) {
+	store := &fixedStore{}
+	rec := serveLimited(store, ratelimit.Policy{Name: "public"})
+	if rec.Code != http.StatusNoContent || len(store.keys) != 0 {
+		t.Errorf("disabled policy gave %d and consulted the store %d times", rec.Code, len(store.keys))
+	}
+}
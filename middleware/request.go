@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern bounds client-supplied IDs so they are safe to log and echo.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID propagates a well-formed X-Request-ID from the client or assigns
+// a new one. The ID is echoed in the response and stored in the context along
+// with a logger tagged with it.
+func RequestID(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !requestIDPattern.MatchString(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			ctx := r.Context()
+			ctx = utils.WithLogger(ctx, logger.With(zap.String("request_id", id)))
+			ctx = context.WithValue(ctx, utils.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLog writes one line per request with its status, size, latency and
+// the authenticated user, if any. It must run after RequestID.
+func AccessLog(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			info := &utils.RequestInfo{}
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(utils.WithRequestInfo(r.Context(), info)))
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.status),
+				zap.Int64("bytes", rec.bytes),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("remote_ip", utils.ClientIP(r)),
+			}
+			if info.UserID != uuid.Nil {
+				fields = append(fields, zap.String("user_id", info.UserID.String()))
+			}
+			utils.Logger(r.Context(), logger).Info("request", fields...)
+		})
+	}
+}
+
+// Recover turns a panic in a handler into a 500 JSON response and logs the
+// stack, instead of letting net/http drop the connection.
+func Recover(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newResponseRecorder(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				utils.Logger(r.Context(), logger).Error("panic recovered",
+					zap.Any("panic", v),
+					zap.ByteString("stack", debug.Stack()),
+				)
+				if !rec.wroteHeader {
+					utils.ErrorResponse(rec, r, fmt.Errorf("panic: %v: %w", v, utils.ErrInternal))
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// responseRecorder captures the status code and body size written by a handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
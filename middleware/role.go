@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/gorilla/mux"
+)
+
+// RequireRole only lets through requests whose token carries one of roles.
+// It must run after ValidateContext.
+func RequireRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctxValue, err := utils.CheckContextValue(r.Context())
+			if err != nil {
+				utils.ErrorResponse(w, r, utils.ErrUnauthorized)
+				return
+			}
+			if !slices.Contains(roles, ctxValue.Role) {
+				utils.ErrorResponse(w, r, utils.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,47 +2,79 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+const (
+	challengeTokenTTL = 5 * time.Minute
+	purposeTwoFactor  = "2fa"
+)
+
 type TokenClaims struct {
 	UserID   uuid.UUID
 	Role     string
 	Username string
+	Purpose  string `json:"Purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 type JWTServiceImpl interface {
 	CreateToken(claims *TokenClaims) (string, error)
 	ValidateToken(tokenString string) (*TokenClaims, error)
+	CreateChallengeToken(claims *TokenClaims) (string, error)
+	ValidateChallengeToken(tokenString string) (*TokenClaims, error)
 	ValidateContext(next http.Handler) http.Handler
+	OptionalContext(next http.Handler) http.Handler
+}
+
+// AccountStatusRepo looks up whether a token's user may still act, so that a
+// suspended or deleted account is locked out before its token expires.
+type AccountStatusRepo interface {
+	GetAccountStatusRepo(ctx context.Context, userID uuid.UUID) (string, error)
 }
 type JWTService struct {
 	secretKey []byte
+	tokenTTL  time.Duration
+	accounts  AccountStatusRepo
 	zap       *zap.Logger
 }
 
-func NewJWTService(key []byte, zap *zap.Logger) *JWTService {
+func NewJWTService(key []byte, tokenTTL time.Duration, accounts AccountStatusRepo, zap *zap.Logger) *JWTService {
 	return &JWTService{
 		secretKey: key,
+		tokenTTL:  tokenTTL,
+		accounts:  accounts,
 		zap:       zap,
 	}
 }
 
 func (js *JWTService) CreateToken(claims *TokenClaims) (string, error) {
+	return js.signToken(claims, "", js.tokenTTL)
+}
+
+// CreateChallengeToken issues a short-lived token that only proves the
+// password step of a two-factor login and cannot be used as a bearer token.
+func (js *JWTService) CreateChallengeToken(claims *TokenClaims) (string, error) {
+	return js.signToken(claims, purposeTwoFactor, challengeTokenTTL)
+}
+
+func (js *JWTService) signToken(claims *TokenClaims, purpose string, ttl time.Duration) (string, error) {
 	newClaims := TokenClaims{
 		UserID:   claims.UserID,
 		Role:     claims.Role,
 		Username: claims.Username,
+		Purpose:  purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 		},
 	}
 
@@ -50,12 +82,20 @@ func (js *JWTService) CreateToken(claims *TokenClaims) (string, error) {
 	tokenString, err := token.SignedString(js.secretKey)
 	if err != nil {
 		js.zap.Error(utils.ErrInternal.Error(), zap.Error(err))
-		return "", fmt.Errorf("%v: failed to create token", utils.ErrInternal)
+		return "", fmt.Errorf("%w: failed to create token", utils.ErrInternal)
 	}
 	return tokenString, nil
 }
 
 func (js *JWTService) ValidateToken(tokenString string) (*TokenClaims, error) {
+	return js.parseToken(tokenString, "")
+}
+
+func (js *JWTService) ValidateChallengeToken(tokenString string) (*TokenClaims, error) {
+	return js.parseToken(tokenString, purposeTwoFactor)
+}
+
+func (js *JWTService) parseToken(tokenString string, purpose string) (*TokenClaims, error) {
 	newClaims := &TokenClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, newClaims, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -72,6 +112,10 @@ func (js *JWTService) ValidateToken(tokenString string) (*TokenClaims, error) {
 			js.zap.Warn("Token missing required claims", zap.Any("claims", claims))
 			return nil, utils.ErrUnauthorized
 		}
+		if claims.Purpose != purpose {
+			js.zap.Warn("Token used for wrong purpose", zap.String("purpose", claims.Purpose))
+			return nil, utils.ErrUnauthorized
+		}
 		return claims, nil
 	}
 	return nil, utils.ErrUnauthorized
@@ -81,21 +125,57 @@ func (js *JWTService) ValidateContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			js.zap.Warn("Missing Token")
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.ErrUnauthorized)
+			utils.Logger(r.Context(), js.zap).Warn("Missing Token")
+			utils.ErrorResponse(w, r, utils.ErrUnauthorized)
 			return
 		}
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := js.ValidateToken(token)
 		if err != nil {
-			js.zap.Error("failed to validate token", zap.Error(err))
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.ErrUnauthorized)
+			utils.Logger(r.Context(), js.zap).Error("failed to validate token", zap.Error(err))
+			utils.ErrorResponse(w, r, utils.ErrUnauthorized)
+			return
+		}
+		status, err := js.accounts.GetAccountStatusRepo(r.Context(), claims.UserID)
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.Logger(r.Context(), js.zap).Warn("Token for missing account", zap.String("user_id", claims.UserID.String()))
+			utils.ErrorResponse(w, r, utils.ErrUnauthorized)
+			return
+		} else if err != nil {
+			utils.ErrorResponse(w, r, utils.ErrInternal)
 			return
 		}
-		ctx := context.WithValue(r.Context(), utils.UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, utils.UsernameKey, claims.Username)
-		ctx = context.WithValue(ctx, utils.RoleKey, claims.Role)
-		js.zap.Info("Success", zap.Any("user", ctx))
+		if status != model.UserStatusActive {
+			utils.Logger(r.Context(), js.zap).Warn("Token for inactive account", zap.String("user_id", claims.UserID.String()), zap.String("status", status))
+			utils.ErrorResponse(w, r, utils.ErrAccountSuspended)
+			return
+		}
+		ctx := utils.WithContextValues(r.Context(), utils.ContextValues{
+			UserID:   claims.UserID,
+			Username: claims.Username,
+			Role:     claims.Role,
+		})
+		if info := utils.RequestInfoFrom(ctx); info != nil {
+			info.UserID = claims.UserID
+		}
+		logger := utils.Logger(ctx, js.zap).With(zap.String("user_id", claims.UserID.String()))
+		ctx = utils.WithLogger(ctx, logger)
+		logger.Debug("Token validated", zap.String("role", claims.Role))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// OptionalContext authenticates the request like ValidateContext when it
+// carries a token and lets it through anonymously when it does not. Public
+// routes use it to show owners and admins more than everyone else sees. A
+// token that is present but invalid is still rejected.
+func (js *JWTService) OptionalContext(next http.Handler) http.Handler {
+	validated := js.ValidateContext(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		validated.ServeHTTP(w, r)
+	})
+}
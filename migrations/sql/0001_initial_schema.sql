@@ -0,0 +1,133 @@
+CREATE TABLE IF NOT EXISTS users (
+  user_id UUID PRIMARY KEY,
+  username VARCHAR(25) NOT NULL UNIQUE,
+  email VARCHAR(100) NOT NULL UNIQUE,
+  password VARCHAR(255),
+  role VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  phone VARCHAR(20),
+  balance BIGINT DEFAULT 0,
+  name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS merchants (
+  merchant_id UUID PRIMARY KEY,
+  name VARCHAR(50) NOT NULL,
+  rating REAL,
+  address VARCHAR(100),
+  category VARCHAR(50),
+  user_id UUID NOT NULL UNIQUE,
+  owner VARCHAR(25) NOT NULL,
+  CONSTRAINT fk_merchant_user FOREIGN KEY(user_id)
+    REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS drivers (
+  driver_id UUID PRIMARY KEY,
+  name VARCHAR(50) NOT NULL,
+  rating REAL,
+  license VARCHAR(10) NOT NULL UNIQUE,
+  area VARCHAR(25),
+  income INT DEFAULT 0,
+  user_id UUID NOT NULL UNIQUE,
+  username VARCHAR(25) NOT NULL,
+  CONSTRAINT fk_driver_user FOREIGN KEY(user_id)
+    REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  scope VARCHAR(20) NOT NULL,
+  subject VARCHAR(100) NOT NULL,
+  failures INT NOT NULL DEFAULT 0,
+  last_failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until TIMESTAMPTZ,
+  PRIMARY KEY (scope, subject)
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  audit_id UUID PRIMARY KEY,
+  actor_id UUID,
+  action VARCHAR(50) NOT NULL,
+  subject VARCHAR(100) NOT NULL,
+  ip VARCHAR(45),
+  detail TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  user_id UUID NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  used_at TIMESTAMPTZ,
+  PRIMARY KEY (user_id, code_hash),
+  CONSTRAINT fk_recovery_code_user FOREIGN KEY(user_id)
+    REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE drivers ALTER COLUMN license DROP NOT NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved';
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS review_note TEXT;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS reviewed_by UUID;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved';
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS review_note TEXT;
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS reviewed_by UUID;
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+  transaction_id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  amount BIGINT NOT NULL,
+  balance_after BIGINT NOT NULL,
+  kind VARCHAR(30) NOT NULL,
+  reason TEXT,
+  actor_id UUID,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_wallet_transaction_user FOREIGN KEY(user_id)
+    REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+ALTER TABLE merchants ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE drivers ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS driver_documents (
+  document_id UUID PRIMARY KEY,
+  driver_id UUID NOT NULL,
+  kind VARCHAR(30) NOT NULL,
+  file_name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size BIGINT NOT NULL,
+  storage_key VARCHAR(255) NOT NULL,
+  uploaded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_document_driver FOREIGN KEY(driver_id)
+    REFERENCES drivers(driver_id) ON DELETE CASCADE
+);
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS description TEXT;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS menus (
+  menu_id UUID PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  description TEXT,
+  price BIGINT NOT NULL CHECK (price >= 0),
+  category VARCHAR(50) NOT NULL,
+  rating REAL DEFAULT 0,
+  stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+  merchant_id UUID NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ,
+  CONSTRAINT fk_menu_merchant FOREIGN KEY(merchant_id)
+    REFERENCES merchants(merchant_id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- Token buckets for the Postgres rate limit store. The data is disposable, so
+-- the table is unlogged to keep the per-request upsert cheap.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+  key VARCHAR(200) PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  allowed BOOLEAN NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_expires_at ON rate_limits (expires_at);
@@ -0,0 +1,16 @@
+-- Responses recorded for requests sent with an Idempotency-Key header, so a
+-- retried request gets the original response instead of running twice. The
+-- response columns stay NULL while the first request is still being handled.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  key VARCHAR(255) NOT NULL,
+  request_hash CHAR(64) NOT NULL,
+  status INT,
+  content_type VARCHAR(255),
+  body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
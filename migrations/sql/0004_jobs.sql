@@ -0,0 +1,33 @@
+-- Background job queue. Workers claim due rows with FOR UPDATE SKIP LOCKED;
+-- locked_until is the lease after which a job whose worker died is claimed
+-- again.
+CREATE TABLE IF NOT EXISTS jobs (
+  job_id UUID PRIMARY KEY,
+  kind VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL,
+  unique_key VARCHAR(255),
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL,
+  run_at TIMESTAMPTZ NOT NULL,
+  locked_until TIMESTAMPTZ,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL,
+  finished_at TIMESTAMPTZ,
+  CONSTRAINT jobs_status_check CHECK (status IN ('pending', 'running', 'done', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs (locked_until) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_jobs_finished ON jobs (finished_at) WHERE status IN ('done', 'failed');
+-- At most one queued or running job per kind and unique key.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique ON jobs (kind, unique_key)
+  WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
+
+-- Next run of each periodic job. Advancing next_run_at is the claim that
+-- stops several instances from enqueueing the same run.
+CREATE TABLE IF NOT EXISTS job_schedules (
+  name VARCHAR(100) PRIMARY KEY,
+  spec VARCHAR(100) NOT NULL,
+  next_run_at TIMESTAMPTZ NOT NULL
+);
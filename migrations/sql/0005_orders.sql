@@ -0,0 +1,58 @@
+-- When a merchant takes orders. Opening hours are per weekday (0 = Sunday) in
+-- the merchant's time zone; a merchant without any is always open.
+-- slot_capacity is how many scheduled orders the kitchen takes per delivery
+-- slot.
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS timezone VARCHAR(50) NOT NULL DEFAULT 'Asia/Jakarta';
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS slot_capacity INT NOT NULL DEFAULT 5 CHECK (slot_capacity >= 0);
+
+CREATE TABLE IF NOT EXISTS merchant_hours (
+  merchant_id UUID NOT NULL,
+  weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+  opens_at TIME NOT NULL,
+  closes_at TIME NOT NULL,
+  PRIMARY KEY (merchant_id, weekday),
+  CONSTRAINT merchant_hours_range_check CHECK (opens_at < closes_at),
+  CONSTRAINT fk_merchant_hours_merchant FOREIGN KEY(merchant_id)
+    REFERENCES merchants(merchant_id) ON DELETE CASCADE
+);
+
+-- Orders are paid from the wallet when placed. A pre-order carries the start
+-- of its delivery slot in scheduled_for and waits in 'scheduled' until
+-- release_at, when the release job hands it to the merchant.
+CREATE TABLE IF NOT EXISTS orders (
+  order_id UUID PRIMARY KEY,
+  customer_id UUID NOT NULL,
+  merchant_id UUID NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  total BIGINT NOT NULL CHECK (total >= 0),
+  scheduled_for TIMESTAMPTZ,
+  release_at TIMESTAMPTZ,
+  placed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ,
+  CONSTRAINT orders_status_check CHECK (status IN ('scheduled', 'placed')),
+  CONSTRAINT fk_order_customer FOREIGN KEY(customer_id)
+    REFERENCES users(user_id) ON DELETE CASCADE,
+  CONSTRAINT fk_order_merchant FOREIGN KEY(merchant_id)
+    REFERENCES merchants(merchant_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_release ON orders (release_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_orders_slot ON orders (merchant_id, scheduled_for) WHERE scheduled_for IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id, created_at);
+
+-- Items keep the name and price the customer paid, so later menu changes do
+-- not rewrite past orders.
+CREATE TABLE IF NOT EXISTS order_items (
+  order_item_id UUID PRIMARY KEY,
+  order_id UUID NOT NULL,
+  line INT NOT NULL,
+  menu_id UUID NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  price BIGINT NOT NULL CHECK (price >= 0),
+  quantity INT NOT NULL CHECK (quantity > 0),
+  CONSTRAINT fk_order_item_order FOREIGN KEY(order_id)
+    REFERENCES orders(order_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items (order_id, line);
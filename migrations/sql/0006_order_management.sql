@@ -0,0 +1,32 @@
+-- Merchants accept or reject placed orders, and an order the merchant has not
+-- answered by respond_by is rejected automatically. Rejections and
+-- unavailable items are refunded to the customer's wallet; refunded is the
+-- running total.
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+  CHECK (status IN ('scheduled', 'placed', 'accepted', 'rejected', 'ready'));
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS respond_by TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS prep_minutes INT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS accepted_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS ready_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reject_reason VARCHAR(255);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD CONSTRAINT orders_refunded_check CHECK (refunded BETWEEN 0 AND total);
+
+-- Orders placed before this migration get the default window.
+UPDATE orders SET respond_by = placed_at + INTERVAL '10 minutes'
+WHERE status = 'placed' AND respond_by IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_orders_respond ON orders (respond_by) WHERE status = 'placed';
+CREATE INDEX IF NOT EXISTS idx_orders_merchant ON orders (merchant_id, created_at);
+
+-- An unavailable item is either refunded at once or offered with a
+-- substitute that the customer accepts or declines.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'available';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS substitute_menu_id UUID;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS substitute_name VARCHAR(100);
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS substitute_price BIGINT;
+ALTER TABLE order_items ADD CONSTRAINT order_items_status_check
+  CHECK (status IN ('available', 'substitution_offered', 'substituted', 'refunded'));
@@ -0,0 +1,47 @@
+-- Orders can be cancelled by their customer (free until the merchant accepts,
+-- cancel_fee kept from the refund after), by the merchant after accepting or
+-- by the assigned driver. Merchants and drivers give a reason code.
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+  CHECK (status IN ('scheduled', 'placed', 'accepted', 'rejected', 'ready', 'cancelled'));
+
+-- driver_id is set by dispatch once it assigns a driver.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS driver_id UUID;
+ALTER TABLE orders ADD CONSTRAINT fk_order_driver FOREIGN KEY(driver_id)
+  REFERENCES drivers(driver_id) ON DELETE SET NULL;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(20);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(30);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancel_note VARCHAR(255);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancel_fee BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD CONSTRAINT orders_cancelled_by_check
+  CHECK (cancelled_by IN ('customer', 'merchant', 'driver'));
+ALTER TABLE orders ADD CONSTRAINT orders_cancel_fee_check CHECK (cancel_fee BETWEEN 0 AND total);
+
+CREATE INDEX IF NOT EXISTS idx_orders_driver ON orders (driver_id) WHERE driver_id IS NOT NULL;
+
+-- A customer disputes missing or wrong items of a ready order; an admin
+-- resolves it with a refund through the wallet, never more than the order has
+-- left to refund. An order has at most one open dispute.
+CREATE TABLE IF NOT EXISTS order_disputes (
+  dispute_id UUID PRIMARY KEY,
+  order_id UUID NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  order_item_ids UUID[] NOT NULL,
+  description VARCHAR(1000) NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'open',
+  refund BIGINT NOT NULL DEFAULT 0 CHECK (refund >= 0),
+  resolution VARCHAR(500),
+  resolved_by UUID,
+  created_at TIMESTAMPTZ NOT NULL,
+  resolved_at TIMESTAMPTZ,
+  CONSTRAINT order_disputes_kind_check CHECK (kind IN ('missing_item', 'wrong_item')),
+  CONSTRAINT order_disputes_status_check CHECK (status IN ('open', 'resolved')),
+  CONSTRAINT fk_dispute_order FOREIGN KEY(order_id)
+    REFERENCES orders(order_id) ON DELETE CASCADE,
+  CONSTRAINT fk_dispute_resolver FOREIGN KEY(resolved_by)
+    REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_disputes_open ON order_disputes (order_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_order_disputes_status ON order_disputes (status, created_at);
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+	// ReviewStatusDeleted marks the profile of a deleted account. It is never
+	// public again and cannot be reviewed.
+	ReviewStatusDeleted = "deleted"
+)
+
+type ListFilter struct {
+	Query  string
+	Role   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type AdminUserRes struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	Phone     string     `json:"phone"`
+	Name      string     `json:"name"`
+	Balance   int64      `json:"balance"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type AdminMerchantRes struct {
+	MerchantID uuid.UUID  `json:"merchant_id"`
+	Name       string     `json:"name"`
+	Rating     float64    `json:"rating"`
+	Address    string     `json:"address"`
+	Category   string     `json:"category"`
+	Owner      string     `json:"owner"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+type AdminDriverRes struct {
+	DriverID   uuid.UUID  `json:"driver_id"`
+	Name       string     `json:"name"`
+	Rating     float64    `json:"rating"`
+	License    string     `json:"license"`
+	Area       string     `json:"area"`
+	Income     int        `json:"income"`
+	Username   string     `json:"username"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+type ModerationReq struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type ReviewReq struct {
+	Note string `json:"note" validate:"max=500"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+type Application struct {
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
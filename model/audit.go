@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditLog struct {
+	AuditID   uuid.UUID `json:"audit_id"`
+	ActorID   uuid.UUID `json:"actor_id,omitempty"`
+	Action    string    `json:"action"`
+	Subject   string    `json:"subject"`
+	IP        string    `json:"ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DisputeKindMissingItem = "missing_item"
+	DisputeKindWrongItem   = "wrong_item"
+)
+
+const (
+	DisputeStatusOpen     = "open"
+	DisputeStatusResolved = "resolved"
+)
+
+// Dispute is a customer's complaint about items of a ready order. An admin
+// resolves it, refunding part of the order or nothing.
+type Dispute struct {
+	DisputeID    uuid.UUID   `json:"dispute_id"`
+	OrderID      uuid.UUID   `json:"order_id"`
+	CustomerID   uuid.UUID   `json:"-"`
+	Customer     string      `json:"customer"`
+	Kind         string      `json:"kind"`
+	OrderItemIDs []uuid.UUID `json:"order_item_ids"`
+	Description  string      `json:"description"`
+	Status       string      `json:"status"`
+	Refund       int64       `json:"refund"`
+	Resolution   string      `json:"resolution,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	ResolvedAt   *time.Time  `json:"resolved_at,omitempty"`
+}
+
+type OpenDisputeReq struct {
+	Kind         string      `json:"kind" validate:"required,oneof=missing_item wrong_item"`
+	OrderItemIDs []uuid.UUID `json:"order_item_ids" validate:"required,min=1,max=50,unique"`
+	Description  string      `json:"description" validate:"required,max=1000"`
+}
+
+// ResolveDisputeReq closes a dispute. Refund may be zero, and cannot exceed
+// what the customer has not already had back for the order.
+type ResolveDisputeReq struct {
+	Refund     int64  `json:"refund" validate:"gte=0"`
+	Resolution string `json:"resolution" validate:"required,max=500"`
+}
+
+// DisputeResolution is a resolution as the repository applies it. AdminID
+// is recorded as the actor of the refund.
+type DisputeResolution struct {
+	DisputeID  uuid.UUID
+	Refund     int64
+	Resolution string
+	AdminID    uuid.UUID
+	At         time.Time
+}
+
+// DisputeFilter narrows the admin dispute list. An empty status means all.
+type DisputeFilter struct {
+	Status string `validate:"omitempty,oneof=open resolved"`
+	Limit  int
+	Offset int
+}
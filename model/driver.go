@@ -1,25 +1,77 @@
 package model
 
 import (
+	"io"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/google/uuid"
 )
 
+const (
+	DocumentKindLicense             = "license"
+	DocumentKindVehicleRegistration = "vehicle_registration"
+)
+
 type Driver struct {
 	DriverID uuid.UUID `json:"driver_id,omitempty"`
-	Name     string    `json:"name" validate:"required"`
+	Name     string    `json:"name" validate:"required,max=50"`
 	Rating   float64   `json:"rating,omitempty"`
-	License  string    `json:"license" validate:"required"`
-	Area     string    `json:"area" validate:"required"`
+	License  string    `json:"license" validate:"required,license"`
+	Area     string    `json:"area" validate:"required,max=25"`
 	Income   int       `json:"income,omitempty"`
 	UserID   uuid.UUID `json:"user_id,omitempty"`
 	Username string    `json:"username,omitempty"`
+	Status   string    `json:"status,omitempty"`
 }
 
+type UpdateDriverReq struct {
+	Name    utils.Optional[string] `json:"name" validate:"omitnil,min=1,max=50"`
+	License utils.Optional[string] `json:"license" validate:"omitnil,license"`
+	Area    utils.Optional[string] `json:"area" validate:"omitnil,min=1,max=25"`
+}
+
+// DriverRes is a driver profile. License, income and status only appear in
+// the owner view.
 type DriverRes struct {
 	Name     string  `json:"name"`
 	Rating   float64 `json:"rating"`
-	License  string  `json:"license"`
 	Area     string  `json:"area"`
-	Income   int     `json:"income"`
 	Username string  `json:"username"`
+	License  string  `json:"license,omitempty"`
+	Income   *int    `json:"income,omitempty"`
+	Status   string  `json:"status,omitempty"`
+}
+
+func NewDriverRes(d *Driver, view View) *DriverRes {
+	res := &DriverRes{
+		Name:     d.Name,
+		Rating:   d.Rating,
+		Area:     d.Area,
+		Username: d.Username,
+	}
+	if view == OwnerView {
+		income := d.Income
+		res.License = d.License
+		res.Income = &income
+		res.Status = d.Status
+	}
+	return res
+}
+
+type DriverDocument struct {
+	DocumentID  uuid.UUID `json:"document_id"`
+	DriverID    uuid.UUID `json:"driver_id"`
+	Kind        string    `json:"kind"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+type DriverDocumentUpload struct {
+	Kind     string    `validate:"required,oneof=license vehicle_registration"`
+	FileName string    `validate:"required,max=255"`
+	Body     io.Reader `validate:"-"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+const (
+	LoginScopeUsername = "username"
+	LoginScopeIP       = "ip"
+)
+
+type LoginAttempt struct {
+	Scope        string     `json:"scope"`
+	Subject      string     `json:"subject"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
@@ -1,23 +1,51 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
 
 type Menu struct {
 	MenuID      uuid.UUID `json:"menu_id"`
-	Name        string    `json:"name" validate:"required"`
-	Price       int64     `json:"price" validate:"required"`
+	Name        string    `json:"name" validate:"required,max=100"`
+	Price       int64     `json:"price" validate:"gte=0"`
 	Description string    `json:"description"`
-	Category    string    `json:"category" validate:"required"`
+	Category    string    `json:"category" validate:"required,max=50"`
 	Rating      float64   `json:"rating,omitempty"`
-	Stock       int       `json:"stock,omitempty"`
+	Stock       int       `json:"stock,omitempty" validate:"gte=0"`
 	MerchantID  uuid.UUID `json:"merchant_id,omitempty"`
 }
 
+type UpdateMenuReq struct {
+	Name        utils.Optional[string] `json:"name" validate:"omitnil,min=1,max=100"`
+	Price       utils.Optional[int64]  `json:"price" validate:"omitnil,gte=0"`
+	Description utils.Optional[string] `json:"description"`
+	Category    utils.Optional[string] `json:"category" validate:"omitnil,min=1,max=50"`
+	Stock       utils.Optional[int]    `json:"stock" validate:"omitnil,gte=0"`
+}
+
 type MenuRes struct {
-	Name        string  `json:"name"`
-	Price       int64   `json:"price"`
-	Description string  `json:"description"`
-	Category    string  `json:"category"`
-	Rating      float64 `json:"rating"`
-	Stock       int     `json:"stock"`
+	MenuID      uuid.UUID `json:"menu_id"`
+	Name        string    `json:"name"`
+	Price       int64     `json:"price"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Rating      float64   `json:"rating"`
+	Stock       int       `json:"stock"`
+}
+
+func NewMenuRes(m *Menu) *MenuRes {
+	return &MenuRes{
+		MenuID:      m.MenuID,
+		Name:        m.Name,
+		Price:       m.Price,
+		Description: m.Description,
+		Category:    m.Category,
+		Rating:      m.Rating,
+		Stock:       m.Stock,
+	}
+}
+
+type MenuDeletedRes struct {
+	MenuID uuid.UUID `json:"menu_id"`
 }
@@ -1,17 +1,30 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
 
 type Merchant struct {
 	MerchantID  uuid.UUID `json:"merchant_id,omitempty"`
-	Name        string    `json:"name" validate:"required"`
+	Name        string    `json:"name" validate:"required,max=50"`
 	Rating      float64   `json:"rating,omitempty"`
-	Address     string    `json:"address" validate:"required"`
-	Category    string    `json:"category" validate:"required"`
+	Address     string    `json:"address" validate:"required,max=100"`
+	Category    string    `json:"category" validate:"required,max=50"`
 	Description string    `json:"description" validate:"required"`
 	UserID      uuid.UUID `json:"user_id,omitempty"`
 	Owner       string    `json:"owner,omitempty"`
+	Status      string    `json:"status,omitempty"`
 }
+type UpdateMerchantReq struct {
+	Name        utils.Optional[string] `json:"name" validate:"omitnil,min=1,max=50"`
+	Address     utils.Optional[string] `json:"address" validate:"omitnil,min=1,max=100"`
+	Category    utils.Optional[string] `json:"category" validate:"omitnil,min=1,max=50"`
+	Description utils.Optional[string] `json:"description"`
+}
+
+// MerchantRes is a merchant profile. Status, which tells the owner whether
+// the profile is live yet, only appears in the owner view.
 type MerchantRes struct {
 	Name        string  `json:"name"`
 	Rating      float64 `json:"rating"`
@@ -19,4 +32,45 @@ type MerchantRes struct {
 	Category    string  `json:"category"`
 	Description string  `json:"description"`
 	Owner       string  `json:"owner"`
+	Status      string  `json:"status,omitempty"`
+}
+
+func NewMerchantRes(m *Merchant, view View) *MerchantRes {
+	res := &MerchantRes{
+		Name:        m.Name,
+		Rating:      m.Rating,
+		Address:     m.Address,
+		Category:    m.Category,
+		Description: m.Description,
+		Owner:       m.Owner,
+	}
+	if view == OwnerView {
+		res.Status = m.Status
+	}
+	return res
+}
+
+const (
+	// DefaultMerchantTimezone and DefaultSlotCapacity apply until a merchant
+	// sets its own schedule.
+	DefaultMerchantTimezone = "Asia/Jakarta"
+	DefaultSlotCapacity     = 5
+)
+
+// MerchantSchedule is when a merchant takes orders and how many scheduled
+// orders it accepts per delivery slot. A merchant without opening hours is
+// always open.
+type MerchantSchedule struct {
+	MerchantID   uuid.UUID      `json:"-"`
+	Timezone     string         `json:"timezone" validate:"required,timezone"`
+	SlotCapacity int            `json:"slot_capacity" validate:"gte=0,lte=1000"`
+	Hours        []OpeningHours `json:"hours" validate:"max=7,unique=Weekday,dive"`
+}
+
+// OpeningHours is one weekday's opening time, as HH:MM in the merchant's
+// time zone. Weekday 0 is Sunday; closing past midnight is not supported.
+type OpeningHours struct {
+	Weekday int    `json:"weekday" validate:"gte=0,lte=6"`
+	Opens   string `json:"opens" validate:"required,datetime=15:04"`
+	Closes  string `json:"closes" validate:"required,datetime=15:04"`
 }
@@ -0,0 +1,180 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// OrderStatusScheduled is a pre-order waiting for its release time.
+	OrderStatusScheduled = "scheduled"
+	// OrderStatusPlaced is an order the merchant can see and act on.
+	OrderStatusPlaced = "placed"
+	// OrderStatusAccepted is being prepared.
+	OrderStatusAccepted = "accepted"
+	// OrderStatusRejected was turned down, by the merchant or because they
+	// did not answer in time, and refunded.
+	OrderStatusRejected = "rejected"
+	// OrderStatusReady is waiting for pickup.
+	OrderStatusReady = "ready"
+	// OrderStatusCancelled was called off by its customer, merchant or
+	// driver and refunded, less any cancellation fee.
+	OrderStatusCancelled = "cancelled"
+)
+
+// OrderStatuses lists every order status, in the order an order moves
+// through them.
+var OrderStatuses = []string{OrderStatusScheduled, OrderStatusPlaced, OrderStatusAccepted, OrderStatusReady, OrderStatusRejected, OrderStatusCancelled}
+
+// Who cancelled an order.
+const (
+	CancelledByCustomer = "customer"
+	CancelledByMerchant = "merchant"
+	CancelledByDriver   = "driver"
+)
+
+const (
+	ItemStatusAvailable = "available"
+	// ItemStatusSubstitutionOffered waits for the customer to accept or
+	// decline the substitute the merchant offered.
+	ItemStatusSubstitutionOffered = "substitution_offered"
+	ItemStatusSubstituted         = "substituted"
+	// ItemStatusRefunded was unavailable and has been refunded.
+	ItemStatusRefunded = "refunded"
+)
+
+const (
+	// RejectReasonNoResponse rejects an order the merchant did not accept in
+	// time.
+	RejectReasonNoResponse = "merchant did not respond in time"
+	// RejectReasonNothingLeft rejects an order once every item has been
+	// refunded.
+	RejectReasonNothingLeft = "no items left"
+)
+
+const (
+	WalletKindOrderPayment = "order_payment"
+	WalletKindOrderRefund  = "order_refund"
+)
+
+type CreateOrderReq struct {
+	Merchant string         `json:"merchant" validate:"required,max=25"`
+	Items    []OrderItemReq `json:"items" validate:"required,min=1,max=50,unique=MenuID,dive"`
+	// ScheduledFor asks for delivery in the slot containing this time
+	// instead of as soon as possible.
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+}
+
+type OrderItemReq struct {
+	MenuID   uuid.UUID `json:"menu_id" validate:"required"`
+	Quantity int       `json:"quantity" validate:"gte=1,lte=50"`
+}
+
+type Order struct {
+	OrderID    uuid.UUID   `json:"order_id"`
+	CustomerID uuid.UUID   `json:"-"`
+	Customer   string      `json:"customer"`
+	MerchantID uuid.UUID   `json:"-"`
+	Merchant   string      `json:"merchant"`
+	Status     string      `json:"status"`
+	Items      []OrderItem `json:"items"`
+	Total      int64       `json:"total"`
+	// ScheduledFor is the start of the delivery slot of a pre-order, and
+	// ReleaseAt when it goes to the merchant.
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	ReleaseAt    *time.Time `json:"release_at,omitempty"`
+	PlacedAt     *time.Time `json:"placed_at,omitempty"`
+	// RespondBy is when a placed order is rejected unless the merchant
+	// accepts it first.
+	RespondBy    *time.Time `json:"respond_by,omitempty"`
+	PrepMinutes  int        `json:"prep_minutes,omitempty"`
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+	ReadyAt      *time.Time `json:"ready_at,omitempty"`
+	RejectedAt   *time.Time `json:"rejected_at,omitempty"`
+	RejectReason string     `json:"reject_reason,omitempty"`
+	// DriverID is the driver delivering the order, once dispatch has
+	// assigned one.
+	DriverID     *uuid.UUID `json:"-"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy  string     `json:"cancelled_by,omitempty"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
+	CancelNote   string     `json:"cancel_note,omitempty"`
+	// CancelFee is what a customer who cancelled late was not refunded.
+	CancelFee int64 `json:"cancel_fee,omitempty"`
+	// Refunded is how much of Total has gone back to the customer's wallet.
+	Refunded  int64     `json:"refunded"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrderItem keeps the name and price paid, whatever later happens to the
+// menu.
+type OrderItem struct {
+	OrderItemID uuid.UUID `json:"order_item_id"`
+	MenuID      uuid.UUID `json:"menu_id"`
+	Name        string    `json:"name"`
+	Price       int64     `json:"price"`
+	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"`
+	// Substitute is what the merchant offered in place of an unavailable
+	// item. The customer is never charged more for it.
+	Substitute *Substitute `json:"substitute,omitempty"`
+}
+
+type Substitute struct {
+	MenuID uuid.UUID `json:"menu_id"`
+	Name   string    `json:"name"`
+	Price  int64     `json:"price"`
+}
+
+// OrderFilter narrows a merchant's order list. No statuses means all.
+type OrderFilter struct {
+	Statuses []string `validate:"dive,oneof=scheduled placed accepted ready rejected cancelled"`
+	Limit    int
+	Offset   int
+}
+
+type AcceptOrderReq struct {
+	PrepMinutes int `json:"prep_minutes" validate:"required,gte=1,lte=180"`
+}
+
+type RejectOrderReq struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// ItemUnavailableReq offers a substitute from the merchant's menu, or without
+// one refunds the item.
+type ItemUnavailableReq struct {
+	SubstituteMenuID *uuid.UUID `json:"substitute_menu_id,omitempty"`
+}
+
+type SubstitutionReply struct {
+	Accept *bool `json:"accept" validate:"required"`
+}
+
+// MerchantCancelReq cancels an order the merchant has accepted. A reason of
+// other needs a note.
+type MerchantCancelReq struct {
+	Reason string `json:"reason" validate:"required,oneof=out_of_stock kitchen_problem closing_early other"`
+	Note   string `json:"note,omitempty" validate:"required_if=Reason other,max=255"`
+}
+
+// DriverCancelReq gives up the delivery of an order. A reason of other
+// needs a note.
+type DriverCancelReq struct {
+	Reason string `json:"reason" validate:"required,oneof=vehicle_problem accident merchant_closed customer_unreachable other"`
+	Note   string `json:"note,omitempty" validate:"required_if=Reason other,max=255"`
+}
+
+// OrderCancellation is a cancellation as the repository applies it. ActorID
+// is the user cancelling: the customer, the merchant's owner or the driver.
+// Fee is only charged to a customer cancelling after the merchant accepted.
+type OrderCancellation struct {
+	OrderID uuid.UUID
+	By      string
+	ActorID uuid.UUID
+	Reason  string
+	Note    string
+	Fee     int64
+	At      time.Time
+}
@@ -0,0 +1,32 @@
+package model
+
+import "github.com/google/uuid"
+
+type TOTP struct {
+	UserID   uuid.UUID
+	Secret   string
+	Enabled  bool
+	LastStep *int64
+}
+
+type TOTPSetupRes struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPLoginReq struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type TOTPStatusRes struct {
+	TOTPEnabled bool `json:"totp_enabled"`
+}
+
+type RecoveryCodesRes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
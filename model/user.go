@@ -3,37 +3,95 @@ package model
 import (
 	"time"
 
+	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/google/uuid"
 )
 
 type User struct {
-	UserID    uuid.UUID `json:"user_id,omitempty"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password,omitempty"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	IsOnline  bool      `json:"is_online"`
-	Phone     string    `json:"phone,omitempty"`
-	Balance   int64     `json:"balance,omitempty"`
-	Name      string    `json:"name"`
+	UserID      uuid.UUID `json:"user_id,omitempty"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Password    string    `json:"password,omitempty"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsOnline    bool      `json:"is_online"`
+	Phone       string    `json:"phone,omitempty"`
+	Balance     int64     `json:"balance,omitempty"`
+	Name        string    `json:"name"`
+	TOTPEnabled bool      `json:"totp_enabled,omitempty"`
+	Status      string    `json:"status,omitempty"`
 }
+
+// UserResp is a user profile. Email and phone only appear in the owner view.
 type UserResp struct {
 	Username  string    `json:"username"`
-	Email     string    `json:"email"`
+	Email     string    `json:"email,omitempty"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	Phone     string    `json:"phone,omitempty"`
 	Name      string    `json:"name"`
 }
+
+func NewUserResp(u *User, view View) *UserResp {
+	res := &UserResp{
+		Username:  u.Username,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		Name:      u.Name,
+	}
+	if view == OwnerView {
+		res.Email = u.Email
+		res.Phone = u.Phone
+	}
+	return res
+}
+
+type UserDeletedRes struct {
+	Username string `json:"username"`
+}
 type RegisterReq struct {
-	Username string `json:"username" validate:"required,min=3,max=24"`
+	Username string `json:"username" validate:"required,min=3,max=24,username"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
 	Role     string `json:"role" validate:"required,oneof=driver merchant user"`
-	Phone    string `json:"phone" validate:"required,e164"`
+	Phone    string `json:"phone" validate:"required,idphone"`
 }
 type LoginReq struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
 }
+type LoginRes struct {
+	Username          string `json:"username"`
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+type UpdateUserReq struct {
+	Name  utils.Optional[string] `json:"name" validate:"omitnil,min=1,max=255"`
+	Phone utils.Optional[string] `json:"phone" validate:"omitnil,idphone"`
+}
+
+// UserExport is everything stored about an account. Orders are the ones
+// the user placed as a customer, with their items and any substitution or
+// refund. Saved addresses are not part of it because the API does not store
+// addresses yet; they belong here once it does.
+type UserExport struct {
+	Profile    UserExportProfile   `json:"profile"`
+	Merchant   *MerchantRes        `json:"merchant,omitempty"`
+	Driver     *DriverRes          `json:"driver,omitempty"`
+	Documents  []DriverDocument    `json:"documents"`
+	Orders     []Order             `json:"orders"`
+	Wallet     []WalletTransaction `json:"wallet"`
+	ExportedAt time.Time           `json:"exported_at"`
+}
+type UserExportProfile struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	Phone       string    `json:"phone"`
+	Name        string    `json:"name"`
+	Balance     int64     `json:"balance"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+)
+
+// failingFields returns the fields err reports, or nil if it is not a
+// validation error.
+func failingFields(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *utils.ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		if f.Rule != "max" {
+			t.Errorf("field %s failed %q, want max", f.Field, f.Rule)
+		}
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+// The create payloads are bounded by their column widths, as the update
+// payloads are, so over-long input is a 400 rather than a database error.
+
+func TestMerchantValidation(t *testing.T) {
+	m := Merchant{Name: strings.Repeat("n", 50), Address: strings.Repeat("a", 100), Category: strings.Repeat("c", 50), Description: "Fried rice"}
+	if err := utils.Validate(&m); err != nil {
+		t.Fatalf("merchant at the limits: %v", err)
+	}
+	m.Name += "n"
+	m.Address += "a"
+	m.Category += "c"
+	if got := failingFields(t, utils.Validate(&m)); !slices.Equal(got, []string{"name", "address", "category"}) {
+		t.Errorf("over-long merchant failed on %v", got)
+	}
+}
+
+func TestDriverValidation(t *testing.T) {
+	d := Driver{Name: strings.Repeat("n", 50), License: "B1234XYZ", Area: strings.Repeat("a", 25)}
+	if err := utils.Validate(&d); err != nil {
+		t.Fatalf("driver at the limits: %v", err)
+	}
+	d.Name += "n"
+	d.Area += "a"
+	if got := failingFields(t, utils.Validate(&d)); !slices.Equal(got, []string{"name", "area"}) {
+		t.Errorf("over-long driver failed on %v", got)
+	}
+}
+
+func TestMenuValidation(t *testing.T) {
+	m := Menu{Name: strings.Repeat("n", 100), Price: 10000, Category: strings.Repeat("c", 50)}
+	if err := utils.Validate(&m); err != nil {
+		t.Fatalf("menu at the limits: %v", err)
+	}
+	m.Name += "n"
+	m.Category += "c"
+	if got := failingFields(t, utils.Validate(&m)); !slices.Equal(got, []string{"name", "category"}) {
+		t.Errorf("over-long menu failed on %v", got)
+	}
+}
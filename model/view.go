@@ -0,0 +1,31 @@
+package model
+
+import (
+	"context"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+)
+
+// View selects which fields a response exposes to the caller.
+type View int
+
+const (
+	// PublicView is what anyone, signed in or not, may see.
+	PublicView View = iota
+	// OwnerView adds contact details and other private fields. It is used
+	// for the account holder and for admins.
+	OwnerView
+)
+
+// ViewFor returns OwnerView when ctx is authenticated as username or as an
+// admin, and PublicView otherwise.
+func ViewFor(ctx context.Context, username string) View {
+	caller, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		return PublicView
+	}
+	if caller.Username == username || caller.Role == "admin" {
+		return OwnerView
+	}
+	return PublicView
+}
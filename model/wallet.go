@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const WalletKindAdminAdjustment = "admin_adjustment"
+
+type WalletTransaction struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Amount        int64     `json:"amount"`
+	BalanceAfter  int64     `json:"balance_after"`
+	Kind          string    `json:"kind"`
+	Reason        string    `json:"reason,omitempty"`
+	ActorID       uuid.UUID `json:"actor_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type WalletAdjustReq struct {
+	Amount int64  `json:"amount" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Ref returns a schema pointing at the named component.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	utilsPkg = reflect.TypeOf(utils.APIError{}).PkgPath()
+)
+
+// SchemaOf returns the schema for the type of v. Named struct types are
+// added to the document's components once and referenced from then on;
+// anonymous structs are inlined.
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	if inner, ok := optionalValue(t); ok {
+		s := d.schema(inner)
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := d.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := t.Name()
+		if existing, ok := d.Components.Schemas[name]; ok {
+			if existing == nil || d.componentTypes[name] == t {
+				return Ref(name)
+			}
+			panic(fmt.Sprintf("openapi: two types named %s", name))
+		}
+		if d.componentTypes == nil {
+			d.componentTypes = map[string]reflect.Type{}
+		}
+		// Reserve the name first so recursive types terminate.
+		d.Components.Schemas[name] = nil
+		d.componentTypes[name] = t
+		d.Components.Schemas[name] = d.structSchema(t)
+		return Ref(name)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			d.addFields(s, f.Type)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := d.schema(f.Type)
+		if applyRules(prop, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyRules copies validate rules onto s and reports whether the field is
+// required. Rules that have no OpenAPI equivalent are ignored. Constraints
+// on a referenced schema cannot be expressed in OpenAPI 3.0, so only
+// required is taken from those.
+func applyRules(s *Schema, tag string) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		if key == "required" {
+			required = true
+			continue
+		}
+		if s.Ref != "" {
+			continue
+		}
+		switch key {
+		case "min", "gte":
+			setBound(s, param, true)
+		case "max", "lte":
+			setBound(s, param, false)
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "email":
+			s.Format = "email"
+		default:
+			if pattern, ok := utils.ValidationPattern(key); ok {
+				s.Pattern = pattern
+			}
+		}
+	}
+	return required
+}
+
+func setBound(s *Schema, param string, lower bool) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		if lower {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	case "array":
+		// minItems/maxItems are not needed by any model yet.
+	default:
+		f := float64(n)
+		if lower {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	}
+}
+
+// optionalValue reports the value type of a utils.Optional field. Optional
+// is a merge-patch wrapper, so its schema is the wrapped type made nullable.
+func optionalValue(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != utilsPkg || !strings.HasPrefix(t.Name(), "Optional[") {
+		return nil, false
+	}
+	f, ok := t.FieldByName("Value")
+	if !ok {
+		return nil, false
+	}
+	return f.Type, true
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from a MemoryStore.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled completely; after that it
+	// is no different from a missing one.
+	full time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per instance, so
+// use PostgresStore when several replicas serve traffic.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (ms *MemoryStore) Take(_ context.Context, key string, p Policy, now time.Time) (Result, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sweep(now)
+
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.Limit), updated: now}
+		ms.buckets[key] = b
+	}
+	tokens := p.refill(b.tokens, b.updated, now)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	b.tokens = tokens
+	b.updated = now
+	b.full = now.Add(p.Period)
+	return newResult(p, tokens, allowed), nil
+}
+
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.swept) < sweepInterval {
+		return
+	}
+	ms.swept = now
+	for key, b := range ms.buckets {
+		if now.After(b.full) {
+			delete(ms.buckets, key)
+		}
+	}
+}
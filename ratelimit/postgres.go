@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps buckets in the rate_limits table so that every replica
+// shares the same limits. Each Take is a single upsert, so concurrent
+// requests for one key are serialised by the row lock.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (ps *PostgresStore) Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	// The refill expression matches Policy.refill; allowed records whether
+	// this request got a token so the caller can tell from RETURNING.
+	var tokens float64
+	var allowed bool
+	err := ps.db.QueryRow(ctx, `
+    INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at, expires_at)
+    VALUES ($1, $2::float8 - 1, TRUE, $3, $5)
+    ON CONFLICT (key) DO UPDATE SET
+      tokens = CASE
+        WHEN LEAST($2::float8, rl.tokens + GREATEST(EXTRACT(EPOCH FROM $3 - rl.updated_at)::float8, 0) * $4::float8) >= 1
+        THEN LEAST($2::float8, rl.tokens + GREATEST(EXTRACT(EPOCH FROM $3 - rl.updated_at)::float8, 0) * $4::float8) - 1
+        ELSE LEAST($2::float8, rl.tokens + GREATEST(EXTRACT(EPOCH FROM $3 - rl.updated_at)::float8, 0) * $4::float8)
+      END,
+      allowed = LEAST($2::float8, rl.tokens + GREATEST(EXTRACT(EPOCH FROM $3 - rl.updated_at)::float8, 0) * $4::float8) >= 1,
+      updated_at = GREATEST(rl.updated_at, $3),
+      expires_at = $5
+    RETURNING tokens, allowed
+    `, key, float64(p.Limit), now, p.rate(), now.Add(p.Period)).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w: %w", utils.ErrDatabase, err)
+	}
+	return newResult(p, tokens, allowed), nil
+}
+
+// PruneJob periodically deletes buckets that have refilled completely. A
+// missing bucket behaves like a full one, so this only keeps the table small.
+type PruneJob struct{}
+
+func (PruneJob) Kind() string { return "prune_rate_limits" }
+
+func (ps *PostgresStore) Prune(ctx context.Context, _ PruneJob) error {
+	_, err := ps.db.Exec(ctx, `
+    DELETE FROM rate_limits WHERE expires_at < $1
+    `, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to prune rate limit buckets: %w: %w", utils.ErrDatabase, err)
+	}
+	return nil
+}
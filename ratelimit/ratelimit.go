@@ -0,0 +1,75 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// storage for the bucket state.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Policy is a token bucket holding up to Limit tokens that refills at a steady
+// rate, going from empty to full over Period. Every request takes one token.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled reports whether the policy limits anything.
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+// rate is the refill rate in tokens per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// refill returns the tokens in a bucket last updated at updated, as of now.
+func (p Policy) refill(tokens float64, updated, now time.Time) float64 {
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens += elapsed * p.rate()
+	}
+	return math.Min(tokens, float64(p.Limit))
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+func newResult(p Policy, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     p.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(p.Limit) - tokens) / p.rate()),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / p.rate())
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps bucket state. Take refills the bucket for key, removes one token
+// if there is one and reports the outcome. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error)
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestPolicyEnabled(t *testing.T) {
+	tests := []struct {
+		p    Policy
+		want bool
+	}{
+		{Policy{Limit: 10, Period: time.Minute}, true},
+		{Policy{Limit: 0, Period: time.Minute}, false},
+		{Policy{Limit: 10}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Enabled(); got != tt.want {
+			t.Errorf("%+v.Enabled() = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyRefill(t *testing.T) {
+	p := Policy{Limit: 10, Period: 10 * time.Second} // one token a second
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{"no time passed", 3, 0, 3},
+		{"partial token", 3, 500 * time.Millisecond, 3.5},
+		{"whole tokens", 0, 4 * time.Second, 4},
+		{"capped at limit", 8, time.Minute, 10},
+		{"clock went backwards", 3, -5 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.refill(tt.tokens, start, start.Add(tt.elapsed)); got != tt.want {
+				t.Errorf("refill = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	p := Policy{Limit: 10, Period: 10 * time.Second}
+	tests := []struct {
+		name    string
+		tokens  float64
+		allowed bool
+		want    Result
+	}{
+		{"full after taking one", 9, true, Result{Allowed: true, Limit: 10, Remaining: 9, Reset: time.Second}},
+		{"fractional tokens round down", 2.5, true, Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 7500 * time.Millisecond}},
+		{"empty", 0, false, Result{Allowed: false, Limit: 10, Remaining: 0, Reset: 10 * time.Second, RetryAfter: time.Second}},
+		{"almost a token", 0.75, false, Result{Allowed: false, Limit: 10, Remaining: 0, Reset: 9250 * time.Millisecond, RetryAfter: 250 * time.Millisecond}},
+		{"at limit", 10, true, Result{Allowed: true, Limit: 10, Remaining: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newResult(p, tt.tokens, tt.allowed); got != tt.want {
+				t.Errorf("newResult = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	p := Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+	now := start
+
+	take := func(key string) Result {
+		t.Helper()
+		res, err := ms.Take(ctx, key, p, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// A new bucket starts full, so the burst goes through.
+	for want := 2; want >= 0; want-- {
+		if res := take("a"); !res.Allowed || res.Remaining != want {
+			t.Fatalf("burst take = %+v, want allowed with %d remaining", res, want)
+		}
+	}
+	res := take("a")
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("take on empty bucket = %+v, want rejected with a 1s retry", res)
+	}
+
+	// Buckets are independent.
+	if res := take("b"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other key = %+v", res)
+	}
+
+	// A rejected request costs nothing, and a token comes back each second.
+	now = now.Add(time.Second)
+	if res := take("a"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("take after refill = %+v", res)
+	}
+	if res := take("a"); res.Allowed {
+		t.Errorf("second take after one refill = %+v", res)
+	}
+
+	// After a full period the bucket is full again.
+	now = now.Add(p.Period)
+	if res := take("a"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("take after full refill = %+v", res)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	p := Policy{Name: "test", Limit: 1, Period: time.Second}
+	for i := range 3 {
+		if _, err := ms.Take(ctx, fmt.Sprint(i), p, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ms.buckets) != 3 {
+		t.Fatalf("buckets = %d, want 3", len(ms.buckets))
+	}
+
+	// Buckets that have refilled are dropped on the next sweep; one used
+	// within its period is kept.
+	now := start.Add(sweepInterval)
+	if _, err := ms.Take(ctx, "0", p, now.Add(-p.Period/2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.Take(ctx, "fresh", p, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ms.buckets["1"]; ok {
+		t.Error("idle bucket survived the sweep")
+	}
+	if _, ok := ms.buckets["0"]; !ok {
+		t.Error("recently used bucket was swept")
+	}
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type AdminRepoImpl interface {
+	ListUsersRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminUserRes, error)
+	GetAdminUserRepo(ctx context.Context, username string) (*model.AdminUserRes, error)
+	SetUserStatusRepo(ctx context.Context, userID uuid.UUID, status string, audit *model.AuditLog) error
+	ListMerchantsRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminMerchantRes, error)
+	GetAdminMerchantRepo(ctx context.Context, username string) (*model.AdminMerchantRes, error)
+	ReviewMerchantRepo(ctx context.Context, merchantID uuid.UUID, status, note string, audit *model.AuditLog) error
+	ListDriversRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminDriverRes, error)
+	GetAdminDriverRepo(ctx context.Context, username string) (*model.AdminDriverRes, error)
+	ReviewDriverRepo(ctx context.Context, driverID uuid.UUID, status, note string, audit *model.AuditLog) error
+	AdjustBalanceRepo(ctx context.Context, new *model.WalletTransaction, audit *model.AuditLog) error
+}
+type AdminRepo struct {
+	db  *pgxpool.Pool
+	zap *zap.Logger
+}
+
+func NewAdminRepo(db *pgxpool.Pool, zap *zap.Logger) *AdminRepo {
+	return &AdminRepo{
+		db:  db,
+		zap: zap,
+	}
+}
+
+const adminUserColumns = `user_id, username, email, role, status, created_at, COALESCE(phone, ''), name,
+      COALESCE(balance, 0), deleted_at`
+
+func scanAdminUser(row pgx.Row, res *model.AdminUserRes) error {
+	return row.Scan(&res.UserID, &res.Username, &res.Email, &res.Role, &res.Status, &res.CreatedAt,
+		&res.Phone, &res.Name, &res.Balance, &res.DeletedAt)
+}
+
+func (ar *AdminRepo) ListUsersRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminUserRes, error) {
+	rows, err := ar.db.Query(ctx, `
+    SELECT `+adminUserColumns+` FROM users
+    WHERE ($1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%' OR name ILIKE '%' || $1 || '%')
+      AND ($2 = '' OR role = $2)
+      AND ($3 = '' OR status = $3)
+    ORDER BY created_at DESC
+    LIMIT $4 OFFSET $5
+    `, filter.Query, filter.Role, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list users: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.AdminUserRes{}
+	for rows.Next() {
+		var user model.AdminUserRes
+		if err := scanAdminUser(rows, &user); err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list users: %w", utils.ErrDatabase)
+		}
+		res = append(res, user)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list users: %w", utils.ErrDatabase)
+	}
+	return res, nil
+}
+
+func (ar *AdminRepo) GetAdminUserRepo(ctx context.Context, username string) (*model.AdminUserRes, error) {
+	var res model.AdminUserRes
+	err := scanAdminUser(ar.db.QueryRow(ctx, `
+    SELECT `+adminUserColumns+` FROM users WHERE username = $1
+    `, username), &res)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, ar.zap).Warn(utils.ErrNotFound.Error(), zap.String("username", username))
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch user: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// withAudit runs write and inserts audit in one transaction, so an admin
+// action is never applied without its audit entry or recorded without
+// having been applied.
+func (ar *AdminRepo) withAudit(ctx context.Context, audit *model.AuditLog, write func(tx pgx.Tx) error) error {
+	tx, err := ar.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, audit); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to write audit log: %w", utils.ErrDatabase)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+func (ar *AdminRepo) SetUserStatusRepo(ctx context.Context, userID uuid.UUID, status string, audit *model.AuditLog) error {
+	return ar.withAudit(ctx, audit, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+    UPDATE users SET status = $2 WHERE user_id = $1 AND deleted_at IS NULL
+    `, userID, status)
+		if err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to update user status: %w", utils.ErrDatabase)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("no user updated: %w", utils.ErrNotFound)
+		}
+		return nil
+	})
+}
+
+const adminMerchantColumns = `merchant_id, name, COALESCE(rating, 0), COALESCE(address, ''), COALESCE(category, ''),
+      owner, user_id, status, COALESCE(review_note, ''), reviewed_at`
+
+func scanAdminMerchant(row pgx.Row, res *model.AdminMerchantRes) error {
+	return row.Scan(&res.MerchantID, &res.Name, &res.Rating, &res.Address, &res.Category,
+		&res.Owner, &res.UserID, &res.Status, &res.ReviewNote, &res.ReviewedAt)
+}
+
+func (ar *AdminRepo) ListMerchantsRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminMerchantRes, error) {
+	rows, err := ar.db.Query(ctx, `
+    SELECT `+adminMerchantColumns+` FROM merchants
+    WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR owner ILIKE '%' || $1 || '%')
+      AND ($2 = '' OR status = $2)
+    ORDER BY name
+    LIMIT $3 OFFSET $4
+    `, filter.Query, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list merchants: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.AdminMerchantRes{}
+	for rows.Next() {
+		var merchant model.AdminMerchantRes
+		if err := scanAdminMerchant(rows, &merchant); err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list merchants: %w", utils.ErrDatabase)
+		}
+		res = append(res, merchant)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list merchants: %w", utils.ErrDatabase)
+	}
+	return res, nil
+}
+
+func (ar *AdminRepo) GetAdminMerchantRepo(ctx context.Context, username string) (*model.AdminMerchantRes, error) {
+	var res model.AdminMerchantRes
+	err := scanAdminMerchant(ar.db.QueryRow(ctx, `
+    SELECT `+adminMerchantColumns+` FROM merchants WHERE owner = $1
+    `, username), &res)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, ar.zap).Warn(utils.ErrNotFound.Error(), zap.String("owner", username))
+		return nil, fmt.Errorf("no merchant found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch merchant: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// ReviewMerchantRepo records the review with audit.ActorID as the reviewer.
+func (ar *AdminRepo) ReviewMerchantRepo(ctx context.Context, merchantID uuid.UUID, status, note string, audit *model.AuditLog) error {
+	return ar.withAudit(ctx, audit, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+    UPDATE merchants SET status = $2, review_note = $3, reviewed_by = $4, reviewed_at = $5
+    WHERE merchant_id = $1 AND status <> 'deleted'
+    `, merchantID, status, note, audit.ActorID, audit.CreatedAt)
+		if err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to review merchant: %w", utils.ErrDatabase)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("no merchant to review: %w", utils.ErrNotFound)
+		}
+		return nil
+	})
+}
+
+const adminDriverColumns = `driver_id, name, COALESCE(rating, 0), COALESCE(license, ''), COALESCE(area, ''),
+      COALESCE(income, 0), username, user_id, status, COALESCE(review_note, ''), reviewed_at`
+
+func scanAdminDriver(row pgx.Row, res *model.AdminDriverRes) error {
+	return row.Scan(&res.DriverID, &res.Name, &res.Rating, &res.License, &res.Area,
+		&res.Income, &res.Username, &res.UserID, &res.Status, &res.ReviewNote, &res.ReviewedAt)
+}
+
+func (ar *AdminRepo) ListDriversRepo(ctx context.Context, filter *model.ListFilter) ([]model.AdminDriverRes, error) {
+	rows, err := ar.db.Query(ctx, `
+    SELECT `+adminDriverColumns+` FROM drivers
+    WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR username ILIKE '%' || $1 || '%' OR area ILIKE '%' || $1 || '%')
+      AND ($2 = '' OR status = $2)
+    ORDER BY name
+    LIMIT $3 OFFSET $4
+    `, filter.Query, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list drivers: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.AdminDriverRes{}
+	for rows.Next() {
+		var driver model.AdminDriverRes
+		if err := scanAdminDriver(rows, &driver); err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list drivers: %w", utils.ErrDatabase)
+		}
+		res = append(res, driver)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list drivers: %w", utils.ErrDatabase)
+	}
+	return res, nil
+}
+
+func (ar *AdminRepo) GetAdminDriverRepo(ctx context.Context, username string) (*model.AdminDriverRes, error) {
+	var res model.AdminDriverRes
+	err := scanAdminDriver(ar.db.QueryRow(ctx, `
+    SELECT `+adminDriverColumns+` FROM drivers WHERE username = $1
+    `, username), &res)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, ar.zap).Warn(utils.ErrNotFound.Error(), zap.String("username", username))
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch driver: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// ReviewDriverRepo records the review with audit.ActorID as the reviewer.
+func (ar *AdminRepo) ReviewDriverRepo(ctx context.Context, driverID uuid.UUID, status, note string, audit *model.AuditLog) error {
+	return ar.withAudit(ctx, audit, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+    UPDATE drivers SET status = $2, review_note = $3, reviewed_by = $4, reviewed_at = $5
+    WHERE driver_id = $1 AND status <> 'deleted'
+    `, driverID, status, note, audit.ActorID, audit.CreatedAt)
+		if err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to review driver: %w", utils.ErrDatabase)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("no driver to review: %w", utils.ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// AdjustBalanceRepo applies new.Amount to the user's balance and appends the
+// ledger entry and the audit entry in one transaction. The balance may not go
+// negative.
+func (ar *AdminRepo) AdjustBalanceRepo(ctx context.Context, new *model.WalletTransaction, audit *model.AuditLog) error {
+	return ar.withAudit(ctx, audit, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+    UPDATE users SET balance = COALESCE(balance, 0) + $2
+    WHERE user_id = $1 AND deleted_at IS NULL AND COALESCE(balance, 0) + $2 >= 0
+    RETURNING balance
+    `, new.UserID, new.Amount).Scan(&new.BalanceAfter)
+		if err == pgx.ErrNoRows {
+			utils.Logger(ctx, ar.zap).Warn("balance adjustment rejected", zap.String("user_id", new.UserID.String()), zap.Int64("amount", new.Amount))
+			return fmt.Errorf("user not found or balance would be negative: %w", utils.ErrBadRequest)
+		} else if err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to adjust balance: %w", utils.ErrDatabase)
+		}
+		_, err = tx.Exec(ctx, `
+    INSERT INTO wallet_transactions (transaction_id, user_id, amount, balance_after, kind, reason, actor_id, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, new.TransactionID, new.UserID, new.Amount, new.BalanceAfter, new.Kind, new.Reason, new.ActorID, new.CreatedAt)
+		if err != nil {
+			utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to record wallet transaction: %w", utils.ErrDatabase)
+		}
+		return nil
+	})
+}
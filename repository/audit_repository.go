@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type AuditRepoImpl interface {
+	CreateAuditRepo(ctx context.Context, new *model.AuditLog) error
+}
+type AuditRepo struct {
+	db  *pgxpool.Pool
+	zap *zap.Logger
+}
+
+func NewAuditRepo(db *pgxpool.Pool, zap *zap.Logger) *AuditRepo {
+	return &AuditRepo{
+		db:  db,
+		zap: zap,
+	}
+}
+
+func (ar *AuditRepo) CreateAuditRepo(ctx context.Context, new *model.AuditLog) error {
+	if err := insertAudit(ctx, ar.db, new); err != nil {
+		utils.Logger(ctx, ar.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to write audit log: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+func insertAudit(ctx context.Context, db execer, new *model.AuditLog) error {
+	var actorID *uuid.UUID
+	if new.ActorID != uuid.Nil {
+		actorID = &new.ActorID
+	}
+	_, err := db.Exec(ctx, `
+    INSERT INTO audit_logs (audit_id, actor_id, action, subject, ip, detail, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, new.AuditID, actorID, new.Action, new.Subject, new.IP, new.Detail, new.CreatedAt)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
@@ -13,8 +14,13 @@ import (
 
 type DriverRepoImpl interface {
 	CreateDriverRepo(ctx context.Context, new *model.Driver) error
-	GetDriverRepo(ctx context.Context, username string) (*model.DriverRes, error)
-	UpdateDriverRepo(ctx context.Context, query string, args []interface{}) error
+	GetDriverRepo(ctx context.Context, username string) (*model.Driver, error)
+	UpdateDriverRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Driver, error)
+	GetDriverApplicationRepo(ctx context.Context, username string) (*model.Application, error)
+	GetDriverIDRepo(ctx context.Context, username string) (uuid.UUID, error)
+	CreateDriverDocumentRepo(ctx context.Context, new *model.DriverDocument) error
+	ListDriverDocumentsRepo(ctx context.Context, driverID uuid.UUID) ([]model.DriverDocument, error)
+	GetDriverDocumentRepo(ctx context.Context, driverID, documentID uuid.UUID) (*model.DriverDocument, error)
 }
 type DriverRepo struct {
 	db  *pgxpool.Pool
@@ -32,49 +38,152 @@ func (dr *DriverRepo) CreateDriverRepo(ctx context.Context, new *model.Driver) e
 	var exists bool
 	err := dr.db.QueryRow(ctx, `
     SELECT EXISTS (SELECT 1 FROM drivers WHERE user_id = $1 OR username = $2)
-    `, new.DriverID, new.Username).Scan(&exists)
+    `, new.UserID, new.Username).Scan(&exists)
 	if err != nil {
-		dr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("%w: %w", utils.ErrUnexpected, utils.ErrDatabase)
 	}
 	if exists {
-		dr.zap.Warn(utils.ErrUniqueConstraint.Error(), zap.String("Merchant exists", new.Name))
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.String("Merchant exists", new.Name))
 		return fmt.Errorf("driver already exists: %w", utils.ErrUniqueConstraint)
 	}
 
 	_, err = dr.db.Exec(ctx, `
-    INSERT INTO drivers (driver_id, name, rating, license, area, income, user_id, username)
-    VALUES ($1, $2, $3, $4 ,$5 , $6, $7, $8)
-    `, new.DriverID, new.Name, new.Rating, new.License, new.Area, new.Income, new.UserID, new.Username)
-	if err != nil {
-		dr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+    INSERT INTO drivers (driver_id, name, rating, license, area, income, user_id, username, status)
+    VALUES ($1, $2, $3, $4 ,$5 , $6, $7, $8, $9)
+    `, new.DriverID, new.Name, new.Rating, new.License, new.Area, new.Income, new.UserID, new.Username, new.Status)
+	if isUniqueViolation(err) {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.Error(err))
+		return fmt.Errorf("license already registered: %w", utils.ErrUniqueConstraint)
+	} else if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("failed to create driver: %w", utils.ErrDatabase)
 	}
 	return nil
 }
 
-func (dr *DriverRepo) GetDriverRepo(ctx context.Context, username string) (*model.DriverRes, error) {
-	var res model.DriverRes
+func (dr *DriverRepo) GetDriverRepo(ctx context.Context, username string) (*model.Driver, error) {
+	var res model.Driver
 	row := dr.db.QueryRow(ctx, `
-    SELECT name, rating, license, area, income FROM drivers
-    WHERE username = $1
+    SELECT driver_id, name, COALESCE(rating, 0), COALESCE(license, ''), COALESCE(area, ''), COALESCE(income, 0),
+      user_id, username, status
+    FROM drivers WHERE username = $1 AND status = 'approved'
     `, username)
-	err := row.Scan(res.Name, res.Rating, res.Area, res.Income)
-	if err != pgx.ErrNoRows {
-		dr.zap.Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+	err := row.Scan(&res.DriverID, &res.Name, &res.Rating, &res.License, &res.Area, &res.Income,
+		&res.UserID, &res.Username, &res.Status)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
 		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		dr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch driver info: %w", utils.ErrDatabase)
 	}
 	return &res, nil
 }
 
-func (dr *DriverRepo) UpdateDriverRepo(ctx context.Context, query string, args []interface{}) error {
-	_, err := dr.db.Exec(ctx, fmt.Sprintf(`UPDATE drivers SET %s`, query), args)
+func (dr *DriverRepo) UpdateDriverRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Driver, error) {
+	query, args := patch.Where("user_id", userID).SQL("drivers", `
+    driver_id, name, COALESCE(rating, 0), COALESCE(license, ''), COALESCE(area, ''), COALESCE(income, 0),
+    user_id, username, status
+    `)
+	var res model.Driver
+	err := dr.db.QueryRow(ctx, query, args...).
+		Scan(&res.DriverID, &res.Name, &res.Rating, &res.License, &res.Area, &res.Income,
+			&res.UserID, &res.Username, &res.Status)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrNotFound.Error(), zap.String("UserID", userID.String()))
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	} else if isUniqueViolation(err) {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.Error(err))
+		return nil, fmt.Errorf("license already registered: %w", utils.ErrUniqueConstraint)
+	} else if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to update driver: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+func (dr *DriverRepo) GetDriverApplicationRepo(ctx context.Context, username string) (*model.Application, error) {
+	var res model.Application
+	err := dr.db.QueryRow(ctx, `
+    SELECT status, COALESCE(review_note, ''), reviewed_at FROM drivers WHERE username = $1
+    `, username).Scan(&res.Status, &res.ReviewNote, &res.ReviewedAt)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch driver application: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+func (dr *DriverRepo) GetDriverIDRepo(ctx context.Context, username string) (uuid.UUID, error) {
+	var driverID uuid.UUID
+	err := dr.db.QueryRow(ctx, `
+    SELECT driver_id FROM drivers WHERE username = $1
+    `, username).Scan(&driverID)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return uuid.Nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to fetch driver: %w", utils.ErrDatabase)
+	}
+	return driverID, nil
+}
+
+func (dr *DriverRepo) CreateDriverDocumentRepo(ctx context.Context, new *model.DriverDocument) error {
+	_, err := dr.db.Exec(ctx, `
+    INSERT INTO driver_documents (document_id, driver_id, kind, file_name, content_type, size, storage_key, uploaded_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, new.DocumentID, new.DriverID, new.Kind, new.FileName, new.ContentType, new.Size, new.StorageKey, new.UploadedAt)
 	if err != nil {
-		dr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return fmt.Errorf("%w", utils.ErrDatabase)
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to save driver document: %w", utils.ErrDatabase)
 	}
 	return nil
 }
+
+func (dr *DriverRepo) ListDriverDocumentsRepo(ctx context.Context, driverID uuid.UUID) ([]model.DriverDocument, error) {
+	rows, err := dr.db.Query(ctx, `
+    SELECT document_id, driver_id, kind, file_name, content_type, size, storage_key, uploaded_at
+    FROM driver_documents WHERE driver_id = $1 ORDER BY uploaded_at
+    `, driverID)
+	if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list driver documents: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.DriverDocument{}
+	for rows.Next() {
+		var doc model.DriverDocument
+		err := rows.Scan(&doc.DocumentID, &doc.DriverID, &doc.Kind, &doc.FileName, &doc.ContentType, &doc.Size, &doc.StorageKey, &doc.UploadedAt)
+		if err != nil {
+			utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list driver documents: %w", utils.ErrDatabase)
+		}
+		res = append(res, doc)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list driver documents: %w", utils.ErrDatabase)
+	}
+	return res, nil
+}
+
+func (dr *DriverRepo) GetDriverDocumentRepo(ctx context.Context, driverID, documentID uuid.UUID) (*model.DriverDocument, error) {
+	var doc model.DriverDocument
+	err := dr.db.QueryRow(ctx, `
+    SELECT document_id, driver_id, kind, file_name, content_type, size, storage_key, uploaded_at
+    FROM driver_documents WHERE driver_id = $1 AND document_id = $2
+    `, driverID, documentID).Scan(&doc.DocumentID, &doc.DriverID, &doc.Kind, &doc.FileName, &doc.ContentType, &doc.Size, &doc.StorageKey, &doc.UploadedAt)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, dr.zap).Warn(utils.ErrNotFound.Error(), zap.String("document_id", documentID.String()))
+		return nil, fmt.Errorf("no document found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, dr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch driver document: %w", utils.ErrDatabase)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type LoginAttemptRepoImpl interface {
+	GetLoginAttemptRepo(ctx context.Context, scope, subject string) (*model.LoginAttempt, error)
+	RecordLoginFailureRepo(ctx context.Context, scope, subject string, now, windowStart time.Time) (*model.LoginAttempt, error)
+	LockLoginRepo(ctx context.Context, scope, subject string, until time.Time) error
+	ResetLoginAttemptRepo(ctx context.Context, scope, subject string) error
+	PruneLoginAttemptsRepo(ctx context.Context, before, now time.Time) (int64, error)
+}
+type LoginAttemptRepo struct {
+	db  *pgxpool.Pool
+	zap *zap.Logger
+}
+
+func NewLoginAttemptRepo(db *pgxpool.Pool, zap *zap.Logger) *LoginAttemptRepo {
+	return &LoginAttemptRepo{
+		db:  db,
+		zap: zap,
+	}
+}
+
+func (lr *LoginAttemptRepo) GetLoginAttemptRepo(ctx context.Context, scope, subject string) (*model.LoginAttempt, error) {
+	res := model.LoginAttempt{Scope: scope, Subject: subject}
+	err := lr.db.QueryRow(ctx, `
+    SELECT failures, last_failed_at, locked_until FROM login_attempts
+    WHERE scope = $1 AND subject = $2
+    `, scope, subject).Scan(&res.Failures, &res.LastFailedAt, &res.LockedUntil)
+	if err == pgx.ErrNoRows {
+		return &res, nil
+	} else if err != nil {
+		utils.Logger(ctx, lr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch login attempt: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// RecordLoginFailureRepo increments the failure counter, restarting it when
+// the previous failure happened before windowStart.
+func (lr *LoginAttemptRepo) RecordLoginFailureRepo(ctx context.Context, scope, subject string, now, windowStart time.Time) (*model.LoginAttempt, error) {
+	res := model.LoginAttempt{Scope: scope, Subject: subject}
+	err := lr.db.QueryRow(ctx, `
+    INSERT INTO login_attempts (scope, subject, failures, last_failed_at)
+    VALUES ($1, $2, 1, $3)
+    ON CONFLICT (scope, subject) DO UPDATE SET
+      failures = CASE WHEN login_attempts.last_failed_at < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+      last_failed_at = EXCLUDED.last_failed_at
+    RETURNING failures, last_failed_at, locked_until
+    `, scope, subject, now, windowStart).Scan(&res.Failures, &res.LastFailedAt, &res.LockedUntil)
+	if err != nil {
+		utils.Logger(ctx, lr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to record login failure: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+func (lr *LoginAttemptRepo) LockLoginRepo(ctx context.Context, scope, subject string, until time.Time) error {
+	_, err := lr.db.Exec(ctx, `
+    UPDATE login_attempts SET locked_until = $3, failures = 0
+    WHERE scope = $1 AND subject = $2
+    `, scope, subject, until)
+	if err != nil {
+		utils.Logger(ctx, lr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to lock login: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+func (lr *LoginAttemptRepo) ResetLoginAttemptRepo(ctx context.Context, scope, subject string) error {
+	_, err := lr.db.Exec(ctx, `
+    DELETE FROM login_attempts WHERE scope = $1 AND subject = $2
+    `, scope, subject)
+	if err != nil {
+		utils.Logger(ctx, lr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to reset login attempts: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+// PruneLoginAttemptsRepo deletes counters whose last failure is older than
+// before and that are not locked at now.
+func (lr *LoginAttemptRepo) PruneLoginAttemptsRepo(ctx context.Context, before, now time.Time) (int64, error) {
+	tag, err := lr.db.Exec(ctx, `
+    DELETE FROM login_attempts
+    WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $2)
+    `, before, now)
+	if err != nil {
+		utils.Logger(ctx, lr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to prune login attempts: %w", utils.ErrDatabase)
+	}
+	return tag.RowsAffected(), nil
+}
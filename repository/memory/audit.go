@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/bagasadiii/gofood-clone/model"
+)
+
+type AuditRepo struct {
+	db *DB
+}
+
+func NewAuditRepo(db *DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (ar *AuditRepo) CreateAuditRepo(ctx context.Context, new *model.AuditLog) error {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+	ar.db.audit = append(ar.db.audit, *new)
+	return nil
+}
+
+// Logs returns the audit entries written so far, oldest first. The Postgres
+// repository has no reader; this exists for tests.
+func (ar *AuditRepo) Logs() []model.AuditLog {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+	return append([]model.AuditLog(nil), ar.db.audit...)
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type DriverRepo struct {
+	db *DB
+}
+
+func NewDriverRepo(db *DB) *DriverRepo {
+	return &DriverRepo{db: db}
+}
+
+func (dr *DriverRepo) CreateDriverRepo(ctx context.Context, new *model.Driver) error {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	if dr.db.driverWhere(func(d *driverRow) bool {
+		return d.UserID == new.UserID || d.Username == new.Username
+	}) != nil {
+		return fmt.Errorf("driver already exists: %w", utils.ErrUniqueConstraint)
+	}
+	if dr.licenseTaken(new.License, uuid.Nil) {
+		return fmt.Errorf("license already registered: %w", utils.ErrUniqueConstraint)
+	}
+	if _, ok := dr.db.users[new.UserID]; !ok {
+		return fmt.Errorf("failed to create driver: %w", utils.ErrDatabase)
+	}
+	dr.db.drivers[new.DriverID] = &driverRow{Driver: *new}
+	return nil
+}
+
+func (dr *DriverRepo) GetDriverRepo(ctx context.Context, username string) (*model.Driver, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	d := dr.db.driverWhere(func(d *driverRow) bool {
+		return d.Username == username && d.Status == model.ReviewStatusApproved
+	})
+	if d == nil {
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	}
+	res := d.Driver
+	return &res, nil
+}
+
+func (dr *DriverRepo) UpdateDriverRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Driver, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	d := dr.db.driverWhere(func(d *driverRow) bool { return d.UserID == userID })
+	if d == nil {
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	}
+	updated := *d
+	if err := applyPatch(patch, map[string]any{
+		"name":    &updated.Name,
+		"license": &updated.License,
+		"area":    &updated.Area,
+	}); err != nil {
+		return nil, err
+	}
+	if dr.licenseTaken(updated.License, d.DriverID) {
+		return nil, fmt.Errorf("license already registered: %w", utils.ErrUniqueConstraint)
+	}
+	*d = updated
+	res := d.Driver
+	return &res, nil
+}
+
+func (dr *DriverRepo) GetDriverApplicationRepo(ctx context.Context, username string) (*model.Application, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	d := dr.db.driverWhere(func(d *driverRow) bool { return d.Username == username })
+	if d == nil {
+		return nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	}
+	return &model.Application{
+		Status:     d.Status,
+		ReviewNote: d.note,
+		ReviewedAt: d.reviewedAt,
+	}, nil
+}
+
+func (dr *DriverRepo) GetDriverIDRepo(ctx context.Context, username string) (uuid.UUID, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	d := dr.db.driverWhere(func(d *driverRow) bool { return d.Username == username })
+	if d == nil {
+		return uuid.Nil, fmt.Errorf("no driver found: %w", utils.ErrNotFound)
+	}
+	return d.DriverID, nil
+}
+
+func (dr *DriverRepo) CreateDriverDocumentRepo(ctx context.Context, new *model.DriverDocument) error {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	if _, ok := dr.db.drivers[new.DriverID]; !ok {
+		return fmt.Errorf("failed to save driver document: %w", utils.ErrDatabase)
+	}
+	doc := *new
+	dr.db.documents[new.DocumentID] = &doc
+	return nil
+}
+
+func (dr *DriverRepo) ListDriverDocumentsRepo(ctx context.Context, driverID uuid.UUID) ([]model.DriverDocument, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	res := []model.DriverDocument{}
+	for _, doc := range dr.db.documents {
+		if doc.DriverID == driverID {
+			res = append(res, *doc)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].UploadedAt.Before(res[j].UploadedAt) })
+	return res, nil
+}
+
+func (dr *DriverRepo) GetDriverDocumentRepo(ctx context.Context, driverID, documentID uuid.UUID) (*model.DriverDocument, error) {
+	dr.db.mu.Lock()
+	defer dr.db.mu.Unlock()
+	doc, ok := dr.db.documents[documentID]
+	if !ok || doc.DriverID != driverID {
+		return nil, fmt.Errorf("no document found: %w", utils.ErrNotFound)
+	}
+	res := *doc
+	return &res, nil
+}
+
+// licenseTaken reports whether another driver than self holds license.
+// Deleted drivers have no license, matching the nullable unique column.
+func (dr *DriverRepo) licenseTaken(license string, self uuid.UUID) bool {
+	if license == "" {
+		return false
+	}
+	return dr.db.driverWhere(func(d *driverRow) bool {
+		return d.License == license && d.DriverID != self
+	}) != nil
+}
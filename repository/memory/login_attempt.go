@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+)
+
+type attemptKey struct {
+	scope   string
+	subject string
+}
+
+type LoginAttemptRepo struct {
+	db *DB
+}
+
+func NewLoginAttemptRepo(db *DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db}
+}
+
+func (lr *LoginAttemptRepo) GetLoginAttemptRepo(ctx context.Context, scope, subject string) (*model.LoginAttempt, error) {
+	lr.db.mu.Lock()
+	defer lr.db.mu.Unlock()
+	if attempt, ok := lr.db.loginAttempts[attemptKey{scope, subject}]; ok {
+		res := *attempt
+		return &res, nil
+	}
+	return &model.LoginAttempt{Scope: scope, Subject: subject}, nil
+}
+
+func (lr *LoginAttemptRepo) RecordLoginFailureRepo(ctx context.Context, scope, subject string, now, windowStart time.Time) (*model.LoginAttempt, error) {
+	lr.db.mu.Lock()
+	defer lr.db.mu.Unlock()
+	key := attemptKey{scope, subject}
+	attempt, ok := lr.db.loginAttempts[key]
+	switch {
+	case !ok:
+		attempt = &model.LoginAttempt{Scope: scope, Subject: subject, Failures: 1}
+		lr.db.loginAttempts[key] = attempt
+	case attempt.LastFailedAt.Before(windowStart):
+		attempt.Failures = 1
+	default:
+		attempt.Failures++
+	}
+	attempt.LastFailedAt = now
+	res := *attempt
+	return &res, nil
+}
+
+func (lr *LoginAttemptRepo) LockLoginRepo(ctx context.Context, scope, subject string, until time.Time) error {
+	lr.db.mu.Lock()
+	defer lr.db.mu.Unlock()
+	if attempt, ok := lr.db.loginAttempts[attemptKey{scope, subject}]; ok {
+		attempt.LockedUntil = &until
+		attempt.Failures = 0
+	}
+	return nil
+}
+
+func (lr *LoginAttemptRepo) ResetLoginAttemptRepo(ctx context.Context, scope, subject string) error {
+	lr.db.mu.Lock()
+	defer lr.db.mu.Unlock()
+	delete(lr.db.loginAttempts, attemptKey{scope, subject})
+	return nil
+}
+
+func (lr *LoginAttemptRepo) PruneLoginAttemptsRepo(ctx context.Context, before, now time.Time) (int64, error) {
+	lr.db.mu.Lock()
+	defer lr.db.mu.Unlock()
+	var n int64
+	for key, attempt := range lr.db.loginAttempts {
+		if attempt.LastFailedAt.Before(before) && (attempt.LockedUntil == nil || attempt.LockedUntil.Before(now)) {
+			delete(lr.db.loginAttempts, key)
+			n++
+		}
+	}
+	return n, nil
+}
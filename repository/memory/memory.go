@@ -0,0 +1,155 @@
+// Package memory implements the repository interfaces in process memory for
+// fast service-layer tests. Behaviour, including the sentinel errors
+// returned, is kept in line with the Postgres repositories by the contract
+// suite in repository/repotest.
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+// DB holds the rows shared by the repositories built on it, the way the
+// tables of one database are.
+type DB struct {
+	mu            sync.Mutex
+	users         map[uuid.UUID]*userRow
+	merchants     map[uuid.UUID]*merchantRow
+	drivers       map[uuid.UUID]*driverRow
+	documents     map[uuid.UUID]*model.DriverDocument
+	menus         map[uuid.UUID]*model.Menu
+	loginAttempts map[attemptKey]*model.LoginAttempt
+	audit         []model.AuditLog
+	wallet        []model.WalletTransaction
+	orders        map[uuid.UUID]*model.Order
+	disputes      map[uuid.UUID]*model.Dispute
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:         make(map[uuid.UUID]*userRow),
+		merchants:     make(map[uuid.UUID]*merchantRow),
+		drivers:       make(map[uuid.UUID]*driverRow),
+		documents:     make(map[uuid.UUID]*model.DriverDocument),
+		menus:         make(map[uuid.UUID]*model.Menu),
+		loginAttempts: make(map[attemptKey]*model.LoginAttempt),
+		orders:        make(map[uuid.UUID]*model.Order),
+		disputes:      make(map[uuid.UUID]*model.Dispute),
+	}
+}
+
+type userRow struct {
+	model.User
+	deletedAt *time.Time
+}
+
+type review struct {
+	note       string
+	reviewedAt *time.Time
+}
+
+type merchantRow struct {
+	model.Merchant
+	review
+	schedule model.MerchantSchedule
+}
+
+// copySchedule returns the schedule with its hours ordered by weekday.
+func (m *merchantRow) copySchedule() *model.MerchantSchedule {
+	res := m.schedule
+	res.Hours = slices.Clone(m.schedule.Hours)
+	if res.Hours == nil {
+		res.Hours = []model.OpeningHours{}
+	}
+	sort.Slice(res.Hours, func(i, j int) bool { return res.Hours[i].Weekday < res.Hours[j].Weekday })
+	return &res
+}
+
+type driverRow struct {
+	model.Driver
+	review
+}
+
+func (db *DB) userByName(username string) *userRow {
+	for _, u := range db.users {
+		if u.Username == username && u.deletedAt == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+func (db *DB) merchantWhere(match func(*merchantRow) bool) *merchantRow {
+	for _, m := range db.merchants {
+		if match(m) {
+			return m
+		}
+	}
+	return nil
+}
+
+func (db *DB) driverWhere(match func(*driverRow) bool) *driverRow {
+	for _, d := range db.drivers {
+		if match(d) {
+			return d
+		}
+	}
+	return nil
+}
+
+// driverDocuments returns copies of the documents of the user's driver
+// profile, oldest first.
+func (db *DB) driverDocuments(userID uuid.UUID) []model.DriverDocument {
+	d := db.driverWhere(func(d *driverRow) bool { return d.UserID == userID })
+	if d == nil {
+		return nil
+	}
+	var res []model.DriverDocument
+	for _, doc := range db.documents {
+		if doc.DriverID == d.DriverID {
+			res = append(res, *doc)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].UploadedAt.Before(res[j].UploadedAt) })
+	return res
+}
+
+// applyPatch writes the values of patch into the fields mapped by column.
+// A cleared column reads back as the zero value, like COALESCE does in the
+// Postgres queries.
+func applyPatch(patch *utils.Patch, fields map[string]any) error {
+	for column, value := range patch.Changes() {
+		switch dst := fields[column].(type) {
+		case *string:
+			*dst, _ = value.(string)
+		case *int64:
+			*dst, _ = value.(int64)
+		case *int:
+			*dst, _ = value.(int)
+		default:
+			return fmt.Errorf("unknown column %s: %w", column, utils.ErrDatabase)
+		}
+	}
+	return nil
+}
+
+// copyOrder returns a copy of o with the current usernames of its customer
+// and merchant, as the Postgres join reads them.
+func (db *DB) copyOrder(o *model.Order) *model.Order {
+	res := *o
+	res.Items = slices.Clone(o.Items)
+	if u, ok := db.users[o.CustomerID]; ok {
+		res.Customer = u.Username
+	}
+	if m, ok := db.merchants[o.MerchantID]; ok {
+		res.Merchant = m.Owner
+	}
+	return &res
+}
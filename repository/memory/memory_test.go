@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/repository/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		db := NewDB()
+		return repotest.Repos{
+			Users:         NewUserRepo(db),
+			Merchants:     NewMerchantRepo(db),
+			Drivers:       NewDriverRepo(db),
+			Menus:         NewMenuRepo(db),
+			LoginAttempts: NewLoginAttemptRepo(db),
+			Audit:         NewAuditRepo(db),
+			Orders:        NewOrderRepo(db),
+		}
+	})
+}
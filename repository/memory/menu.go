@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type MenuRepo struct {
+	db *DB
+}
+
+func NewMenuRepo(db *DB) *MenuRepo {
+	return &MenuRepo{db: db}
+}
+
+func (mr *MenuRepo) CreateMenuRepo(ctx context.Context, new *model.Menu, userID uuid.UUID) error {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	if _, ok := mr.db.merchants[new.MerchantID]; !ok {
+		return fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
+	}
+	menu := *new
+	mr.db.menus[new.MenuID] = &menu
+	return nil
+}
+
+func (mr *MenuRepo) GetMenuRepo(ctx context.Context, id uuid.UUID) (*model.MenuRes, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	menu, ok := mr.db.menus[id]
+	if !ok || mr.db.merchants[menu.MerchantID].Status != model.ReviewStatusApproved {
+		return nil, fmt.Errorf("menu not found: %w", utils.ErrNotFound)
+	}
+	return model.NewMenuRes(menu), nil
+}
+
+func (mr *MenuRepo) UpdateMenuRepo(ctx context.Context, menuID, merchantID uuid.UUID, patch *utils.Patch) (*model.MenuRes, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	menu, ok := mr.db.menus[menuID]
+	if !ok || menu.MerchantID != merchantID {
+		return nil, fmt.Errorf("menu not found: %w", utils.ErrNotFound)
+	}
+	updated := *menu
+	if err := applyPatch(patch, map[string]any{
+		"name":        &updated.Name,
+		"price":       &updated.Price,
+		"description": &updated.Description,
+		"category":    &updated.Category,
+		"stock":       &updated.Stock,
+	}); err != nil {
+		return nil, err
+	}
+	*menu = updated
+	return model.NewMenuRes(menu), nil
+}
+
+func (mr *MenuRepo) DeleteMenuRepo(ctx context.Context, id uuid.UUID, merchantID uuid.UUID) error {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	menu, ok := mr.db.menus[id]
+	if !ok || menu.MerchantID != merchantID {
+		return fmt.Errorf("menu not found: %w", utils.ErrNotFound)
+	}
+	delete(mr.db.menus, id)
+	return nil
+}
+
+func (mr *MenuRepo) GetMerchantID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool { return m.UserID == userID })
+	if m == nil {
+		return uuid.Nil, fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
+	}
+	return m.MerchantID, nil
+}
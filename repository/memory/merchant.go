@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type MerchantRepo struct {
+	db *DB
+}
+
+func NewMerchantRepo(db *DB) *MerchantRepo {
+	return &MerchantRepo{db: db}
+}
+
+func (mr *MerchantRepo) CreateMerchantRepo(ctx context.Context, new *model.Merchant) error {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	if mr.db.merchantWhere(func(m *merchantRow) bool {
+		return m.UserID == new.UserID || m.Owner == new.Owner
+	}) != nil {
+		return fmt.Errorf("merchant already exists: %w", utils.ErrUniqueConstraint)
+	}
+	if _, ok := mr.db.users[new.UserID]; !ok {
+		return fmt.Errorf("failed to create merchant: %w", utils.ErrDatabase)
+	}
+	mr.db.merchants[new.MerchantID] = &merchantRow{
+		Merchant: *new,
+		schedule: model.MerchantSchedule{
+			MerchantID:   new.MerchantID,
+			Timezone:     model.DefaultMerchantTimezone,
+			SlotCapacity: model.DefaultSlotCapacity,
+		},
+	}
+	return nil
+}
+
+func (mr *MerchantRepo) GetMerchantRepo(ctx context.Context, username string) (*model.Merchant, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool {
+		return m.Owner == username && m.Status == model.ReviewStatusApproved
+	})
+	if m == nil {
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	}
+	res := m.Merchant
+	return &res, nil
+}
+
+func (mr *MerchantRepo) UpdateMerchantRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Merchant, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool { return m.UserID == userID })
+	if m == nil {
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	}
+	updated := *m
+	if err := applyPatch(patch, map[string]any{
+		"name":        &updated.Name,
+		"address":     &updated.Address,
+		"category":    &updated.Category,
+		"description": &updated.Description,
+	}); err != nil {
+		return nil, err
+	}
+	*m = updated
+	res := m.Merchant
+	return &res, nil
+}
+
+func (mr *MerchantRepo) GetMerchantApplicationRepo(ctx context.Context, username string) (*model.Application, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool { return m.Owner == username })
+	if m == nil {
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	}
+	return &model.Application{
+		Status:     m.Status,
+		ReviewNote: m.note,
+		ReviewedAt: m.reviewedAt,
+	}, nil
+}
+
+func (mr *MerchantRepo) GetMerchantScheduleRepo(ctx context.Context, username string) (*model.MerchantSchedule, error) {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool {
+		return m.Owner == username && m.Status == model.ReviewStatusApproved
+	})
+	if m == nil {
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	}
+	return m.copySchedule(), nil
+}
+
+func (mr *MerchantRepo) SetMerchantScheduleRepo(ctx context.Context, userID uuid.UUID, schedule *model.MerchantSchedule) error {
+	mr.db.mu.Lock()
+	defer mr.db.mu.Unlock()
+	m := mr.db.merchantWhere(func(m *merchantRow) bool { return m.UserID == userID })
+	if m == nil {
+		return fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	}
+	schedule.MerchantID = m.MerchantID
+	m.schedule = *schedule
+	m.schedule.Hours = slices.Clone(schedule.Hours)
+	return nil
+}
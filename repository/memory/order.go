@@ -0,0 +1,488 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type OrderRepo struct {
+	db *DB
+}
+
+func NewOrderRepo(db *DB) *OrderRepo {
+	return &OrderRepo{db: db}
+}
+
+func (or *OrderRepo) CreateOrderRepo(ctx context.Context, new *model.Order, slotCapacity int) error {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	m, ok := or.db.merchants[new.MerchantID]
+	if !ok || m.Status != model.ReviewStatusApproved {
+		return fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
+	}
+	if new.ScheduledFor != nil {
+		taken := 0
+		for _, o := range or.db.orders {
+			if o.MerchantID == new.MerchantID && o.ScheduledFor != nil && o.ScheduledFor.Equal(*new.ScheduledFor) &&
+				o.Status != model.OrderStatusRejected && o.Status != model.OrderStatusCancelled {
+				taken++
+			}
+		}
+		if taken >= slotCapacity {
+			return fmt.Errorf("slot %s is full: %w", new.ScheduledFor.Format(time.RFC3339), utils.ErrSlotUnavailable)
+		}
+	}
+
+	items := slices.Clone(new.Items)
+	var total int64
+	for i := range items {
+		menu, ok := or.db.menus[items[i].MenuID]
+		if !ok || menu.MerchantID != new.MerchantID {
+			return fmt.Errorf("menu %s is not sold by this merchant: %w", items[i].MenuID, utils.ErrBadRequest)
+		}
+		items[i].Name = menu.Name
+		items[i].Price = menu.Price
+		items[i].Status = model.ItemStatusAvailable
+		total += menu.Price * int64(items[i].Quantity)
+	}
+
+	customer, ok := or.db.users[new.CustomerID]
+	if !ok || customer.deletedAt != nil || customer.Balance < total {
+		return fmt.Errorf("order total %d: %w", total, utils.ErrInsufficientFunds)
+	}
+	customer.Balance -= total
+	or.db.wallet = append(or.db.wallet, model.WalletTransaction{
+		TransactionID: uuid.New(),
+		UserID:        new.CustomerID,
+		Amount:        -total,
+		BalanceAfter:  customer.Balance,
+		Kind:          model.WalletKindOrderPayment,
+		Reason:        "order " + new.OrderID.String(),
+		ActorID:       new.CustomerID,
+		CreatedAt:     new.CreatedAt,
+	})
+
+	new.Items = items
+	new.Total = total
+	new.Merchant = m.Owner
+	stored := *new
+	stored.Items = slices.Clone(items)
+	or.db.orders[new.OrderID] = &stored
+	return nil
+}
+
+func (or *OrderRepo) GetOrderRepo(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	o, ok := or.db.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order not found: %w", utils.ErrNotFound)
+	}
+	return or.db.copyOrder(o), nil
+}
+
+func (or *OrderRepo) ReleaseScheduledOrdersRepo(ctx context.Context, now, respondBy time.Time, limit int) (int64, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	var due []*model.Order
+	for _, o := range or.db.orders {
+		if o.Status == model.OrderStatusScheduled && !o.ReleaseAt.After(now) {
+			due = append(due, o)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ReleaseAt.Before(*due[j].ReleaseAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, o := range due {
+		placedAt, deadline := now, respondBy
+		o.Status = model.OrderStatusPlaced
+		o.PlacedAt = &placedAt
+		o.RespondBy = &deadline
+	}
+	return int64(len(due)), nil
+}
+
+func (or *OrderRepo) ListMerchantOrdersRepo(ctx context.Context, merchantID uuid.UUID, filter *model.OrderFilter) ([]model.Order, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	var matched []*model.Order
+	for _, o := range or.db.orders {
+		if o.MerchantID == merchantID && (len(filter.Statuses) == 0 || slices.Contains(filter.Statuses, o.Status)) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].OrderID.String() < matched[j].OrderID.String()
+	})
+	matched = matched[min(filter.Offset, len(matched)):]
+	matched = matched[:min(filter.Limit, len(matched))]
+	res := []model.Order{}
+	for _, o := range matched {
+		res = append(res, *or.db.copyOrder(o))
+	}
+	return res, nil
+}
+
+// withOrder calls fn with the order unless it does not exist or does not
+// belong to owns.
+func (or *OrderRepo) withOrder(orderID uuid.UUID, owns func(o *model.Order) bool, fn func(o *model.Order, tx *walletTx) error) error {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	stored, ok := or.db.orders[orderID]
+	if !ok || !owns(stored) {
+		return fmt.Errorf("order not found: %w", utils.ErrNotFound)
+	}
+	return or.change(stored, fn)
+}
+
+// change calls fn on a copy of stored that is only kept, together with the
+// refund it adds, when fn succeeds. The caller holds the lock.
+func (or *OrderRepo) change(stored *model.Order, fn func(o *model.Order, tx *walletTx) error) error {
+	o := *stored
+	o.Items = slices.Clone(stored.Items)
+	tx := &walletTx{}
+	if err := fn(&o, tx); err != nil {
+		return err
+	}
+	customer := or.db.users[o.CustomerID]
+	for _, r := range tx.refunds {
+		customer.Balance += r.Amount
+		r.TransactionID = uuid.New()
+		r.UserID = o.CustomerID
+		r.BalanceAfter = customer.Balance
+		r.Kind = model.WalletKindOrderRefund
+		r.ActorID = tx.actor
+		or.db.wallet = append(or.db.wallet, r)
+		o.Refunded += r.Amount
+	}
+	*stored = o
+	return nil
+}
+
+// walletTx collects the refunds of one order change. actor is the user who
+// caused them, or uuid.Nil when the system did.
+type walletTx struct {
+	refunds []model.WalletTransaction
+	total   int64
+	actor   uuid.UUID
+}
+
+func (tx *walletTx) add(amount int64, reason string, at time.Time) {
+	if amount <= 0 {
+		return
+	}
+	tx.refunds = append(tx.refunds, model.WalletTransaction{Amount: amount, Reason: reason, CreatedAt: at})
+	tx.total += amount
+}
+
+func wrongState(o *model.Order, action string) error {
+	return fmt.Errorf("cannot %s a %s order: %w", action, o.Status, utils.ErrOrderState)
+}
+
+func reject(o *model.Order, tx *walletTx, reason string, now time.Time) {
+	tx.add(o.Total-o.Refunded-tx.total, "order "+o.OrderID.String()+" rejected", now)
+	o.Status = model.OrderStatusRejected
+	o.RejectedAt = &now
+	o.RejectReason = reason
+}
+
+func (or *OrderRepo) AcceptOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, prepMinutes int, now time.Time) error {
+	return or.withOrder(orderID, ownedByMerchant(merchantID), func(o *model.Order, _ *walletTx) error {
+		if o.Status != model.OrderStatusPlaced {
+			return wrongState(o, "accept")
+		}
+		o.Status = model.OrderStatusAccepted
+		o.PrepMinutes = prepMinutes
+		o.AcceptedAt = &now
+		return nil
+	})
+}
+
+func (or *OrderRepo) RejectOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, reason string, now time.Time) error {
+	return or.withOrder(orderID, ownedByMerchant(merchantID), func(o *model.Order, tx *walletTx) error {
+		if o.Status != model.OrderStatusPlaced {
+			return wrongState(o, "reject")
+		}
+		reject(o, tx, reason, now)
+		return nil
+	})
+}
+
+func (or *OrderRepo) MarkOrderReadyRepo(ctx context.Context, merchantID, orderID uuid.UUID, now time.Time) error {
+	return or.withOrder(orderID, ownedByMerchant(merchantID), func(o *model.Order, _ *walletTx) error {
+		if o.Status != model.OrderStatusAccepted {
+			return wrongState(o, "mark ready")
+		}
+		for _, item := range o.Items {
+			if item.Status == model.ItemStatusSubstitutionOffered {
+				return fmt.Errorf("the customer has not answered a substitution yet: %w", utils.ErrOrderState)
+			}
+		}
+		o.Status = model.OrderStatusReady
+		o.ReadyAt = &now
+		return nil
+	})
+}
+
+func findItem(o *model.Order, itemID uuid.UUID) (*model.OrderItem, error) {
+	for i := range o.Items {
+		if o.Items[i].OrderItemID == itemID {
+			return &o.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("order item not found: %w", utils.ErrNotFound)
+}
+
+func refundItem(o *model.Order, item *model.OrderItem, tx *walletTx, now time.Time) {
+	item.Status = model.ItemStatusRefunded
+	tx.add(item.Price*int64(item.Quantity), "order "+o.OrderID.String()+" item unavailable", now)
+	for _, other := range o.Items {
+		if other.Status != model.ItemStatusRefunded {
+			return
+		}
+	}
+	reject(o, tx, model.RejectReasonNothingLeft, now)
+}
+
+func (or *OrderRepo) MarkItemUnavailableRepo(ctx context.Context, merchantID, orderID, itemID uuid.UUID, substituteMenuID *uuid.UUID, now time.Time) error {
+	return or.withOrder(orderID, ownedByMerchant(merchantID), func(o *model.Order, tx *walletTx) error {
+		if o.Status != model.OrderStatusPlaced && o.Status != model.OrderStatusAccepted {
+			return wrongState(o, "change items of")
+		}
+		item, err := findItem(o, itemID)
+		if err != nil {
+			return err
+		}
+		if item.Status != model.ItemStatusAvailable {
+			return fmt.Errorf("item is already %s: %w", item.Status, utils.ErrOrderState)
+		}
+		if substituteMenuID == nil {
+			refundItem(o, item, tx, now)
+			return nil
+		}
+		if *substituteMenuID == item.MenuID {
+			return fmt.Errorf("an item cannot substitute itself: %w", utils.ErrBadRequest)
+		}
+		menu, ok := or.db.menus[*substituteMenuID]
+		if !ok || menu.MerchantID != merchantID {
+			return fmt.Errorf("menu %s is not sold by this merchant: %w", *substituteMenuID, utils.ErrBadRequest)
+		}
+		item.Status = model.ItemStatusSubstitutionOffered
+		item.Substitute = &model.Substitute{MenuID: menu.MenuID, Name: menu.Name, Price: menu.Price}
+		return nil
+	})
+}
+
+func (or *OrderRepo) ResolveSubstitutionRepo(ctx context.Context, customerID, orderID, itemID uuid.UUID, accept bool, now time.Time) error {
+	owns := func(o *model.Order) bool { return o.CustomerID == customerID }
+	return or.withOrder(orderID, owns, func(o *model.Order, tx *walletTx) error {
+		if o.Status != model.OrderStatusPlaced && o.Status != model.OrderStatusAccepted {
+			return wrongState(o, "change items of")
+		}
+		item, err := findItem(o, itemID)
+		if err != nil {
+			return err
+		}
+		if item.Status != model.ItemStatusSubstitutionOffered {
+			return fmt.Errorf("item has no substitution to answer: %w", utils.ErrOrderState)
+		}
+		if !accept {
+			refundItem(o, item, tx, now)
+			return nil
+		}
+		item.Status = model.ItemStatusSubstituted
+		tx.add((item.Price-item.Substitute.Price)*int64(item.Quantity), "order "+o.OrderID.String()+" cheaper substitute", now)
+		return nil
+	})
+}
+
+func (or *OrderRepo) AutoRejectOrdersRepo(ctx context.Context, now time.Time, reason string, limit int) (int64, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	var overdue []*model.Order
+	for _, o := range or.db.orders {
+		if o.Status == model.OrderStatusPlaced && o.RespondBy != nil && !o.RespondBy.After(now) {
+			overdue = append(overdue, o)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool { return overdue[i].RespondBy.Before(*overdue[j].RespondBy) })
+	if len(overdue) > limit {
+		overdue = overdue[:limit]
+	}
+	for _, o := range overdue {
+		or.change(o, func(o *model.Order, tx *walletTx) error {
+			reject(o, tx, reason, now)
+			return nil
+		})
+	}
+	return int64(len(overdue)), nil
+}
+
+func ownedByMerchant(merchantID uuid.UUID) func(o *model.Order) bool {
+	return func(o *model.Order) bool { return o.MerchantID == merchantID }
+}
+
+func cancellable(o *model.Order, by string) (allowed, late bool) {
+	switch o.Status {
+	case model.OrderStatusScheduled, model.OrderStatusPlaced:
+		return by == model.CancelledByCustomer, false
+	case model.OrderStatusAccepted, model.OrderStatusReady:
+		return true, true
+	}
+	return false, false
+}
+
+func (or *OrderRepo) cancelledBy(c *model.OrderCancellation) func(o *model.Order) bool {
+	return func(o *model.Order) bool {
+		switch c.By {
+		case model.CancelledByCustomer:
+			return o.CustomerID == c.ActorID
+		case model.CancelledByMerchant:
+			m, ok := or.db.merchants[o.MerchantID]
+			return ok && m.UserID == c.ActorID
+		case model.CancelledByDriver:
+			if o.DriverID == nil {
+				return false
+			}
+			d, ok := or.db.drivers[*o.DriverID]
+			return ok && d.UserID == c.ActorID
+		}
+		return false
+	}
+}
+
+func (or *OrderRepo) CancelOrderRepo(ctx context.Context, c *model.OrderCancellation) error {
+	return or.withOrder(c.OrderID, or.cancelledBy(c), func(o *model.Order, tx *walletTx) error {
+		allowed, late := cancellable(o, c.By)
+		if !allowed {
+			return wrongState(o, "cancel")
+		}
+		var fee int64
+		if late && c.By == model.CancelledByCustomer {
+			fee = min(c.Fee, o.Total-o.Refunded)
+		}
+		tx.actor = c.ActorID
+		tx.add(o.Total-o.Refunded-fee, "order "+o.OrderID.String()+" cancelled", c.At)
+		at := c.At
+		o.Status = model.OrderStatusCancelled
+		o.CancelledAt = &at
+		o.CancelledBy = c.By
+		o.CancelReason = c.Reason
+		o.CancelNote = c.Note
+		o.CancelFee = fee
+		return nil
+	})
+}
+
+func (or *OrderRepo) CreateDisputeRepo(ctx context.Context, customerID uuid.UUID, new *model.Dispute) error {
+	owns := func(o *model.Order) bool { return o.CustomerID == customerID }
+	return or.withOrder(new.OrderID, owns, func(o *model.Order, _ *walletTx) error {
+		if o.Status != model.OrderStatusReady {
+			return wrongState(o, "dispute")
+		}
+		for _, d := range or.db.disputes {
+			if d.OrderID == new.OrderID && d.Status == model.DisputeStatusOpen {
+				return fmt.Errorf("order already has an open dispute: %w", utils.ErrOrderState)
+			}
+		}
+		for _, id := range new.OrderItemIDs {
+			item, err := findItem(o, id)
+			if err != nil || item.Status == model.ItemStatusRefunded {
+				return fmt.Errorf("disputed items must be unrefunded items of the order: %w", utils.ErrBadRequest)
+			}
+		}
+		stored := *new
+		stored.OrderItemIDs = slices.Clone(new.OrderItemIDs)
+		or.db.disputes[new.DisputeID] = &stored
+		return nil
+	})
+}
+
+// copyDispute returns a copy of d with its customer as the Postgres join
+// reads them. The caller holds the lock.
+func (or *OrderRepo) copyDispute(d *model.Dispute) model.Dispute {
+	res := *d
+	res.OrderItemIDs = slices.Clone(d.OrderItemIDs)
+	o := or.db.orders[d.OrderID]
+	res.CustomerID = o.CustomerID
+	if u, ok := or.db.users[o.CustomerID]; ok {
+		res.Customer = u.Username
+	}
+	return res
+}
+
+func (or *OrderRepo) GetDisputeRepo(ctx context.Context, disputeID uuid.UUID) (*model.Dispute, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	d, ok := or.db.disputes[disputeID]
+	if !ok {
+		return nil, fmt.Errorf("dispute not found: %w", utils.ErrNotFound)
+	}
+	res := or.copyDispute(d)
+	return &res, nil
+}
+
+func (or *OrderRepo) ListDisputesRepo(ctx context.Context, filter *model.DisputeFilter) ([]model.Dispute, error) {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	var matched []*model.Dispute
+	for _, d := range or.db.disputes {
+		if filter.Status == "" || d.Status == filter.Status {
+			matched = append(matched, d)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].DisputeID.String() < matched[j].DisputeID.String()
+	})
+	matched = matched[min(filter.Offset, len(matched)):]
+	matched = matched[:min(filter.Limit, len(matched))]
+	res := []model.Dispute{}
+	for _, d := range matched {
+		res = append(res, or.copyDispute(d))
+	}
+	return res, nil
+}
+
+func (or *OrderRepo) ResolveDisputeRepo(ctx context.Context, r *model.DisputeResolution, audit *model.AuditLog) error {
+	or.db.mu.Lock()
+	defer or.db.mu.Unlock()
+	d, ok := or.db.disputes[r.DisputeID]
+	if !ok {
+		return fmt.Errorf("dispute not found: %w", utils.ErrNotFound)
+	}
+	err := or.change(or.db.orders[d.OrderID], func(o *model.Order, tx *walletTx) error {
+		if d.Status != model.DisputeStatusOpen {
+			return fmt.Errorf("dispute is already %s: %w", d.Status, utils.ErrOrderState)
+		}
+		if left := o.Total - o.Refunded; r.Refund > left {
+			return fmt.Errorf("refund %d is more than the %d left to refund: %w", r.Refund, left, utils.ErrBadRequest)
+		}
+		tx.actor = r.AdminID
+		tx.add(r.Refund, "dispute "+r.DisputeID.String()+" on order "+o.OrderID.String(), r.At)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	at := r.At
+	d.Status = model.DisputeStatusResolved
+	d.Refund = r.Refund
+	d.Resolution = r.Resolution
+	d.ResolvedAt = &at
+	or.db.audit = append(or.db.audit, *audit)
+	return nil
+}
@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+type UserRepo struct {
+	db *DB
+}
+
+func NewUserRepo(db *DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+func (ur *UserRepo) RegisterUserRepo(ctx context.Context, new *model.User) error {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	for _, u := range ur.db.users {
+		if u.Username == new.Username || u.Email == new.Email {
+			return fmt.Errorf("username or email already exist: %w", utils.ErrUniqueConstraint)
+		}
+	}
+	row := &userRow{User: *new}
+	row.TOTPEnabled = false
+	row.Status = model.UserStatusActive
+	ur.db.users[new.UserID] = row
+	return nil
+}
+
+func (ur *UserRepo) LoginRepo(ctx context.Context, username string) (*model.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u := ur.db.userByName(username)
+	if u == nil {
+		return nil, fmt.Errorf("no username found: %w", utils.ErrNotFound)
+	}
+	return &model.User{
+		UserID:      u.UserID,
+		Username:    u.Username,
+		Password:    u.Password,
+		Role:        u.Role,
+		TOTPEnabled: u.TOTPEnabled,
+		Status:      u.Status,
+	}, nil
+}
+
+func (ur *UserRepo) GetUserRepo(ctx context.Context, username string) (*model.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u := ur.db.userByName(username)
+	if u == nil {
+		return nil, fmt.Errorf("no row found: %w", utils.ErrNotFound)
+	}
+	return profile(u), nil
+}
+
+func (ur *UserRepo) UpdateUserRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.User, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u, ok := ur.db.users[userID]
+	if !ok || u.deletedAt != nil {
+		return nil, fmt.Errorf("no user updated: %w", utils.ErrNotFound)
+	}
+	updated := *u
+	if err := applyPatch(patch, map[string]any{
+		"name":  &updated.Name,
+		"phone": &updated.Phone,
+	}); err != nil {
+		return nil, err
+	}
+	*u = updated
+	return profile(u), nil
+}
+
+func (ur *UserRepo) DeleteUserRepo(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u, ok := ur.db.users[userID]
+	if !ok || u.deletedAt != nil {
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	}
+	anonymous := "deleted_" + strings.ReplaceAll(userID.String(), "-", "")[:12]
+	now := time.Now()
+	u.Username = anonymous
+	u.Email = userID.String() + "@deleted.invalid"
+	u.Password = ""
+	u.Phone = ""
+	u.Name = "Deleted User"
+	u.TOTPEnabled = false
+	u.deletedAt = &now
+	if m := ur.db.merchantWhere(func(m *merchantRow) bool { return m.UserID == userID }); m != nil {
+		m.Owner = anonymous
+		m.Status = model.ReviewStatusDeleted
+	}
+	if d := ur.db.driverWhere(func(d *driverRow) bool { return d.UserID == userID }); d != nil {
+		d.Username = anonymous
+		d.Name = "Deleted Driver"
+		d.License = ""
+		d.Status = model.ReviewStatusDeleted
+	}
+	keys := []string{}
+	for _, doc := range ur.db.driverDocuments(userID) {
+		keys = append(keys, doc.StorageKey)
+		delete(ur.db.documents, doc.DocumentID)
+	}
+	return keys, nil
+}
+
+func (ur *UserRepo) ExportUserRepo(ctx context.Context, userID uuid.UUID) (*model.UserExport, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u, ok := ur.db.users[userID]
+	if !ok || u.deletedAt != nil {
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	}
+	res := model.UserExport{
+		Profile: model.UserExportProfile{
+			UserID:      u.UserID,
+			Username:    u.Username,
+			Email:       u.Email,
+			Role:        u.Role,
+			CreatedAt:   u.CreatedAt,
+			Phone:       u.Phone,
+			Name:        u.Name,
+			Balance:     u.Balance,
+			TOTPEnabled: u.TOTPEnabled,
+		},
+		Documents:  []model.DriverDocument{},
+		Orders:     []model.Order{},
+		Wallet:     []model.WalletTransaction{},
+		ExportedAt: time.Now(),
+	}
+	for _, doc := range ur.db.driverDocuments(userID) {
+		doc.StorageKey = ""
+		res.Documents = append(res.Documents, doc)
+	}
+	for _, tx := range ur.db.wallet {
+		if tx.UserID == userID {
+			tx.ActorID = uuid.Nil
+			res.Wallet = append(res.Wallet, tx)
+		}
+	}
+	for _, o := range ur.db.orders {
+		if o.CustomerID == userID {
+			res.Orders = append(res.Orders, *ur.db.copyOrder(o))
+		}
+	}
+	sort.Slice(res.Orders, func(i, j int) bool {
+		if !res.Orders[i].CreatedAt.Equal(res.Orders[j].CreatedAt) {
+			return res.Orders[i].CreatedAt.Before(res.Orders[j].CreatedAt)
+		}
+		return res.Orders[i].OrderID.String() < res.Orders[j].OrderID.String()
+	})
+	if m := ur.db.merchantWhere(func(m *merchantRow) bool { return m.UserID == userID }); m != nil {
+		res.Merchant = model.NewMerchantRes(&m.Merchant, model.OwnerView)
+	}
+	if d := ur.db.driverWhere(func(d *driverRow) bool { return d.UserID == userID }); d != nil {
+		res.Driver = model.NewDriverRes(&d.Driver, model.OwnerView)
+	}
+	return &res, nil
+}
+
+func (ur *UserRepo) GetAccountStatusRepo(ctx context.Context, userID uuid.UUID) (string, error) {
+	ur.db.mu.Lock()
+	defer ur.db.mu.Unlock()
+	u, ok := ur.db.users[userID]
+	if !ok || u.deletedAt != nil {
+		return "", fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	}
+	return u.Status, nil
+}
+
+// profile returns the columns the Postgres repository selects for a profile,
+// which leave out credentials and balances.
+func profile(u *userRow) *model.User {
+	return &model.User{
+		UserID:    u.UserID,
+		Username:  u.Username,
+		Email:     u.Email,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		Phone:     u.Phone,
+		Name:      u.Name,
+	}
+}
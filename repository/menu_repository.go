@@ -14,7 +14,7 @@ import (
 
 type MenuRepoImpl interface {
 	CreateMenuRepo(ctx context.Context, new *model.Menu, userID uuid.UUID) error
-	UpdateMenuRepo(ctx context.Context, query string, args []interface{}) error
+	UpdateMenuRepo(ctx context.Context, menuID, merchantID uuid.UUID, patch *utils.Patch) (*model.MenuRes, error)
 	GetMenuRepo(ctx context.Context, id uuid.UUID) (*model.MenuRes, error)
 	DeleteMenuRepo(ctx context.Context, id uuid.UUID, merchantID uuid.UUID) error
 	GetMerchantID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error)
@@ -36,8 +36,11 @@ func (mr *MenuRepo) CreateMenuRepo(ctx context.Context, new *model.Menu, userID
     INSERT INTO menus (menu_id, name, description, price, category, rating, stock, merchant_id)
     VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
     `, new.MenuID, new.Name, new.Description, new.Price, new.Category, new.Rating, new.Stock, new.MerchantID)
-	if err != nil {
-		mr.zap.Warn(utils.ErrDatabase.Error(), zap.Error(err))
+	if isForeignKeyViolation(err) {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("merchant_id", new.MerchantID.String()))
+		return fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("failed to create menu: %w", utils.ErrDatabase)
 	}
 	return nil
@@ -46,35 +49,48 @@ func (mr *MenuRepo) CreateMenuRepo(ctx context.Context, new *model.Menu, userID
 func (mr *MenuRepo) GetMenuRepo(ctx context.Context, id uuid.UUID) (*model.MenuRes, error) {
 	var res model.MenuRes
 	err := mr.db.QueryRow(ctx, `
-    SELECT name, price, description, category, rating, stock FROM menus WHERE menu_id = $1
-    `, id).Scan(&res.Name, &res.Price, &res.Description, &res.Category, &res.Rating, &res.Stock)
+    SELECT m.menu_id, m.name, m.price, COALESCE(m.description, ''), m.category, COALESCE(m.rating, 0), m.stock
+    FROM menus m JOIN merchants mc ON mc.merchant_id = m.merchant_id
+    WHERE m.menu_id = $1 AND mc.status = 'approved'
+    `, id).Scan(&res.MenuID, &res.Name, &res.Price, &res.Description, &res.Category, &res.Rating, &res.Stock)
 	if err == pgx.ErrNoRows {
-		mr.zap.Warn(utils.ErrNotFound.Error(), zap.String("menu_id", id.String()))
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("menu_id", id.String()))
 		return nil, fmt.Errorf("menu not found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return nil, fmt.Errorf("error while fetching menu: %w", utils.ErrNotFound)
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("error while fetching menu: %w", utils.ErrDatabase)
 	}
 	return &res, nil
 }
 
-func (mr *MenuRepo) UpdateMenuRepo(ctx context.Context, query string, args []interface{}) error {
-	_, err := mr.db.Exec(ctx, query, args...)
-	if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return fmt.Errorf("failed to update menu: %w", utils.ErrDatabase)
+func (mr *MenuRepo) UpdateMenuRepo(ctx context.Context, menuID, merchantID uuid.UUID, patch *utils.Patch) (*model.MenuRes, error) {
+	query, args := patch.Where("menu_id", menuID).Where("merchant_id", merchantID).SQL("menus", `
+    menu_id, name, price, COALESCE(description, ''), category, COALESCE(rating, 0), stock
+    `)
+	var res model.MenuRes
+	err := mr.db.QueryRow(ctx, query, args...).
+		Scan(&res.MenuID, &res.Name, &res.Price, &res.Description, &res.Category, &res.Rating, &res.Stock)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("menu_id", menuID.String()))
+		return nil, fmt.Errorf("menu not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to update menu: %w", utils.ErrDatabase)
 	}
-	return nil
+	return &res, nil
 }
 
 func (mr *MenuRepo) DeleteMenuRepo(ctx context.Context, id uuid.UUID, merchantID uuid.UUID) error {
-	_, err := mr.db.Exec(ctx, `
+	tag, err := mr.db.Exec(ctx, `
     DELETE FROM menus WHERE menu_id = $1 AND merchant_id = $2
-    `, id)
+    `, id, merchantID)
 	if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("failed to delete menu: %w", utils.ErrDatabase)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("menu not found: %w", utils.ErrNotFound)
+	}
 	return nil
 }
 
@@ -84,10 +100,10 @@ func (mr *MenuRepo) GetMerchantID(ctx context.Context, userID uuid.UUID) (uuid.U
     SELECT merchant_id FROM merchants WHERE user_id = $1
     `, userID).Scan(&merchantID)
 	if err == pgx.ErrNoRows {
-		mr.zap.Warn(utils.ErrNotFound.Error(), zap.String("no merchant_id found", userID.String()))
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("no merchant_id found", userID.String()))
 		return uuid.Nil, fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		mr.zap.Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.Logger(ctx, mr.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
 		return uuid.Nil, fmt.Errorf("%w", utils.ErrDatabase)
 	}
 	return merchantID, nil
@@ -14,8 +14,11 @@ import (
 
 type MerchantRepoImpl interface {
 	CreateMerchantRepo(ctx context.Context, new *model.Merchant) error
-	GetMerchantRepo(ctx context.Context, username string) (*model.MerchantRes, error)
-	UpdateMerchantRepo(ctx context.Context, query string, args []interface{}) error
+	GetMerchantRepo(ctx context.Context, username string) (*model.Merchant, error)
+	UpdateMerchantRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Merchant, error)
+	GetMerchantApplicationRepo(ctx context.Context, username string) (*model.Application, error)
+	GetMerchantScheduleRepo(ctx context.Context, username string) (*model.MerchantSchedule, error)
+	SetMerchantScheduleRepo(ctx context.Context, userID uuid.UUID, schedule *model.MerchantSchedule) error
 }
 type MerchantRepo struct {
 	db  *pgxpool.Pool
@@ -33,57 +36,163 @@ func (mr *MerchantRepo) CreateMerchantRepo(ctx context.Context, new *model.Merch
 	var exists bool
 	err := mr.db.QueryRow(ctx, `
     SELECT EXISTS (SELECT 1 FROM merchants WHERE user_id = $1 OR owner = $2)
-    `, new.MerchantID, new.Owner).Scan(&exists)
+    `, new.UserID, new.Owner).Scan(&exists)
 	if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("%w:%w", utils.ErrUnexpected, utils.ErrDatabase)
 	}
 	if exists {
-		mr.zap.Warn(utils.ErrUniqueConstraint.Error(), zap.String("merchant exists", new.Name))
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.String("merchant exists", new.Name))
 		return fmt.Errorf("merchant already exists: %w", utils.ErrUniqueConstraint)
 	}
 	_, err = mr.db.Exec(ctx, `
-    INSERT INTO merchants (merchant_id, name, rating, address, category, user_id, owner)
-    VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `, new.MerchantID, new.Name, new.Rating, new.Address, new.Category, new.UserID, new.Owner)
-	if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+    INSERT INTO merchants (merchant_id, name, rating, address, category, description, user_id, owner, status)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `, new.MerchantID, new.Name, new.Rating, new.Address, new.Category, new.Description, new.UserID, new.Owner, new.Status)
+	if isUniqueViolation(err) {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.Error(err))
+		return fmt.Errorf("merchant already exists: %w", utils.ErrUniqueConstraint)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return fmt.Errorf("failed to create merchant: %w", utils.ErrDatabase)
 	}
 	return nil
 }
 
-func (mr *MerchantRepo) GetMerchantRepo(ctx context.Context, username string) (*model.MerchantRes, error) {
-	var id uuid.UUID
+func (mr *MerchantRepo) GetMerchantRepo(ctx context.Context, username string) (*model.Merchant, error) {
+	var res model.Merchant
 	err := mr.db.QueryRow(ctx, `
-    SELECT merchant_id FROM merchants WHERE owner = $1
-    `, username).Scan(&id)
+    SELECT merchant_id, name, COALESCE(rating, 0), COALESCE(address, ''), COALESCE(category, ''),
+      COALESCE(description, ''), user_id, owner, status
+    FROM merchants WHERE owner = $1 AND status = 'approved'
+    `, username).Scan(&res.MerchantID, &res.Name, &res.Rating, &res.Address, &res.Category,
+		&res.Description, &res.UserID, &res.Owner, &res.Status)
 	if err == pgx.ErrNoRows {
-		mr.zap.Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
-		return nil, fmt.Errorf("merchant not exists: %war", utils.ErrNotFound)
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
 	} else if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return nil, fmt.Errorf("%w: %w", utils.ErrUnexpected, utils.ErrDatabase)
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch merchant: %w", utils.ErrDatabase)
 	}
-	var res model.MerchantRes
-	err = mr.db.QueryRow(ctx, `
-    SELECT (name, rating, address, category) FROM merchants WHERE merchant_id = $1
-    `, id).Scan(&res.Name, &res.Rating, &res.Address, &res.Category)
+	return &res, nil
+}
+
+func (mr *MerchantRepo) UpdateMerchantRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.Merchant, error) {
+	query, args := patch.Where("user_id", userID).SQL("merchants", `
+    merchant_id, name, COALESCE(rating, 0), COALESCE(address, ''), COALESCE(category, ''),
+    COALESCE(description, ''), user_id, owner, status
+    `)
+	var res model.Merchant
+	err := mr.db.QueryRow(ctx, query, args...).
+		Scan(&res.MerchantID, &res.Name, &res.Rating, &res.Address, &res.Category,
+			&res.Description, &res.UserID, &res.Owner, &res.Status)
 	if err == pgx.ErrNoRows {
-		mr.zap.Warn(utils.ErrNotFound.Error(), zap.String("MerchantID", id.String()))
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("UserID", userID.String()))
 		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
 	} else if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return nil, fmt.Errorf("failed to fetch merchant: %w", utils.ErrDatabase)
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to update merchant: %w", utils.ErrDatabase)
 	}
 	return &res, nil
 }
 
-func (mr *MerchantRepo) UpdateMerchantRepo(ctx context.Context, query string, args []interface{}) error {
-	_, err := mr.db.Exec(ctx, fmt.Sprintf(`UPDATE merchants SET %s`, query), args)
+func (mr *MerchantRepo) GetMerchantApplicationRepo(ctx context.Context, username string) (*model.Application, error) {
+	var res model.Application
+	err := mr.db.QueryRow(ctx, `
+    SELECT status, COALESCE(review_note, ''), reviewed_at FROM merchants WHERE owner = $1
+    `, username).Scan(&res.Status, &res.ReviewNote, &res.ReviewedAt)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch merchant application: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// GetMerchantScheduleRepo returns the schedule of an approved merchant with
+// its opening hours ordered by weekday.
+func (mr *MerchantRepo) GetMerchantScheduleRepo(ctx context.Context, username string) (*model.MerchantSchedule, error) {
+	var res model.MerchantSchedule
+	err := mr.db.QueryRow(ctx, `
+    SELECT merchant_id, timezone, slot_capacity FROM merchants
+    WHERE owner = $1 AND status = 'approved'
+    `, username).Scan(&res.MerchantID, &res.Timezone, &res.SlotCapacity)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return nil, fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch merchant schedule: %w", utils.ErrDatabase)
+	}
+
+	rows, err := mr.db.Query(ctx, `
+    SELECT weekday, to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI')
+    FROM merchant_hours WHERE merchant_id = $1 ORDER BY weekday
+    `, res.MerchantID)
+	if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch opening hours: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res.Hours = []model.OpeningHours{}
+	for rows.Next() {
+		var h model.OpeningHours
+		if err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes); err != nil {
+			utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to fetch opening hours: %w", utils.ErrDatabase)
+		}
+		res.Hours = append(res.Hours, h)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch opening hours: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// SetMerchantScheduleRepo replaces the schedule of the user's merchant,
+// opening hours included.
+func (mr *MerchantRepo) SetMerchantScheduleRepo(ctx context.Context, userID uuid.UUID, schedule *model.MerchantSchedule) error {
+	tx, err := mr.db.Begin(ctx)
 	if err != nil {
-		mr.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return fmt.Errorf("%w", utils.ErrDatabase)
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update merchant schedule: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+    UPDATE merchants SET timezone = $2, slot_capacity = $3, updated_at = NOW()
+    WHERE user_id = $1
+    RETURNING merchant_id
+    `, userID, schedule.Timezone, schedule.SlotCapacity).Scan(&schedule.MerchantID)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, mr.zap).Warn(utils.ErrNotFound.Error(), zap.String("UserID", userID.String()))
+		return fmt.Errorf("merchant not exists: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update merchant schedule: %w", utils.ErrDatabase)
+	}
+	if _, err := tx.Exec(ctx, `
+    DELETE FROM merchant_hours WHERE merchant_id = $1
+    `, schedule.MerchantID); err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update opening hours: %w", utils.ErrDatabase)
+	}
+	for _, h := range schedule.Hours {
+		_, err := tx.Exec(ctx, `
+    INSERT INTO merchant_hours (merchant_id, weekday, opens_at, closes_at)
+    VALUES ($1, $2, $3::time, $4::time)
+    `, schedule.MerchantID, h.Weekday, h.Opens, h.Closes)
+		if err != nil {
+			utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to update opening hours: %w", utils.ErrDatabase)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, mr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update merchant schedule: %w", utils.ErrDatabase)
 	}
 	return nil
 }
@@ -0,0 +1,875 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type OrderRepoImpl interface {
+	CreateOrderRepo(ctx context.Context, new *model.Order, slotCapacity int) error
+	GetOrderRepo(ctx context.Context, orderID uuid.UUID) (*model.Order, error)
+	ReleaseScheduledOrdersRepo(ctx context.Context, now, respondBy time.Time, limit int) (int64, error)
+	ListMerchantOrdersRepo(ctx context.Context, merchantID uuid.UUID, filter *model.OrderFilter) ([]model.Order, error)
+	AcceptOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, prepMinutes int, now time.Time) error
+	RejectOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, reason string, now time.Time) error
+	MarkOrderReadyRepo(ctx context.Context, merchantID, orderID uuid.UUID, now time.Time) error
+	MarkItemUnavailableRepo(ctx context.Context, merchantID, orderID, itemID uuid.UUID, substituteMenuID *uuid.UUID, now time.Time) error
+	ResolveSubstitutionRepo(ctx context.Context, customerID, orderID, itemID uuid.UUID, accept bool, now time.Time) error
+	AutoRejectOrdersRepo(ctx context.Context, now time.Time, reason string, limit int) (int64, error)
+	CancelOrderRepo(ctx context.Context, c *model.OrderCancellation) error
+	CreateDisputeRepo(ctx context.Context, customerID uuid.UUID, new *model.Dispute) error
+	GetDisputeRepo(ctx context.Context, disputeID uuid.UUID) (*model.Dispute, error)
+	ListDisputesRepo(ctx context.Context, filter *model.DisputeFilter) ([]model.Dispute, error)
+	ResolveDisputeRepo(ctx context.Context, r *model.DisputeResolution, audit *model.AuditLog) error
+}
+type OrderRepo struct {
+	db  *pgxpool.Pool
+	zap *zap.Logger
+}
+
+func NewOrderRepo(db *pgxpool.Pool, zap *zap.Logger) *OrderRepo {
+	return &OrderRepo{
+		db:  db,
+		zap: zap,
+	}
+}
+
+// CreateOrderRepo prices new.Items from the merchant's menu, takes the total
+// from the customer's wallet and stores the order, all in one transaction.
+// A scheduled order is refused with ErrSlotUnavailable once its slot holds
+// slotCapacity orders.
+func (or *OrderRepo) CreateOrderRepo(ctx context.Context, new *model.Order, slotCapacity int) error {
+	tx, err := or.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to create order: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the merchant serialises its orders, so two customers cannot
+	// both take the last place in a slot.
+	err = tx.QueryRow(ctx, `
+    SELECT owner FROM merchants WHERE merchant_id = $1 AND status = 'approved'
+    FOR UPDATE
+    `, new.MerchantID).Scan(&new.Merchant)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("merchant_id", new.MerchantID.String()))
+		return fmt.Errorf("merchant not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to create order: %w", utils.ErrDatabase)
+	}
+	if new.ScheduledFor != nil {
+		var taken int
+		err := tx.QueryRow(ctx, `
+    SELECT COUNT(*) FROM orders WHERE merchant_id = $1 AND scheduled_for = $2 AND status NOT IN ('rejected', 'cancelled')
+    `, new.MerchantID, *new.ScheduledFor).Scan(&taken)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to count slot orders: %w", utils.ErrDatabase)
+		}
+		if taken >= slotCapacity {
+			utils.Logger(ctx, or.zap).Warn(utils.ErrSlotUnavailable.Error(), zap.Time("slot", *new.ScheduledFor), zap.Int("taken", taken))
+			return fmt.Errorf("slot %s is full: %w", new.ScheduledFor.Format(time.RFC3339), utils.ErrSlotUnavailable)
+		}
+	}
+
+	if err := or.priceItems(ctx, tx, new); err != nil {
+		return err
+	}
+
+	var balance int64
+	err = tx.QueryRow(ctx, `
+    UPDATE users SET balance = COALESCE(balance, 0) - $2
+    WHERE user_id = $1 AND deleted_at IS NULL AND COALESCE(balance, 0) >= $2
+    RETURNING balance
+    `, new.CustomerID, new.Total).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrInsufficientFunds.Error(), zap.String("user_id", new.CustomerID.String()), zap.Int64("total", new.Total))
+		return fmt.Errorf("order total %d: %w", new.Total, utils.ErrInsufficientFunds)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to charge wallet: %w", utils.ErrDatabase)
+	}
+	_, err = tx.Exec(ctx, `
+    INSERT INTO wallet_transactions (transaction_id, user_id, amount, balance_after, kind, reason, actor_id, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $2, $7)
+    `, uuid.New(), new.CustomerID, -new.Total, balance, model.WalletKindOrderPayment, "order "+new.OrderID.String(), new.CreatedAt)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to record wallet transaction: %w", utils.ErrDatabase)
+	}
+
+	_, err = tx.Exec(ctx, `
+    INSERT INTO orders (order_id, customer_id, merchant_id, driver_id, status, total, scheduled_for, release_at, placed_at, respond_by, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+    `, new.OrderID, new.CustomerID, new.MerchantID, new.DriverID, new.Status, new.Total, new.ScheduledFor, new.ReleaseAt, new.PlacedAt, new.RespondBy, new.CreatedAt)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to create order: %w", utils.ErrDatabase)
+	}
+	for i, item := range new.Items {
+		_, err := tx.Exec(ctx, `
+    INSERT INTO order_items (order_item_id, order_id, line, menu_id, name, price, quantity, status)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, item.OrderItemID, new.OrderID, i, item.MenuID, item.Name, item.Price, item.Quantity, item.Status)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to create order item: %w", utils.ErrDatabase)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to create order: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+// priceItems fills in the name and price of each item from the merchant's
+// menu and sets the order total.
+func (or *OrderRepo) priceItems(ctx context.Context, tx pgx.Tx, new *model.Order) error {
+	ids := make([]uuid.UUID, len(new.Items))
+	for i, item := range new.Items {
+		ids[i] = item.MenuID
+	}
+	rows, err := tx.Query(ctx, `
+    SELECT menu_id, name, price FROM menus WHERE merchant_id = $1 AND menu_id = ANY($2)
+    `, new.MerchantID, ids)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch menus: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	menus := make(map[uuid.UUID]model.OrderItem, len(ids))
+	for rows.Next() {
+		var m model.OrderItem
+		if err := rows.Scan(&m.MenuID, &m.Name, &m.Price); err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to fetch menus: %w", utils.ErrDatabase)
+		}
+		menus[m.MenuID] = m
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch menus: %w", utils.ErrDatabase)
+	}
+
+	new.Total = 0
+	for i := range new.Items {
+		item := &new.Items[i]
+		m, ok := menus[item.MenuID]
+		if !ok {
+			utils.Logger(ctx, or.zap).Warn(utils.ErrBadRequest.Error(), zap.String("menu_id", item.MenuID.String()))
+			return fmt.Errorf("menu %s is not sold by this merchant: %w", item.MenuID, utils.ErrBadRequest)
+		}
+		item.Name = m.Name
+		item.Price = m.Price
+		item.Status = model.ItemStatusAvailable
+		new.Total += m.Price * int64(item.Quantity)
+	}
+	return nil
+}
+
+const orderColumns = `o.order_id, o.customer_id, u.username, o.merchant_id, m.owner, o.status, o.total,
+      o.scheduled_for, o.release_at, o.placed_at, o.respond_by, COALESCE(o.prep_minutes, 0), o.accepted_at,
+      o.ready_at, o.rejected_at, COALESCE(o.reject_reason, ''), o.driver_id, o.cancelled_at, COALESCE(o.cancelled_by, ''),
+      COALESCE(o.cancel_reason, ''), COALESCE(o.cancel_note, ''), o.cancel_fee, o.refunded, o.created_at
+    FROM orders o
+    JOIN users u ON u.user_id = o.customer_id
+    JOIN merchants m ON m.merchant_id = o.merchant_id`
+
+func scanOrder(row pgx.Row, res *model.Order) error {
+	return row.Scan(&res.OrderID, &res.CustomerID, &res.Customer, &res.MerchantID, &res.Merchant, &res.Status, &res.Total,
+		&res.ScheduledFor, &res.ReleaseAt, &res.PlacedAt, &res.RespondBy, &res.PrepMinutes, &res.AcceptedAt,
+		&res.ReadyAt, &res.RejectedAt, &res.RejectReason, &res.DriverID, &res.CancelledAt, &res.CancelledBy,
+		&res.CancelReason, &res.CancelNote, &res.CancelFee, &res.Refunded, &res.CreatedAt)
+}
+
+func (or *OrderRepo) GetOrderRepo(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
+	var res model.Order
+	err := scanOrder(or.db.QueryRow(ctx, `
+    SELECT `+orderColumns+`
+    WHERE o.order_id = $1
+    `, orderID), &res)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("order_id", orderID.String()))
+		return nil, fmt.Errorf("order not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch order: %w", utils.ErrDatabase)
+	}
+	orders := []model.Order{res}
+	if err := or.loadItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// loadItems fills in the items of each order.
+func (or *OrderRepo) loadItems(ctx context.Context, orders []model.Order) error {
+	return loadOrderItems(ctx, or.db, or.zap, orders)
+}
+
+// loadOrderItems is loadItems for the repositories that read orders of
+// their own, such as the user export.
+func loadOrderItems(ctx context.Context, db *pgxpool.Pool, log *zap.Logger, orders []model.Order) error {
+	ids := make([]uuid.UUID, len(orders))
+	index := make(map[uuid.UUID]int, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].OrderID
+		index[orders[i].OrderID] = i
+		orders[i].Items = []model.OrderItem{}
+	}
+	rows, err := db.Query(ctx, `
+    SELECT order_id, order_item_id, menu_id, name, price, quantity, status,
+      substitute_menu_id, COALESCE(substitute_name, ''), COALESCE(substitute_price, 0)
+    FROM order_items WHERE order_id = ANY($1) ORDER BY order_id, line
+    `, ids)
+	if err != nil {
+		utils.Logger(ctx, log).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch order items: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			orderID      uuid.UUID
+			item         model.OrderItem
+			substituteID *uuid.UUID
+			substitute   model.Substitute
+		)
+		if err := rows.Scan(&orderID, &item.OrderItemID, &item.MenuID, &item.Name, &item.Price, &item.Quantity, &item.Status,
+			&substituteID, &substitute.Name, &substitute.Price); err != nil {
+			utils.Logger(ctx, log).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to fetch order items: %w", utils.ErrDatabase)
+		}
+		if substituteID != nil {
+			substitute.MenuID = *substituteID
+			item.Substitute = &substitute
+		}
+		o := &orders[index[orderID]]
+		o.Items = append(o.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, log).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch order items: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+// ReleaseScheduledOrdersRepo places up to limit scheduled orders whose
+// release time has come, giving the merchant until respondBy to accept them,
+// and returns how many it placed. Rows another instance is releasing are
+// skipped, and the status check in the outer update means an order is only
+// ever released once.
+func (or *OrderRepo) ReleaseScheduledOrdersRepo(ctx context.Context, now, respondBy time.Time, limit int) (int64, error) {
+	tag, err := or.db.Exec(ctx, `
+    UPDATE orders SET status = 'placed', placed_at = $1, respond_by = $2, updated_at = $1
+    WHERE status = 'scheduled' AND order_id IN (
+      SELECT order_id FROM orders
+      WHERE status = 'scheduled' AND release_at <= $1
+      ORDER BY release_at
+      LIMIT $3
+      FOR UPDATE SKIP LOCKED
+    )
+    `, now, respondBy, limit)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to release scheduled orders: %w", utils.ErrDatabase)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListMerchantOrdersRepo returns a merchant's orders, newest first.
+func (or *OrderRepo) ListMerchantOrdersRepo(ctx context.Context, merchantID uuid.UUID, filter *model.OrderFilter) ([]model.Order, error) {
+	statuses := filter.Statuses
+	if statuses == nil {
+		statuses = []string{}
+	}
+	rows, err := or.db.Query(ctx, `
+    SELECT `+orderColumns+`
+    WHERE o.merchant_id = $1 AND (cardinality($2::text[]) = 0 OR o.status = ANY($2))
+    ORDER BY o.created_at DESC, o.order_id
+    LIMIT $3 OFFSET $4
+    `, merchantID, statuses, filter.Limit, filter.Offset)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list orders: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.Order{}
+	for rows.Next() {
+		var order model.Order
+		if err := scanOrder(rows, &order); err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list orders: %w", utils.ErrDatabase)
+		}
+		res = append(res, order)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list orders: %w", utils.ErrDatabase)
+	}
+	rows.Close()
+	if len(res) == 0 {
+		return res, nil
+	}
+	if err := or.loadItems(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// lockedOrder is the part of an order the state changes below read under
+// its row lock. merchantUserID and driverUserID are the users behind the
+// merchant and the assigned driver, if any.
+type lockedOrder struct {
+	orderID        uuid.UUID
+	customerID     uuid.UUID
+	merchantID     uuid.UUID
+	merchantUserID uuid.UUID
+	driverUserID   *uuid.UUID
+	status         string
+	total          int64
+	refunded       int64
+}
+
+// withOrder locks the order in a transaction and calls fn with it unless the
+// order does not exist or does not belong to owns. fn returning an error
+// rolls everything back.
+func (or *OrderRepo) withOrder(ctx context.Context, orderID uuid.UUID, owns func(o *lockedOrder) bool, fn func(tx pgx.Tx, o *lockedOrder) error) error {
+	tx, err := or.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update order: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	o := lockedOrder{orderID: orderID}
+	err = tx.QueryRow(ctx, `
+    SELECT o.customer_id, o.merchant_id, m.user_id, d.user_id, o.status, o.total, o.refunded FROM orders o
+    JOIN merchants m ON m.merchant_id = o.merchant_id
+    LEFT JOIN drivers d ON d.driver_id = o.driver_id
+    WHERE o.order_id = $1
+    FOR UPDATE OF o
+    `, orderID).Scan(&o.customerID, &o.merchantID, &o.merchantUserID, &o.driverUserID, &o.status, &o.total, &o.refunded)
+	if err == pgx.ErrNoRows || (err == nil && !owns(&o)) {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("order_id", orderID.String()))
+		return fmt.Errorf("order not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch order: %w", utils.ErrDatabase)
+	}
+	if err := fn(tx, &o); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update order: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+func ownedByMerchant(merchantID uuid.UUID) func(o *lockedOrder) bool {
+	return func(o *lockedOrder) bool { return o.merchantID == merchantID }
+}
+
+func wrongState(o *lockedOrder, action string) error {
+	return fmt.Errorf("cannot %s a %s order: %w", action, o.status, utils.ErrOrderState)
+}
+
+func (or *OrderRepo) AcceptOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, prepMinutes int, now time.Time) error {
+	return or.withOrder(ctx, orderID, ownedByMerchant(merchantID), func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusPlaced {
+			return wrongState(o, "accept")
+		}
+		_, err := tx.Exec(ctx, `
+    UPDATE orders SET status = 'accepted', prep_minutes = $2, accepted_at = $3, updated_at = $3
+    WHERE order_id = $1
+    `, orderID, prepMinutes, now)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to accept order: %w", utils.ErrDatabase)
+		}
+		return nil
+	})
+}
+
+// RejectOrderRepo rejects a placed order and refunds what the customer has
+// not already had back.
+func (or *OrderRepo) RejectOrderRepo(ctx context.Context, merchantID, orderID uuid.UUID, reason string, now time.Time) error {
+	return or.withOrder(ctx, orderID, ownedByMerchant(merchantID), func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusPlaced {
+			return wrongState(o, "reject")
+		}
+		return or.reject(ctx, tx, o, reason, now)
+	})
+}
+
+func (or *OrderRepo) reject(ctx context.Context, tx pgx.Tx, o *lockedOrder, reason string, now time.Time) error {
+	if err := or.refund(ctx, tx, o, o.total-o.refunded, nil, "order "+o.orderID.String()+" rejected", now); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+    UPDATE orders SET status = 'rejected', rejected_at = $2, reject_reason = $3, updated_at = $2
+    WHERE order_id = $1
+    `, o.orderID, now, reason)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to reject order: %w", utils.ErrDatabase)
+	}
+	o.status = model.OrderStatusRejected
+	return nil
+}
+
+// refund pays amount of the order back into the customer's wallet. actorID
+// is the user who caused the refund, or nil when the system did.
+func (or *OrderRepo) refund(ctx context.Context, tx pgx.Tx, o *lockedOrder, amount int64, actorID *uuid.UUID, reason string, now time.Time) error {
+	if amount <= 0 {
+		return nil
+	}
+	var balance int64
+	err := tx.QueryRow(ctx, `
+    UPDATE users SET balance = COALESCE(balance, 0) + $2 WHERE user_id = $1
+    RETURNING balance
+    `, o.customerID, amount).Scan(&balance)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to refund order: %w", utils.ErrDatabase)
+	}
+	_, err = tx.Exec(ctx, `
+    INSERT INTO wallet_transactions (transaction_id, user_id, amount, balance_after, kind, reason, actor_id, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, uuid.New(), o.customerID, amount, balance, model.WalletKindOrderRefund, reason, actorID, now)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to record wallet transaction: %w", utils.ErrDatabase)
+	}
+	_, err = tx.Exec(ctx, `
+    UPDATE orders SET refunded = refunded + $2, updated_at = $3 WHERE order_id = $1
+    `, o.orderID, amount, now)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to refund order: %w", utils.ErrDatabase)
+	}
+	o.refunded += amount
+	return nil
+}
+
+// MarkOrderReadyRepo marks an accepted order ready once the customer has
+// answered every substitution.
+func (or *OrderRepo) MarkOrderReadyRepo(ctx context.Context, merchantID, orderID uuid.UUID, now time.Time) error {
+	return or.withOrder(ctx, orderID, ownedByMerchant(merchantID), func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusAccepted {
+			return wrongState(o, "mark ready")
+		}
+		var waiting bool
+		err := tx.QueryRow(ctx, `
+    SELECT EXISTS (SELECT 1 FROM order_items WHERE order_id = $1 AND status = 'substitution_offered')
+    `, orderID).Scan(&waiting)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to check order items: %w", utils.ErrDatabase)
+		}
+		if waiting {
+			return fmt.Errorf("the customer has not answered a substitution yet: %w", utils.ErrOrderState)
+		}
+		_, err = tx.Exec(ctx, `
+    UPDATE orders SET status = 'ready', ready_at = $2, updated_at = $2 WHERE order_id = $1
+    `, orderID, now)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to mark order ready: %w", utils.ErrDatabase)
+		}
+		return nil
+	})
+}
+
+// lockedItem is an order item read under its row lock.
+type lockedItem struct {
+	menuID          uuid.UUID
+	price           int64
+	quantity        int
+	status          string
+	substitutePrice int64
+}
+
+func (or *OrderRepo) lockItem(ctx context.Context, tx pgx.Tx, orderID, itemID uuid.UUID) (*lockedItem, error) {
+	var item lockedItem
+	err := tx.QueryRow(ctx, `
+    SELECT menu_id, price, quantity, status, COALESCE(substitute_price, 0) FROM order_items
+    WHERE order_item_id = $1 AND order_id = $2
+    FOR UPDATE
+    `, itemID, orderID).Scan(&item.menuID, &item.price, &item.quantity, &item.status, &item.substitutePrice)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("order_item_id", itemID.String()))
+		return nil, fmt.Errorf("order item not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch order item: %w", utils.ErrDatabase)
+	}
+	return &item, nil
+}
+
+// refundItem refunds an item in full and rejects the order if nothing is
+// left of it.
+func (or *OrderRepo) refundItem(ctx context.Context, tx pgx.Tx, o *lockedOrder, itemID uuid.UUID, item *lockedItem, now time.Time) error {
+	_, err := tx.Exec(ctx, `
+    UPDATE order_items SET status = 'refunded' WHERE order_item_id = $1
+    `, itemID)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to update order item: %w", utils.ErrDatabase)
+	}
+	if err := or.refund(ctx, tx, o, item.price*int64(item.quantity), nil, "order "+o.orderID.String()+" item unavailable", now); err != nil {
+		return err
+	}
+	var left bool
+	err = tx.QueryRow(ctx, `
+    SELECT EXISTS (SELECT 1 FROM order_items WHERE order_id = $1 AND status <> 'refunded')
+    `, o.orderID).Scan(&left)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to check order items: %w", utils.ErrDatabase)
+	}
+	if !left {
+		return or.reject(ctx, tx, o, model.RejectReasonNothingLeft, now)
+	}
+	return nil
+}
+
+// MarkItemUnavailableRepo offers the customer the substitute from the
+// merchant's menu, or refunds the item when there is none.
+func (or *OrderRepo) MarkItemUnavailableRepo(ctx context.Context, merchantID, orderID, itemID uuid.UUID, substituteMenuID *uuid.UUID, now time.Time) error {
+	return or.withOrder(ctx, orderID, ownedByMerchant(merchantID), func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusPlaced && o.status != model.OrderStatusAccepted {
+			return wrongState(o, "change items of")
+		}
+		item, err := or.lockItem(ctx, tx, orderID, itemID)
+		if err != nil {
+			return err
+		}
+		if item.status != model.ItemStatusAvailable {
+			return fmt.Errorf("item is already %s: %w", item.status, utils.ErrOrderState)
+		}
+		if substituteMenuID == nil {
+			return or.refundItem(ctx, tx, o, itemID, item, now)
+		}
+		if *substituteMenuID == item.menuID {
+			return fmt.Errorf("an item cannot substitute itself: %w", utils.ErrBadRequest)
+		}
+		var substitute model.Substitute
+		err = tx.QueryRow(ctx, `
+    SELECT menu_id, name, price FROM menus WHERE menu_id = $1 AND merchant_id = $2
+    `, *substituteMenuID, merchantID).Scan(&substitute.MenuID, &substitute.Name, &substitute.Price)
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("menu %s is not sold by this merchant: %w", *substituteMenuID, utils.ErrBadRequest)
+		} else if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to fetch menu: %w", utils.ErrDatabase)
+		}
+		_, err = tx.Exec(ctx, `
+    UPDATE order_items SET status = 'substitution_offered', substitute_menu_id = $2, substitute_name = $3, substitute_price = $4
+    WHERE order_item_id = $1
+    `, itemID, substitute.MenuID, substitute.Name, substitute.Price)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to update order item: %w", utils.ErrDatabase)
+		}
+		return nil
+	})
+}
+
+// ResolveSubstitutionRepo records the customer's answer to a substitution.
+// An accepted substitute that costs less refunds the difference; a declined
+// one refunds the item.
+func (or *OrderRepo) ResolveSubstitutionRepo(ctx context.Context, customerID, orderID, itemID uuid.UUID, accept bool, now time.Time) error {
+	owns := func(o *lockedOrder) bool { return o.customerID == customerID }
+	return or.withOrder(ctx, orderID, owns, func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusPlaced && o.status != model.OrderStatusAccepted {
+			return wrongState(o, "change items of")
+		}
+		item, err := or.lockItem(ctx, tx, orderID, itemID)
+		if err != nil {
+			return err
+		}
+		if item.status != model.ItemStatusSubstitutionOffered {
+			return fmt.Errorf("item has no substitution to answer: %w", utils.ErrOrderState)
+		}
+		if !accept {
+			return or.refundItem(ctx, tx, o, itemID, item, now)
+		}
+		_, err = tx.Exec(ctx, `
+    UPDATE order_items SET status = 'substituted' WHERE order_item_id = $1
+    `, itemID)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to update order item: %w", utils.ErrDatabase)
+		}
+		difference := (item.price - item.substitutePrice) * int64(item.quantity)
+		return or.refund(ctx, tx, o, difference, nil, "order "+orderID.String()+" cheaper substitute", now)
+	})
+}
+
+// AutoRejectOrdersRepo rejects and refunds up to limit placed orders whose
+// merchant did not respond in time, and returns how many it rejected. Rows
+// another instance is handling are skipped.
+func (or *OrderRepo) AutoRejectOrdersRepo(ctx context.Context, now time.Time, reason string, limit int) (int64, error) {
+	tx, err := or.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to reject overdue orders: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+    SELECT order_id, customer_id, merchant_id, status, total, refunded FROM orders
+    WHERE status = 'placed' AND respond_by <= $1
+    ORDER BY respond_by
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+    `, now, limit)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to fetch overdue orders: %w", utils.ErrDatabase)
+	}
+	var overdue []*lockedOrder
+	for rows.Next() {
+		var o lockedOrder
+		if err := rows.Scan(&o.orderID, &o.customerID, &o.merchantID, &o.status, &o.total, &o.refunded); err != nil {
+			rows.Close()
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return 0, fmt.Errorf("failed to fetch overdue orders: %w", utils.ErrDatabase)
+		}
+		overdue = append(overdue, &o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to fetch overdue orders: %w", utils.ErrDatabase)
+	}
+
+	for _, o := range overdue {
+		if err := or.reject(ctx, tx, o, reason, now); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return 0, fmt.Errorf("failed to reject overdue orders: %w", utils.ErrDatabase)
+	}
+	return int64(len(overdue)), nil
+}
+
+// cancellable reports whether by may cancel an order in o's state, and
+// whether the order is past the point a customer cancels for free.
+func cancellable(o *lockedOrder, by string) (allowed, late bool) {
+	switch o.status {
+	case model.OrderStatusScheduled, model.OrderStatusPlaced:
+		return by == model.CancelledByCustomer, false
+	case model.OrderStatusAccepted, model.OrderStatusReady:
+		return true, true
+	}
+	return false, false
+}
+
+// cancelledBy matches orders on which c.ActorID is the party c.By.
+func cancelledBy(c *model.OrderCancellation) func(o *lockedOrder) bool {
+	return func(o *lockedOrder) bool {
+		switch c.By {
+		case model.CancelledByCustomer:
+			return o.customerID == c.ActorID
+		case model.CancelledByMerchant:
+			return o.merchantUserID == c.ActorID
+		case model.CancelledByDriver:
+			return o.driverUserID != nil && *o.driverUserID == c.ActorID
+		}
+		return false
+	}
+}
+
+// CancelOrderRepo cancels the order for c.By and refunds the customer what
+// they have not had back yet. A customer cancelling after the merchant
+// accepted keeps up to c.Fee of it unrefunded; merchants and drivers can only
+// cancel accepted orders, since a placed one is rejected instead.
+func (or *OrderRepo) CancelOrderRepo(ctx context.Context, c *model.OrderCancellation) error {
+	return or.withOrder(ctx, c.OrderID, cancelledBy(c), func(tx pgx.Tx, o *lockedOrder) error {
+		allowed, late := cancellable(o, c.By)
+		if !allowed {
+			return wrongState(o, "cancel")
+		}
+		var fee int64
+		if late && c.By == model.CancelledByCustomer {
+			fee = min(c.Fee, o.total-o.refunded)
+		}
+		if err := or.refund(ctx, tx, o, o.total-o.refunded-fee, &c.ActorID, "order "+o.orderID.String()+" cancelled", c.At); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+    UPDATE orders SET status = 'cancelled', cancelled_at = $2, cancelled_by = $3, cancel_reason = NULLIF($4, ''),
+      cancel_note = NULLIF($5, ''), cancel_fee = $6, updated_at = $2
+    WHERE order_id = $1
+    `, o.orderID, c.At, c.By, c.Reason, c.Note, fee)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to cancel order: %w", utils.ErrDatabase)
+		}
+		o.status = model.OrderStatusCancelled
+		return nil
+	})
+}
+
+// CreateDisputeRepo opens new on a ready order of the customer's. The items
+// must belong to the order and not already be refunded, and the order may
+// only have one open dispute at a time.
+func (or *OrderRepo) CreateDisputeRepo(ctx context.Context, customerID uuid.UUID, new *model.Dispute) error {
+	owns := func(o *lockedOrder) bool { return o.customerID == customerID }
+	return or.withOrder(ctx, new.OrderID, owns, func(tx pgx.Tx, o *lockedOrder) error {
+		if o.status != model.OrderStatusReady {
+			return wrongState(o, "dispute")
+		}
+		var open bool
+		err := tx.QueryRow(ctx, `
+    SELECT EXISTS (SELECT 1 FROM order_disputes WHERE order_id = $1 AND status = 'open')
+    `, new.OrderID).Scan(&open)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to check disputes: %w", utils.ErrDatabase)
+		}
+		if open {
+			return fmt.Errorf("order already has an open dispute: %w", utils.ErrOrderState)
+		}
+		var found int
+		err = tx.QueryRow(ctx, `
+    SELECT COUNT(*) FROM order_items WHERE order_id = $1 AND order_item_id = ANY($2) AND status <> 'refunded'
+    `, new.OrderID, new.OrderItemIDs).Scan(&found)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to check order items: %w", utils.ErrDatabase)
+		}
+		if found != len(new.OrderItemIDs) {
+			return fmt.Errorf("disputed items must be unrefunded items of the order: %w", utils.ErrBadRequest)
+		}
+		_, err = tx.Exec(ctx, `
+    INSERT INTO order_disputes (dispute_id, order_id, kind, order_item_ids, description, status, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, new.DisputeID, new.OrderID, new.Kind, new.OrderItemIDs, new.Description, new.Status, new.CreatedAt)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to create dispute: %w", utils.ErrDatabase)
+		}
+		return nil
+	})
+}
+
+const disputeColumns = `d.dispute_id, d.order_id, o.customer_id, u.username, d.kind, d.order_item_ids, d.description,
+      d.status, d.refund, COALESCE(d.resolution, ''), d.created_at, d.resolved_at
+    FROM order_disputes d
+    JOIN orders o ON o.order_id = d.order_id
+    JOIN users u ON u.user_id = o.customer_id`
+
+func scanDispute(row pgx.Row, res *model.Dispute) error {
+	return row.Scan(&res.DisputeID, &res.OrderID, &res.CustomerID, &res.Customer, &res.Kind, &res.OrderItemIDs, &res.Description,
+		&res.Status, &res.Refund, &res.Resolution, &res.CreatedAt, &res.ResolvedAt)
+}
+
+func (or *OrderRepo) GetDisputeRepo(ctx context.Context, disputeID uuid.UUID) (*model.Dispute, error) {
+	var res model.Dispute
+	err := scanDispute(or.db.QueryRow(ctx, `
+    SELECT `+disputeColumns+`
+    WHERE d.dispute_id = $1
+    `, disputeID), &res)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("dispute_id", disputeID.String()))
+		return nil, fmt.Errorf("dispute not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch dispute: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// ListDisputesRepo returns disputes oldest first, so the ones waiting longest
+// come up first.
+func (or *OrderRepo) ListDisputesRepo(ctx context.Context, filter *model.DisputeFilter) ([]model.Dispute, error) {
+	rows, err := or.db.Query(ctx, `
+    SELECT `+disputeColumns+`
+    WHERE ($1 = '' OR d.status = $1)
+    ORDER BY d.created_at, d.dispute_id
+    LIMIT $2 OFFSET $3
+    `, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list disputes: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res := []model.Dispute{}
+	for rows.Next() {
+		var d model.Dispute
+		if err := scanDispute(rows, &d); err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to list disputes: %w", utils.ErrDatabase)
+		}
+		res = append(res, d)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to list disputes: %w", utils.ErrDatabase)
+	}
+	return res, nil
+}
+
+// ResolveDisputeRepo closes an open dispute, refunds r.Refund of the order to
+// the customer and writes audit, in one transaction. The refund may not be
+// more than the order has left to refund.
+func (or *OrderRepo) ResolveDisputeRepo(ctx context.Context, r *model.DisputeResolution, audit *model.AuditLog) error {
+	var orderID uuid.UUID
+	err := or.db.QueryRow(ctx, `
+    SELECT order_id FROM order_disputes WHERE dispute_id = $1
+    `, r.DisputeID).Scan(&orderID)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, or.zap).Warn(utils.ErrNotFound.Error(), zap.String("dispute_id", r.DisputeID.String()))
+		return fmt.Errorf("dispute not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to fetch dispute: %w", utils.ErrDatabase)
+	}
+	anyone := func(*lockedOrder) bool { return true }
+	return or.withOrder(ctx, orderID, anyone, func(tx pgx.Tx, o *lockedOrder) error {
+		var status string
+		err := tx.QueryRow(ctx, `
+    SELECT status FROM order_disputes WHERE dispute_id = $1
+    FOR UPDATE
+    `, r.DisputeID).Scan(&status)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to fetch dispute: %w", utils.ErrDatabase)
+		}
+		if status != model.DisputeStatusOpen {
+			return fmt.Errorf("dispute is already %s: %w", status, utils.ErrOrderState)
+		}
+		if left := o.total - o.refunded; r.Refund > left {
+			return fmt.Errorf("refund %d is more than the %d left to refund: %w", r.Refund, left, utils.ErrBadRequest)
+		}
+		reason := "dispute " + r.DisputeID.String() + " on order " + orderID.String()
+		if err := or.refund(ctx, tx, o, r.Refund, &r.AdminID, reason, r.At); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+    UPDATE order_disputes SET status = 'resolved', refund = $2, resolution = $3, resolved_by = $4, resolved_at = $5
+    WHERE dispute_id = $1
+    `, r.DisputeID, r.Refund, r.Resolution, r.AdminID, r.At)
+		if err != nil {
+			utils.Logger(ctx, or.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to resolve dispute: %w", utils.ErrDatabase)
+		}
+		return insertAudit(ctx, tx, audit)
+	})
+}
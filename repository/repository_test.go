@@ -0,0 +1,29 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/repository/repotest"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	repotest.Main(m)
+}
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		db := repotest.Postgres(t)
+		logger := zap.NewNop()
+		return repotest.Repos{
+			Users:         repository.NewUserRepo(db, logger),
+			Merchants:     repository.NewMerchantRepo(db, logger),
+			Drivers:       repository.NewDriverRepo(db, logger),
+			Menus:         repository.NewMenuRepo(db, logger),
+			LoginAttempts: repository.NewLoginAttemptRepo(db, logger),
+			Audit:         repository.NewAuditRepo(db, logger),
+			Orders:        repository.NewOrderRepo(db, logger),
+		}
+	})
+}
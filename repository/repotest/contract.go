@@ -0,0 +1,1619 @@
+// Package repotest holds the contract every repository implementation must
+// meet, so the Postgres and in-memory versions cannot drift apart.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+)
+
+// Repos is one set of repositories sharing a store.
+type Repos struct {
+	Users         repository.UserRepoImpl
+	Merchants     repository.MerchantRepoImpl
+	Drivers       repository.DriverRepoImpl
+	Menus         repository.MenuRepoImpl
+	LoginAttempts repository.LoginAttemptRepoImpl
+	Audit         repository.AuditRepoImpl
+	Orders        repository.OrderRepoImpl
+}
+
+// Run checks the contract against the repositories returned by newRepos,
+// which is called once per subtest and must start from an empty store.
+func Run(t *testing.T, newRepos func(t *testing.T) Repos) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r Repos)
+	}{
+		{"UserRegisterAndGet", testUserRegisterAndGet},
+		{"UserUnique", testUserUnique},
+		{"UserNotFound", testUserNotFound},
+		{"UserUpdate", testUserUpdate},
+		{"UserDelete", testUserDelete},
+		{"DeletedProfilesHidden", testDeletedProfilesHidden},
+		{"UserExport", testUserExport},
+		{"UserDeleteDocuments", testUserDeleteDocuments},
+		{"MerchantCreateAndGet", testMerchantCreateAndGet},
+		{"MerchantUnique", testMerchantUnique},
+		{"MerchantUpdate", testMerchantUpdate},
+		{"MerchantSchedule", testMerchantSchedule},
+		{"DriverCreateAndGet", testDriverCreateAndGet},
+		{"DriverUnique", testDriverUnique},
+		{"DriverUpdate", testDriverUpdate},
+		{"DriverDocuments", testDriverDocuments},
+		{"Menu", testMenu},
+		{"LoginAttempts", testLoginAttempts},
+		{"LoginAttemptsPrune", testLoginAttemptsPrune},
+		{"Audit", testAudit},
+		{"OrderCreate", testOrderCreate},
+		{"OrderSlotCapacity", testOrderSlotCapacity},
+		{"OrderRelease", testOrderRelease},
+		{"OrderListForMerchant", testOrderListForMerchant},
+		{"OrderAcceptReject", testOrderAcceptReject},
+		{"OrderItemUnavailable", testOrderItemUnavailable},
+		{"OrderAutoReject", testOrderAutoReject},
+		{"UserExportOrders", testUserExportOrders},
+		{"OrderCancel", testOrderCancel},
+		{"OrderCancelFee", testOrderCancelFee},
+		{"OrderDisputes", testOrderDisputes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepos(t))
+		})
+	}
+}
+
+var (
+	userColumns     = map[string]bool{"name": false, "phone": true}
+	merchantColumns = map[string]bool{"name": false, "address": true, "category": true, "description": true}
+	driverColumns   = map[string]bool{"name": false, "license": false, "area": true}
+	menuColumns     = map[string]bool{"name": false, "price": false, "description": true, "category": false, "stock": false}
+)
+
+func newUser(t *testing.T, r Repos, username string) *model.User {
+	t.Helper()
+	user := &model.User{
+		UserID:    uuid.New(),
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  "hash-" + username,
+		Role:      "user",
+		CreatedAt: time.Now(),
+		Phone:     "081234567890",
+		Name:      username,
+	}
+	if err := r.Users.RegisterUserRepo(context.Background(), user); err != nil {
+		t.Fatalf("register %s: %v", username, err)
+	}
+	return user
+}
+
+func newMerchant(t *testing.T, r Repos, owner *model.User, status string) *model.Merchant {
+	t.Helper()
+	merchant := &model.Merchant{
+		MerchantID:  uuid.New(),
+		Name:        owner.Username + " kitchen",
+		Address:     "Jl. Sudirman 1",
+		Category:    "indonesian",
+		Description: "home cooking",
+		UserID:      owner.UserID,
+		Owner:       owner.Username,
+		Status:      status,
+	}
+	if err := r.Merchants.CreateMerchantRepo(context.Background(), merchant); err != nil {
+		t.Fatalf("create merchant for %s: %v", owner.Username, err)
+	}
+	return merchant
+}
+
+func newDriver(t *testing.T, r Repos, owner *model.User, license string) *model.Driver {
+	t.Helper()
+	driver := &model.Driver{
+		DriverID: uuid.New(),
+		Name:     owner.Username,
+		License:  license,
+		Area:     "Jakarta",
+		UserID:   owner.UserID,
+		Username: owner.Username,
+		Status:   model.ReviewStatusApproved,
+	}
+	if err := r.Drivers.CreateDriverRepo(context.Background(), driver); err != nil {
+		t.Fatalf("create driver for %s: %v", owner.Username, err)
+	}
+	return driver
+}
+
+func wantErr(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("err = %v, want %v", err, target)
+	}
+}
+
+func mustPatch(t *testing.T, p *utils.Patch) *utils.Patch {
+	t.Helper()
+	if err := p.Err(); err != nil {
+		t.Fatalf("build patch: %v", err)
+	}
+	return p
+}
+
+func testUserRegisterAndGet(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+
+	got, err := r.Users.GetUserRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != user.UserID || got.Username != "alice" || got.Email != user.Email || got.Role != "user" || got.Phone != user.Phone || got.Name != "alice" {
+		t.Errorf("GetUserRepo = %+v", got)
+	}
+	if got.Password != "" {
+		t.Error("GetUserRepo returned the password hash")
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("CreatedAt is zero")
+	}
+
+	login, err := r.Users.LoginRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.UserID != user.UserID || login.Password != user.Password || login.Role != "user" {
+		t.Errorf("LoginRepo = %+v", login)
+	}
+	if login.Status != model.UserStatusActive || login.TOTPEnabled {
+		t.Errorf("new user status = %q, totp = %v", login.Status, login.TOTPEnabled)
+	}
+
+	status, err := r.Users.GetAccountStatusRepo(ctx, user.UserID)
+	if err != nil || status != model.UserStatusActive {
+		t.Errorf("GetAccountStatusRepo = %q, %v", status, err)
+	}
+}
+
+func testUserUnique(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+
+	sameName := *user
+	sameName.UserID = uuid.New()
+	sameName.Email = "other@example.com"
+	wantErr(t, r.Users.RegisterUserRepo(ctx, &sameName), utils.ErrUniqueConstraint)
+
+	sameEmail := *user
+	sameEmail.UserID = uuid.New()
+	sameEmail.Username = "alice2"
+	wantErr(t, r.Users.RegisterUserRepo(ctx, &sameEmail), utils.ErrUniqueConstraint)
+}
+
+func testUserNotFound(t *testing.T, r Repos) {
+	ctx := context.Background()
+	_, err := r.Users.GetUserRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.LoginRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.GetAccountStatusRepo(ctx, uuid.New())
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.ExportUserRepo(ctx, uuid.New())
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.DeleteUserRepo(ctx, uuid.New())
+	wantErr(t, err, utils.ErrNotFound)
+
+	patch := utils.NewPatch(userColumns)
+	utils.PatchField(patch, "name", utils.Some("Nobody"))
+	_, err = r.Users.UpdateUserRepo(ctx, uuid.New(), mustPatch(t, patch))
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testUserUpdate(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+
+	patch := utils.NewPatch(userColumns)
+	utils.PatchField(patch, "name", utils.Some("Alice Liddell"))
+	utils.PatchField(patch, "phone", utils.Optional[string]{Set: true, Null: true})
+	got, err := r.Users.UpdateUserRepo(ctx, user.UserID, mustPatch(t, patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Alice Liddell" || got.Phone != "" || got.Email != user.Email {
+		t.Errorf("UpdateUserRepo = %+v", got)
+	}
+
+	stored, err := r.Users.GetUserRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Alice Liddell" || stored.Phone != "" {
+		t.Errorf("GetUserRepo after update = %+v", stored)
+	}
+}
+
+func testUserDelete(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+	newMerchant(t, r, user, model.ReviewStatusApproved)
+
+	keys, err := r.Users.DeleteUserRepo(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("DeleteUserRepo returned storage keys %v for a user without documents", keys)
+	}
+	_, err = r.Users.GetUserRepo(ctx, "alice")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.LoginRepo(ctx, "alice")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.GetAccountStatusRepo(ctx, user.UserID)
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Users.DeleteUserRepo(ctx, user.UserID)
+	wantErr(t, err, utils.ErrNotFound)
+
+	// The merchant profile no longer points at the old username.
+	_, err = r.Merchants.GetMerchantRepo(ctx, "alice")
+	wantErr(t, err, utils.ErrNotFound)
+
+	// The username and email are free again.
+	newUser(t, r, "alice")
+}
+
+func testDeletedProfilesHidden(t *testing.T, r Repos) {
+	ctx := context.Background()
+	owner := newUser(t, r, "alice")
+	merchant := newMerchant(t, r, owner, model.ReviewStatusApproved)
+	menu := &model.Menu{
+		MenuID:     uuid.New(),
+		Name:       "Nasi Goreng",
+		Price:      25000,
+		Category:   "rice",
+		Stock:      10,
+		MerchantID: merchant.MerchantID,
+	}
+	if err := r.Menus.CreateMenuRepo(ctx, menu, owner.UserID); err != nil {
+		t.Fatal(err)
+	}
+	driver := newUser(t, r, "dave")
+	newDriver(t, r, driver, "B1234XYZ")
+
+	for _, user := range []*model.User{owner, driver} {
+		if _, err := r.Users.DeleteUserRepo(ctx, user.UserID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Neither the profiles nor the menus stay public under the anonymised
+	// names.
+	_, err := r.Merchants.GetMerchantRepo(ctx, anonymisedName(owner.UserID))
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Menus.GetMenuRepo(ctx, menu.MenuID)
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Drivers.GetDriverRepo(ctx, anonymisedName(driver.UserID))
+	wantErr(t, err, utils.ErrNotFound)
+
+	app, err := r.Merchants.GetMerchantApplicationRepo(ctx, anonymisedName(owner.UserID))
+	if err != nil || app.Status != model.ReviewStatusDeleted {
+		t.Errorf("merchant application = %+v, %v", app, err)
+	}
+	app, err = r.Drivers.GetDriverApplicationRepo(ctx, anonymisedName(driver.UserID))
+	if err != nil || app.Status != model.ReviewStatusDeleted {
+		t.Errorf("driver application = %+v, %v", app, err)
+	}
+}
+
+// anonymisedName is the username DeleteUserRepo gives a deleted account.
+func anonymisedName(userID uuid.UUID) string {
+	return "deleted_" + strings.ReplaceAll(userID.String(), "-", "")[:12]
+}
+
+func testUserExport(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+
+	export, err := r.Users.ExportUserRepo(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.UserID != user.UserID || export.Profile.Username != "alice" || export.Profile.Email != user.Email {
+		t.Errorf("profile = %+v", export.Profile)
+	}
+	if export.Merchant != nil || export.Driver != nil {
+		t.Errorf("unexpected merchant %+v or driver %+v", export.Merchant, export.Driver)
+	}
+	if export.Wallet == nil || len(export.Wallet) != 0 {
+		t.Errorf("wallet = %#v, want empty", export.Wallet)
+	}
+	if export.Documents == nil || len(export.Documents) != 0 {
+		t.Errorf("documents = %#v, want empty", export.Documents)
+	}
+	if export.Orders == nil || len(export.Orders) != 0 {
+		t.Errorf("orders = %#v, want empty", export.Orders)
+	}
+
+	driver := newDriver(t, r, user, "B1234XYZ")
+	doc := newDriverDocument(t, r, driver, model.DocumentKindLicense)
+	export, err = r.Users.ExportUserRepo(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Driver == nil || export.Driver.License != "B1234XYZ" {
+		t.Errorf("driver = %+v", export.Driver)
+	}
+	// The export lists document metadata but not where the files live.
+	if len(export.Documents) != 1 || export.Documents[0].DocumentID != doc.DocumentID ||
+		export.Documents[0].FileName != doc.FileName || export.Documents[0].StorageKey != "" {
+		t.Errorf("documents = %+v", export.Documents)
+	}
+}
+
+func testUserDeleteDocuments(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "dave")
+	driver := newDriver(t, r, user, "B1234XYZ")
+	license := newDriverDocument(t, r, driver, model.DocumentKindLicense)
+	registration := newDriverDocument(t, r, driver, model.DocumentKindVehicleRegistration)
+	other := newDriverDocument(t, r, newDriver(t, r, newUser(t, r, "erin"), "D5678ABC"), model.DocumentKindLicense)
+
+	keys, err := r.Users.DeleteUserRepo(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	want := []string{license.StorageKey, registration.StorageKey}
+	sort.Strings(want)
+	if !slices.Equal(keys, want) {
+		t.Errorf("DeleteUserRepo storage keys = %v, want %v", keys, want)
+	}
+	list, err := r.Drivers.ListDriverDocumentsRepo(ctx, driver.DriverID)
+	if err != nil || len(list) != 0 {
+		t.Errorf("documents after delete = %+v, %v", list, err)
+	}
+	_, err = r.Drivers.GetDriverDocumentRepo(ctx, driver.DriverID, license.DocumentID)
+	wantErr(t, err, utils.ErrNotFound)
+
+	// Other drivers keep theirs.
+	if _, err := r.Drivers.GetDriverDocumentRepo(ctx, other.DriverID, other.DocumentID); err != nil {
+		t.Errorf("other driver's document: %v", err)
+	}
+}
+
+func newDriverDocument(t *testing.T, r Repos, driver *model.Driver, kind string) *model.DriverDocument {
+	t.Helper()
+	doc := &model.DriverDocument{
+		DocumentID:  uuid.New(),
+		DriverID:    driver.DriverID,
+		Kind:        kind,
+		FileName:    kind + ".pdf",
+		ContentType: "application/pdf",
+		Size:        100,
+		UploadedAt:  time.Now().Truncate(time.Second),
+	}
+	doc.StorageKey = "drivers/" + driver.DriverID.String() + "/" + doc.DocumentID.String() + ".pdf"
+	if err := r.Drivers.CreateDriverDocumentRepo(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func testMerchantCreateAndGet(t *testing.T, r Repos) {
+	ctx := context.Background()
+	approved := newMerchant(t, r, newUser(t, r, "alice"), model.ReviewStatusApproved)
+
+	got, err := r.Merchants.GetMerchantRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MerchantID != approved.MerchantID || got.Name != approved.Name || got.Address != approved.Address ||
+		got.Category != approved.Category || got.Description != approved.Description || got.Owner != "alice" ||
+		got.Status != model.ReviewStatusApproved {
+		t.Errorf("GetMerchantRepo = %+v", got)
+	}
+
+	newMerchant(t, r, newUser(t, r, "bob"), model.ReviewStatusPending)
+	_, err = r.Merchants.GetMerchantRepo(ctx, "bob")
+	wantErr(t, err, utils.ErrNotFound)
+	app, err := r.Merchants.GetMerchantApplicationRepo(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Status != model.ReviewStatusPending || app.ReviewedAt != nil {
+		t.Errorf("application = %+v", app)
+	}
+
+	_, err = r.Merchants.GetMerchantApplicationRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testMerchantUnique(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+	first := newMerchant(t, r, user, model.ReviewStatusApproved)
+
+	second := *first
+	second.MerchantID = uuid.New()
+	wantErr(t, r.Merchants.CreateMerchantRepo(ctx, &second), utils.ErrUniqueConstraint)
+
+	// Same user under a different owner name is still a duplicate.
+	second.Owner = "alice_renamed"
+	wantErr(t, r.Merchants.CreateMerchantRepo(ctx, &second), utils.ErrUniqueConstraint)
+}
+
+func testMerchantUpdate(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+	newMerchant(t, r, user, model.ReviewStatusApproved)
+
+	patch := utils.NewPatch(merchantColumns)
+	utils.PatchField(patch, "name", utils.Some("Warung Alice"))
+	utils.PatchField(patch, "description", utils.Optional[string]{Set: true, Null: true})
+	got, err := r.Merchants.UpdateMerchantRepo(ctx, user.UserID, mustPatch(t, patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Warung Alice" || got.Description != "" || got.Address != "Jl. Sudirman 1" || got.Owner != "alice" {
+		t.Errorf("UpdateMerchantRepo = %+v", got)
+	}
+
+	patch = utils.NewPatch(merchantColumns)
+	utils.PatchField(patch, "name", utils.Some("Nobody"))
+	_, err = r.Merchants.UpdateMerchantRepo(ctx, uuid.New(), mustPatch(t, patch))
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testMerchantSchedule(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "alice")
+	merchant := newMerchant(t, r, user, model.ReviewStatusApproved)
+
+	got, err := r.Merchants.GetMerchantScheduleRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MerchantID != merchant.MerchantID || got.Timezone != model.DefaultMerchantTimezone ||
+		got.SlotCapacity != model.DefaultSlotCapacity || got.Hours == nil || len(got.Hours) != 0 {
+		t.Errorf("default schedule = %+v", got)
+	}
+
+	schedule := &model.MerchantSchedule{
+		Timezone:     "Asia/Makassar",
+		SlotCapacity: 3,
+		Hours: []model.OpeningHours{
+			{Weekday: 1, Opens: "08:00", Closes: "21:30"},
+			{Weekday: 6, Opens: "10:00", Closes: "14:00"},
+		},
+	}
+	if err := r.Merchants.SetMerchantScheduleRepo(ctx, user.UserID, schedule); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Merchants.GetMerchantScheduleRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timezone != "Asia/Makassar" || got.SlotCapacity != 3 || !slices.Equal(got.Hours, schedule.Hours) {
+		t.Errorf("schedule = %+v", got)
+	}
+
+	// Setting hours replaces the old ones.
+	schedule.Hours = []model.OpeningHours{{Weekday: 0, Opens: "09:00", Closes: "17:00"}}
+	if err := r.Merchants.SetMerchantScheduleRepo(ctx, user.UserID, schedule); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Merchants.GetMerchantScheduleRepo(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.Hours, schedule.Hours) {
+		t.Errorf("hours = %+v, want %+v", got.Hours, schedule.Hours)
+	}
+
+	wantErr(t, r.Merchants.SetMerchantScheduleRepo(ctx, uuid.New(), schedule), utils.ErrNotFound)
+	newMerchant(t, r, newUser(t, r, "bob"), model.ReviewStatusPending)
+	_, err = r.Merchants.GetMerchantScheduleRepo(ctx, "bob")
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testDriverCreateAndGet(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "dave")
+	driver := newDriver(t, r, user, "B1234XYZ")
+
+	got, err := r.Drivers.GetDriverRepo(ctx, "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DriverID != driver.DriverID || got.Name != driver.Name || got.License != driver.License || got.Area != driver.Area ||
+		got.Username != "dave" || got.Status != model.ReviewStatusApproved {
+		t.Errorf("GetDriverRepo = %+v", got)
+	}
+	id, err := r.Drivers.GetDriverIDRepo(ctx, "dave")
+	if err != nil || id != driver.DriverID {
+		t.Errorf("GetDriverIDRepo = %v, %v", id, err)
+	}
+	app, err := r.Drivers.GetDriverApplicationRepo(ctx, "dave")
+	if err != nil || app.Status != model.ReviewStatusApproved {
+		t.Errorf("GetDriverApplicationRepo = %+v, %v", app, err)
+	}
+
+	_, err = r.Drivers.GetDriverRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Drivers.GetDriverIDRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+	_, err = r.Drivers.GetDriverApplicationRepo(ctx, "nobody")
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testDriverUnique(t *testing.T, r Repos) {
+	ctx := context.Background()
+	user := newUser(t, r, "dave")
+	first := newDriver(t, r, user, "B1234XYZ")
+
+	again := *first
+	again.DriverID = uuid.New()
+	again.License = "B9999XYZ"
+	wantErr(t, r.Drivers.CreateDriverRepo(ctx, &again), utils.ErrUniqueConstraint)
+
+	other := newUser(t, r, "erin")
+	sameLicense := &model.Driver{
+		DriverID: uuid.New(),
+		Name:     "erin",
+		License:  "B1234XYZ",
+		Area:     "Bandung",
+		UserID:   other.UserID,
+		Username: "erin",
+		Status:   model.ReviewStatusApproved,
+	}
+	wantErr(t, r.Drivers.CreateDriverRepo(ctx, sameLicense), utils.ErrUniqueConstraint)
+}
+
+func testDriverUpdate(t *testing.T, r Repos) {
+	ctx := context.Background()
+	dave := newUser(t, r, "dave")
+	newDriver(t, r, dave, "B1234XYZ")
+	newDriver(t, r, newUser(t, r, "erin"), "D5678ABC")
+
+	patch := utils.NewPatch(driverColumns)
+	utils.PatchField(patch, "area", utils.Optional[string]{Set: true, Null: true})
+	utils.PatchField(patch, "license", utils.Some("B1111AAA"))
+	got, err := r.Drivers.UpdateDriverRepo(ctx, dave.UserID, mustPatch(t, patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Area != "" || got.License != "B1111AAA" || got.Username != "dave" {
+		t.Errorf("UpdateDriverRepo = %+v", got)
+	}
+
+	patch = utils.NewPatch(driverColumns)
+	utils.PatchField(patch, "license", utils.Some("D5678ABC"))
+	_, err = r.Drivers.UpdateDriverRepo(ctx, dave.UserID, mustPatch(t, patch))
+	wantErr(t, err, utils.ErrUniqueConstraint)
+
+	stored, err := r.Drivers.GetDriverRepo(ctx, "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.License != "B1111AAA" {
+		t.Errorf("failed update changed license to %q", stored.License)
+	}
+	// A cleared area reads back empty from the public profile too.
+	if stored.Area != "" {
+		t.Errorf("GetDriverRepo area = %q, want empty", stored.Area)
+	}
+
+	patch = utils.NewPatch(driverColumns)
+	utils.PatchField(patch, "name", utils.Some("Nobody"))
+	_, err = r.Drivers.UpdateDriverRepo(ctx, uuid.New(), mustPatch(t, patch))
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testDriverDocuments(t *testing.T, r Repos) {
+	ctx := context.Background()
+	driver := newDriver(t, r, newUser(t, r, "dave"), "B1234XYZ")
+	other := newDriver(t, r, newUser(t, r, "erin"), "D5678ABC")
+
+	base := time.Now().Truncate(time.Second)
+	var docs []model.DriverDocument
+	for i, kind := range []string{model.DocumentKindVehicleRegistration, model.DocumentKindLicense} {
+		doc := model.DriverDocument{
+			DocumentID:  uuid.New(),
+			DriverID:    driver.DriverID,
+			Kind:        kind,
+			FileName:    kind + ".pdf",
+			ContentType: "application/pdf",
+			Size:        int64(100 + i),
+			StorageKey:  "drivers/" + kind,
+			UploadedAt:  base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := r.Drivers.CreateDriverDocumentRepo(ctx, &doc); err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	list, err := r.Drivers.ListDriverDocumentsRepo(ctx, driver.DriverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].DocumentID != docs[0].DocumentID || list[1].DocumentID != docs[1].DocumentID {
+		t.Fatalf("ListDriverDocumentsRepo = %+v", list)
+	}
+
+	got, err := r.Drivers.GetDriverDocumentRepo(ctx, driver.DriverID, docs[1].DocumentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StorageKey != docs[1].StorageKey || got.Size != docs[1].Size || !got.UploadedAt.Equal(docs[1].UploadedAt) {
+		t.Errorf("GetDriverDocumentRepo = %+v", got)
+	}
+
+	// Documents are only visible through the driver that owns them.
+	_, err = r.Drivers.GetDriverDocumentRepo(ctx, other.DriverID, docs[1].DocumentID)
+	wantErr(t, err, utils.ErrNotFound)
+	empty, err := r.Drivers.ListDriverDocumentsRepo(ctx, other.DriverID)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("other driver's documents = %#v, %v", empty, err)
+	}
+}
+
+func testMenu(t *testing.T, r Repos) {
+	ctx := context.Background()
+	owner := newUser(t, r, "alice")
+	_, err := r.Menus.GetMerchantID(ctx, owner.UserID)
+	wantErr(t, err, utils.ErrNotFound)
+
+	merchant := newMerchant(t, r, owner, model.ReviewStatusApproved)
+	merchantID, err := r.Menus.GetMerchantID(ctx, owner.UserID)
+	if err != nil || merchantID != merchant.MerchantID {
+		t.Fatalf("GetMerchantID = %v, %v", merchantID, err)
+	}
+
+	menu := &model.Menu{
+		MenuID:      uuid.New(),
+		Name:        "Nasi Goreng",
+		Price:       25000,
+		Description: "fried rice",
+		Category:    "rice",
+		Stock:       10,
+		MerchantID:  merchantID,
+	}
+	if err := r.Menus.CreateMenuRepo(ctx, menu, owner.UserID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Menus.GetMenuRepo(ctx, menu.MenuID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MenuID != menu.MenuID || got.Name != menu.Name || got.Price != menu.Price || got.Stock != menu.Stock || got.Description != menu.Description {
+		t.Errorf("GetMenuRepo = %+v", got)
+	}
+
+	orphan := *menu
+	orphan.MenuID = uuid.New()
+	orphan.MerchantID = uuid.New()
+	wantErr(t, r.Menus.CreateMenuRepo(ctx, &orphan, owner.UserID), utils.ErrNotFound)
+
+	patch := utils.NewPatch(menuColumns)
+	utils.PatchField(patch, "price", utils.Some[int64](27000))
+	utils.PatchField(patch, "stock", utils.Some(0))
+	utils.PatchField(patch, "description", utils.Optional[string]{Set: true, Null: true})
+	_, err = r.Menus.UpdateMenuRepo(ctx, menu.MenuID, uuid.New(), mustPatch(t, patch))
+	wantErr(t, err, utils.ErrNotFound)
+
+	patch = utils.NewPatch(menuColumns)
+	utils.PatchField(patch, "price", utils.Some[int64](27000))
+	utils.PatchField(patch, "stock", utils.Some(0))
+	utils.PatchField(patch, "description", utils.Optional[string]{Set: true, Null: true})
+	updated, err := r.Menus.UpdateMenuRepo(ctx, menu.MenuID, merchantID, mustPatch(t, patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Price != 27000 || updated.Stock != 0 || updated.Description != "" || updated.Name != menu.Name {
+		t.Errorf("UpdateMenuRepo = %+v", updated)
+	}
+
+	wantErr(t, r.Menus.DeleteMenuRepo(ctx, menu.MenuID, uuid.New()), utils.ErrNotFound)
+	if err := r.Menus.DeleteMenuRepo(ctx, menu.MenuID, merchantID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Menus.GetMenuRepo(ctx, menu.MenuID)
+	wantErr(t, err, utils.ErrNotFound)
+	wantErr(t, r.Menus.DeleteMenuRepo(ctx, menu.MenuID, merchantID), utils.ErrNotFound)
+}
+
+func testLoginAttempts(t *testing.T, r Repos) {
+	ctx := context.Background()
+	scope, subject := model.LoginScopeUsername, "alice"
+	now := time.Now().Truncate(time.Second)
+
+	attempt, err := r.LoginAttempts.GetLoginAttemptRepo(ctx, scope, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != 0 || attempt.LockedUntil != nil || attempt.Scope != scope || attempt.Subject != subject {
+		t.Errorf("unknown subject = %+v, want an empty attempt", attempt)
+	}
+
+	for want := 1; want <= 3; want++ {
+		attempt, err = r.LoginAttempts.RecordLoginFailureRepo(ctx, scope, subject, now, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempt.Failures != want || !attempt.LastFailedAt.Equal(now) {
+			t.Errorf("failure %d recorded as %+v", want, attempt)
+		}
+	}
+
+	// Counters are kept per scope.
+	other, err := r.LoginAttempts.RecordLoginFailureRepo(ctx, model.LoginScopeIP, subject, now, now.Add(-time.Minute))
+	if err != nil || other.Failures != 1 {
+		t.Errorf("other scope = %+v, %v", other, err)
+	}
+
+	// A failure after the window starts the count again.
+	later := now.Add(time.Hour)
+	attempt, err = r.LoginAttempts.RecordLoginFailureRepo(ctx, scope, subject, later, later.Add(-time.Minute))
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("failure after the window = %+v, %v", attempt, err)
+	}
+
+	until := later.Add(15 * time.Minute)
+	if err := r.LoginAttempts.LockLoginRepo(ctx, scope, subject, until); err != nil {
+		t.Fatal(err)
+	}
+	attempt, err = r.LoginAttempts.GetLoginAttemptRepo(ctx, scope, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.LockedUntil == nil || !attempt.LockedUntil.Equal(until) || attempt.Failures != 0 {
+		t.Errorf("locked attempt = %+v", attempt)
+	}
+
+	if err := r.LoginAttempts.ResetLoginAttemptRepo(ctx, scope, subject); err != nil {
+		t.Fatal(err)
+	}
+	attempt, err = r.LoginAttempts.GetLoginAttemptRepo(ctx, scope, subject)
+	if err != nil || attempt.Failures != 0 || attempt.LockedUntil != nil {
+		t.Errorf("reset attempt = %+v, %v", attempt, err)
+	}
+	other, err = r.LoginAttempts.GetLoginAttemptRepo(ctx, model.LoginScopeIP, subject)
+	if err != nil || other.Failures != 1 {
+		t.Errorf("reset cleared another scope: %+v, %v", other, err)
+	}
+}
+
+func testLoginAttemptsPrune(t *testing.T, r Repos) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	old := now.Add(-time.Hour)
+	record := func(subject string, at time.Time) {
+		t.Helper()
+		if _, err := r.LoginAttempts.RecordLoginFailureRepo(ctx, model.LoginScopeIP, subject, at, at.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record("stale", old)
+	record("recent", now)
+	record("locked", old)
+	if err := r.LoginAttempts.LockLoginRepo(ctx, model.LoginScopeIP, "locked", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	record("expired", old)
+	if err := r.LoginAttempts.LockLoginRepo(ctx, model.LoginScopeIP, "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := r.LoginAttempts.PruneLoginAttemptsRepo(ctx, now.Add(-15*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("pruned %d, want the stale and expired counters", n)
+	}
+	for subject, wantKept := range map[string]bool{"stale": false, "recent": true, "locked": true, "expired": false} {
+		attempt, err := r.LoginAttempts.GetLoginAttemptRepo(ctx, model.LoginScopeIP, subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := attempt.Failures > 0 || attempt.LockedUntil != nil; kept != wantKept {
+			t.Errorf("%s after prune = %+v, want kept %v", subject, attempt, wantKept)
+		}
+	}
+}
+
+func testAudit(t *testing.T, r Repos) {
+	ctx := context.Background()
+	actor := newUser(t, r, "admin")
+	for _, entry := range []*model.AuditLog{
+		{AuditID: uuid.New(), ActorID: actor.UserID, Action: "admin.user.suspended", Subject: "user:alice", Detail: "spam", CreatedAt: time.Now()},
+		// System entries such as lockouts have no actor.
+		{AuditID: uuid.New(), Action: "login.lockout", Subject: "ip:203.0.113.7", IP: "203.0.113.7", CreatedAt: time.Now()},
+	} {
+		if err := r.Audit.CreateAuditRepo(ctx, entry); err != nil {
+			t.Errorf("CreateAuditRepo(%s) = %v", entry.Action, err)
+		}
+	}
+}
+
+// newCustomer registers a customer with balance in their wallet.
+func newCustomer(t *testing.T, r Repos, username string, balance int64) *model.User {
+	t.Helper()
+	user := &model.User{
+		UserID:    uuid.New(),
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  "hash-" + username,
+		Role:      "user",
+		CreatedAt: time.Now(),
+		Phone:     "081234567890",
+		Name:      username,
+		Balance:   balance,
+	}
+	if err := r.Users.RegisterUserRepo(context.Background(), user); err != nil {
+		t.Fatalf("register %s: %v", username, err)
+	}
+	return user
+}
+
+func newMenu(t *testing.T, r Repos, merchant *model.Merchant, name string, price int64) *model.Menu {
+	t.Helper()
+	menu := &model.Menu{
+		MenuID:     uuid.New(),
+		Name:       name,
+		Price:      price,
+		Category:   "rice",
+		Stock:      10,
+		MerchantID: merchant.MerchantID,
+	}
+	if err := r.Menus.CreateMenuRepo(context.Background(), menu, merchant.UserID); err != nil {
+		t.Fatalf("create menu %s: %v", name, err)
+	}
+	return menu
+}
+
+// newOrder builds an order for one of each menu, i+1 of the i-th; slot,
+// when set, makes it a pre-order released an hour earlier. A placed order
+// must be answered within ten minutes.
+func newOrder(customer *model.User, merchant *model.Merchant, slot *time.Time, menus ...*model.Menu) *model.Order {
+	now := time.Now().Truncate(time.Millisecond)
+	respondBy := now.Add(10 * time.Minute)
+	order := &model.Order{
+		OrderID:    uuid.New(),
+		CustomerID: customer.UserID,
+		Customer:   customer.Username,
+		MerchantID: merchant.MerchantID,
+		Status:     model.OrderStatusPlaced,
+		PlacedAt:   &now,
+		RespondBy:  &respondBy,
+		CreatedAt:  now,
+	}
+	if slot != nil {
+		releaseAt := slot.Add(-time.Hour)
+		order.Status = model.OrderStatusScheduled
+		order.ScheduledFor = slot
+		order.ReleaseAt = &releaseAt
+		order.PlacedAt = nil
+		order.RespondBy = nil
+	}
+	for i, menu := range menus {
+		order.Items = append(order.Items, model.OrderItem{OrderItemID: uuid.New(), MenuID: menu.MenuID, Quantity: i + 1})
+	}
+	return order
+}
+
+func testOrderCreate(t *testing.T, r Repos) {
+	ctx := context.Background()
+	merchant := newMerchant(t, r, newUser(t, r, "alice"), model.ReviewStatusApproved)
+	rice := newMenu(t, r, merchant, "Nasi Goreng", 25000)
+	tea := newMenu(t, r, merchant, "Es Teh", 5000)
+	customer := newCustomer(t, r, "bob", 40000)
+
+	order := newOrder(customer, merchant, nil, rice, tea)
+	if err := r.Orders.CreateOrderRepo(ctx, order, 5); err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 35000 || order.Merchant != "alice" || order.Items[0].Name != "Nasi Goreng" || order.Items[1].Price != 5000 {
+		t.Errorf("created order = %+v", order)
+	}
+	got, err := r.Orders.GetOrderRepo(ctx, order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Customer != "bob" || got.Merchant != "alice" || got.Status != model.OrderStatusPlaced || got.Total != 35000 ||
+		got.ScheduledFor != nil || got.PlacedAt == nil || !got.RespondBy.Equal(*order.RespondBy) || got.Refunded != 0 || len(got.Items) != 2 ||
+		got.Items[0].MenuID != rice.MenuID || got.Items[1].Quantity != 2 || got.Items[1].Name != "Es Teh" ||
+		got.Items[0].Status != model.ItemStatusAvailable || got.Items[0].Substitute != nil {
+		t.Errorf("GetOrderRepo = %+v", got)
+	}
+
+	// The payment is in the customer's wallet history.
+	export, err := r.Users.ExportUserRepo(ctx, customer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.Balance != 5000 || len(export.Wallet) != 1 || export.Wallet[0].Amount != -35000 ||
+		export.Wallet[0].BalanceAfter != 5000 || export.Wallet[0].Kind != model.WalletKindOrderPayment {
+		t.Errorf("balance %d, wallet %+v after paying 35000", export.Profile.Balance, export.Wallet)
+	}
+
+	// A failed order takes nothing from the wallet.
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, nil, rice), 5), utils.ErrInsufficientFunds)
+	other := newMerchant(t, r, newUser(t, r, "carol"), model.ReviewStatusApproved)
+	foreign := newMenu(t, r, other, "Sate", 1000)
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, nil, foreign), 5), utils.ErrBadRequest)
+	pending := newMerchant(t, r, newUser(t, r, "dave"), model.ReviewStatusPending)
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(customer, pending, nil, tea), 5), utils.ErrNotFound)
+	export, err = r.Users.ExportUserRepo(ctx, customer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.Balance != 5000 || len(export.Wallet) != 1 {
+		t.Errorf("balance %d, wallet %+v after failed orders", export.Profile.Balance, export.Wallet)
+	}
+
+	_, err = r.Orders.GetOrderRepo(ctx, uuid.New())
+	wantErr(t, err, utils.ErrNotFound)
+}
+
+func testOrderSlotCapacity(t *testing.T, r Repos) {
+	ctx := context.Background()
+	merchant := newMerchant(t, r, newUser(t, r, "alice"), model.ReviewStatusApproved)
+	tea := newMenu(t, r, merchant, "Es Teh", 5000)
+	customer := newCustomer(t, r, "bob", 100000)
+	slot := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	next := slot.Add(15 * time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, &slot, tea), 2); err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, &slot, tea), 2), utils.ErrSlotUnavailable)
+	// Orders for now do not take a slot, and other slots are unaffected.
+	if err := r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, nil, tea), 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, &next, tea), 2); err != nil {
+		t.Fatal(err)
+	}
+	// A capacity of zero takes no pre-orders at all.
+	later := next.Add(15 * time.Minute)
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(customer, merchant, &later, tea), 0), utils.ErrSlotUnavailable)
+}
+
+func testOrderRelease(t *testing.T, r Repos) {
+	ctx := context.Background()
+	merchant := newMerchant(t, r, newUser(t, r, "alice"), model.ReviewStatusApproved)
+	tea := newMenu(t, r, merchant, "Es Teh", 5000)
+	customer := newCustomer(t, r, "bob", 100000)
+	now := time.Now().Truncate(time.Millisecond)
+
+	var due []*model.Order
+	for _, lead := range []time.Duration{30 * time.Minute, 45 * time.Minute, 50 * time.Minute} {
+		slot := now.Add(lead)
+		order := newOrder(customer, merchant, &slot, tea)
+		if err := r.Orders.CreateOrderRepo(ctx, order, 5); err != nil {
+			t.Fatal(err)
+		}
+		due = append(due, order)
+	}
+	future := now.Add(2 * time.Hour)
+	waiting := newOrder(customer, merchant, &future, tea)
+	if err := r.Orders.CreateOrderRepo(ctx, waiting, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Batches release the earliest first, and a rerun never releases an
+	// order twice.
+	respondBy := now.Add(10 * time.Minute)
+	n, err := r.Orders.ReleaseScheduledOrdersRepo(ctx, now, respondBy, 2)
+	if err != nil || n != 2 {
+		t.Fatalf("first batch = %d, %v; want 2", n, err)
+	}
+	n, err = r.Orders.ReleaseScheduledOrdersRepo(ctx, now, respondBy, 2)
+	if err != nil || n != 1 {
+		t.Fatalf("second batch = %d, %v; want 1", n, err)
+	}
+	n, err = r.Orders.ReleaseScheduledOrdersRepo(ctx, now, respondBy, 2)
+	if err != nil || n != 0 {
+		t.Fatalf("rerun = %d, %v; want 0", n, err)
+	}
+	for _, order := range due {
+		got, err := r.Orders.GetOrderRepo(ctx, order.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != model.OrderStatusPlaced || got.PlacedAt == nil || !got.PlacedAt.Equal(now) ||
+			got.RespondBy == nil || !got.RespondBy.Equal(respondBy) {
+			t.Errorf("released order = %+v", got)
+		}
+	}
+	got, err := r.Orders.GetOrderRepo(ctx, waiting.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.OrderStatusScheduled || got.PlacedAt != nil {
+		t.Errorf("order due in an hour = %+v, want it still scheduled", got)
+	}
+}
+
+// orderFixture is an approved merchant "alice" selling rice at 25000 and tea
+// at 5000, and a customer "bob" with 100000 in his wallet.
+type orderFixture struct {
+	merchant  *model.Merchant
+	rice, tea *model.Menu
+	customer  *model.User
+}
+
+func newOrderFixture(t *testing.T, r Repos) *orderFixture {
+	t.Helper()
+	f := &orderFixture{merchant: newMerchant(t, r, newUser(t, r, "alice"), model.ReviewStatusApproved)}
+	f.rice = newMenu(t, r, f.merchant, "Nasi Goreng", 25000)
+	f.tea = newMenu(t, r, f.merchant, "Es Teh", 5000)
+	f.customer = newCustomer(t, r, "bob", 100000)
+	return f
+}
+
+// place stores a placed order for one rice and two teas.
+func (f *orderFixture) place(t *testing.T, r Repos) *model.Order {
+	t.Helper()
+	order := newOrder(f.customer, f.merchant, nil, f.rice, f.tea)
+	if err := r.Orders.CreateOrderRepo(context.Background(), order, 5); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func getOrder(t *testing.T, r Repos, orderID uuid.UUID) *model.Order {
+	t.Helper()
+	got, err := r.Orders.GetOrderRepo(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// wallet returns the customer's balance and wallet history.
+func wallet(t *testing.T, r Repos, user *model.User) (int64, []model.WalletTransaction) {
+	t.Helper()
+	export, err := r.Users.ExportUserRepo(context.Background(), user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return export.Profile.Balance, export.Wallet
+}
+
+func testOrderListForMerchant(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	slot := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	first := newOrder(f.customer, f.merchant, nil, f.rice, f.tea)
+	second := newOrder(f.customer, f.merchant, nil, f.rice)
+	scheduled := newOrder(f.customer, f.merchant, &slot, f.tea)
+	for i, order := range []*model.Order{first, second, scheduled} {
+		order.CreatedAt = order.CreatedAt.Add(time.Duration(i) * time.Second)
+		if err := r.Orders.CreateOrderRepo(ctx, order, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Orders.AcceptOrderRepo(ctx, f.merchant.MerchantID, second.OrderID, 15, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	other := newMerchant(t, r, newUser(t, r, "carol"), model.ReviewStatusApproved)
+	if err := r.Orders.CreateOrderRepo(ctx, newOrder(f.customer, other, nil, newMenu(t, r, other, "Sate", 1000)), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(orders []model.Order) []uuid.UUID {
+		res := make([]uuid.UUID, len(orders))
+		for i, o := range orders {
+			res[i] = o.OrderID
+		}
+		return res
+	}
+	tests := []struct {
+		name   string
+		filter model.OrderFilter
+		want   []uuid.UUID
+	}{
+		{"all", model.OrderFilter{Limit: 10}, []uuid.UUID{scheduled.OrderID, second.OrderID, first.OrderID}},
+		{"one status", model.OrderFilter{Statuses: []string{model.OrderStatusPlaced}, Limit: 10}, []uuid.UUID{first.OrderID}},
+		{"two statuses", model.OrderFilter{Statuses: []string{model.OrderStatusPlaced, model.OrderStatusAccepted}, Limit: 10}, []uuid.UUID{second.OrderID, first.OrderID}},
+		{"page", model.OrderFilter{Limit: 1, Offset: 1}, []uuid.UUID{second.OrderID}},
+		{"past the end", model.OrderFilter{Limit: 10, Offset: 5}, []uuid.UUID{}},
+	}
+	for _, tt := range tests {
+		got, err := r.Orders.ListMerchantOrdersRepo(ctx, f.merchant.MerchantID, &tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids(got), tt.want) {
+			t.Errorf("%s: orders = %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+
+	got, err := r.Orders.ListMerchantOrdersRepo(ctx, f.merchant.MerchantID, &model.OrderFilter{Statuses: []string{model.OrderStatusAccepted}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Customer != "bob" || got[0].Merchant != "alice" || got[0].PrepMinutes != 15 ||
+		len(got[0].Items) != 1 || got[0].Items[0].Name != "Nasi Goreng" {
+		t.Errorf("listed order = %+v", got)
+	}
+}
+
+func testOrderAcceptReject(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	merchantID := f.merchant.MerchantID
+	now := time.Now().Truncate(time.Millisecond)
+
+	accepted := f.place(t, r)
+	wantErr(t, r.Orders.AcceptOrderRepo(ctx, uuid.New(), accepted.OrderID, 20, now), utils.ErrNotFound)
+	wantErr(t, r.Orders.AcceptOrderRepo(ctx, merchantID, uuid.New(), 20, now), utils.ErrNotFound)
+	wantErr(t, r.Orders.MarkOrderReadyRepo(ctx, merchantID, accepted.OrderID, now), utils.ErrOrderState)
+	if err := r.Orders.AcceptOrderRepo(ctx, merchantID, accepted.OrderID, 20, now); err != nil {
+		t.Fatal(err)
+	}
+	got := getOrder(t, r, accepted.OrderID)
+	if got.Status != model.OrderStatusAccepted || got.PrepMinutes != 20 || got.AcceptedAt == nil || !got.AcceptedAt.Equal(now) {
+		t.Errorf("accepted order = %+v", got)
+	}
+	wantErr(t, r.Orders.AcceptOrderRepo(ctx, merchantID, accepted.OrderID, 20, now), utils.ErrOrderState)
+	wantErr(t, r.Orders.RejectOrderRepo(ctx, merchantID, accepted.OrderID, "too busy", now), utils.ErrOrderState)
+
+	if err := r.Orders.MarkOrderReadyRepo(ctx, merchantID, accepted.OrderID, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := getOrder(t, r, accepted.OrderID); got.Status != model.OrderStatusReady || got.ReadyAt == nil {
+		t.Errorf("ready order = %+v", got)
+	}
+	wantErr(t, r.Orders.MarkOrderReadyRepo(ctx, merchantID, accepted.OrderID, now), utils.ErrOrderState)
+
+	// Rejecting refunds the whole order, once.
+	rejected := f.place(t, r)
+	wantErr(t, r.Orders.RejectOrderRepo(ctx, uuid.New(), rejected.OrderID, "too busy", now), utils.ErrNotFound)
+	if err := r.Orders.RejectOrderRepo(ctx, merchantID, rejected.OrderID, "too busy", now); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.RejectOrderRepo(ctx, merchantID, rejected.OrderID, "too busy", now), utils.ErrOrderState)
+	got = getOrder(t, r, rejected.OrderID)
+	if got.Status != model.OrderStatusRejected || got.RejectReason != "too busy" || got.RejectedAt == nil || got.Refunded != 35000 {
+		t.Errorf("rejected order = %+v", got)
+	}
+	balance, history := wallet(t, r, f.customer)
+	if balance != 100000-35000 || len(history) != 3 {
+		t.Fatalf("balance %d with history %+v, want only the accepted order paid", balance, history)
+	}
+	refund := history[2]
+	if refund.Kind != model.WalletKindOrderRefund || refund.Amount != 35000 || refund.BalanceAfter != balance {
+		t.Errorf("refund = %+v", refund)
+	}
+}
+
+func testOrderItemUnavailable(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	merchantID := f.merchant.MerchantID
+	now := time.Now()
+	cheap := newMenu(t, r, f.merchant, "Nasi Putih", 20000)
+	dear := newMenu(t, r, f.merchant, "Nasi Goreng Spesial", 40000)
+	foreign := newMenu(t, r, newMerchant(t, r, newUser(t, r, "carol"), model.ReviewStatusApproved), "Sate", 1000)
+
+	order := f.place(t, r)
+	if err := r.Orders.AcceptOrderRepo(ctx, merchantID, order.OrderID, 20, now); err != nil {
+		t.Fatal(err)
+	}
+	rice, tea := order.Items[0].OrderItemID, order.Items[1].OrderItemID
+
+	wantErr(t, r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, uuid.New(), nil, now), utils.ErrNotFound)
+	wantErr(t, r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, rice, &foreign.MenuID, now), utils.ErrBadRequest)
+	wantErr(t, r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, rice, &f.rice.MenuID, now), utils.ErrBadRequest)
+
+	// A cheaper substitute refunds the difference once accepted.
+	if err := r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, rice, &cheap.MenuID, now); err != nil {
+		t.Fatal(err)
+	}
+	got := getOrder(t, r, order.OrderID)
+	if item := got.Items[0]; item.Status != model.ItemStatusSubstitutionOffered || item.Substitute == nil ||
+		item.Substitute.MenuID != cheap.MenuID || item.Substitute.Name != "Nasi Putih" || item.Substitute.Price != 20000 {
+		t.Errorf("item with a substitute offered = %+v", item)
+	}
+	wantErr(t, r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, rice, nil, now), utils.ErrOrderState)
+	wantErr(t, r.Orders.MarkOrderReadyRepo(ctx, merchantID, order.OrderID, now), utils.ErrOrderState)
+	wantErr(t, r.Orders.ResolveSubstitutionRepo(ctx, uuid.New(), order.OrderID, rice, true, now), utils.ErrNotFound)
+	wantErr(t, r.Orders.ResolveSubstitutionRepo(ctx, f.customer.UserID, order.OrderID, tea, true, now), utils.ErrOrderState)
+	if err := r.Orders.ResolveSubstitutionRepo(ctx, f.customer.UserID, order.OrderID, rice, true, now); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.ResolveSubstitutionRepo(ctx, f.customer.UserID, order.OrderID, rice, true, now), utils.ErrOrderState)
+	got = getOrder(t, r, order.OrderID)
+	if got.Items[0].Status != model.ItemStatusSubstituted || got.Refunded != 5000 {
+		t.Errorf("after accepting a cheaper substitute = %+v", got)
+	}
+
+	// Two teas, offered a dearer substitute and declined: refunded in full.
+	if err := r.Orders.MarkItemUnavailableRepo(ctx, merchantID, order.OrderID, tea, &dear.MenuID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.ResolveSubstitutionRepo(ctx, f.customer.UserID, order.OrderID, tea, false, now); err != nil {
+		t.Fatal(err)
+	}
+	got = getOrder(t, r, order.OrderID)
+	if got.Items[1].Status != model.ItemStatusRefunded || got.Refunded != 15000 || got.Status != model.OrderStatusAccepted {
+		t.Errorf("after declining a substitute = %+v", got)
+	}
+	if err := r.Orders.MarkOrderReadyRepo(ctx, merchantID, order.OrderID, now); err != nil {
+		t.Fatal(err)
+	}
+	balance, history := wallet(t, r, f.customer)
+	if balance != 100000-35000+15000 || len(history) != 3 || history[1].Amount != 5000 || history[2].Amount != 10000 {
+		t.Errorf("balance %d with history %+v", balance, history)
+	}
+
+	// Refunding every item rejects the order.
+	second := f.place(t, r)
+	for _, item := range second.Items {
+		if err := r.Orders.MarkItemUnavailableRepo(ctx, merchantID, second.OrderID, item.OrderItemID, nil, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got = getOrder(t, r, second.OrderID)
+	if got.Status != model.OrderStatusRejected || got.RejectReason != model.RejectReasonNothingLeft || got.Refunded != 35000 {
+		t.Errorf("order with every item refunded = %+v", got)
+	}
+	balance, _ = wallet(t, r, f.customer)
+	if balance != 100000-35000+15000 {
+		t.Errorf("balance = %d after a fully refunded order", balance)
+	}
+}
+
+func testOrderAutoReject(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	now := time.Now().Truncate(time.Millisecond)
+
+	overdue := f.place(t, r)
+	answered := f.place(t, r)
+	if err := r.Orders.AcceptOrderRepo(ctx, f.merchant.MerchantID, answered.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	waiting := newOrder(f.customer, f.merchant, nil, f.tea)
+	later := now.Add(time.Hour)
+	waiting.RespondBy = &later
+	if err := r.Orders.CreateOrderRepo(ctx, waiting, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := overdue.RespondBy.Add(time.Second)
+	n, err := r.Orders.AutoRejectOrdersRepo(ctx, deadline, model.RejectReasonNoResponse, 10)
+	if err != nil || n != 1 {
+		t.Fatalf("AutoRejectOrdersRepo = %d, %v; want 1", n, err)
+	}
+	if n, err := r.Orders.AutoRejectOrdersRepo(ctx, deadline, model.RejectReasonNoResponse, 10); err != nil || n != 0 {
+		t.Errorf("rerun = %d, %v; want 0", n, err)
+	}
+	got := getOrder(t, r, overdue.OrderID)
+	if got.Status != model.OrderStatusRejected || got.RejectReason != model.RejectReasonNoResponse || got.Refunded != got.Total {
+		t.Errorf("overdue order = %+v", got)
+	}
+	if got := getOrder(t, r, answered.OrderID); got.Status != model.OrderStatusAccepted {
+		t.Errorf("accepted order = %+v", got)
+	}
+	if got := getOrder(t, r, waiting.OrderID); got.Status != model.OrderStatusPlaced {
+		t.Errorf("order with time left = %+v", got)
+	}
+	balance, _ := wallet(t, r, f.customer)
+	if balance != 100000-35000-5000 {
+		t.Errorf("balance = %d after the overdue order was refunded", balance)
+	}
+}
+
+func testUserExportOrders(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	now := time.Now()
+	cheap := newMenu(t, r, f.merchant, "Nasi Putih", 20000)
+
+	// One order gets a cheaper substitute, the next is rejected; another
+	// customer's order stays out of the export.
+	substituted := newOrder(f.customer, f.merchant, nil, f.rice, f.tea)
+	rejected := newOrder(f.customer, f.merchant, nil, f.tea)
+	rejected.CreatedAt = substituted.CreatedAt.Add(time.Second)
+	for _, order := range []*model.Order{substituted, rejected} {
+		if err := r.Orders.CreateOrderRepo(ctx, order, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Orders.CreateOrderRepo(ctx, newOrder(newCustomer(t, r, "carol", 50000), f.merchant, nil, f.tea), 5); err != nil {
+		t.Fatal(err)
+	}
+	rice := substituted.Items[0].OrderItemID
+	if err := r.Orders.MarkItemUnavailableRepo(ctx, f.merchant.MerchantID, substituted.OrderID, rice, &cheap.MenuID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.ResolveSubstitutionRepo(ctx, f.customer.UserID, substituted.OrderID, rice, true, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.RejectOrderRepo(ctx, f.merchant.MerchantID, rejected.OrderID, "too busy", now); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := r.Users.ExportUserRepo(ctx, f.customer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Orders) != 2 || export.Orders[0].OrderID != substituted.OrderID || export.Orders[1].OrderID != rejected.OrderID {
+		t.Fatalf("orders = %+v, want the customer's two orders, oldest first", export.Orders)
+	}
+	got := export.Orders[0]
+	if got.Merchant != "alice" || got.Total != 35000 || got.Refunded != 5000 || len(got.Items) != 2 ||
+		got.Items[0].Status != model.ItemStatusSubstituted || got.Items[0].Substitute == nil ||
+		got.Items[0].Substitute.Name != "Nasi Putih" || got.Items[1].Status != model.ItemStatusAvailable {
+		t.Errorf("substituted order = %+v", got)
+	}
+	got = export.Orders[1]
+	if got.Status != model.OrderStatusRejected || got.RejectReason != "too busy" || got.Refunded != got.Total || len(got.Items) != 1 {
+		t.Errorf("rejected order = %+v", got)
+	}
+}
+
+func testOrderCancel(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	merchantID := f.merchant.MerchantID
+	driverUser := newUser(t, r, "dave")
+	driver := newDriver(t, r, driverUser, "B1234XYZ")
+	now := time.Now().Truncate(time.Millisecond)
+	byCustomer := func(order *model.Order) *model.OrderCancellation {
+		return &model.OrderCancellation{OrderID: order.OrderID, By: model.CancelledByCustomer, ActorID: f.customer.UserID, Fee: 5000, At: now}
+	}
+
+	// A customer cancels a placed order for free, once.
+	placed := f.place(t, r)
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: placed.OrderID, By: model.CancelledByCustomer, ActorID: uuid.New(), At: now}), utils.ErrNotFound)
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: uuid.New(), By: model.CancelledByCustomer, ActorID: f.customer.UserID, At: now}), utils.ErrNotFound)
+	merchantCancel := &model.OrderCancellation{OrderID: placed.OrderID, By: model.CancelledByMerchant, ActorID: f.merchant.UserID, Reason: "kitchen_problem", At: now}
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, merchantCancel), utils.ErrOrderState)
+	if err := r.Orders.CancelOrderRepo(ctx, byCustomer(placed)); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, byCustomer(placed)), utils.ErrOrderState)
+	wantErr(t, r.Orders.AcceptOrderRepo(ctx, merchantID, placed.OrderID, 10, now), utils.ErrOrderState)
+	got := getOrder(t, r, placed.OrderID)
+	if got.Status != model.OrderStatusCancelled || got.CancelledBy != model.CancelledByCustomer || got.CancelledAt == nil ||
+		!got.CancelledAt.Equal(now) || got.CancelFee != 0 || got.Refunded != 35000 || got.CancelReason != "" {
+		t.Errorf("order cancelled while placed = %+v", got)
+	}
+
+	// A scheduled order gives its slot back.
+	slot := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	scheduled := newOrder(f.customer, f.merchant, &slot, f.tea)
+	if err := r.Orders.CreateOrderRepo(ctx, scheduled, 1); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.CreateOrderRepo(ctx, newOrder(f.customer, f.merchant, &slot, f.tea), 1), utils.ErrSlotUnavailable)
+	if err := r.Orders.CancelOrderRepo(ctx, byCustomer(scheduled)); err != nil {
+		t.Fatal(err)
+	}
+	if got := getOrder(t, r, scheduled.OrderID); got.CancelFee != 0 || got.Refunded != 5000 {
+		t.Errorf("order cancelled while scheduled = %+v", got)
+	}
+	if err := r.Orders.CreateOrderRepo(ctx, newOrder(f.customer, f.merchant, &slot, f.tea), 1); err != nil {
+		t.Errorf("slot after a cancellation: %v", err)
+	}
+
+	// The merchant cancels an accepted order with a reason, refunding it all.
+	accepted := f.place(t, r)
+	if err := r.Orders.AcceptOrderRepo(ctx, merchantID, accepted.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	merchantCancel.OrderID = accepted.OrderID
+	merchantCancel.Reason, merchantCancel.Note = "other", "gas ran out"
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: accepted.OrderID, By: model.CancelledByMerchant, ActorID: f.customer.UserID, At: now}), utils.ErrNotFound)
+	if err := r.Orders.CancelOrderRepo(ctx, merchantCancel); err != nil {
+		t.Fatal(err)
+	}
+	got = getOrder(t, r, accepted.OrderID)
+	if got.Status != model.OrderStatusCancelled || got.CancelledBy != model.CancelledByMerchant || got.CancelReason != "other" ||
+		got.CancelNote != "gas ran out" || got.CancelFee != 0 || got.Refunded != 35000 {
+		t.Errorf("order cancelled by its merchant = %+v", got)
+	}
+
+	// Only the driver assigned to an order can cancel it, once accepted.
+	delivered := newOrder(f.customer, f.merchant, nil, f.rice)
+	delivered.DriverID = &driver.DriverID
+	if err := r.Orders.CreateOrderRepo(ctx, delivered, 5); err != nil {
+		t.Fatal(err)
+	}
+	driverCancel := &model.OrderCancellation{OrderID: delivered.OrderID, By: model.CancelledByDriver, ActorID: driverUser.UserID, Reason: "accident", At: now}
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, driverCancel), utils.ErrOrderState)
+	if err := r.Orders.AcceptOrderRepo(ctx, merchantID, delivered.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	unassigned := f.place(t, r)
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: unassigned.OrderID, By: model.CancelledByDriver, ActorID: driverUser.UserID, At: now}), utils.ErrNotFound)
+	if err := r.Orders.CancelOrderRepo(ctx, driverCancel); err != nil {
+		t.Fatal(err)
+	}
+	if got := getOrder(t, r, delivered.OrderID); got.CancelledBy != model.CancelledByDriver || got.CancelReason != "accident" || got.Refunded != 25000 {
+		t.Errorf("order cancelled by its driver = %+v", got)
+	}
+
+	// Rejected orders are over already.
+	if err := r.Orders.RejectOrderRepo(ctx, merchantID, unassigned.OrderID, "too busy", now); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.CancelOrderRepo(ctx, byCustomer(unassigned)), utils.ErrOrderState)
+
+	balance, history := wallet(t, r, f.customer)
+	if balance != 100000-5000 {
+		t.Errorf("balance = %d, want only the open pre-order paid", balance)
+	}
+	var refunds int64
+	for _, tx := range history {
+		if tx.Kind == model.WalletKindOrderRefund {
+			refunds += tx.Amount
+		}
+	}
+	if refunds != 35000+5000+35000+25000+35000 {
+		t.Errorf("refunds = %d in history %+v", refunds, history)
+	}
+}
+
+func testOrderCancelFee(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	now := time.Now()
+
+	// After the merchant accepts, the fee is kept back from the refund...
+	order := f.place(t, r)
+	if err := r.Orders.AcceptOrderRepo(ctx, f.merchant.MerchantID, order.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: order.OrderID, By: model.CancelledByCustomer, ActorID: f.customer.UserID, Fee: 5000, At: now}); err != nil {
+		t.Fatal(err)
+	}
+	got := getOrder(t, r, order.OrderID)
+	if got.Status != model.OrderStatusCancelled || got.CancelFee != 5000 || got.Refunded != 30000 {
+		t.Errorf("order cancelled after acceptance = %+v", got)
+	}
+
+	// ...but never more than is left of the order, ready or not.
+	small := newOrder(f.customer, f.merchant, nil, f.tea)
+	if err := r.Orders.CreateOrderRepo(ctx, small, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.AcceptOrderRepo(ctx, f.merchant.MerchantID, small.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.MarkOrderReadyRepo(ctx, f.merchant.MerchantID, small.OrderID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.CancelOrderRepo(ctx, &model.OrderCancellation{OrderID: small.OrderID, By: model.CancelledByCustomer, ActorID: f.customer.UserID, Fee: 8000, At: now}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getOrder(t, r, small.OrderID); got.CancelFee != 5000 || got.Refunded != 0 {
+		t.Errorf("order cancelled for more than its total = %+v", got)
+	}
+
+	balance, history := wallet(t, r, f.customer)
+	if balance != 100000-5000-5000 || len(history) != 3 {
+		t.Errorf("balance %d with history %+v, want one refund of 30000", balance, history)
+	}
+}
+
+func testOrderDisputes(t *testing.T, r Repos) {
+	ctx := context.Background()
+	f := newOrderFixture(t, r)
+	admin := newUser(t, r, "root")
+	now := time.Now().Truncate(time.Millisecond)
+
+	order := f.place(t, r)
+	rice, tea := order.Items[0].OrderItemID, order.Items[1].OrderItemID
+	dispute := func(items ...uuid.UUID) *model.Dispute {
+		return &model.Dispute{DisputeID: uuid.New(), OrderID: order.OrderID, Kind: model.DisputeKindMissingItem,
+			OrderItemIDs: items, Description: "no tea in the bag", Status: model.DisputeStatusOpen, CreatedAt: now}
+	}
+	wantErr(t, r.Orders.CreateDisputeRepo(ctx, f.customer.UserID, dispute(tea)), utils.ErrOrderState)
+	if err := r.Orders.AcceptOrderRepo(ctx, f.merchant.MerchantID, order.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Orders.MarkOrderReadyRepo(ctx, f.merchant.MerchantID, order.OrderID, now); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.CreateDisputeRepo(ctx, uuid.New(), dispute(tea)), utils.ErrNotFound)
+	wantErr(t, r.Orders.CreateDisputeRepo(ctx, f.customer.UserID, dispute(tea, uuid.New())), utils.ErrBadRequest)
+	first := dispute(tea)
+	if err := r.Orders.CreateDisputeRepo(ctx, f.customer.UserID, first); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.CreateDisputeRepo(ctx, f.customer.UserID, dispute(rice)), utils.ErrOrderState)
+
+	got, err := r.Orders.GetDisputeRepo(ctx, first.DisputeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Customer != "bob" || got.CustomerID != f.customer.UserID || got.Status != model.DisputeStatusOpen ||
+		!slices.Equal(got.OrderItemIDs, []uuid.UUID{tea}) || got.Refund != 0 || got.ResolvedAt != nil {
+		t.Errorf("GetDisputeRepo = %+v", got)
+	}
+	_, err = r.Orders.GetDisputeRepo(ctx, uuid.New())
+	wantErr(t, err, utils.ErrNotFound)
+
+	// The refund is capped by what the order has left to refund.
+	audit := func() *model.AuditLog {
+		return &model.AuditLog{AuditID: uuid.New(), ActorID: admin.UserID, Action: "admin.dispute.resolve", Subject: "dispute:" + first.DisputeID.String(), CreatedAt: now}
+	}
+	resolution := &model.DisputeResolution{DisputeID: first.DisputeID, Refund: 35001, Resolution: "too much", AdminID: admin.UserID, At: now}
+	wantErr(t, r.Orders.ResolveDisputeRepo(ctx, resolution, audit()), utils.ErrBadRequest)
+	wantErr(t, r.Orders.ResolveDisputeRepo(ctx, &model.DisputeResolution{DisputeID: uuid.New(), AdminID: admin.UserID, At: now}, audit()), utils.ErrNotFound)
+	resolution.Refund, resolution.Resolution = 10000, "two teas refunded"
+	if err := r.Orders.ResolveDisputeRepo(ctx, resolution, audit()); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.Orders.ResolveDisputeRepo(ctx, resolution, audit()), utils.ErrOrderState)
+	got, err = r.Orders.GetDisputeRepo(ctx, first.DisputeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.DisputeStatusResolved || got.Refund != 10000 || got.Resolution != "two teas refunded" ||
+		got.ResolvedAt == nil || !got.ResolvedAt.Equal(now) {
+		t.Errorf("resolved dispute = %+v", got)
+	}
+	if got := getOrder(t, r, order.OrderID); got.Refunded != 10000 || got.Status != model.OrderStatusReady {
+		t.Errorf("order after the dispute = %+v", got)
+	}
+	balance, history := wallet(t, r, f.customer)
+	if last := history[len(history)-1]; balance != 100000-35000+10000 || last.Kind != model.WalletKindOrderRefund || last.Amount != 10000 {
+		t.Errorf("balance %d with history %+v", balance, history)
+	}
+
+	// Once resolved, the order can be disputed again, up to what is left.
+	second := dispute(rice)
+	second.Kind = model.DisputeKindWrongItem
+	second.CreatedAt = now.Add(time.Second)
+	if err := r.Orders.CreateDisputeRepo(ctx, f.customer.UserID, second); err != nil {
+		t.Fatal(err)
+	}
+	over := &model.DisputeResolution{DisputeID: second.DisputeID, Refund: 25001, Resolution: "too much", AdminID: admin.UserID, At: now}
+	wantErr(t, r.Orders.ResolveDisputeRepo(ctx, over, audit()), utils.ErrBadRequest)
+
+	ids := func(disputes []model.Dispute) []uuid.UUID {
+		res := make([]uuid.UUID, len(disputes))
+		for i, d := range disputes {
+			res[i] = d.DisputeID
+		}
+		return res
+	}
+	tests := []struct {
+		name   string
+		filter model.DisputeFilter
+		want   []uuid.UUID
+	}{
+		{"all", model.DisputeFilter{Limit: 10}, []uuid.UUID{first.DisputeID, second.DisputeID}},
+		{"open", model.DisputeFilter{Status: model.DisputeStatusOpen, Limit: 10}, []uuid.UUID{second.DisputeID}},
+		{"resolved", model.DisputeFilter{Status: model.DisputeStatusResolved, Limit: 10}, []uuid.UUID{first.DisputeID}},
+		{"page", model.DisputeFilter{Limit: 1, Offset: 1}, []uuid.UUID{second.DisputeID}},
+	}
+	for _, tt := range tests {
+		got, err := r.Orders.ListDisputesRepo(ctx, &tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids(got), tt.want) {
+			t.Errorf("%s: disputes = %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+}
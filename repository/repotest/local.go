@@ -0,0 +1,136 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Main runs the tests in m. When TEST_DATABASE_URL is unset and the Postgres
+// server binaries can be found, through PG_BIN, PATH or the usual install
+// directories, it starts a throwaway cluster for the run and points
+// TEST_DATABASE_URL at it. Otherwise Postgres tests skip as usual.
+func Main(m *testing.M) {
+	stop := func() {}
+	if os.Getenv(DatabaseEnv) == "" {
+		url, stopLocal, err := startLocal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "repotest: no local Postgres: %v\n", err)
+		} else {
+			os.Setenv(DatabaseEnv, url)
+			stop = stopLocal
+		}
+	}
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+func findPostgresBin() (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		return dir, nil
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin", "/usr/local/pgsql/bin", "/opt/homebrew/opt/postgresql*/bin"} {
+		matches, _ := filepath.Glob(pattern)
+		for i := len(matches) - 1; i >= 0; i-- {
+			if _, err := os.Stat(filepath.Join(matches[i], "initdb")); err == nil {
+				return matches[i], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("initdb not found; set %s or PG_BIN", DatabaseEnv)
+}
+
+// startLocal initialises a cluster in a temporary directory and runs it on a
+// free loopback port, tuned for speed rather than durability.
+func startLocal() (string, func(), error) {
+	if os.Geteuid() == 0 {
+		return "", nil, fmt.Errorf("postgres refuses to run as root")
+	}
+	bin, err := findPostgresBin()
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "gofood-pg-")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb: %v: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	server := exec.Command(filepath.Join(bin, "postgres"),
+		"-D", data,
+		"-p", strconv.Itoa(port),
+		"-k", dir,
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+	)
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	server.Stdout = logFile
+	server.Stderr = logFile
+	if err := server.Start(); err != nil {
+		logFile.Close()
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("start postgres: %w", err)
+	}
+	stop := func() {
+		// SIGINT asks for a fast shutdown that disconnects clients.
+		server.Process.Signal(syscall.SIGINT)
+		server.Wait()
+		logFile.Close()
+		os.RemoveAll(dir)
+	}
+
+	url := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn, err := pgx.Connect(ctx, url)
+		cancel()
+		if err == nil {
+			conn.Close(context.Background())
+			return url, stop, nil
+		}
+		if time.Now().After(deadline) {
+			stop()
+			return "", nil, fmt.Errorf("postgres did not accept connections: %w", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type TwoFactorRepoImpl interface {
+	GetTOTPRepo(ctx context.Context, userID uuid.UUID) (*model.TOTP, error)
+	SetTOTPSecretRepo(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTPRepo(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	DisableTOTPRepo(ctx context.Context, userID uuid.UUID) error
+	UseTOTPStepRepo(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCodeRepo(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+}
+type TwoFactorRepo struct {
+	db  *pgxpool.Pool
+	zap *zap.Logger
+}
+
+func NewTwoFactorRepo(db *pgxpool.Pool, zap *zap.Logger) *TwoFactorRepo {
+	return &TwoFactorRepo{
+		db:  db,
+		zap: zap,
+	}
+}
+
+func (tr *TwoFactorRepo) GetTOTPRepo(ctx context.Context, userID uuid.UUID) (*model.TOTP, error) {
+	res := model.TOTP{UserID: userID}
+	var secret *string
+	err := tr.db.QueryRow(ctx, `
+    SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE user_id = $1
+    `, userID).Scan(&secret, &res.Enabled, &res.LastStep)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, tr.zap).Warn(utils.ErrNotFound.Error(), zap.String("user_id", userID.String()))
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch totp: %w", utils.ErrDatabase)
+	}
+	if secret != nil {
+		res.Secret = *secret
+	}
+	return &res, nil
+}
+
+func (tr *TwoFactorRepo) SetTOTPSecretRepo(ctx context.Context, userID uuid.UUID, secret string) error {
+	_, err := tr.db.Exec(ctx, `
+    UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = NULL
+    WHERE user_id = $1
+    `, userID, secret)
+	if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to store totp secret: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+// EnableTOTPRepo turns on TOTP and replaces the user's recovery codes in one transaction.
+func (tr *TwoFactorRepo) EnableTOTPRepo(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to enable totp: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET totp_enabled = TRUE WHERE user_id = $1`, userID); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to enable totp: %w", utils.ErrDatabase)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to reset recovery codes: %w", utils.ErrDatabase)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `
+      INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)
+      `, userID, hash); err != nil {
+			utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return fmt.Errorf("failed to store recovery codes: %w", utils.ErrDatabase)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to enable totp: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+func (tr *TwoFactorRepo) DisableTOTPRepo(ctx context.Context, userID uuid.UUID) error {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to disable totp: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+    UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL
+    WHERE user_id = $1
+    `, userID); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to disable totp: %w", utils.ErrDatabase)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to delete recovery codes: %w", utils.ErrDatabase)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return fmt.Errorf("failed to disable totp: %w", utils.ErrDatabase)
+	}
+	return nil
+}
+
+// UseTOTPStepRepo records step as consumed, returning false if it or a later
+// step was already used so a code cannot be replayed.
+func (tr *TwoFactorRepo) UseTOTPStepRepo(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	tag, err := tr.db.Exec(ctx, `
+    UPDATE users SET totp_last_step = $2
+    WHERE user_id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+    `, userID, step)
+	if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return false, fmt.Errorf("failed to record totp step: %w", utils.ErrDatabase)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (tr *TwoFactorRepo) UseRecoveryCodeRepo(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	tag, err := tr.db.Exec(ctx, `
+    UPDATE recovery_codes SET used_at = $3
+    WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+    `, userID, codeHash, time.Now())
+	if err != nil {
+		utils.Logger(ctx, tr.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return false, fmt.Errorf("failed to use recovery code: %w", utils.ErrDatabase)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/utils"
@@ -15,7 +17,11 @@ import (
 type UserRepoImpl interface {
 	RegisterUserRepo(ctx context.Context, new *model.User) error
 	LoginRepo(ctx context.Context, username string) (*model.User, error)
-	GetUserRepo(ctx context.Context, username string) (*model.UserResp, error)
+	GetUserRepo(ctx context.Context, username string) (*model.User, error)
+	UpdateUserRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.User, error)
+	DeleteUserRepo(ctx context.Context, userID uuid.UUID) ([]string, error)
+	ExportUserRepo(ctx context.Context, userID uuid.UUID) (*model.UserExport, error)
+	GetAccountStatusRepo(ctx context.Context, userID uuid.UUID) (string, error)
 }
 type UserRepo struct {
 	db  *pgxpool.Pool
@@ -36,63 +42,278 @@ func (ur *UserRepo) RegisterUserRepo(ctx context.Context, new *model.User) error
     `, new.Username, new.Email).
 		Scan(&exists)
 	if err != nil {
-		ur.zap.Error(utils.ErrDatabase.Error(), zap.String("failed to register", new.Username), zap.Error(err))
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.String("failed to register", new.Username), zap.Error(err))
 		return fmt.Errorf("failed to register user: %w", utils.ErrDatabase)
 	}
 	if exists {
-		ur.zap.Warn(utils.ErrUniqueConstraint.Error(), zap.String("username", new.Username))
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.String("username", new.Username))
 		return fmt.Errorf("username or email already exist: %w", utils.ErrUniqueConstraint)
 	}
 	_, err = ur.db.Exec(ctx, `
     INSERT INTO users (user_id, username, email, password, role, created_at, phone, balance, name)
     VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     `, new.UserID, new.Username, new.Email, new.Password, new.Role, new.CreatedAt, new.Phone, new.Balance, new.Name)
-	if err != nil {
-		ur.zap.Error(utils.ErrDatabase.Error(), zap.String("failed to register", new.Username), zap.Error(err))
+	if isUniqueViolation(err) {
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrUniqueConstraint.Error(), zap.String("username", new.Username))
+		return fmt.Errorf("username or email already exist: %w", utils.ErrUniqueConstraint)
+	} else if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.String("failed to register", new.Username), zap.Error(err))
 		return fmt.Errorf("failed to create user: %w", utils.ErrDatabase)
 	}
 	return nil
 }
 
-func (ur *UserRepo) GetUserRepo(ctx context.Context, username string) (*model.UserResp, error) {
-	var userID uuid.UUID
-	var res model.UserResp
+func (ur *UserRepo) GetUserRepo(ctx context.Context, username string) (*model.User, error) {
+	var res model.User
 	err := ur.db.QueryRow(ctx, `
-    SELECT user_id FROM users WHERE username = $1
-    `, username).Scan(&userID)
+    SELECT user_id, username, email, role, created_at, COALESCE(phone, ''), name FROM users
+    WHERE username = $1 AND deleted_at IS NULL
+    `, username).Scan(&res.UserID, &res.Username, &res.Email, &res.Role, &res.CreatedAt, &res.Phone, &res.Name)
 	if err == pgx.ErrNoRows {
-		ur.zap.Warn(utils.ErrNotFound.Error(), zap.String("username", username))
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrNotFound.Error(), zap.String("username", username))
 		return nil, fmt.Errorf("no row found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		ur.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch user: %w", utils.ErrDatabase)
 	}
+	return &res, nil
+}
 
-	err = ur.db.QueryRow(ctx, `
-    SELECT username, email, role, created_at, phone, name FROM users WHERE user_id = $1
-    `, &userID).Scan(&res.Username, &res.Email, &res.Role, &res.CreatedAt, &res.Phone)
+func (ur *UserRepo) LoginRepo(ctx context.Context, username string) (*model.User, error) {
+	var res model.User
+	err := ur.db.QueryRow(ctx, `
+    SELECT user_id, username, password, role, totp_enabled, status FROM users
+    WHERE username = $1 AND deleted_at IS NULL
+    `, username).Scan(&res.UserID, &res.Username, &res.Password, &res.Role, &res.TOTPEnabled, &res.Status)
 	if err == pgx.ErrNoRows {
-		ur.zap.Warn(utils.ErrNotFound.Error(), zap.String("user_id", userID.String()))
-		return nil, fmt.Errorf("no row found: %w", utils.ErrNotFound)
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
+		return nil, fmt.Errorf("no username found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		ur.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch user: %w", utils.ErrDatabase)
 	}
-
 	return &res, nil
 }
 
-func (ur *UserRepo) LoginRepo(ctx context.Context, username string) (*model.User, error) {
+func (ur *UserRepo) UpdateUserRepo(ctx context.Context, userID uuid.UUID, patch *utils.Patch) (*model.User, error) {
+	query, args := patch.Where("user_id", userID).WhereNull("deleted_at").SQL("users", `
+    user_id, username, email, role, created_at, COALESCE(phone, ''), name
+    `)
 	var res model.User
+	err := ur.db.QueryRow(ctx, query, args...).
+		Scan(&res.UserID, &res.Username, &res.Email, &res.Role, &res.CreatedAt, &res.Phone, &res.Name)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrNotFound.Error(), zap.String("UserID", userID.String()))
+		return nil, fmt.Errorf("no user updated: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to update user: %w", utils.ErrDatabase)
+	}
+	return &res, nil
+}
+
+// DeleteUserRepo soft deletes the user and scrubs personal data from the user
+// and any merchant or driver profile, which also leave public listings. Rows
+// are kept so that records pointing at them survive, which a hard delete
+// would cascade away. Driver documents are deleted outright; their storage
+// keys are returned so the caller can remove the files once this commits.
+func (ur *UserRepo) DeleteUserRepo(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	anonymous := "deleted_" + strings.ReplaceAll(userID.String(), "-", "")[:12]
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to delete user: %w", utils.ErrDatabase)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+    UPDATE users SET username = $2, email = $3, password = NULL, phone = NULL,
+      name = 'Deleted User', totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL,
+      deleted_at = $4
+    WHERE user_id = $1 AND deleted_at IS NULL
+    `, userID, anonymous, userID.String()+"@deleted.invalid", time.Now())
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to delete user: %w", utils.ErrDatabase)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	}
+	queries := []string{
+		`UPDATE merchants SET owner = $2, status = 'deleted' WHERE user_id = $1`,
+		`UPDATE drivers SET username = $2, name = 'Deleted Driver', license = NULL, status = 'deleted' WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query, userID, anonymous); err != nil {
+			utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to anonymise user: %w", utils.ErrDatabase)
+		}
+	}
+	rows, err := tx.Query(ctx, `
+    DELETE FROM driver_documents
+    WHERE driver_id IN (SELECT driver_id FROM drivers WHERE user_id = $1)
+    RETURNING storage_key
+    `, userID)
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to delete driver documents: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to delete driver documents: %w", utils.ErrDatabase)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to delete driver documents: %w", utils.ErrDatabase)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to delete user: %w", utils.ErrDatabase)
+	}
+	return keys, nil
+}
+
+func (ur *UserRepo) ExportUserRepo(ctx context.Context, userID uuid.UUID) (*model.UserExport, error) {
+	res := model.UserExport{ExportedAt: time.Now()}
+	p := &res.Profile
 	err := ur.db.QueryRow(ctx, `
-    SELECT user_id, username, password, role FROM users WHERE username = $1
-    `, username).Scan(&res.UserID, &res.Username, &res.Password, &res.Role)
+    SELECT user_id, username, email, role, created_at, COALESCE(phone, ''), name,
+      COALESCE(balance, 0), totp_enabled
+    FROM users WHERE user_id = $1 AND deleted_at IS NULL
+    `, userID).Scan(&p.UserID, &p.Username, &p.Email, &p.Role, &p.CreatedAt, &p.Phone, &p.Name,
+		&p.Balance, &p.TOTPEnabled)
 	if err == pgx.ErrNoRows {
-		ur.zap.Warn(utils.ErrNotFound.Error(), zap.String("Username", username))
-		return nil, fmt.Errorf("no username found: %w", utils.ErrNotFound)
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrNotFound.Error(), zap.String("user_id", userID.String()))
+		return nil, fmt.Errorf("no user found: %w", utils.ErrNotFound)
 	} else if err != nil {
-		ur.zap.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return nil, fmt.Errorf("failed to fetch user: %w", utils.ErrDatabase)
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export user: %w", utils.ErrDatabase)
+	}
+
+	var merchant model.Merchant
+	err = ur.db.QueryRow(ctx, `
+    SELECT name, COALESCE(rating, 0), COALESCE(address, ''), COALESCE(category, ''),
+      COALESCE(description, ''), owner, status
+    FROM merchants WHERE user_id = $1
+    `, userID).Scan(&merchant.Name, &merchant.Rating, &merchant.Address, &merchant.Category,
+		&merchant.Description, &merchant.Owner, &merchant.Status)
+	if err == nil {
+		res.Merchant = model.NewMerchantRes(&merchant, model.OwnerView)
+	} else if err != pgx.ErrNoRows {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export merchant: %w", utils.ErrDatabase)
+	}
+
+	var driver model.Driver
+	err = ur.db.QueryRow(ctx, `
+    SELECT name, COALESCE(rating, 0), COALESCE(license, ''), COALESCE(area, ''), COALESCE(income, 0), username, status
+    FROM drivers WHERE user_id = $1
+    `, userID).Scan(&driver.Name, &driver.Rating, &driver.License, &driver.Area, &driver.Income, &driver.Username, &driver.Status)
+	if err == nil {
+		res.Driver = model.NewDriverRes(&driver, model.OwnerView)
+	} else if err != pgx.ErrNoRows {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export driver: %w", utils.ErrDatabase)
+	}
+
+	docs, err := ur.db.Query(ctx, `
+    SELECT d.document_id, d.driver_id, d.kind, d.file_name, d.content_type, d.size, d.uploaded_at
+    FROM driver_documents d JOIN drivers dr ON dr.driver_id = d.driver_id
+    WHERE dr.user_id = $1 ORDER BY d.uploaded_at
+    `, userID)
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export driver documents: %w", utils.ErrDatabase)
+	}
+	defer docs.Close()
+	res.Documents = []model.DriverDocument{}
+	for docs.Next() {
+		var doc model.DriverDocument
+		if err := docs.Scan(&doc.DocumentID, &doc.DriverID, &doc.Kind, &doc.FileName, &doc.ContentType, &doc.Size, &doc.UploadedAt); err != nil {
+			utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to export driver documents: %w", utils.ErrDatabase)
+		}
+		res.Documents = append(res.Documents, doc)
+	}
+	if err := docs.Err(); err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export driver documents: %w", utils.ErrDatabase)
+	}
+
+	rows, err := ur.db.Query(ctx, `
+    SELECT transaction_id, user_id, amount, balance_after, kind, COALESCE(reason, ''), created_at
+    FROM wallet_transactions WHERE user_id = $1 ORDER BY created_at
+    `, userID)
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export wallet: %w", utils.ErrDatabase)
+	}
+	defer rows.Close()
+	res.Wallet = []model.WalletTransaction{}
+	for rows.Next() {
+		var tx model.WalletTransaction
+		if err := rows.Scan(&tx.TransactionID, &tx.UserID, &tx.Amount, &tx.BalanceAfter, &tx.Kind, &tx.Reason, &tx.CreatedAt); err != nil {
+			utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to export wallet: %w", utils.ErrDatabase)
+		}
+		res.Wallet = append(res.Wallet, tx)
+	}
+	if err := rows.Err(); err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export wallet: %w", utils.ErrDatabase)
+	}
+	rows.Close()
+
+	orders, err := ur.db.Query(ctx, `
+    SELECT `+orderColumns+`
+    WHERE o.customer_id = $1
+    ORDER BY o.created_at, o.order_id
+    `, userID)
+	if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export orders: %w", utils.ErrDatabase)
+	}
+	defer orders.Close()
+	res.Orders = []model.Order{}
+	for orders.Next() {
+		var order model.Order
+		if err := scanOrder(orders, &order); err != nil {
+			utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+			return nil, fmt.Errorf("failed to export orders: %w", utils.ErrDatabase)
+		}
+		res.Orders = append(res.Orders, order)
+	}
+	if err := orders.Err(); err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to export orders: %w", utils.ErrDatabase)
+	}
+	orders.Close()
+	if len(res.Orders) > 0 {
+		if err := loadOrderItems(ctx, ur.db, ur.zap, res.Orders); err != nil {
+			return nil, err
+		}
 	}
 	return &res, nil
 }
+
+func (ur *UserRepo) GetAccountStatusRepo(ctx context.Context, userID uuid.UUID) (string, error) {
+	var status string
+	err := ur.db.QueryRow(ctx, `
+    SELECT status FROM users WHERE user_id = $1 AND deleted_at IS NULL
+    `, userID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		utils.Logger(ctx, ur.zap).Warn(utils.ErrNotFound.Error(), zap.String("user_id", userID.String()))
+		return "", fmt.Errorf("no user found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		utils.Logger(ctx, ur.zap).Error(utils.ErrDatabase.Error(), zap.Error(err))
+		return "", fmt.Errorf("failed to fetch account status: %w", utils.ErrDatabase)
+	}
+	return status, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/bagasadiii/gofood-clone/config"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/seed"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/storage"
+	"go.uber.org/zap"
+)
+
+// runSeed fills the configured database with generated development data.
+func runSeed(args []string) {
+	opts := seed.DefaultOptions()
+	var wipe bool
+	cfg := loadConfig("seed", args, func(fs *flag.FlagSet) {
+		fs.Uint64Var(&opts.Seed, "seed", opts.Seed, "random seed; the same seed produces the same data")
+		fs.IntVar(&opts.Customers, "customers", opts.Customers, "number of customer accounts")
+		fs.IntVar(&opts.Merchants, "merchants", opts.Merchants, "number of merchants")
+		fs.IntVar(&opts.Drivers, "drivers", opts.Drivers, "number of drivers")
+		fs.IntVar(&opts.MaxMenus, "max-menus", opts.MaxMenus, "maximum menu items per merchant")
+		fs.IntVar(&opts.Orders, "orders", opts.Orders, "number of historical orders to place")
+		fs.StringVar(&opts.Password, "password", opts.Password, "password for every generated account")
+		fs.BoolVar(&wipe, "wipe", false, "delete all existing data before seeding")
+	})
+
+	logger, err := config.NewLogger(cfg.Log)
+	if err != nil {
+		fatalf("Failed to init logger: %v", err)
+	}
+	db := config.InitDB(cfg.Database.URL)
+	defer db.Close()
+	ctx := context.Background()
+
+	if wipe {
+		logger.Warn("Wiping database before seeding")
+		if err := seed.Wipe(ctx, db); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	userRepo := repository.NewUserRepo(db, logger)
+	jwtService := middleware.NewJWTService([]byte(cfg.Auth.SecretKey), cfg.Auth.TokenTTL, userRepo, logger)
+	auditRepo := repository.NewAuditRepo(db, logger)
+	loginGuard := service.NewLoginGuard(repository.NewLoginAttemptRepo(db, logger), auditRepo, logger)
+	documentStore, err := storage.NewLocalStorage(cfg.Storage.UploadDir)
+	if err != nil {
+		logger.Fatal("Failed to init document storage", zap.Error(err))
+	}
+
+	merchantRepo := repository.NewMerchantRepo(db, logger)
+	orderRepo := repository.NewOrderRepo(db, logger)
+	seeder := seed.NewSeeder(seed.Services{
+		Users:          service.NewUserService(userRepo, loginGuard, documentStore, logger, jwtService, cfg.Auth.BcryptCost),
+		Merchants:      service.NewMerchantService(merchantRepo, logger),
+		Menus:          service.NewMenuService(repository.NewMenuRepo(db, logger), logger),
+		Drivers:        service.NewDriverService(repository.NewDriverRepo(db, logger), documentStore, logger),
+		Admin:          service.NewAdminService(repository.NewAdminRepo(db, logger), orderRepo, logger),
+		Orders:         service.NewOrderService(orderRepo, merchantRepo, cfg.Orders.ReleaseLead, cfg.Orders.AcceptWindow, cfg.Orders.CancelFee, logger),
+		MerchantOrders: service.NewMerchantOrderService(orderRepo, merchantRepo, logger),
+	}, logger)
+	sum, err := seeder.Run(ctx, opts)
+	if err != nil {
+		fatalf("Seeding failed: %v", err)
+	}
+	fmt.Printf("Seeded %d customers, %d merchants with %d menu items, %d drivers and %d orders (%d accounts already existed).\n",
+		sum.Customers, sum.Merchants, sum.Menus, sum.Drivers, sum.Orders, sum.Skipped)
+	fmt.Printf("Every account uses the password %q.\n", opts.Password)
+}
@@ -0,0 +1,121 @@
+package seed
+
+// Vocabulary the generator draws from. Menu items are keyed by merchant
+// category so a coffee shop does not end up selling rendang.
+
+var firstNames = []string{
+	"adi", "agus", "ayu", "bayu", "budi", "citra", "dewi", "dimas", "eka", "fajar",
+	"fitri", "gita", "hadi", "indah", "joko", "kartika", "lestari", "made", "nadia", "nur",
+	"putri", "rani", "reza", "rina", "sari", "siti", "tono", "wahyu", "wulan", "yudi",
+}
+
+var lastNames = []string{
+	"hidayat", "kusuma", "lubis", "nasution", "pratama", "putra", "santoso", "saputra",
+	"setiawan", "siregar", "wibowo", "wijaya",
+}
+
+var areas = []string{
+	"Jakarta Selatan", "Jakarta Pusat", "Jakarta Barat", "Jakarta Timur", "Jakarta Utara",
+	"Bandung", "Bekasi", "Bogor", "Depok", "Surabaya", "Tangerang", "Yogyakarta",
+}
+
+var streets = []string{
+	"Jl. Sudirman", "Jl. Thamrin", "Jl. Gatot Subroto", "Jl. Diponegoro", "Jl. Merdeka",
+	"Jl. Gajah Mada", "Jl. Hayam Wuruk", "Jl. Pemuda", "Jl. Ahmad Yani", "Jl. Kebon Jeruk",
+}
+
+var rejectReasons = []string{
+	"Kitchen is too busy right now", "Out of ingredients", "Closing early today", "Delivery address is too far",
+}
+
+type menuItem struct {
+	name     string
+	category string
+	price    int64
+}
+
+type merchantCategory struct {
+	name     string
+	prefixes []string
+	blurb    string
+	menu     []menuItem
+}
+
+var merchantCategories = []merchantCategory{
+	{
+		name:     "indonesian",
+		prefixes: []string{"Warung", "Rumah Makan", "Dapur"},
+		blurb:    "Home-style Indonesian cooking",
+		menu: []menuItem{
+			{"Nasi Goreng Spesial", "rice", 25000},
+			{"Mie Goreng Jawa", "noodles", 22000},
+			{"Sate Ayam", "grill", 28000},
+			{"Soto Ayam", "soup", 20000},
+			{"Gado-Gado", "salad", 18000},
+			{"Rendang Sapi", "rice", 35000},
+			{"Ayam Penyet", "rice", 27000},
+			{"Es Teh Manis", "drinks", 5000},
+		},
+	},
+	{
+		name:     "coffee",
+		prefixes: []string{"Kopi", "Kedai Kopi"},
+		blurb:    "Single-origin coffee and light bites",
+		menu: []menuItem{
+			{"Kopi Susu Gula Aren", "coffee", 22000},
+			{"Americano", "coffee", 20000},
+			{"Cafe Latte", "coffee", 28000},
+			{"Matcha Latte", "non-coffee", 30000},
+			{"Croissant", "pastry", 18000},
+			{"Banana Bread", "pastry", 20000},
+		},
+	},
+	{
+		name:     "bakery",
+		prefixes: []string{"Toko Roti", "Bakery"},
+		blurb:    "Fresh bread and cakes baked every morning",
+		menu: []menuItem{
+			{"Roti Sobek Cokelat", "bread", 25000},
+			{"Bolu Pandan", "cake", 45000},
+			{"Donat Gula", "pastry", 8000},
+			{"Lapis Legit", "cake", 120000},
+			{"Roti Abon", "bread", 12000},
+		},
+	},
+	{
+		name:     "japanese",
+		prefixes: []string{"Sushi", "Ramen", "Izakaya"},
+		blurb:    "Japanese comfort food",
+		menu: []menuItem{
+			{"Chicken Katsu Don", "rice", 45000},
+			{"Tonkotsu Ramen", "noodles", 55000},
+			{"Salmon Sushi Roll", "sushi", 60000},
+			{"Gyoza", "snacks", 30000},
+			{"Ocha", "drinks", 10000},
+		},
+	},
+	{
+		name:     "dessert",
+		prefixes: []string{"Es", "Manisan"},
+		blurb:    "Shaved ice, puddings and sweet drinks",
+		menu: []menuItem{
+			{"Es Campur", "shaved ice", 20000},
+			{"Es Teler", "shaved ice", 22000},
+			{"Klepon", "traditional", 12000},
+			{"Pudding Cokelat", "pudding", 15000},
+			{"Martabak Manis", "traditional", 40000},
+		},
+	},
+	{
+		name:     "fast food",
+		prefixes: []string{"Burger", "Fried Chicken"},
+		blurb:    "Burgers, fried chicken and fries",
+		menu: []menuItem{
+			{"Cheeseburger", "burgers", 35000},
+			{"Double Beef Burger", "burgers", 52000},
+			{"Fried Chicken 2 pcs", "chicken", 38000},
+			{"French Fries", "sides", 15000},
+			{"Cola", "drinks", 10000},
+		},
+	},
+}
@@ -0,0 +1,464 @@
+// Package seed fills a development database with generated users, merchants,
+// menus, drivers and order history. Everything is created through the service
+// layer, so validation, password hashing, the review workflow and wallet
+// payments apply exactly as they do for API requests. Reviews are not seeded
+// because the application has no reviews yet.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Options controls how much data Run generates. The same Seed and counts yield
+// the same usernames, names, menus and amounts; only the IDs differ between
+// runs because the services assign them.
+type Options struct {
+	Seed      uint64
+	Customers int
+	Merchants int
+	Drivers   int
+	// MaxMenus caps the menu items per merchant; each merchant gets between
+	// one and MaxMenus items from its category.
+	MaxMenus int
+	// Orders is how many orders to place. Each goes to an approved merchant
+	// and is left placed, accepted, ready or rejected; orders a customer's
+	// seeded balance cannot cover are dropped from the plan.
+	Orders int
+	// Password is shared by every generated account.
+	Password string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Seed:      1,
+		Customers: 50,
+		Merchants: 10,
+		Drivers:   10,
+		MaxMenus:  6,
+		Orders:    100,
+		Password:  "password123",
+	}
+}
+
+type Summary struct {
+	Customers int
+	Merchants int
+	Menus     int
+	Drivers   int
+	Orders    int
+	// Skipped counts accounts that already existed, typically from an
+	// earlier run with the same seed.
+	Skipped int
+}
+
+type Services struct {
+	Users     service.UserServiceImpl
+	Merchants service.MerchantServiceImpl
+	Menus     service.MenuServiceImpl
+	Drivers   service.DriverServiceImpl
+	Admin     service.AdminServiceImpl
+	// Orders places orders as the seeded customers and MerchantOrders moves
+	// them along as the merchants.
+	Orders         service.OrderServiceImpl
+	MerchantOrders service.MerchantOrderServiceImpl
+}
+
+// reviewer is the admin identity that approves seeded merchants and drivers
+// and tops up wallets. It shows up as the actor in the audit log.
+var reviewer = utils.ContextValues{
+	UserID:   uuid.MustParse("00000000-0000-4000-8000-000000005eed"),
+	Username: "seed",
+	Role:     "admin",
+}
+
+type Seeder struct {
+	svc Services
+	zap *zap.Logger
+}
+
+func NewSeeder(svc Services, zap *zap.Logger) *Seeder {
+	return &Seeder{
+		svc: svc,
+		zap: zap,
+	}
+}
+
+// Run generates the data described by opts. Accounts that already exist are
+// skipped along with everything that hangs off them, so re-running with the
+// same seed only fills in what is missing.
+func (s *Seeder) Run(ctx context.Context, opts Options) (*Summary, error) {
+	p := newPlan(opts)
+	admin := utils.WithContextValues(ctx, reviewer)
+	var sum Summary
+	// Orders need the contexts of both parties and the menu IDs the services
+	// assigned; entries stay nil for accounts created by an earlier run.
+	customers := make([]context.Context, len(p.customers))
+	merchants := make([]context.Context, len(p.merchants))
+	menus := make([][]uuid.UUID, len(p.merchants))
+
+	for i, c := range p.customers {
+		userCtx, err := s.register(ctx, c.account)
+		if errors.Is(err, utils.ErrUniqueConstraint) {
+			sum.Skipped++
+			continue
+		} else if err != nil {
+			return &sum, err
+		}
+		if c.topUp > 0 {
+			if _, err := s.svc.Admin.AdjustBalanceService(admin, c.Username, &model.WalletAdjustReq{
+				Amount: c.topUp,
+				Reason: "seed top-up",
+			}); err != nil {
+				return &sum, fmt.Errorf("top up %s: %w", c.Username, err)
+			}
+		}
+		customers[i] = userCtx
+		sum.Customers++
+	}
+
+	for i, m := range p.merchants {
+		userCtx, err := s.register(ctx, m.account)
+		if errors.Is(err, utils.ErrUniqueConstraint) {
+			sum.Skipped++
+			continue
+		} else if err != nil {
+			return &sum, err
+		}
+		if _, err := s.svc.Merchants.CreateMerchantService(userCtx, &m.merchant); err != nil {
+			return &sum, fmt.Errorf("create merchant %s: %w", m.Username, err)
+		}
+		for j := range m.menus {
+			menu, err := s.svc.Menus.CreateMenuService(userCtx, &m.menus[j], m.Username)
+			if err != nil {
+				return &sum, fmt.Errorf("create menu %q for %s: %w", m.menus[j].Name, m.Username, err)
+			}
+			menus[i] = append(menus[i], menu.MenuID)
+			sum.Menus++
+		}
+		if m.approve {
+			if _, err := s.svc.Admin.ReviewMerchantService(admin, m.Username, model.ReviewStatusApproved, &model.ReviewReq{Note: "seed"}); err != nil {
+				return &sum, fmt.Errorf("approve merchant %s: %w", m.Username, err)
+			}
+		}
+		merchants[i] = userCtx
+		sum.Merchants++
+	}
+
+	for _, d := range p.drivers {
+		userCtx, err := s.register(ctx, d.account)
+		if errors.Is(err, utils.ErrUniqueConstraint) {
+			sum.Skipped++
+			continue
+		} else if err != nil {
+			return &sum, err
+		}
+		if _, err := s.svc.Drivers.CreateDriverService(userCtx, &d.driver); err != nil {
+			return &sum, fmt.Errorf("create driver %s: %w", d.Username, err)
+		}
+		if d.approve {
+			if _, err := s.svc.Admin.ReviewDriverService(admin, d.Username, model.ReviewStatusApproved, &model.ReviewReq{Note: "seed"}); err != nil {
+				return &sum, fmt.Errorf("approve driver %s: %w", d.Username, err)
+			}
+		}
+		sum.Drivers++
+	}
+
+	for _, o := range p.orders {
+		customerCtx, merchantCtx := customers[o.customer], merchants[o.merchant]
+		if customerCtx == nil || merchantCtx == nil {
+			continue
+		}
+		if err := s.placeOrder(customerCtx, merchantCtx, p.merchants[o.merchant].Username, menus[o.merchant], o); err != nil {
+			return &sum, err
+		}
+		sum.Orders++
+	}
+
+	utils.Logger(ctx, s.zap).Info("Seed complete",
+		zap.Uint64("seed", opts.Seed),
+		zap.Int("customers", sum.Customers),
+		zap.Int("merchants", sum.Merchants),
+		zap.Int("menus", sum.Menus),
+		zap.Int("drivers", sum.Drivers),
+		zap.Int("orders", sum.Orders),
+		zap.Int("skipped", sum.Skipped),
+	)
+	return &sum, nil
+}
+
+// placeOrder creates the order as the customer and then takes it to its
+// planned status as the merchant.
+func (s *Seeder) placeOrder(customerCtx, merchantCtx context.Context, merchant string, menus []uuid.UUID, o orderSpec) error {
+	req := model.CreateOrderReq{Merchant: merchant}
+	for _, line := range o.lines {
+		req.Items = append(req.Items, model.OrderItemReq{MenuID: menus[line.menu], Quantity: line.quantity})
+	}
+	order, err := s.svc.Orders.CreateOrderService(customerCtx, &req)
+	if err != nil {
+		return fmt.Errorf("place order at %s: %w", merchant, err)
+	}
+
+	switch o.outcome {
+	case model.OrderStatusRejected:
+		_, err = s.svc.MerchantOrders.RejectOrderService(merchantCtx, merchant, order.OrderID, &model.RejectOrderReq{Reason: o.reason})
+	case model.OrderStatusAccepted, model.OrderStatusReady:
+		_, err = s.svc.MerchantOrders.AcceptOrderService(merchantCtx, merchant, order.OrderID, &model.AcceptOrderReq{PrepMinutes: o.prepMinutes})
+		if err == nil && o.outcome == model.OrderStatusReady {
+			_, err = s.svc.MerchantOrders.MarkOrderReadyService(merchantCtx, merchant, order.OrderID)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("move order %s to %s: %w", order.OrderID, o.outcome, err)
+	}
+	return nil
+}
+
+// register creates the account and returns a context authenticated as it,
+// the way the JWT middleware would after a login.
+func (s *Seeder) register(ctx context.Context, a account) (context.Context, error) {
+	user, err := s.svc.Users.RegisterService(ctx, &a.RegisterReq)
+	if err != nil {
+		if !errors.Is(err, utils.ErrUniqueConstraint) {
+			err = fmt.Errorf("register %s: %w", a.Username, err)
+		}
+		return nil, err
+	}
+	return utils.WithContextValues(ctx, utils.ContextValues{
+		UserID:   user.UserID,
+		Username: user.Username,
+		Role:     user.Role,
+	}), nil
+}
+
+// Wipe empties every table the application writes to. It is meant for
+// development databases only.
+func Wipe(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, `
+    TRUNCATE users, audit_logs, login_attempts, rate_limits, jobs CASCADE
+    `)
+	if err != nil {
+		return fmt.Errorf("wipe database: %w", err)
+	}
+	return nil
+}
+
+type account struct {
+	model.RegisterReq
+}
+
+type customerSpec struct {
+	account
+	topUp int64
+}
+
+type merchantSpec struct {
+	account
+	merchant model.Merchant
+	menus    []model.Menu
+	approve  bool
+}
+
+type driverSpec struct {
+	account
+	driver  model.Driver
+	approve bool
+}
+
+// orderSpec refers to customers, merchants and menus by their index in the
+// plan, since their IDs are only known once Run has created them.
+type orderSpec struct {
+	customer    int
+	merchant    int
+	lines       []orderLine
+	outcome     string
+	prepMinutes int
+	reason      string
+}
+
+type orderLine struct {
+	menu     int
+	quantity int
+}
+
+type plan struct {
+	customers []customerSpec
+	merchants []merchantSpec
+	drivers   []driverSpec
+	orders    []orderSpec
+}
+
+// newPlan draws everything Run will create from a generator seeded with
+// opts.Seed. Building the whole plan before touching the database keeps the
+// output stable when some accounts are skipped.
+func newPlan(opts Options) *plan {
+	g := &generator{rng: rand.New(rand.NewPCG(opts.Seed, 0x5eed)), password: opts.Password}
+	p := &plan{}
+	for range opts.Customers {
+		p.customers = append(p.customers, customerSpec{
+			account: g.account("user"),
+			topUp:   g.amount(0, 500000, 10000),
+		})
+	}
+	for range opts.Merchants {
+		a := g.account("merchant")
+		cat := merchantCategories[g.rng.IntN(len(merchantCategories))]
+		m := merchantSpec{
+			account: a,
+			merchant: model.Merchant{
+				Name:        pick(g.rng, cat.prefixes) + " " + capitalize(pick(g.rng, firstNames)),
+				Address:     g.address(),
+				Category:    cat.name,
+				Description: cat.blurb,
+			},
+			approve: g.rng.IntN(10) < 8,
+		}
+		items := make([]menuItem, len(cat.menu))
+		copy(items, cat.menu)
+		g.rng.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		n := 0
+		if opts.MaxMenus > 0 {
+			n = 1 + g.rng.IntN(min(opts.MaxMenus, len(items)))
+		}
+		for _, item := range items[:n] {
+			m.menus = append(m.menus, model.Menu{
+				Name:        item.name,
+				Price:       item.price + g.amount(-2000, 5000, 1000),
+				Description: item.name + " from " + m.merchant.Name,
+				Category:    item.category,
+				Stock:       g.rng.IntN(100),
+			})
+		}
+		p.merchants = append(p.merchants, m)
+	}
+	for range opts.Drivers {
+		p.drivers = append(p.drivers, driverSpec{
+			account: g.account("driver"),
+			driver: model.Driver{
+				Name:    capitalize(pick(g.rng, firstNames)),
+				License: g.license(),
+				Area:    pick(g.rng, areas),
+			},
+			approve: g.rng.IntN(10) < 8,
+		})
+	}
+	p.planOrders(g, opts.Orders)
+	return p
+}
+
+// planOrders draws n orders from customers at approved merchants with a
+// menu. Prices and balances are known up front, so an order the customer
+// could not pay for is left out instead of failing at checkout.
+func (p *plan) planOrders(g *generator, n int) {
+	var open []int
+	for i, m := range p.merchants {
+		if m.approve && len(m.menus) > 0 {
+			open = append(open, i)
+		}
+	}
+	if len(open) == 0 || len(p.customers) == 0 {
+		return
+	}
+	balances := make([]int64, len(p.customers))
+	for i, c := range p.customers {
+		balances[i] = c.topUp
+	}
+
+	for range n {
+		o := orderSpec{
+			customer: g.rng.IntN(len(p.customers)),
+			merchant: open[g.rng.IntN(len(open))],
+		}
+		menu := p.merchants[o.merchant].menus
+		var total int64
+		for _, idx := range g.rng.Perm(len(menu))[:1+g.rng.IntN(min(3, len(menu)))] {
+			line := orderLine{menu: idx, quantity: 1 + g.rng.IntN(3)}
+			total += menu[idx].Price * int64(line.quantity)
+			o.lines = append(o.lines, line)
+		}
+		switch r := g.rng.IntN(10); {
+		case r < 6:
+			o.outcome = model.OrderStatusReady
+		case r < 7:
+			o.outcome = model.OrderStatusAccepted
+		case r < 9:
+			o.outcome = model.OrderStatusRejected
+		default:
+			o.outcome = model.OrderStatusPlaced
+		}
+		o.prepMinutes = 5 + 5*g.rng.IntN(8)
+		o.reason = pick(g.rng, rejectReasons)
+		if total > balances[o.customer] {
+			continue
+		}
+		// A rejected order is refunded, so only the others use up the balance.
+		if o.outcome != model.OrderStatusRejected {
+			balances[o.customer] -= total
+		}
+		p.orders = append(p.orders, o)
+	}
+}
+
+type generator struct {
+	rng      *rand.Rand
+	password string
+	accounts int
+	licenses map[string]bool
+}
+
+// account returns a unique username, email and phone. The running counter
+// keeps them unique however the names collide.
+func (g *generator) account(role string) account {
+	g.accounts++
+	username := fmt.Sprintf("%s_%s%d", pick(g.rng, firstNames), pick(g.rng, lastNames), g.accounts)
+	return account{model.RegisterReq{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: g.password,
+		Role:     role,
+		Phone:    fmt.Sprintf("0812%08d", g.rng.IntN(100000000)),
+	}}
+}
+
+func (g *generator) address() string {
+	return fmt.Sprintf("%s No. %d, %s", pick(g.rng, streets), 1+g.rng.IntN(200), pick(g.rng, areas))
+}
+
+// license returns a plate-style license number that has not been used yet.
+func (g *generator) license() string {
+	if g.licenses == nil {
+		g.licenses = make(map[string]bool)
+	}
+	for {
+		l := fmt.Sprintf("%c%04d%c%c", 'A'+g.rng.IntN(26), g.rng.IntN(10000), 'A'+g.rng.IntN(26), 'A'+g.rng.IntN(26))
+		if !g.licenses[l] {
+			g.licenses[l] = true
+			return l
+		}
+	}
+}
+
+// amount returns a multiple of step in [lo, hi].
+func (g *generator) amount(lo, hi, step int64) int64 {
+	return lo + g.rng.Int64N((hi-lo)/step+1)*step
+}
+
+func pick(rng *rand.Rand, list []string) string {
+	return list[rng.IntN(len(list))]
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
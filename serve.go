@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/app"
+	"github.com/bagasadiii/gofood-clone/certs"
+	"github.com/bagasadiii/gofood-clone/config"
+	"github.com/bagasadiii/gofood-clone/handler"
+	"github.com/bagasadiii/gofood-clone/health"
+	"github.com/bagasadiii/gofood-clone/idempotency"
+	"github.com/bagasadiii/gofood-clone/jobs"
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/ratelimit"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/service"
+	"github.com/bagasadiii/gofood-clone/storage"
+	"github.com/bagasadiii/gofood-clone/tracing"
+	"github.com/rs/cors"
+	"go.uber.org/zap"
+)
+
+// serve runs the API and admin listeners until SIGINT or SIGTERM.
+func serve(cfg *config.Config) {
+	logger, err := config.NewLogger(cfg.Log)
+	if err != nil {
+		fatalf("Failed to init logger: %v", err)
+	}
+	db := config.InitDB(cfg.Database.URL)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, nil)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	metrics.RegisterPool(db)
+
+	userRepo := repository.NewUserRepo(db, logger)
+	jwtService := middleware.NewJWTService([]byte(cfg.Auth.SecretKey), cfg.Auth.TokenTTL, userRepo, logger)
+	auditRepo := repository.NewAuditRepo(db, logger)
+	loginAttemptRepo := repository.NewLoginAttemptRepo(db, logger)
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, auditRepo, logger)
+	documentStore, err := storage.NewLocalStorage(cfg.Storage.UploadDir)
+	if err != nil {
+		logger.Fatal("Failed to init document storage", zap.Error(err))
+	}
+	userService := service.NewUserService(userRepo, loginGuard, documentStore, logger, jwtService, cfg.Auth.BcryptCost)
+	userHandler := handler.NewUserHandler(userService, logger)
+
+	twoFactorRepo := repository.NewTwoFactorRepo(db, logger)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, loginGuard, logger, jwtService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService, logger)
+
+	merchantRepo := repository.NewMerchantRepo(db, logger)
+	merchantService := service.NewMerchantService(merchantRepo, logger)
+	merchantHandler := handler.NewMerchantHandler(merchantService, logger)
+
+	menuRepo := repository.NewMenuRepo(db, logger)
+	menuService := service.NewMenuService(menuRepo, logger)
+	menuHandler := handler.NewMenuHandler(menuService, logger)
+
+	orderRepo := repository.NewOrderRepo(db, logger)
+	orderService := service.NewOrderService(orderRepo, merchantRepo, cfg.Orders.ReleaseLead, cfg.Orders.AcceptWindow, cfg.Orders.CancelFee, logger)
+	orderHandler := handler.NewOrderHandler(orderService, logger)
+	merchantOrderService := service.NewMerchantOrderService(orderRepo, merchantRepo, logger)
+	merchantOrderHandler := handler.NewMerchantOrderHandler(merchantOrderService, logger)
+
+	driverRepo := repository.NewDriverRepo(db, logger)
+	driverService := service.NewDriverService(driverRepo, documentStore, logger)
+	driverHandler := handler.NewDriverHandler(driverService, logger)
+
+	adminRepo := repository.NewAdminRepo(db, logger)
+	adminService := service.NewAdminService(adminRepo, orderRepo, logger)
+	adminHandler := handler.NewAdminHandler(adminService, logger)
+
+	checker := health.NewChecker(logger)
+	checker.Register("database", 0, health.Database(db))
+	checker.Register("migrations", 0, health.Migrations(db))
+
+	queue := jobs.NewQueue(db, cfg.Jobs.Options(), logger)
+	rateLimitStore := ratelimit.NewPostgresStore(db)
+	idempotencyStore := idempotency.NewPostgresStore(db)
+	jobs.Handle(queue, loginGuard.Prune)
+	jobs.Handle(queue, rateLimitStore.Prune)
+	jobs.Handle(queue, idempotencyStore.Prune)
+	jobs.Handle(queue, orderService.ReleaseScheduled)
+	jobs.Handle(queue, merchantOrderService.AutoReject)
+	schedules := []struct {
+		spec string
+		args jobs.Args
+	}{
+		{"@hourly", service.PruneLoginAttemptsJob{}},
+		{"*/5 * * * *", ratelimit.PruneJob{}},
+		{"*/10 * * * *", idempotency.PruneJob{}},
+		{"* * * * *", service.ReleaseScheduledOrdersJob{}},
+		{"* * * * *", service.AutoRejectOrdersJob{}},
+	}
+	for _, s := range schedules {
+		if err := queue.Schedule(s.spec, s.args); err != nil {
+			logger.Fatal("Failed to schedule job", zap.Error(err))
+		}
+	}
+	if cfg.Jobs.Workers > 0 {
+		checker.Register("jobs", 0, queue.Check)
+		queue.Start()
+	}
+
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "postgres" {
+		limitStore = rateLimitStore
+	}
+	limiter := middleware.NewRateLimiter(limitStore, logger)
+	idempotent := middleware.NewIdempotency(idempotencyStore, cfg.Idempotency.TTL, logger)
+
+	dependencies := app.HandlerDependencies{
+		UserEndpoint:          userHandler,
+		MerchantEndpoint:      merchantHandler,
+		DriverEndpoint:        driverHandler,
+		MenuEndpoint:          menuHandler,
+		OrderEndpoint:         orderHandler,
+		MerchantOrderEndpoint: merchantOrderHandler,
+		TwoFactorEndpoint:     twoFactorHandler,
+		AdminEndpoint:         adminHandler,
+		Middleware:            jwtService,
+		Health:                checker,
+		RateLimits: app.RateLimits{
+			Auth:          limiter.Limit(cfg.RateLimit.Auth.Policy("auth"), middleware.KeyByIP),
+			Authenticated: limiter.Limit(cfg.RateLimit.Authenticated.Policy("authenticated"), middleware.KeyByUser),
+			Public:        limiter.Limit(cfg.RateLimit.Public.Policy("public"), middleware.KeyByIP),
+		},
+		Idempotency: idempotent.HandleOnce,
+	}
+
+	app := app.NewRouter(dependencies)
+	cors := cors.New(cors.Options{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", middleware.RequestIDHeader, middleware.IdempotencyKeyHeader},
+		ExposedHeaders:   append([]string{middleware.RequestIDHeader, middleware.IdempotentReplayHeader}, middleware.RateLimitHeaders...),
+		AllowCredentials: true,
+	}).Handler
+
+	// Checked by Config.Validate.
+	trustedProxies, _ := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	root := middleware.ClientIP(trustedProxies)(
+		middleware.RequestID(logger)(
+			middleware.AccessLog(logger)(
+				middleware.Recover(logger)(cors(app.Route())),
+			),
+		),
+	)
+	server := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: root,
+	}
+
+	// The admin listener serves operational endpoints and binds to loopback
+	// by default so it is not reachable from the public network.
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/metrics", metrics.Handler())
+	adminMux.HandleFunc("/readyz", checker.DetailHandler)
+	adminServer := &http.Server{
+		Addr:    cfg.Server.AdminAddr,
+		Handler: adminMux,
+	}
+
+	var reloader *certs.Reloader
+	var redirectServer *http.Server
+	if cfg.TLS.Enabled() {
+		reloader, err = certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ReloadInterval, logger)
+		if err != nil {
+			logger.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		// Both values were checked by Config.Validate.
+		minVersion, _ := certs.ParseMinVersion(cfg.TLS.MinVersion)
+		cipherSuites, _ := certs.ParseCipherSuites(cfg.TLS.CipherSuites)
+		server.TLSConfig = certs.ServerConfig(reloader, minVersion, cipherSuites)
+
+		if cfg.TLS.RedirectAddr != "" {
+			_, httpsPort, _ := net.SplitHostPort(cfg.Server.Addr)
+			redirectServer = &http.Server{
+				Addr:    cfg.TLS.RedirectAddr,
+				Handler: certs.RedirectHandler(httpsPort),
+			}
+		}
+	}
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+
+	// SIGHUP reloads the certificate right away instead of waiting for the
+	// next periodic check.
+	if reloader != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					logger.Error("Failed to reload TLS certificate", zap.Error(err))
+					continue
+				}
+				logger.Info("TLS certificate reloaded")
+			}
+		}()
+	}
+
+	go func() {
+		logger.Info("Server is starting", zap.String("addr", cfg.Server.Addr), zap.Bool("tls", reloader != nil))
+		var err error
+		if reloader != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Could not listen", zap.String("addr", cfg.Server.Addr), zap.Error(err))
+		}
+	}()
+	if redirectServer != nil {
+		go func() {
+			logger.Info("HTTPS redirect server is starting", zap.String("addr", redirectServer.Addr))
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Could not listen on redirect address", zap.String("addr", redirectServer.Addr), zap.Error(err))
+			}
+		}()
+	}
+	go func() {
+		logger.Info("Admin server is starting", zap.String("addr", cfg.Server.AdminAddr))
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Could not listen on admin address", zap.String("addr", cfg.Server.AdminAddr), zap.Error(err))
+		}
+	}()
+
+	<-done
+	// Fail readiness first and keep serving for a while so load balancers
+	// stop sending traffic before connections are closed.
+	checker.Drain()
+	logger.Info("Server is draining...", zap.Duration("delay", cfg.Server.DrainDelay))
+	time.Sleep(cfg.Server.DrainDelay)
+	logger.Info("Server is shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Running jobs finish alongside in-flight requests within the same
+	// timeout; any still running after it go back on the queue.
+	jobsDrained := make(chan error, 1)
+	go func() { jobsDrained <- queue.Drain(ctx) }()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			logger.Error("Redirect server forced to shutdown", zap.Error(err))
+		}
+	}
+	if err := adminServer.Shutdown(ctx); err != nil {
+		logger.Error("Admin server forced to shutdown", zap.Error(err))
+	}
+
+	if err := <-jobsDrained; err != nil {
+		logger.Error("Job workers forced to stop", zap.Error(err))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
+
+	logger.Info("Server exited gracefully")
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type AdminServiceImpl interface {
+	ListUsersService(ctx context.Context, filter *model.ListFilter) ([]model.AdminUserRes, error)
+	GetUserService(ctx context.Context, username string) (*model.AdminUserRes, error)
+	SetUserStatusService(ctx context.Context, username, status string, input *model.ModerationReq) (*model.AdminUserRes, error)
+	ListMerchantsService(ctx context.Context, filter *model.ListFilter) ([]model.AdminMerchantRes, error)
+	GetMerchantService(ctx context.Context, username string) (*model.AdminMerchantRes, error)
+	ReviewMerchantService(ctx context.Context, username, status string, input *model.ReviewReq) (*model.AdminMerchantRes, error)
+	ListDriversService(ctx context.Context, filter *model.ListFilter) ([]model.AdminDriverRes, error)
+	GetDriverService(ctx context.Context, username string) (*model.AdminDriverRes, error)
+	ReviewDriverService(ctx context.Context, username, status string, input *model.ReviewReq) (*model.AdminDriverRes, error)
+	AdjustBalanceService(ctx context.Context, username string, input *model.WalletAdjustReq) (*model.WalletTransaction, error)
+	ListDisputesService(ctx context.Context, filter *model.DisputeFilter) ([]model.Dispute, error)
+	ResolveDisputeService(ctx context.Context, disputeID uuid.UUID, input *model.ResolveDisputeReq) (*model.Dispute, error)
+}
+type AdminService struct {
+	repo   repository.AdminRepoImpl
+	orders repository.OrderRepoImpl
+	zap    *zap.Logger
+}
+
+func NewAdminService(repo repository.AdminRepoImpl, orders repository.OrderRepoImpl, zap *zap.Logger) *AdminService {
+	return &AdminService{
+		repo:   repo,
+		orders: orders,
+		zap:    zap,
+	}
+}
+
+func (as *AdminService) ListUsersService(ctx context.Context, filter *model.ListFilter) ([]model.AdminUserRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	normalizeListFilter(filter)
+	return as.repo.ListUsersRepo(ctx, filter)
+}
+
+func (as *AdminService) GetUserService(ctx context.Context, username string) (*model.AdminUserRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return as.repo.GetAdminUserRepo(ctx, username)
+}
+
+func (as *AdminService) SetUserStatusService(ctx context.Context, username, status string, input *model.ModerationReq) (*model.AdminUserRes, error) {
+	ctxValue, err := as.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	user, err := as.repo.GetAdminUserRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserID == ctxValue.UserID {
+		return nil, fmt.Errorf("admins cannot change their own status: %w", utils.ErrForbidden)
+	}
+	audit := as.auditEntry(ctx, ctxValue, "admin.user."+status, "user:"+username, input.Reason)
+	if err := as.repo.SetUserStatusRepo(ctx, user.UserID, status, audit); err != nil {
+		return nil, err
+	}
+	user.Status = status
+	return user, nil
+}
+
+func (as *AdminService) ListMerchantsService(ctx context.Context, filter *model.ListFilter) ([]model.AdminMerchantRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	normalizeListFilter(filter)
+	return as.repo.ListMerchantsRepo(ctx, filter)
+}
+
+func (as *AdminService) GetMerchantService(ctx context.Context, username string) (*model.AdminMerchantRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return as.repo.GetAdminMerchantRepo(ctx, username)
+}
+
+func (as *AdminService) ReviewMerchantService(ctx context.Context, username, status string, input *model.ReviewReq) (*model.AdminMerchantRes, error) {
+	ctxValue, err := as.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	merchant, err := as.repo.GetAdminMerchantRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	audit := as.auditEntry(ctx, ctxValue, "admin.merchant."+status, "merchant:"+username, input.Note)
+	if err := as.repo.ReviewMerchantRepo(ctx, merchant.MerchantID, status, input.Note, audit); err != nil {
+		return nil, err
+	}
+	return as.repo.GetAdminMerchantRepo(ctx, username)
+}
+
+func (as *AdminService) ListDriversService(ctx context.Context, filter *model.ListFilter) ([]model.AdminDriverRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	normalizeListFilter(filter)
+	return as.repo.ListDriversRepo(ctx, filter)
+}
+
+func (as *AdminService) GetDriverService(ctx context.Context, username string) (*model.AdminDriverRes, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return as.repo.GetAdminDriverRepo(ctx, username)
+}
+
+func (as *AdminService) ReviewDriverService(ctx context.Context, username, status string, input *model.ReviewReq) (*model.AdminDriverRes, error) {
+	ctxValue, err := as.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	driver, err := as.repo.GetAdminDriverRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	audit := as.auditEntry(ctx, ctxValue, "admin.driver."+status, "driver:"+username, input.Note)
+	if err := as.repo.ReviewDriverRepo(ctx, driver.DriverID, status, input.Note, audit); err != nil {
+		return nil, err
+	}
+	return as.repo.GetAdminDriverRepo(ctx, username)
+}
+
+func (as *AdminService) AdjustBalanceService(ctx context.Context, username string, input *model.WalletAdjustReq) (*model.WalletTransaction, error) {
+	ctxValue, err := as.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	user, err := as.repo.GetAdminUserRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	newTx := model.WalletTransaction{
+		TransactionID: uuid.New(),
+		UserID:        user.UserID,
+		Amount:        input.Amount,
+		Kind:          model.WalletKindAdminAdjustment,
+		Reason:        input.Reason,
+		ActorID:       ctxValue.UserID,
+		CreatedAt:     time.Now(),
+	}
+	detail := fmt.Sprintf("transaction %s, amount %d: %s", newTx.TransactionID, newTx.Amount, input.Reason)
+	audit := as.auditEntry(ctx, ctxValue, "admin.wallet.adjust", "user:"+username, detail)
+	if err := as.repo.AdjustBalanceRepo(ctx, &newTx, audit); err != nil {
+		return nil, err
+	}
+	return &newTx, nil
+}
+
+// ListDisputesService returns disputes oldest first, optionally only those
+// in one status.
+func (as *AdminService) ListDisputesService(ctx context.Context, filter *model.DisputeFilter) ([]model.Dispute, error) {
+	if _, err := as.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(filter); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return as.orders.ListDisputesRepo(ctx, filter)
+}
+
+// ResolveDisputeService closes an open dispute, refunding input.Refund of
+// the order to the customer's wallet. The refund cannot exceed what the order
+// has left to refund.
+func (as *AdminService) ResolveDisputeService(ctx context.Context, disputeID uuid.UUID, input *model.ResolveDisputeReq) (*model.Dispute, error) {
+	ctxValue, err := as.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	resolution := model.DisputeResolution{
+		DisputeID:  disputeID,
+		Refund:     input.Refund,
+		Resolution: input.Resolution,
+		AdminID:    ctxValue.UserID,
+		At:         time.Now(),
+	}
+	detail := fmt.Sprintf("refund %d: %s", input.Refund, input.Resolution)
+	audit := as.auditEntry(ctx, ctxValue, "admin.dispute.resolve", "dispute:"+disputeID.String(), detail)
+	if err := as.orders.ResolveDisputeRepo(ctx, &resolution, audit); err != nil {
+		return nil, err
+	}
+	return as.orders.GetDisputeRepo(ctx, disputeID)
+}
+
+func (as *AdminService) checkAdmin(ctx context.Context) (*utils.ContextValues, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, as.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Role != "admin" {
+		utils.Logger(ctx, as.zap).Error("invalid role", zap.String("needed", "admin"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	return ctxValue, nil
+}
+
+// auditEntry builds the audit row the repository writes in the same
+// transaction as the action itself.
+func (as *AdminService) auditEntry(ctx context.Context, actor *utils.ContextValues, action, subject, detail string) *model.AuditLog {
+	utils.Logger(ctx, as.zap).Info("admin action", zap.String("action", action), zap.String("subject", subject), zap.String("admin", actor.Username))
+	return &model.AuditLog{
+		AuditID:   uuid.New(),
+		ActorID:   actor.UserID,
+		Action:    action,
+		Subject:   subject,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+}
+
+func normalizeListFilter(filter *model.ListFilter) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+}
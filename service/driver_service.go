@@ -1,44 +1,61 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"strings"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/storage"
 	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 type DriverServiceImpl interface {
-	CreateDriverService(ctx context.Context, new *model.Driver) error
-	GetDriverService(ctx context.Context, username string) (*model.DriverRes, error)
-	UpdateDriverService(ctx context.Context, update *model.Driver) error
+	CreateDriverService(ctx context.Context, new *model.Driver) (*model.Driver, error)
+	GetDriverService(ctx context.Context, username string) (*model.Driver, error)
+	UpdateDriverService(ctx context.Context, username string, update *model.UpdateDriverReq) (*model.Driver, error)
+	GetDriverApplicationService(ctx context.Context, username string) (*model.Application, error)
+	UploadDriverDocumentService(ctx context.Context, username string, input *model.DriverDocumentUpload) (*model.DriverDocument, error)
+	ListDriverDocumentsService(ctx context.Context, username string) ([]model.DriverDocument, error)
+	OpenDriverDocumentService(ctx context.Context, username string, documentID uuid.UUID) (*model.DriverDocument, io.ReadCloser, error)
+}
+
+// allowedDocumentTypes are the sniffed content types accepted for driver documents.
+var allowedDocumentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
 }
 
 type DriverService struct {
-	repo repository.DriverRepoImpl
-	zap  *zap.Logger
+	repo  repository.DriverRepoImpl
+	store storage.Storage
+	zap   *zap.Logger
 }
 
-func NewDriverService(repo repository.DriverRepoImpl, zap *zap.Logger) *DriverService {
+func NewDriverService(repo repository.DriverRepoImpl, store storage.Storage, zap *zap.Logger) *DriverService {
 	return &DriverService{
-		repo: repo,
-		zap:  zap,
+		repo:  repo,
+		store: store,
+		zap:   zap,
 	}
 }
 
-func (ds *DriverService) CreateDriverService(ctx context.Context, new *model.Driver) error {
+func (ds *DriverService) CreateDriverService(ctx context.Context, new *model.Driver) (*model.Driver, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ds.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("%v", err)
+		utils.Logger(ctx, ds.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
 	}
 	if ctxValue.Role != "driver" {
-		ds.zap.Error("invalid role", zap.String("needed", "driver"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%v: user role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ds.zap).Error("invalid role", zap.String("needed", "driver"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
 	}
 	newDriver := model.Driver{
 		DriverID: uuid.New(),
@@ -48,55 +65,167 @@ func (ds *DriverService) CreateDriverService(ctx context.Context, new *model.Dri
 		Income:   0,
 		UserID:   ctxValue.UserID,
 		Username: ctxValue.Username,
+		Status:   model.ReviewStatusPending,
+	}
+	if err := utils.Validate(&newDriver); err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
 	}
-	if err := utils.ValidateDriver(&newDriver); err != nil {
-		ds.zap.Error(utils.ErrBadRequest.Error(), zap.Error(err))
-		return fmt.Errorf("%v", err)
+	if err := ds.repo.CreateDriverRepo(ctx, &newDriver); err != nil {
+		return nil, err
 	}
-	return ds.repo.CreateDriverRepo(ctx, &newDriver)
+	return &newDriver, nil
 }
 
-func (ds *DriverService) GetDriverService(ctx context.Context, username string) (*model.DriverRes, error) {
+func (ds *DriverService) GetDriverService(ctx context.Context, username string) (*model.Driver, error) {
 	return ds.repo.GetDriverRepo(ctx, username)
 }
 
-func (ds *DriverService) UpdateDriverService(ctx context.Context, update *model.Driver) error {
+func (ds *DriverService) UpdateDriverService(ctx context.Context, username string, update *model.UpdateDriverReq) (*model.Driver, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ds.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("%v", err)
+		utils.Logger(ctx, ds.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 	if ctxValue.Role != "driver" {
-		ds.zap.Error("invalid role", zap.String("needed", "driver"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%v: user role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ds.zap).Error("invalid role", zap.String("needed", "driver"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(update); err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	patch := driverPatch(update)
+	if err := patch.Err(); err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if patch.Empty() {
+		return nil, fmt.Errorf("no fields to update: %w", utils.ErrBadRequest)
+	}
+	return ds.repo.UpdateDriverRepo(ctx, ctxValue.UserID, patch)
+}
+
+func (ds *DriverService) GetDriverApplicationService(ctx context.Context, username string) (*model.Application, error) {
+	if _, err := ds.checkOwnerOrAdmin(ctx, username); err != nil {
+		return nil, err
+	}
+	return ds.repo.GetDriverApplicationRepo(ctx, username)
+}
+
+func (ds *DriverService) UploadDriverDocumentService(ctx context.Context, username string, input *model.DriverDocumentUpload) (*model.DriverDocument, error) {
+	ctxValue, err := ds.checkOwnerOrAdmin(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("only the driver can upload documents: %w", utils.ErrForbidden)
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	driverID, err := ds.repo.GetDriverIDRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(input.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to read document: %w", utils.ErrBadRequest)
+	}
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := allowedDocumentTypes[contentType]
+	if !ok {
+		utils.Logger(ctx, ds.zap).Warn("unsupported document type", zap.String("content_type", contentType))
+		return nil, fmt.Errorf("document must be jpeg, png or pdf: %w", utils.ErrBadRequest)
+	}
+
+	doc := model.DriverDocument{
+		DocumentID:  uuid.New(),
+		DriverID:    driverID,
+		Kind:        input.Kind,
+		FileName:    input.FileName,
+		ContentType: contentType,
+		UploadedAt:  time.Now(),
+	}
+	doc.StorageKey = fmt.Sprintf("drivers/%s/%s%s", driverID, doc.DocumentID, ext)
+	doc.Size, err = ds.store.Save(ctx, doc.StorageKey, io.MultiReader(bytes.NewReader(head[:n]), input.Body))
+	if err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrInternal.Error(), zap.Error(err))
+		return nil, err
+	}
+	if err := ds.repo.CreateDriverDocumentRepo(ctx, &doc); err != nil {
+		ds.store.Delete(ctx, doc.StorageKey)
+		return nil, err
+	}
+	utils.Logger(ctx, ds.zap).Info("driver document uploaded", zap.String("username", username), zap.String("kind", doc.Kind))
+	return &doc, nil
+}
+
+func (ds *DriverService) ListDriverDocumentsService(ctx context.Context, username string) ([]model.DriverDocument, error) {
+	if _, err := ds.checkOwnerOrAdmin(ctx, username); err != nil {
+		return nil, err
+	}
+	driverID, err := ds.repo.GetDriverIDRepo(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return ds.repo.ListDriverDocumentsRepo(ctx, driverID)
+}
+
+func (ds *DriverService) OpenDriverDocumentService(ctx context.Context, username string, documentID uuid.UUID) (*model.DriverDocument, io.ReadCloser, error) {
+	if _, err := ds.checkOwnerOrAdmin(ctx, username); err != nil {
+		return nil, nil, err
+	}
+	driverID, err := ds.repo.GetDriverIDRepo(ctx, username)
+	if err != nil {
+		return nil, nil, err
 	}
-	update.UserID = ctxValue.UserID
-	update.Username = ctxValue.Username
-	query, args := updateDriverQueryBuilder(update)
-	return ds.repo.UpdateDriverRepo(ctx, query, args)
+	doc, err := ds.repo.GetDriverDocumentRepo(ctx, driverID, documentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ds.store.Open(ctx, doc.StorageKey)
+	if err != nil {
+		utils.Logger(ctx, ds.zap).Error("failed to open driver document", zap.Error(err))
+		return nil, nil, err
+	}
+	return doc, body, nil
+}
+
+func (ds *DriverService) checkOwnerOrAdmin(ctx context.Context, username string) (*utils.ContextValues, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Username != username && ctxValue.Role != "admin" {
+		utils.Logger(ctx, ds.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	return ctxValue, nil
+}
+
+// driverColumns lists the driver columns a PATCH may write and whether they
+// can be cleared with null.
+var driverColumns = map[string]bool{
+	"name":    false,
+	"license": false,
+	"area":    true,
 }
 
-func updateDriverQueryBuilder(updated *model.Driver) (string, []interface{}) {
-	fields := []string{}
-	argsIndex := 1
-	args := []interface{}{}
-
-	if updated.Name != "" {
-		fields = append(fields, fmt.Sprintf("name = $%d", argsIndex))
-		args = append(args, updated.Name)
-		argsIndex++
-	}
-	if updated.License != "" {
-		fields = append(fields, fmt.Sprintf("license = $%d", argsIndex))
-		args = append(args, updated.License)
-		argsIndex++
-	}
-	if updated.Area != "" {
-		fields = append(fields, fmt.Sprintf("area = $%d", argsIndex))
-		args = append(args, updated.Area)
-		argsIndex++
-	}
-	args = append(args, updated.UserID)
-	updatedQuery := fmt.Sprintf("%s WHERE user_id = $%d", strings.Join(fields, ", "), argsIndex)
-	return updatedQuery, args
+func driverPatch(update *model.UpdateDriverReq) *utils.Patch {
+	patch := utils.NewPatch(driverColumns)
+	utils.PatchField(patch, "name", update.Name)
+	utils.PatchField(patch, "license", update.License)
+	utils.PatchField(patch, "area", update.Area)
+	return patch
 }
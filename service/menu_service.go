@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/repository"
@@ -13,10 +12,10 @@ import (
 )
 
 type MenuServiceImpl interface {
-	CreateMenuService(ctx context.Context, input *model.Menu, username string) error
+	CreateMenuService(ctx context.Context, input *model.Menu, username string) (*model.Menu, error)
 	GetMenuService(ctx context.Context, id uuid.UUID) (*model.MenuRes, error)
-	UpdateMenuService(ctx context.Context, data *model.Menu, username string) error
-	DeleteMenuService(ctx context.Context, menuID uuid.UUID) error
+	UpdateMenuService(ctx context.Context, username string, menuID uuid.UUID, data *model.UpdateMenuReq) (*model.MenuRes, error)
+	DeleteMenuService(ctx context.Context, username string, menuID uuid.UUID) error
 }
 type MenuService struct {
 	repo repository.MenuRepoImpl
@@ -30,28 +29,29 @@ func NewMenuService(repo repository.MenuRepoImpl, zap *zap.Logger) *MenuService
 	}
 }
 
-func (ms *MenuService) CreateMenuService(ctx context.Context, input *model.Menu, username string) error {
+func (ms *MenuService) CreateMenuService(ctx context.Context, input *model.Menu, username string) (*model.Menu, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ms.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
 	}
 	if ctxValue.Username != username {
-		ms.zap.Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
-		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 	if ctxValue.Role != "merchant" {
-		ms.zap.Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%w: role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
 	}
 	merchantID, err := ms.repo.GetMerchantID(ctx, ctxValue.UserID)
 	if err != nil {
-		ms.zap.Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
-		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 
 	newMenu := model.Menu{
 		MenuID:      uuid.New(),
+		Name:        input.Name,
 		Price:       input.Price,
 		Description: input.Description,
 		Category:    input.Category,
@@ -59,86 +59,92 @@ func (ms *MenuService) CreateMenuService(ctx context.Context, input *model.Menu,
 		Stock:       input.Stock,
 		MerchantID:  merchantID,
 	}
-	return ms.repo.CreateMenuRepo(ctx, &newMenu, ctxValue.UserID)
+	if err := utils.Validate(&newMenu); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if err := ms.repo.CreateMenuRepo(ctx, &newMenu, ctxValue.UserID); err != nil {
+		return nil, err
+	}
+	return &newMenu, nil
 }
 
 func (ms *MenuService) GetMenuService(ctx context.Context, id uuid.UUID) (*model.MenuRes, error) {
 	return ms.repo.GetMenuRepo(ctx, id)
 }
 
-func (ms *MenuService) UpdateMenuService(ctx context.Context, data *model.Menu, username string) error {
+func (ms *MenuService) UpdateMenuService(ctx context.Context, username string, menuID uuid.UUID, data *model.UpdateMenuReq) (*model.MenuRes, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ms.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
 	}
 	if ctxValue.Username != username {
-		ms.zap.Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
-		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 	if ctxValue.Role != "merchant" {
-		ms.zap.Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%w: role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
 	}
 	merchantID, err := ms.repo.GetMerchantID(ctx, ctxValue.UserID)
 	if err != nil {
-		ms.zap.Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
-		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	if err := utils.Validate(data); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
 	}
-	query, args := updateMenuBuilder(data, merchantID)
-	return ms.repo.UpdateMenuRepo(ctx, query, args)
+	patch := menuPatch(data)
+	if err := patch.Err(); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if patch.Empty() {
+		return nil, fmt.Errorf("no fields to update: %w", utils.ErrBadRequest)
+	}
+	return ms.repo.UpdateMenuRepo(ctx, menuID, merchantID, patch)
 }
 
-func (ms *MenuService) DeleteMenuService(ctx context.Context, menuID uuid.UUID) error {
+func (ms *MenuService) DeleteMenuService(ctx context.Context, username string, menuID uuid.UUID) error {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ms.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
 		return fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
 	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
 	if ctxValue.Role != "merchant" {
-		ms.zap.Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%w: role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
 	}
 	merchantID, err := ms.repo.GetMerchantID(ctx, ctxValue.UserID)
 	if err != nil {
-		ms.zap.Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", "invalid merchant and user id"))
 		return fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 	return ms.repo.DeleteMenuRepo(ctx, menuID, merchantID)
 }
 
-func updateMenuBuilder(updated *model.Menu, merchantID uuid.UUID) (string, []interface{}) {
-	fields := []string{}
-	argsIndex := 1
-	args := []interface{}{}
-
-	if updated.Name != "" {
-		fields = append(fields, fmt.Sprintf("name = $%d", argsIndex))
-		args = append(args, updated.Name)
-		argsIndex++
-	}
-	if updated.Price != 0 {
-		fields = append(fields, fmt.Sprintf("price = $%d", argsIndex))
-		args = append(args, updated.Price)
-		argsIndex++
-	}
-	if updated.Category != "" {
-		fields = append(fields, fmt.Sprintf("category = $%d", argsIndex))
-		args = append(args, updated.Category)
-		argsIndex++
-	}
-	if updated.Description != "" {
-		fields = append(fields, fmt.Sprintf("description = $%d", argsIndex))
-		args = append(args, updated.Description)
-		argsIndex++
-	}
-	if updated.Stock != 0 {
-		fields = append(fields, fmt.Sprintf("stock = $%d", argsIndex))
-		args = append(args, updated.Stock)
-		argsIndex++
-	}
+// menuColumns lists the menu columns a PATCH may write and whether they can
+// be cleared with null.
+var menuColumns = map[string]bool{
+	"name":        false,
+	"price":       false,
+	"description": true,
+	"category":    false,
+	"stock":       false,
+}
 
-	args = append(args, merchantID)
-	updatedQuery := fmt.Sprintf("%s WHERE merchant_id = $%d", strings.Join(fields, ", "), argsIndex)
-	return updatedQuery, args
+func menuPatch(data *model.UpdateMenuReq) *utils.Patch {
+	patch := utils.NewPatch(menuColumns)
+	utils.PatchField(patch, "name", data.Name)
+	utils.PatchField(patch, "price", data.Price)
+	utils.PatchField(patch, "description", data.Description)
+	utils.PatchField(patch, "category", data.Category)
+	utils.PatchField(patch, "stock", data.Stock)
+	return patch
 }
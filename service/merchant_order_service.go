@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const autoRejectBatchSize = 100
+
+type MerchantOrderServiceImpl interface {
+	ListMerchantOrdersService(ctx context.Context, username string, filter *model.OrderFilter) ([]model.Order, error)
+	AcceptOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.AcceptOrderReq) (*model.Order, error)
+	RejectOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.RejectOrderReq) (*model.Order, error)
+	MarkOrderReadyService(ctx context.Context, username string, orderID uuid.UUID) (*model.Order, error)
+	MarkItemUnavailableService(ctx context.Context, username string, orderID, itemID uuid.UUID, input *model.ItemUnavailableReq) (*model.Order, error)
+	CancelOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.MerchantCancelReq) (*model.Order, error)
+}
+type MerchantOrderService struct {
+	repo      repository.OrderRepoImpl
+	merchants repository.MerchantRepoImpl
+	zap       *zap.Logger
+}
+
+// NewMerchantOrderService returns the service merchants work their order
+// queue through.
+func NewMerchantOrderService(repo repository.OrderRepoImpl, merchants repository.MerchantRepoImpl, zap *zap.Logger) *MerchantOrderService {
+	return &MerchantOrderService{
+		repo:      repo,
+		merchants: merchants,
+		zap:       zap,
+	}
+}
+
+// ListMerchantOrdersService returns the merchant's orders, newest first,
+// optionally only those in the given statuses.
+func (mos *MerchantOrderService) ListMerchantOrdersService(ctx context.Context, username string, filter *model.OrderFilter) ([]model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(filter); err != nil {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return mos.repo.ListMerchantOrdersRepo(ctx, merchant.MerchantID, filter)
+}
+
+// AcceptOrderService accepts a placed order and tells the customer how long
+// it will take to prepare.
+func (mos *MerchantOrderService) AcceptOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.AcceptOrderReq) (*model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if err := mos.repo.AcceptOrderRepo(ctx, merchant.MerchantID, orderID, input.PrepMinutes, time.Now()); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusAccepted).Inc()
+	utils.Logger(ctx, mos.zap).Info("order accepted", zap.String("order_id", orderID.String()), zap.Int("prep_minutes", input.PrepMinutes))
+	return mos.repo.GetOrderRepo(ctx, orderID)
+}
+
+// RejectOrderService rejects a placed order and refunds the customer.
+func (mos *MerchantOrderService) RejectOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.RejectOrderReq) (*model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if err := mos.repo.RejectOrderRepo(ctx, merchant.MerchantID, orderID, input.Reason, time.Now()); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusRejected).Inc()
+	utils.Logger(ctx, mos.zap).Info("order rejected", zap.String("order_id", orderID.String()), zap.String("reason", input.Reason))
+	return mos.repo.GetOrderRepo(ctx, orderID)
+}
+
+// MarkOrderReadyService marks an accepted order ready for pickup. It fails
+// while the customer still has a substitution to answer.
+func (mos *MerchantOrderService) MarkOrderReadyService(ctx context.Context, username string, orderID uuid.UUID) (*model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := mos.repo.MarkOrderReadyRepo(ctx, merchant.MerchantID, orderID, time.Now()); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusReady).Inc()
+	utils.Logger(ctx, mos.zap).Info("order ready", zap.String("order_id", orderID.String()))
+	return mos.repo.GetOrderRepo(ctx, orderID)
+}
+
+// MarkItemUnavailableService takes an item off an order. With a substitute
+// the customer is asked to accept it; without one the item is refunded at
+// once, and an order left with nothing is rejected.
+func (mos *MerchantOrderService) MarkItemUnavailableService(ctx context.Context, username string, orderID, itemID uuid.UUID, input *model.ItemUnavailableReq) (*model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := mos.repo.MarkItemUnavailableRepo(ctx, merchant.MerchantID, orderID, itemID, input.SubstituteMenuID, time.Now()); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, mos.zap).Info("order item unavailable", zap.String("order_id", orderID.String()),
+		zap.String("order_item_id", itemID.String()), zap.Bool("substitute", input.SubstituteMenuID != nil))
+	return getOrderAfterItemChange(ctx, mos.repo, orderID)
+}
+
+// CancelOrderService cancels an order the merchant has already accepted and
+// refunds the customer in full. A placed order is rejected instead.
+func (mos *MerchantOrderService) CancelOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.MerchantCancelReq) (*model.Order, error) {
+	merchant, err := mos.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	cancellation := model.OrderCancellation{
+		OrderID: orderID,
+		By:      model.CancelledByMerchant,
+		ActorID: merchant.UserID,
+		Reason:  input.Reason,
+		Note:    input.Note,
+		At:      time.Now(),
+	}
+	if err := mos.repo.CancelOrderRepo(ctx, &cancellation); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusCancelled).Inc()
+	utils.Logger(ctx, mos.zap).Info("order cancelled", zap.String("order_id", orderID.String()),
+		zap.String("by", model.CancelledByMerchant), zap.String("reason", input.Reason))
+	return mos.repo.GetOrderRepo(ctx, orderID)
+}
+
+// checkOwner lets only the merchant account named username through and
+// returns its merchant.
+func (mos *MerchantOrderService) checkOwner(ctx context.Context, username string) (*model.Merchant, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, mos.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	if ctxValue.Role != "merchant" {
+		utils.Logger(ctx, mos.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	return mos.merchants.GetMerchantRepo(ctx, username)
+}
+
+// AutoRejectOrdersJob periodically rejects placed orders their merchant did
+// not answer in time.
+type AutoRejectOrdersJob struct{}
+
+func (AutoRejectOrdersJob) Kind() string { return "auto_reject_orders" }
+
+// AutoReject rejects and refunds every placed order past its response
+// deadline. Like ReleaseScheduled it is safe to run concurrently and to
+// rerun: an order is rejected at most once.
+func (mos *MerchantOrderService) AutoReject(ctx context.Context, _ AutoRejectOrdersJob) error {
+	now := time.Now()
+	var total int64
+	for {
+		n, err := mos.repo.AutoRejectOrdersRepo(ctx, now, model.RejectReasonNoResponse, autoRejectBatchSize)
+		if err != nil {
+			return err
+		}
+		metrics.Orders.WithLabelValues(model.OrderStatusRejected).Add(float64(n))
+		total += n
+		if n < autoRejectBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		utils.Logger(ctx, mos.zap).Info("Rejected unanswered orders", zap.Int64("count", total))
+	}
+	return nil
+}
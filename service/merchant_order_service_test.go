@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository/memory"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestMerchantOrderTransitions(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	ctx := f.customer(t, "bob", 100000)
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ctx := range map[string]context.Context{"customer": ctx, "another merchant": signIn(t, f.users, "carol", "merchant")} {
+		if _, err := merchantOrders.AcceptOrderService(ctx, "alice", order.OrderID, &model.AcceptOrderReq{PrepMinutes: 10}); !errors.Is(err, utils.ErrForbidden) {
+			t.Errorf("%s accepting = %v, want ErrForbidden", name, err)
+		}
+	}
+	if _, err := merchantOrders.AcceptOrderService(f.owner, "alice", order.OrderID, &model.AcceptOrderReq{}); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("accepting without a prep time = %v, want ErrBadRequest", err)
+	}
+	if _, err := merchantOrders.MarkOrderReadyService(f.owner, "alice", order.OrderID); !errors.Is(err, utils.ErrOrderState) {
+		t.Errorf("ready before accepting = %v, want ErrOrderState", err)
+	}
+	got, err := merchantOrders.AcceptOrderService(f.owner, "alice", order.OrderID, &model.AcceptOrderReq{PrepMinutes: 25})
+	if err != nil || got.Status != model.OrderStatusAccepted || got.PrepMinutes != 25 {
+		t.Fatalf("AcceptOrderService = %+v, %v", got, err)
+	}
+	if _, err := merchantOrders.RejectOrderService(f.owner, "alice", order.OrderID, &model.RejectOrderReq{Reason: "too busy"}); !errors.Is(err, utils.ErrOrderState) {
+		t.Errorf("rejecting an accepted order = %v, want ErrOrderState", err)
+	}
+	if got, err := merchantOrders.MarkOrderReadyService(f.owner, "alice", order.OrderID); err != nil || got.Status != model.OrderStatusReady {
+		t.Errorf("MarkOrderReadyService = %+v, %v", got, err)
+	}
+
+	rejected, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merchantOrders.RejectOrderService(f.owner, "alice", rejected.OrderID, &model.RejectOrderReq{}); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("rejecting without a reason = %v, want ErrBadRequest", err)
+	}
+	got, err = merchantOrders.RejectOrderService(f.owner, "alice", rejected.OrderID, &model.RejectOrderReq{Reason: "too busy"})
+	if err != nil || got.Status != model.OrderStatusRejected || got.Refunded != got.Total {
+		t.Errorf("RejectOrderService = %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{"", 2},
+		{model.OrderStatusReady, 1},
+		{model.OrderStatusPlaced, 0},
+	}
+	for _, tt := range tests {
+		filter := &model.OrderFilter{}
+		if tt.status != "" {
+			filter.Statuses = []string{tt.status}
+		}
+		if got, err := merchantOrders.ListMerchantOrdersService(f.owner, "alice", filter); err != nil || len(got) != tt.want {
+			t.Errorf("orders with status %q = %d, %v; want %d", tt.status, len(got), err, tt.want)
+		}
+	}
+	if _, err := merchantOrders.ListMerchantOrdersService(f.owner, "alice", &model.OrderFilter{Statuses: []string{"cooking"}}); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("unknown status filter = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestItemSubstitution(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	ctx := f.customer(t, "bob", 100000)
+	owner, _ := utils.CheckContextValue(f.owner)
+	cheaper := &model.Menu{MenuID: uuid.New(), Name: "Nasi Putih", Price: 7000, Category: "rice", Stock: 10, MerchantID: f.menu.MerchantID}
+	if err := memory.NewMenuRepo(f.db).CreateMenuRepo(context.Background(), cheaper, owner.UserID); err != nil {
+		t.Fatal(err)
+	}
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID := order.Items[0].OrderItemID
+
+	got, err := merchantOrders.MarkItemUnavailableService(f.owner, "alice", order.OrderID, itemID, &model.ItemUnavailableReq{SubstituteMenuID: &cheaper.MenuID})
+	if err != nil || got.Items[0].Status != model.ItemStatusSubstitutionOffered || got.Items[0].Substitute.Price != 7000 {
+		t.Fatalf("MarkItemUnavailableService = %+v, %v", got, err)
+	}
+	accept := true
+	if _, err := f.service.RespondSubstitutionService(ctx, order.OrderID, itemID, &model.SubstitutionReply{}); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("reply without an answer = %v, want ErrBadRequest", err)
+	}
+	if _, err := f.service.RespondSubstitutionService(f.owner, order.OrderID, itemID, &model.SubstitutionReply{Accept: &accept}); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("merchant answering = %v, want ErrForbidden", err)
+	}
+	if _, err := f.service.RespondSubstitutionService(f.customer(t, "carol", 0), order.OrderID, itemID, &model.SubstitutionReply{Accept: &accept}); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("another customer answering = %v, want ErrNotFound", err)
+	}
+	got, err = f.service.RespondSubstitutionService(ctx, order.OrderID, itemID, &model.SubstitutionReply{Accept: &accept})
+	if err != nil || got.Items[0].Status != model.ItemStatusSubstituted || got.Refunded != 2*(10000-7000) {
+		t.Errorf("RespondSubstitutionService = %+v, %v", got, err)
+	}
+}
+
+func TestAutoReject(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	customer, _ := utils.CheckContextValue(f.customer(t, "bob", 10000*(autoRejectBatchSize+10)))
+	schedule, err := f.merchants.GetMerchantScheduleRepo(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More orders are overdue than fit in one batch; one still has time.
+	now := time.Now()
+	for i := 0; i <= autoRejectBatchSize+1; i++ {
+		respondBy := now.Add(-time.Duration(i) * time.Second)
+		if i == autoRejectBatchSize+1 {
+			respondBy = now.Add(testAcceptWindow)
+		}
+		order := &model.Order{
+			OrderID:    uuid.New(),
+			CustomerID: customer.UserID,
+			MerchantID: schedule.MerchantID,
+			Status:     model.OrderStatusPlaced,
+			Items:      []model.OrderItem{{OrderItemID: uuid.New(), MenuID: f.menu.MenuID, Quantity: 1}},
+			PlacedAt:   &now,
+			RespondBy:  &respondBy,
+			CreatedAt:  now,
+		}
+		if err := f.orders.CreateOrderRepo(context.Background(), order, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := merchantOrders.AutoReject(context.Background(), AutoRejectOrdersJob{}); err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := f.orders.ListMerchantOrdersRepo(context.Background(), schedule.MerchantID, &model.OrderFilter{Statuses: []string{model.OrderStatusRejected}, Limit: maxListLimit * 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != autoRejectBatchSize+1 || rejected[0].RejectReason != model.RejectReasonNoResponse {
+		t.Errorf("rejected %d orders, want every overdue one", len(rejected))
+	}
+	if n, err := f.orders.AutoRejectOrdersRepo(context.Background(), now.Add(testAcceptWindow), model.RejectReasonNoResponse, autoRejectBatchSize); err != nil || n != 1 {
+		t.Errorf("orders rejected once overdue = %d, %v; want only the one with time left", n, err)
+	}
+}
+
+func TestMerchantCancelOrder(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	ctx := f.customer(t, "bob", 100000)
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reason := &model.MerchantCancelReq{Reason: "kitchen_problem"}
+	if _, err := merchantOrders.CancelOrderService(f.owner, "alice", order.OrderID, reason); !errors.Is(err, utils.ErrOrderState) {
+		t.Errorf("cancelling a placed order = %v, want ErrOrderState", err)
+	}
+	if _, err := merchantOrders.AcceptOrderService(f.owner, "alice", order.OrderID, &model.AcceptOrderReq{PrepMinutes: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []*model.MerchantCancelReq{{}, {Reason: "bored"}, {Reason: "other"}} {
+		if _, err := merchantOrders.CancelOrderService(f.owner, "alice", order.OrderID, input); !errors.Is(err, utils.ErrBadRequest) {
+			t.Errorf("cancelling with %+v = %v, want ErrBadRequest", input, err)
+		}
+	}
+	if _, err := merchantOrders.CancelOrderService(ctx, "alice", order.OrderID, reason); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("customer cancelling as the merchant = %v, want ErrForbidden", err)
+	}
+	got, err := merchantOrders.CancelOrderService(f.owner, "alice", order.OrderID, &model.MerchantCancelReq{Reason: "other", Note: "power cut"})
+	if err != nil || got.Status != model.OrderStatusCancelled || got.CancelledBy != model.CancelledByMerchant ||
+		got.CancelNote != "power cut" || got.CancelFee != 0 || got.Refunded != got.Total {
+		t.Errorf("CancelOrderService = %+v, %v", got, err)
+	}
+}
+
+func TestOrderMetrics(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	ctx := f.customer(t, "bob", 100000)
+	counts := func() map[string]float64 {
+		res := map[string]float64{}
+		for _, status := range model.OrderStatuses {
+			res[status] = testutil.ToFloat64(metrics.Orders.WithLabelValues(status))
+		}
+		return res
+	}
+	before := counts()
+	place := func() *model.Order {
+		t.Helper()
+		order, err := f.service.CreateOrderService(ctx, f.request(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+
+	ready := place()
+	if _, err := merchantOrders.AcceptOrderService(f.owner, "alice", ready.OrderID, &model.AcceptOrderReq{PrepMinutes: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merchantOrders.MarkOrderReadyService(f.owner, "alice", ready.OrderID); err != nil {
+		t.Fatal(err)
+	}
+	// Failed transitions are not counted.
+	if _, err := merchantOrders.MarkOrderReadyService(f.owner, "alice", ready.OrderID); !errors.Is(err, utils.ErrOrderState) {
+		t.Fatalf("marking ready twice = %v", err)
+	}
+	if _, err := merchantOrders.RejectOrderService(f.owner, "alice", place().OrderID, &model.RejectOrderReq{Reason: "too busy"}); err != nil {
+		t.Fatal(err)
+	}
+	emptied := place()
+	if _, err := merchantOrders.MarkItemUnavailableService(f.owner, "alice", emptied.OrderID, emptied.Items[0].OrderItemID, &model.ItemUnavailableReq{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.CancelOrderService(ctx, place().OrderID); err != nil {
+		t.Fatal(err)
+	}
+
+	after := counts()
+	want := map[string]float64{
+		model.OrderStatusPlaced:    4,
+		model.OrderStatusAccepted:  1,
+		model.OrderStatusReady:     1,
+		model.OrderStatusRejected:  2,
+		model.OrderStatusCancelled: 1,
+	}
+	for _, status := range model.OrderStatuses {
+		if got := after[status] - before[status]; got != want[status] {
+			t.Errorf("orders_total{status=%q} grew by %v, want %v", status, got, want[status])
+		}
+	}
+}
@@ -3,7 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/repository"
@@ -13,9 +13,12 @@ import (
 )
 
 type MerchantServiceImpl interface {
-	CreateMerchantService(ctx context.Context, new *model.Merchant) error
-	GetMerchantService(ctx context.Context, username string) (*model.MerchantRes, error)
-	UpdateMerchantService(ctx context.Context, update *model.Merchant) error
+	CreateMerchantService(ctx context.Context, new *model.Merchant) (*model.Merchant, error)
+	GetMerchantService(ctx context.Context, username string) (*model.Merchant, error)
+	UpdateMerchantService(ctx context.Context, username string, update *model.UpdateMerchantReq) (*model.Merchant, error)
+	GetMerchantApplicationService(ctx context.Context, username string) (*model.Application, error)
+	GetMerchantScheduleService(ctx context.Context, username string) (*model.MerchantSchedule, error)
+	SetMerchantScheduleService(ctx context.Context, username string, input *model.MerchantSchedule) (*model.MerchantSchedule, error)
 }
 type MerchantService struct {
 	repo repository.MerchantRepoImpl
@@ -29,15 +32,15 @@ func NewMerchantService(repo repository.MerchantRepoImpl, zap *zap.Logger) *Merc
 	}
 }
 
-func (ms *MerchantService) CreateMerchantService(ctx context.Context, new *model.Merchant) error {
+func (ms *MerchantService) CreateMerchantService(ctx context.Context, new *model.Merchant) (*model.Merchant, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ms.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("%w", err)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
 	}
 	if ctxValue.Role != "merchant" {
-		ms.zap.Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%w: role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
 	}
 	newMerchant := model.Merchant{
 		MerchantID:  uuid.New(),
@@ -48,58 +51,119 @@ func (ms *MerchantService) CreateMerchantService(ctx context.Context, new *model
 		Description: new.Description,
 		UserID:      ctxValue.UserID,
 		Owner:       ctxValue.Username,
+		Status:      model.ReviewStatusPending,
 	}
-	if err := utils.ValidateMerchant(&newMerchant); err != nil {
-		ms.zap.Error(utils.ErrBadRequest.Error(), zap.Error(err))
-		return fmt.Errorf("%w", err)
+	if err := utils.Validate(&newMerchant); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
 	}
-	return ms.repo.CreateMerchantRepo(ctx, &newMerchant)
+	if err := ms.repo.CreateMerchantRepo(ctx, &newMerchant); err != nil {
+		return nil, err
+	}
+	return &newMerchant, nil
 }
 
-func (ms *MerchantService) GetMerchantService(ctx context.Context, username string) (*model.MerchantRes, error) {
+func (ms *MerchantService) GetMerchantService(ctx context.Context, username string) (*model.Merchant, error) {
 	return ms.repo.GetMerchantRepo(ctx, username)
 }
 
-func (ms *MerchantService) UpdateMerchantService(ctx context.Context, update *model.Merchant) error {
+func (ms *MerchantService) UpdateMerchantService(ctx context.Context, username string, update *model.UpdateMerchantReq) (*model.Merchant, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w", err)
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	if ctxValue.Role != "merchant" {
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(update); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	patch := merchantPatch(update)
+	if err := patch.Err(); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if patch.Empty() {
+		return nil, fmt.Errorf("no fields to update: %w", utils.ErrBadRequest)
+	}
+	return ms.repo.UpdateMerchantRepo(ctx, ctxValue.UserID, patch)
+}
+
+func (ms *MerchantService) GetMerchantApplicationService(ctx context.Context, username string) (*model.Application, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Username != username && ctxValue.Role != "admin" {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	return ms.repo.GetMerchantApplicationRepo(ctx, username)
+}
+
+func (ms *MerchantService) GetMerchantScheduleService(ctx context.Context, username string) (*model.MerchantSchedule, error) {
+	return ms.repo.GetMerchantScheduleRepo(ctx, username)
+}
+
+// SetMerchantScheduleService replaces the caller's opening hours, time zone
+// and slot capacity. Orders already taken keep their slots.
+func (ms *MerchantService) SetMerchantScheduleService(ctx context.Context, username string, input *model.MerchantSchedule) (*model.MerchantSchedule, error) {
 	ctxValue, err := utils.CheckContextValue(ctx)
 	if err != nil {
-		ms.zap.Error(utils.ErrUnauthorized.Error(), zap.Error(err))
-		return fmt.Errorf("%w", err)
+		utils.Logger(ctx, ms.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
 	}
 	if ctxValue.Role != "merchant" {
-		ms.zap.Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
-		return fmt.Errorf("%w: user role %s is not allowed", utils.ErrUnauthorized, ctxValue.Role)
+		utils.Logger(ctx, ms.zap).Error("invalid role", zap.String("needed", "merchant"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if input.Hours == nil {
+		input.Hours = []model.OpeningHours{}
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ms.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
 	}
-	query, args := updateMerchantQueryBuilder(update)
-	return ms.repo.UpdateMerchantRepo(ctx, query, args)
+	for _, h := range input.Hours {
+		// Both are zero-padded HH:MM, so they compare as strings.
+		if h.Opens >= h.Closes {
+			return nil, fmt.Errorf("weekday %d closes before it opens: %w", h.Weekday, utils.ErrBadRequest)
+		}
+	}
+	sort.Slice(input.Hours, func(i, j int) bool { return input.Hours[i].Weekday < input.Hours[j].Weekday })
+	if err := ms.repo.SetMerchantScheduleRepo(ctx, ctxValue.UserID, input); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, ms.zap).Info("merchant schedule updated", zap.Int("slot_capacity", input.SlotCapacity), zap.Int("days", len(input.Hours)))
+	return input, nil
 }
 
-func updateMerchantQueryBuilder(updated *model.Merchant) (string, []interface{}) {
-	fields := []string{}
-	argsIndex := 1
-	args := []interface{}{}
+// merchantColumns lists the merchant columns a PATCH may write and whether
+// they can be cleared with null.
+var merchantColumns = map[string]bool{
+	"name":        false,
+	"address":     true,
+	"category":    true,
+	"description": true,
+}
 
-	if updated.Name != "" {
-		fields = append(fields, fmt.Sprintf("name = $%d", argsIndex))
-		args = append(args, updated.Name)
-		argsIndex++
-	}
-	if updated.Address != "" {
-		fields = append(fields, fmt.Sprintf("address = $%d", argsIndex))
-		args = append(args, updated.Address)
-		argsIndex++
-	}
-	if updated.Category != "" {
-		fields = append(fields, fmt.Sprintf("category = $%d", argsIndex))
-		args = append(args, updated.Category)
-		argsIndex++
-	}
-	if updated.Description != "" {
-		fields = append(fields, fmt.Sprintf("description = $%d", argsIndex))
-		args = append(args, updated.Description)
-		argsIndex++
-	}
-	args = append(args, updated.UserID)
-	updatedQuery := fmt.Sprintf("%s WHERE user_id = $%d", strings.Join(fields, ", "), argsIndex)
-	return updatedQuery, args
+func merchantPatch(update *model.UpdateMerchantReq) *utils.Patch {
+	patch := utils.NewPatch(merchantColumns)
+	utils.PatchField(patch, "name", update.Name)
+	utils.PatchField(patch, "address", update.Address)
+	utils.PatchField(patch, "category", update.Category)
+	utils.PatchField(patch, "description", update.Description)
+	return patch
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository/memory"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// signIn registers username with role and returns a context authenticated
+// as that user.
+func signIn(t *testing.T, users *memory.UserRepo, username, role string) context.Context {
+	t.Helper()
+	user := &model.User{UserID: uuid.New(), Username: username, Email: username + "@example.com", Role: role, Name: username}
+	if err := users.RegisterUserRepo(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return utils.WithContextValues(context.Background(), utils.ContextValues{UserID: user.UserID, Username: username, Role: role})
+}
+
+func TestCreateMerchantService(t *testing.T) {
+	db := memory.NewDB()
+	users := memory.NewUserRepo(db)
+	repo := memory.NewMerchantRepo(db)
+	ms := NewMerchantService(repo, zap.NewNop())
+	ctx := signIn(t, users, "alice", "merchant")
+	owner, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := model.Merchant{
+		// Fields the caller must not control are ignored.
+		MerchantID:  uuid.New(),
+		Rating:      5,
+		UserID:      uuid.New(),
+		Owner:       "mallory",
+		Status:      model.ReviewStatusApproved,
+		Name:        "Warung Alice",
+		Address:     "Jl. Merdeka 1",
+		Category:    "rice",
+		Description: "Nasi goreng and friends",
+	}
+
+	got, err := ms.CreateMerchantService(ctx, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MerchantID == input.MerchantID || got.Rating != 0 || got.UserID != owner.UserID ||
+		got.Owner != "alice" || got.Status != model.ReviewStatusPending || got.Name != input.Name {
+		t.Errorf("CreateMerchantService = %+v", got)
+	}
+	app, err := repo.GetMerchantApplicationRepo(context.Background(), "alice")
+	if err != nil || app.Status != model.ReviewStatusPending {
+		t.Errorf("stored application = %+v, %v", app, err)
+	}
+	// Pending merchants stay out of public view.
+	if _, err := repo.GetMerchantRepo(context.Background(), "alice"); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("public lookup of a pending merchant = %v, want ErrNotFound", err)
+	}
+
+	_, err = ms.CreateMerchantService(ctx, &input)
+	if !errors.Is(err, utils.ErrUniqueConstraint) {
+		t.Errorf("second merchant for the same owner = %v, want ErrUniqueConstraint", err)
+	}
+
+	customer := signIn(t, users, "bob", "customer")
+	if _, err := ms.CreateMerchantService(customer, &input); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("customer creating a merchant = %v, want ErrForbidden", err)
+	}
+	if _, err := ms.CreateMerchantService(context.Background(), &input); !errors.Is(err, utils.ErrUnauthorized) {
+		t.Errorf("unauthenticated create = %v, want ErrUnauthorized", err)
+	}
+
+	carol := signIn(t, users, "carol", "merchant")
+	incomplete := input
+	incomplete.Address = ""
+	if _, err := ms.CreateMerchantService(carol, &incomplete); !errors.Is(err, utils.ErrValidation) {
+		t.Errorf("merchant without an address = %v, want ErrValidation", err)
+	}
+	if _, err := repo.GetMerchantApplicationRepo(context.Background(), "carol"); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("invalid merchant was stored: %v", err)
+	}
+}
+
+func TestSetMerchantScheduleService(t *testing.T) {
+	db := memory.NewDB()
+	users := memory.NewUserRepo(db)
+	repo := memory.NewMerchantRepo(db)
+	ms := NewMerchantService(repo, zap.NewNop())
+	ctx := signIn(t, users, "alice", "merchant")
+	owner, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merchant := &model.Merchant{MerchantID: uuid.New(), Name: "Warung Alice", UserID: owner.UserID, Owner: "alice", Status: model.ReviewStatusApproved}
+	if err := repo.CreateMerchantRepo(context.Background(), merchant); err != nil {
+		t.Fatal(err)
+	}
+
+	input := model.MerchantSchedule{
+		Timezone:     "Asia/Makassar",
+		SlotCapacity: 8,
+		Hours: []model.OpeningHours{
+			{Weekday: 5, Opens: "10:00", Closes: "20:00"},
+			{Weekday: 1, Opens: "08:00", Closes: "17:30"},
+		},
+	}
+	got, err := ms.SetMerchantScheduleService(ctx, "alice", &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timezone != "Asia/Makassar" || got.SlotCapacity != 8 || len(got.Hours) != 2 || got.Hours[0].Weekday != 1 {
+		t.Errorf("SetMerchantScheduleService = %+v", got)
+	}
+	stored, err := ms.GetMerchantScheduleService(context.Background(), "alice")
+	if err != nil || stored.SlotCapacity != 8 || len(stored.Hours) != 2 {
+		t.Errorf("stored schedule = %+v, %v", stored, err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(s *model.MerchantSchedule)
+	}{
+		{"unknown time zone", func(s *model.MerchantSchedule) { s.Timezone = "Mars/Olympus" }},
+		{"negative capacity", func(s *model.MerchantSchedule) { s.SlotCapacity = -1 }},
+		{"bad weekday", func(s *model.MerchantSchedule) { s.Hours[0].Weekday = 7 }},
+		{"bad time", func(s *model.MerchantSchedule) { s.Hours[0].Opens = "8am" }},
+		{"closes before opening", func(s *model.MerchantSchedule) { s.Hours[0].Closes = "07:00" }},
+		{"weekday twice", func(s *model.MerchantSchedule) { s.Hours[1].Weekday = s.Hours[0].Weekday }},
+	}
+	for _, tt := range tests {
+		bad := model.MerchantSchedule{Timezone: "Asia/Jakarta", Hours: []model.OpeningHours{
+			{Weekday: 1, Opens: "08:00", Closes: "17:30"},
+			{Weekday: 2, Opens: "08:00", Closes: "17:30"},
+		}}
+		tt.mutate(&bad)
+		if _, err := ms.SetMerchantScheduleService(ctx, "alice", &bad); !errors.Is(err, utils.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", tt.name, err)
+		}
+	}
+
+	bob := signIn(t, users, "bob", "merchant")
+	if _, err := ms.SetMerchantScheduleService(bob, "alice", &input); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("another merchant = %v, want ErrForbidden", err)
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	// Merchant time zones are checked and loaded by name, which must not
+	// depend on the zone database of the host.
+	_ "time/tzdata"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// OrderSlotLength is the width of a delivery slot; a pre-order is booked
+	// into the slot containing the time asked for.
+	OrderSlotLength = 15 * time.Minute
+	// MaxScheduleAhead is how far ahead a delivery can be booked.
+	MaxScheduleAhead = 7 * 24 * time.Hour
+	releaseBatchSize = 100
+)
+
+type OrderServiceImpl interface {
+	CreateOrderService(ctx context.Context, input *model.CreateOrderReq) (*model.Order, error)
+	GetOrderService(ctx context.Context, orderID uuid.UUID) (*model.Order, error)
+	RespondSubstitutionService(ctx context.Context, orderID, itemID uuid.UUID, input *model.SubstitutionReply) (*model.Order, error)
+	CancelOrderService(ctx context.Context, orderID uuid.UUID) (*model.Order, error)
+	DriverCancelOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.DriverCancelReq) (*model.Order, error)
+	OpenDisputeService(ctx context.Context, orderID uuid.UUID, input *model.OpenDisputeReq) (*model.Dispute, error)
+}
+type OrderService struct {
+	repo         repository.OrderRepoImpl
+	merchants    repository.MerchantRepoImpl
+	releaseLead  time.Duration
+	acceptWindow time.Duration
+	cancelFee    int64
+	zap          *zap.Logger
+}
+
+// NewOrderService returns an order service that hands pre-orders to the
+// merchant releaseLead before their delivery slot, gives the merchant
+// acceptWindow to answer each order it receives and keeps cancelFee of an
+// order its customer cancels after the merchant accepted it.
+func NewOrderService(repo repository.OrderRepoImpl, merchants repository.MerchantRepoImpl, releaseLead, acceptWindow time.Duration, cancelFee int64, zap *zap.Logger) *OrderService {
+	return &OrderService{
+		repo:         repo,
+		merchants:    merchants,
+		releaseLead:  releaseLead,
+		acceptWindow: acceptWindow,
+		cancelFee:    cancelFee,
+		zap:          zap,
+	}
+}
+
+// CreateOrderService places an order for the caller, paid from their
+// wallet. Without a scheduled time it goes to the merchant at once and the
+// merchant must be open now; otherwise the slot must be open, at least the
+// release lead away and not yet full.
+func (ors *OrderService) CreateOrderService(ctx context.Context, input *model.CreateOrderReq) (*model.Order, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	if ctxValue.Role != "user" {
+		utils.Logger(ctx, ors.zap).Error("invalid role", zap.String("needed", "user"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	schedule, err := ors.merchants.GetMerchantScheduleRepo(ctx, input.Merchant)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrInternal.Error(), zap.String("timezone", schedule.Timezone), zap.Error(err))
+		return nil, fmt.Errorf("merchant time zone %q: %w", schedule.Timezone, utils.ErrInternal)
+	}
+
+	now := time.Now()
+	order := model.Order{
+		OrderID:    uuid.New(),
+		CustomerID: ctxValue.UserID,
+		Customer:   ctxValue.Username,
+		MerchantID: schedule.MerchantID,
+		Merchant:   input.Merchant,
+		Items:      make([]model.OrderItem, len(input.Items)),
+		CreatedAt:  now,
+	}
+	for i, item := range input.Items {
+		order.Items[i] = model.OrderItem{
+			OrderItemID: uuid.New(),
+			MenuID:      item.MenuID,
+			Quantity:    item.Quantity,
+		}
+	}
+	if input.ScheduledFor == nil {
+		if !isOpen(schedule, now.In(loc)) {
+			utils.Logger(ctx, ors.zap).Warn(utils.ErrSlotUnavailable.Error(), zap.String("merchant", input.Merchant))
+			return nil, fmt.Errorf("merchant is closed, schedule the order instead: %w", utils.ErrSlotUnavailable)
+		}
+		respondBy := now.Add(ors.acceptWindow)
+		order.Status = model.OrderStatusPlaced
+		order.PlacedAt = &now
+		order.RespondBy = &respondBy
+	} else {
+		slot := input.ScheduledFor.Truncate(OrderSlotLength)
+		switch {
+		case slot.Before(now.Add(ors.releaseLead)):
+			return nil, fmt.Errorf("slot must start at least %s from now: %w", ors.releaseLead, utils.ErrSlotUnavailable)
+		case slot.After(now.Add(MaxScheduleAhead)):
+			return nil, fmt.Errorf("slot must start within %s from now: %w", MaxScheduleAhead, utils.ErrSlotUnavailable)
+		case !isOpen(schedule, slot.In(loc)):
+			return nil, fmt.Errorf("merchant is closed at %s: %w", slot.In(loc).Format("Mon 15:04"), utils.ErrSlotUnavailable)
+		}
+		releaseAt := slot.Add(-ors.releaseLead)
+		order.Status = model.OrderStatusScheduled
+		order.ScheduledFor = &slot
+		order.ReleaseAt = &releaseAt
+	}
+
+	if err := ors.repo.CreateOrderRepo(ctx, &order, schedule.SlotCapacity); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(order.Status).Inc()
+	utils.Logger(ctx, ors.zap).Info("order created", zap.String("order_id", order.OrderID.String()), zap.String("status", order.Status), zap.Int64("total", order.Total))
+	return &order, nil
+}
+
+// isOpen reports whether the merchant takes orders at t, given in the
+// merchant's time zone. A merchant without opening hours is always open.
+func isOpen(schedule *model.MerchantSchedule, t time.Time) bool {
+	if len(schedule.Hours) == 0 {
+		return true
+	}
+	clock := t.Format("15:04")
+	for _, h := range schedule.Hours {
+		if h.Weekday == int(t.Weekday()) {
+			return h.Opens <= clock && clock < h.Closes
+		}
+	}
+	return false
+}
+
+// GetOrderService returns an order to its customer, the merchant it was
+// placed with or an admin. Anyone else is told it does not exist.
+func (ors *OrderService) GetOrderService(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	order, err := ors.repo.GetOrderRepo(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ctxValue.Role == "admin":
+	case ctxValue.Role == "user" && order.Customer == ctxValue.Username:
+	case ctxValue.Role == "merchant" && order.Merchant == ctxValue.Username:
+	default:
+		utils.Logger(ctx, ors.zap).Warn(utils.ErrNotFound.Error(), zap.String("order_id", orderID.String()))
+		return nil, fmt.Errorf("order not found: %w", utils.ErrNotFound)
+	}
+	return order, nil
+}
+
+// RespondSubstitutionService answers a substitute the merchant offered for
+// an item of the caller's order. Accepting never costs more; a cheaper
+// substitute refunds the difference. Declining refunds the item.
+func (ors *OrderService) RespondSubstitutionService(ctx context.Context, orderID, itemID uuid.UUID, input *model.SubstitutionReply) (*model.Order, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	if ctxValue.Role != "user" {
+		utils.Logger(ctx, ors.zap).Error("invalid role", zap.String("needed", "user"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	if err := ors.repo.ResolveSubstitutionRepo(ctx, ctxValue.UserID, orderID, itemID, *input.Accept, time.Now()); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, ors.zap).Info("substitution answered", zap.String("order_id", orderID.String()),
+		zap.String("order_item_id", itemID.String()), zap.Bool("accepted", *input.Accept))
+	return getOrderAfterItemChange(ctx, ors.repo, orderID)
+}
+
+// getOrderAfterItemChange returns the order after one of its items was
+// refunded, counting the order as rejected if that left nothing of it. The
+// change would have failed had the order been rejected already.
+func getOrderAfterItemChange(ctx context.Context, repo repository.OrderRepoImpl, orderID uuid.UUID) (*model.Order, error) {
+	order, err := repo.GetOrderRepo(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == model.OrderStatusRejected {
+		metrics.Orders.WithLabelValues(model.OrderStatusRejected).Inc()
+	}
+	return order, nil
+}
+
+// CancelOrderService cancels the caller's order and refunds it to their
+// wallet. Until the merchant accepts it cancelling is free; after that the
+// cancellation fee is kept.
+func (ors *OrderService) CancelOrderService(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	if ctxValue.Role != "user" {
+		utils.Logger(ctx, ors.zap).Error("invalid role", zap.String("needed", "user"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	cancellation := model.OrderCancellation{
+		OrderID: orderID,
+		By:      model.CancelledByCustomer,
+		ActorID: ctxValue.UserID,
+		Fee:     ors.cancelFee,
+		At:      time.Now(),
+	}
+	if err := ors.repo.CancelOrderRepo(ctx, &cancellation); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusCancelled).Inc()
+	order, err := ors.repo.GetOrderRepo(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, ors.zap).Info("order cancelled", zap.String("order_id", orderID.String()),
+		zap.String("by", model.CancelledByCustomer), zap.Int64("fee", order.CancelFee))
+	return order, nil
+}
+
+// DriverCancelOrderService gives up the delivery of an accepted order
+// assigned to the driver named username. The customer is refunded in full.
+func (ors *OrderService) DriverCancelOrderService(ctx context.Context, username string, orderID uuid.UUID, input *model.DriverCancelReq) (*model.Order, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	if ctxValue.Role != "driver" {
+		utils.Logger(ctx, ors.zap).Error("invalid role", zap.String("needed", "driver"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: user role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	cancellation := model.OrderCancellation{
+		OrderID: orderID,
+		By:      model.CancelledByDriver,
+		ActorID: ctxValue.UserID,
+		Reason:  input.Reason,
+		Note:    input.Note,
+		At:      time.Now(),
+	}
+	if err := ors.repo.CancelOrderRepo(ctx, &cancellation); err != nil {
+		return nil, err
+	}
+	metrics.Orders.WithLabelValues(model.OrderStatusCancelled).Inc()
+	utils.Logger(ctx, ors.zap).Info("order cancelled", zap.String("order_id", orderID.String()),
+		zap.String("by", model.CancelledByDriver), zap.String("reason", input.Reason))
+	return ors.repo.GetOrderRepo(ctx, orderID)
+}
+
+// OpenDisputeService reports missing or wrong items of the caller's ready
+// order for an admin to resolve.
+func (ors *OrderService) OpenDisputeService(ctx context.Context, orderID uuid.UUID, input *model.OpenDisputeReq) (*model.Dispute, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, fmt.Errorf("missing authorization: %w", utils.ErrUnauthorized)
+	}
+	if ctxValue.Role != "user" {
+		utils.Logger(ctx, ors.zap).Error("invalid role", zap.String("needed", "user"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ors.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	dispute := model.Dispute{
+		DisputeID:    uuid.New(),
+		OrderID:      orderID,
+		CustomerID:   ctxValue.UserID,
+		Customer:     ctxValue.Username,
+		Kind:         input.Kind,
+		OrderItemIDs: input.OrderItemIDs,
+		Description:  input.Description,
+		Status:       model.DisputeStatusOpen,
+		CreatedAt:    time.Now(),
+	}
+	if err := ors.repo.CreateDisputeRepo(ctx, ctxValue.UserID, &dispute); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, ors.zap).Info("dispute opened", zap.String("dispute_id", dispute.DisputeID.String()),
+		zap.String("order_id", orderID.String()), zap.String("kind", input.Kind))
+	return &dispute, nil
+}
+
+// ReleaseScheduledOrdersJob periodically hands pre-orders to their merchants
+// once their release time has come.
+type ReleaseScheduledOrdersJob struct{}
+
+func (ReleaseScheduledOrdersJob) Kind() string { return "release_scheduled_orders" }
+
+// ReleaseScheduled places every scheduled order that is due, giving its
+// merchant the accept window from now to answer. It is safe to run on
+// several instances at once and to rerun after a crash: an order leaves the
+// scheduled state exactly once.
+func (ors *OrderService) ReleaseScheduled(ctx context.Context, _ ReleaseScheduledOrdersJob) error {
+	now := time.Now()
+	var total int64
+	for {
+		n, err := ors.repo.ReleaseScheduledOrdersRepo(ctx, now, now.Add(ors.acceptWindow), releaseBatchSize)
+		if err != nil {
+			return err
+		}
+		metrics.Orders.WithLabelValues(model.OrderStatusPlaced).Add(float64(n))
+		total += n
+		if n < releaseBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		utils.Logger(ctx, ors.zap).Info("Released scheduled orders", zap.Int64("count", total))
+	}
+	return nil
+}
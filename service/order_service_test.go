@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository/memory"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	testReleaseLead  = 45 * time.Minute
+	testAcceptWindow = 10 * time.Minute
+	testCancelFee    = 3000
+)
+
+type orderFixture struct {
+	db        *memory.DB
+	users     *memory.UserRepo
+	merchants *memory.MerchantRepo
+	orders    *memory.OrderRepo
+	service   *OrderService
+	owner     context.Context
+	menu      *model.Menu
+}
+
+// newOrderFixture sets up the approved merchant "alice" selling one menu
+// item at 10000.
+func newOrderFixture(t *testing.T) *orderFixture {
+	t.Helper()
+	db := memory.NewDB()
+	f := &orderFixture{
+		db:        db,
+		users:     memory.NewUserRepo(db),
+		merchants: memory.NewMerchantRepo(db),
+		orders:    memory.NewOrderRepo(db),
+	}
+	f.service = NewOrderService(f.orders, f.merchants, testReleaseLead, testAcceptWindow, testCancelFee, zap.NewNop())
+	f.owner = signIn(t, f.users, "alice", "merchant")
+	owner, _ := utils.CheckContextValue(f.owner)
+	merchant := &model.Merchant{MerchantID: uuid.New(), Name: "Warung Alice", UserID: owner.UserID, Owner: "alice", Status: model.ReviewStatusApproved}
+	if err := f.merchants.CreateMerchantRepo(context.Background(), merchant); err != nil {
+		t.Fatal(err)
+	}
+	f.menu = &model.Menu{MenuID: uuid.New(), Name: "Nasi Goreng", Price: 10000, Category: "rice", Stock: 10, MerchantID: merchant.MerchantID}
+	if err := memory.NewMenuRepo(db).CreateMenuRepo(context.Background(), f.menu, owner.UserID); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// customer registers a customer with balance in their wallet and returns a
+// context authenticated as them.
+func (f *orderFixture) customer(t *testing.T, username string, balance int64) context.Context {
+	t.Helper()
+	user := &model.User{UserID: uuid.New(), Username: username, Email: username + "@example.com", Role: "user", Name: username, Balance: balance}
+	if err := f.users.RegisterUserRepo(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return utils.WithContextValues(context.Background(), utils.ContextValues{UserID: user.UserID, Username: username, Role: "user"})
+}
+
+func (f *orderFixture) setSchedule(t *testing.T, schedule model.MerchantSchedule) {
+	t.Helper()
+	owner, _ := utils.CheckContextValue(f.owner)
+	if err := f.merchants.SetMerchantScheduleRepo(context.Background(), owner.UserID, &schedule); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *orderFixture) request(at *time.Time) *model.CreateOrderReq {
+	return &model.CreateOrderReq{
+		Merchant:     "alice",
+		Items:        []model.OrderItemReq{{MenuID: f.menu.MenuID, Quantity: 2}},
+		ScheduledFor: at,
+	}
+}
+
+func TestCreateOrderService(t *testing.T) {
+	f := newOrderFixture(t)
+	ctx := f.customer(t, "bob", 50000)
+
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != model.OrderStatusPlaced || order.PlacedAt == nil || order.ScheduledFor != nil ||
+		order.RespondBy == nil || !order.RespondBy.Equal(order.PlacedAt.Add(testAcceptWindow)) ||
+		order.Total != 20000 || order.Customer != "bob" || order.Merchant != "alice" {
+		t.Errorf("CreateOrderService = %+v", order)
+	}
+
+	if _, err := f.service.CreateOrderService(f.owner, f.request(nil)); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("merchant placing an order = %v, want ErrForbidden", err)
+	}
+	empty := f.request(nil)
+	empty.Items = nil
+	if _, err := f.service.CreateOrderService(ctx, empty); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("order without items = %v, want ErrBadRequest", err)
+	}
+	unknown := f.request(nil)
+	unknown.Merchant = "nobody"
+	if _, err := f.service.CreateOrderService(ctx, unknown); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("order from an unknown merchant = %v, want ErrNotFound", err)
+	}
+	if _, err := f.service.CreateOrderService(f.customer(t, "carol", 100), f.request(nil)); !errors.Is(err, utils.ErrInsufficientFunds) {
+		t.Errorf("order without funds = %v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestCreateScheduledOrder(t *testing.T) {
+	f := newOrderFixture(t)
+	ctx := f.customer(t, "bob", 100000)
+	loc, err := time.LoadLocation(model.DefaultMerchantTimezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Open tomorrow only, so the merchant is closed now.
+	tomorrow := time.Now().In(loc).AddDate(0, 0, 1)
+	f.setSchedule(t, model.MerchantSchedule{
+		Timezone:     model.DefaultMerchantTimezone,
+		SlotCapacity: 1,
+		Hours:        []model.OpeningHours{{Weekday: int(tomorrow.Weekday()), Opens: "10:00", Closes: "22:00"}},
+	})
+	if _, err := f.service.CreateOrderService(ctx, f.request(nil)); !errors.Is(err, utils.ErrSlotUnavailable) {
+		t.Errorf("order while closed = %v, want ErrSlotUnavailable", err)
+	}
+
+	at := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 12, 10, 0, 0, loc)
+	order, err := f.service.CreateOrderService(ctx, f.request(&at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 12, 0, 0, 0, loc)
+	if order.Status != model.OrderStatusScheduled || order.PlacedAt != nil || !order.ScheduledFor.Equal(slot) ||
+		!order.ReleaseAt.Equal(slot.Add(-testReleaseLead)) {
+		t.Errorf("scheduled order = %+v, want the %s slot released %s before", order, slot, testReleaseLead)
+	}
+	if _, err := f.service.CreateOrderService(ctx, f.request(&slot)); !errors.Is(err, utils.ErrSlotUnavailable) {
+		t.Errorf("order for a full slot = %v, want ErrSlotUnavailable", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+	}{
+		{"before opening", time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 9, 45, 0, 0, loc)},
+		{"at closing", time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 22, 0, 0, 0, loc)},
+		{"closed day", slot.AddDate(0, 0, 1)},
+		{"inside the release lead", time.Now().Add(testReleaseLead / 2)},
+		{"too far ahead", slot.AddDate(0, 0, 7)},
+	}
+	for _, tt := range tests {
+		at := tt.at
+		if _, err := f.service.CreateOrderService(ctx, f.request(&at)); !errors.Is(err, utils.ErrSlotUnavailable) {
+			t.Errorf("%s: err = %v, want ErrSlotUnavailable", tt.name, err)
+		}
+	}
+}
+
+func TestGetOrderService(t *testing.T) {
+	f := newOrderFixture(t)
+	ctx := f.customer(t, "bob", 50000)
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := utils.WithContextValues(context.Background(), utils.ContextValues{UserID: uuid.New(), Username: "root", Role: "admin"})
+	for name, ctx := range map[string]context.Context{"customer": ctx, "merchant": f.owner, "admin": admin} {
+		if got, err := f.service.GetOrderService(ctx, order.OrderID); err != nil || got.OrderID != order.OrderID {
+			t.Errorf("%s: GetOrderService = %+v, %v", name, got, err)
+		}
+	}
+	if _, err := f.service.GetOrderService(f.customer(t, "carol", 0), order.OrderID); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("another customer = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReleaseScheduled(t *testing.T) {
+	f := newOrderFixture(t)
+	customer, _ := utils.CheckContextValue(f.customer(t, "bob", 10000*(releaseBatchSize+10)))
+	schedule, err := f.merchants.GetMerchantScheduleRepo(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More orders are due than fit in one batch; one more is not due yet.
+	now := time.Now()
+	for i := 0; i <= releaseBatchSize+1; i++ {
+		slot := now.Add(time.Duration(i) * time.Second)
+		if i == releaseBatchSize+1 {
+			slot = now.Add(2 * testReleaseLead)
+		}
+		releaseAt := slot.Add(-testReleaseLead)
+		order := &model.Order{
+			OrderID:      uuid.New(),
+			CustomerID:   customer.UserID,
+			MerchantID:   schedule.MerchantID,
+			Status:       model.OrderStatusScheduled,
+			Items:        []model.OrderItem{{OrderItemID: uuid.New(), MenuID: f.menu.MenuID, Quantity: 1}},
+			ScheduledFor: &slot,
+			ReleaseAt:    &releaseAt,
+			CreatedAt:    now,
+		}
+		if err := f.orders.CreateOrderRepo(context.Background(), order, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.service.ReleaseScheduled(context.Background(), ReleaseScheduledOrdersJob{}); err != nil {
+		t.Fatal(err)
+	}
+	placed, err := f.orders.ListMerchantOrdersRepo(context.Background(), schedule.MerchantID, &model.OrderFilter{Statuses: []string{model.OrderStatusPlaced}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placed) != 1 || placed[0].RespondBy == nil || placed[0].RespondBy.Sub(*placed[0].PlacedAt) != testAcceptWindow {
+		t.Errorf("released order = %+v, want the accept window to answer it", placed)
+	}
+	later := time.Now()
+	if n, err := f.orders.ReleaseScheduledOrdersRepo(context.Background(), later, later, releaseBatchSize); err != nil || n != 0 {
+		t.Errorf("orders left to release = %d, %v; want 0", n, err)
+	}
+	later = now.Add(2 * testReleaseLead)
+	if n, err := f.orders.ReleaseScheduledOrdersRepo(context.Background(), later, later, releaseBatchSize); err != nil || n != 1 {
+		t.Errorf("orders released once due = %d, %v; want only the future one", n, err)
+	}
+}
+
+func TestCancelOrderService(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	ctx := f.customer(t, "bob", 100000)
+
+	free, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.CancelOrderService(f.owner, free.OrderID); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("merchant cancelling as a customer = %v, want ErrForbidden", err)
+	}
+	if _, err := f.service.CancelOrderService(f.customer(t, "carol", 0), free.OrderID); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("another customer cancelling = %v, want ErrNotFound", err)
+	}
+	got, err := f.service.CancelOrderService(ctx, free.OrderID)
+	if err != nil || got.Status != model.OrderStatusCancelled || got.CancelFee != 0 || got.Refunded != 20000 {
+		t.Errorf("cancelling a placed order = %+v, %v", got, err)
+	}
+
+	late, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merchantOrders.AcceptOrderService(f.owner, "alice", late.OrderID, &model.AcceptOrderReq{PrepMinutes: 10}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = f.service.CancelOrderService(ctx, late.OrderID)
+	if err != nil || got.CancelFee != testCancelFee || got.Refunded != 20000-testCancelFee {
+		t.Errorf("cancelling an accepted order = %+v, %v", got, err)
+	}
+	if _, err := f.service.CancelOrderService(ctx, late.OrderID); !errors.Is(err, utils.ErrOrderState) {
+		t.Errorf("cancelling twice = %v, want ErrOrderState", err)
+	}
+
+	customer, _ := utils.CheckContextValue(ctx)
+	export, err := f.users.ExportUserRepo(context.Background(), customer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.Balance != 100000-testCancelFee || len(export.Wallet) != 4 {
+		t.Errorf("balance %d with history %+v, want only the fee kept", export.Profile.Balance, export.Wallet)
+	}
+}
+
+func TestDriverCancelOrderService(t *testing.T) {
+	f := newOrderFixture(t)
+	ctx := context.Background()
+	customer, _ := utils.CheckContextValue(f.customer(t, "bob", 100000))
+	driverCtx := signIn(t, f.users, "dave", "driver")
+	driverUser, _ := utils.CheckContextValue(driverCtx)
+	driver := &model.Driver{DriverID: uuid.New(), Name: "Dave", License: "B1234XYZ", UserID: driverUser.UserID, Username: "dave", Status: model.ReviewStatusApproved}
+	if err := memory.NewDriverRepo(f.db).CreateDriverRepo(ctx, driver); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing assigns drivers yet, so the order is stored with one.
+	now := time.Now()
+	order := &model.Order{
+		OrderID:    uuid.New(),
+		CustomerID: customer.UserID,
+		MerchantID: f.menu.MerchantID,
+		DriverID:   &driver.DriverID,
+		Status:     model.OrderStatusPlaced,
+		Items:      []model.OrderItem{{OrderItemID: uuid.New(), MenuID: f.menu.MenuID, Quantity: 1}},
+		PlacedAt:   &now,
+		CreatedAt:  now,
+	}
+	if err := f.orders.CreateOrderRepo(ctx, order, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.orders.AcceptOrderRepo(ctx, f.menu.MerchantID, order.OrderID, 10, now); err != nil {
+		t.Fatal(err)
+	}
+
+	reason := &model.DriverCancelReq{Reason: "vehicle_problem"}
+	if _, err := f.service.DriverCancelOrderService(driverCtx, "erin", order.OrderID, reason); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("cancelling as another driver = %v, want ErrForbidden", err)
+	}
+	for _, input := range []*model.DriverCancelReq{{}, {Reason: "tired"}, {Reason: "other"}} {
+		if _, err := f.service.DriverCancelOrderService(driverCtx, "dave", order.OrderID, input); !errors.Is(err, utils.ErrBadRequest) {
+			t.Errorf("cancelling with %+v = %v, want ErrBadRequest", input, err)
+		}
+	}
+	if _, err := f.service.DriverCancelOrderService(signIn(t, f.users, "erin", "driver"), "erin", order.OrderID, reason); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("unassigned driver cancelling = %v, want ErrNotFound", err)
+	}
+	got, err := f.service.DriverCancelOrderService(driverCtx, "dave", order.OrderID, reason)
+	if err != nil || got.CancelledBy != model.CancelledByDriver || got.CancelReason != "vehicle_problem" || got.Refunded != got.Total {
+		t.Errorf("DriverCancelOrderService = %+v, %v", got, err)
+	}
+}
+
+func TestDisputes(t *testing.T) {
+	f := newOrderFixture(t)
+	merchantOrders := NewMerchantOrderService(f.orders, f.merchants, zap.NewNop())
+	admin := NewAdminService(nil, f.orders, zap.NewNop())
+	adminCtx := signIn(t, f.users, "root", "admin")
+	ctx := f.customer(t, "bob", 100000)
+	order, err := f.service.CreateOrderService(ctx, f.request(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := order.Items[0].OrderItemID
+	input := &model.OpenDisputeReq{Kind: model.DisputeKindMissingItem, OrderItemIDs: []uuid.UUID{item}, Description: "only one plate came"}
+
+	if _, err := f.service.OpenDisputeService(ctx, order.OrderID, input); !errors.Is(err, utils.ErrOrderState) {
+		t.Errorf("disputing an order before it is ready = %v, want ErrOrderState", err)
+	}
+	if _, err := merchantOrders.AcceptOrderService(f.owner, "alice", order.OrderID, &model.AcceptOrderReq{PrepMinutes: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merchantOrders.MarkOrderReadyService(f.owner, "alice", order.OrderID); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []*model.OpenDisputeReq{
+		{Kind: "late", OrderItemIDs: []uuid.UUID{item}, Description: "slow"},
+		{Kind: model.DisputeKindWrongItem, Description: "no items"},
+		{Kind: model.DisputeKindWrongItem, OrderItemIDs: []uuid.UUID{item, item}, Description: "twice"},
+	} {
+		if _, err := f.service.OpenDisputeService(ctx, order.OrderID, bad); !errors.Is(err, utils.ErrBadRequest) {
+			t.Errorf("dispute %+v = %v, want ErrBadRequest", bad, err)
+		}
+	}
+	dispute, err := f.service.OpenDisputeService(ctx, order.OrderID, input)
+	if err != nil || dispute.Status != model.DisputeStatusOpen || dispute.Customer != "bob" {
+		t.Fatalf("OpenDisputeService = %+v, %v", dispute, err)
+	}
+
+	if _, err := admin.ListDisputesService(ctx, &model.DisputeFilter{}); !errors.Is(err, utils.ErrForbidden) {
+		t.Errorf("customer listing disputes = %v, want ErrForbidden", err)
+	}
+	if _, err := admin.ListDisputesService(adminCtx, &model.DisputeFilter{Status: "closed"}); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("unknown status filter = %v, want ErrBadRequest", err)
+	}
+	if got, err := admin.ListDisputesService(adminCtx, &model.DisputeFilter{Status: model.DisputeStatusOpen}); err != nil || len(got) != 1 {
+		t.Errorf("open disputes = %+v, %v", got, err)
+	}
+
+	resolve := &model.ResolveDisputeReq{Refund: 20001, Resolution: "refund everything"}
+	if _, err := admin.ResolveDisputeService(adminCtx, dispute.DisputeID, resolve); !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("refunding more than the order = %v, want ErrBadRequest", err)
+	}
+	resolve.Refund, resolve.Resolution = 10000, "one plate refunded"
+	got, err := admin.ResolveDisputeService(adminCtx, dispute.DisputeID, resolve)
+	if err != nil || got.Status != model.DisputeStatusResolved || got.Refund != 10000 {
+		t.Fatalf("ResolveDisputeService = %+v, %v", got, err)
+	}
+	if got, err := f.service.GetOrderService(ctx, order.OrderID); err != nil || got.Refunded != 10000 {
+		t.Errorf("order after the dispute = %+v, %v", got, err)
+	}
+	root, _ := utils.CheckContextValue(adminCtx)
+	customer, _ := utils.CheckContextValue(ctx)
+	export, err := f.users.ExportUserRepo(context.Background(), customer.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := export.Wallet[len(export.Wallet)-1]; last.Kind != model.WalletKindOrderRefund || last.Amount != 10000 || export.Profile.Balance != 90000 {
+		t.Errorf("balance %d after dispute refund %+v", export.Profile.Balance, last)
+	}
+	logs := memory.NewAuditRepo(f.db).Logs()
+	if len(logs) != 1 || logs[0].Action != "admin.dispute.resolve" || logs[0].Subject != "dispute:"+dispute.DisputeID.String() || logs[0].ActorID != root.UserID {
+		t.Errorf("audit = %+v", logs)
+	}
+}
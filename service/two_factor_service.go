@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/metrics"
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/tracing"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	totpIssuer        = "GoFood"
+	recoveryCodeCount = 10
+)
+
+type TwoFactorServiceImpl interface {
+	SetupTOTPService(ctx context.Context) (*model.TOTPSetupRes, error)
+	EnableTOTPService(ctx context.Context, input *model.TOTPCodeReq) (*model.RecoveryCodesRes, error)
+	DisableTOTPService(ctx context.Context, input *model.TOTPCodeReq) error
+	VerifyLoginService(ctx context.Context, input *model.TOTPLoginReq, clientIP string) (*model.LoginRes, error)
+}
+type TwoFactorService struct {
+	repo       repository.TwoFactorRepoImpl
+	guard      *LoginGuard
+	zap        *zap.Logger
+	jwtService middleware.JWTServiceImpl
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepoImpl, guard *LoginGuard, zap *zap.Logger, jwt middleware.JWTServiceImpl) *TwoFactorService {
+	return &TwoFactorService{
+		repo:       repo,
+		guard:      guard,
+		zap:        zap,
+		jwtService: jwt,
+	}
+}
+
+func (ts *TwoFactorService) SetupTOTPService(ctx context.Context) (*model.TOTPSetupRes, error) {
+	ctxValue, err := ts.checkRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ts.repo.GetTOTPRepo(ctx, ctxValue.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Enabled {
+		utils.Logger(ctx, ts.zap).Warn("totp already enabled", zap.String("username", ctxValue.Username))
+		return nil, fmt.Errorf("two-factor authentication already enabled: %w", utils.ErrBadRequest)
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.Logger(ctx, ts.zap).Error(utils.ErrInternal.Error(), zap.Error(err))
+		return nil, err
+	}
+	if err := ts.repo.SetTOTPSecretRepo(ctx, ctxValue.UserID, secret); err != nil {
+		return nil, err
+	}
+	return &model.TOTPSetupRes{
+		Secret: secret,
+		URI:    utils.TOTPProvisioningURI(totpIssuer, ctxValue.Username, secret),
+	}, nil
+}
+
+func (ts *TwoFactorService) EnableTOTPService(ctx context.Context, input *model.TOTPCodeReq) (*model.RecoveryCodesRes, error) {
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ts.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	ctxValue, err := ts.checkRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ts.repo.GetTOTPRepo(ctx, ctxValue.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Enabled || current.Secret == "" {
+		utils.Logger(ctx, ts.zap).Warn("totp not pending setup", zap.String("username", ctxValue.Username))
+		return nil, fmt.Errorf("two-factor setup not started: %w", utils.ErrBadRequest)
+	}
+	if ok, err := ts.verifyTOTP(ctx, current, input.Code); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("invalid code: %w", utils.ErrBadRequest)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			utils.Logger(ctx, ts.zap).Error(utils.ErrInternal.Error(), zap.Error(err))
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	if err := ts.repo.EnableTOTPRepo(ctx, ctxValue.UserID, hashes); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, ts.zap).Info("totp enabled", zap.String("username", ctxValue.Username))
+	return &model.RecoveryCodesRes{RecoveryCodes: codes}, nil
+}
+
+func (ts *TwoFactorService) DisableTOTPService(ctx context.Context, input *model.TOTPCodeReq) error {
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ts.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	ctxValue, err := ts.checkRole(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := ts.repo.GetTOTPRepo(ctx, ctxValue.UserID)
+	if err != nil {
+		return err
+	}
+	if !current.Enabled {
+		return fmt.Errorf("two-factor authentication not enabled: %w", utils.ErrBadRequest)
+	}
+	if ok, err := ts.verifyCode(ctx, current, input.Code); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("invalid code: %w", utils.ErrBadRequest)
+	}
+	utils.Logger(ctx, ts.zap).Info("totp disabled", zap.String("username", ctxValue.Username))
+	return ts.repo.DisableTOTPRepo(ctx, ctxValue.UserID)
+}
+
+func (ts *TwoFactorService) VerifyLoginService(ctx context.Context, input *model.TOTPLoginReq, clientIP string) (*model.LoginRes, error) {
+	ctx, span := tracing.Start(ctx, "TwoFactorService.VerifyLogin")
+	res, err := ts.verifyLogin(ctx, input, clientIP)
+	metrics.ObserveLogin(metrics.LoginStepTOTP, res, err)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (ts *TwoFactorService) verifyLogin(ctx context.Context, input *model.TOTPLoginReq, clientIP string) (*model.LoginRes, error) {
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, ts.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	claims, err := ts.jwtService.ValidateChallengeToken(input.ChallengeToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge token: %w", utils.ErrUnauthorized)
+	}
+	if _, err := ts.guard.Check(ctx, claims.Username, clientIP); err != nil {
+		return nil, err
+	}
+	current, err := ts.repo.GetTOTPRepo(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	ok := false
+	if current.Enabled {
+		if ok, err = ts.verifyCode(ctx, current, input.Code); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		if err := ts.guard.Fail(ctx, claims.Username, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, utils.ErrInvalidCredentials
+	}
+	if err := ts.guard.Succeed(ctx, claims.Username); err != nil {
+		return nil, err
+	}
+	token, err := ts.jwtService.CreateToken(&middleware.TokenClaims{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Role:     claims.Role,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.LoginRes{Username: claims.Username, Token: token}, nil
+}
+
+func (ts *TwoFactorService) checkRole(ctx context.Context) (*utils.ContextValues, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, ts.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Role != "merchant" && ctxValue.Role != "driver" {
+		utils.Logger(ctx, ts.zap).Error("invalid role", zap.String("needed", "merchant or driver"), zap.String("actual", ctxValue.Role))
+		return nil, fmt.Errorf("%w: role %s is not allowed", utils.ErrForbidden, ctxValue.Role)
+	}
+	return ctxValue, nil
+}
+
+// verifyCode accepts either a current TOTP code or an unused recovery code.
+func (ts *TwoFactorService) verifyCode(ctx context.Context, current *model.TOTP, code string) (bool, error) {
+	if ok, err := ts.verifyTOTP(ctx, current, code); err != nil || ok {
+		return ok, err
+	}
+	return ts.repo.UseRecoveryCodeRepo(ctx, current.UserID, hashRecoveryCode(code))
+}
+
+func (ts *TwoFactorService) verifyTOTP(ctx context.Context, current *model.TOTP, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(current.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+	return ts.repo.UseTOTPStepRepo(ctx, current.UserID, step)
+}
+
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 7)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", utils.ErrInternal)
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
+	"github.com/bagasadiii/gofood-clone/metrics"
 	"github.com/bagasadiii/gofood-clone/middleware"
 	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/bagasadiii/gofood-clone/repository"
+	"github.com/bagasadiii/gofood-clone/storage"
+	"github.com/bagasadiii/gofood-clone/tracing"
 	"github.com/bagasadiii/gofood-clone/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -16,38 +19,46 @@ import (
 )
 
 type UserServiceImpl interface {
-	RegisterService(ctx context.Context, input *model.RegisterReq) error
-	GetUserService(ctx context.Context, username string) (*model.UserResp, error)
-	LoginService(ctx context.Context, input *model.LoginReq) (string, error)
+	RegisterService(ctx context.Context, input *model.RegisterReq) (*model.User, error)
+	GetUserService(ctx context.Context, username string) (*model.User, error)
+	LoginService(ctx context.Context, input *model.LoginReq, clientIP string) (*model.LoginRes, error)
+	UpdateUserService(ctx context.Context, username string, input *model.UpdateUserReq) (*model.User, error)
+	DeleteUserService(ctx context.Context, username string) error
+	ExportUserService(ctx context.Context, username string) (*model.UserExport, error)
 }
 type UserService struct {
 	repo       repository.UserRepoImpl
+	guard      *LoginGuard
+	store      storage.Storage
 	zap        *zap.Logger
 	jwtService middleware.JWTServiceImpl
+	bcryptCost int
+	dummyHash  func() []byte
 }
 
-func NewUserService(repo repository.UserRepoImpl, zap *zap.Logger, jwt middleware.JWTServiceImpl) *UserService {
+func NewUserService(repo repository.UserRepoImpl, guard *LoginGuard, store storage.Storage, zap *zap.Logger, jwt middleware.JWTServiceImpl, bcryptCost int) *UserService {
 	return &UserService{
 		repo:       repo,
+		guard:      guard,
+		store:      store,
 		zap:        zap,
 		jwtService: jwt,
+		bcryptCost: bcryptCost,
+		dummyHash:  newDummyPasswordHash(bcryptCost),
 	}
 }
 
-func (us *UserService) RegisterService(ctx context.Context, input *model.RegisterReq) error {
-	re := regexp.MustCompile("^[a-z0-9_]+$")
-	if !re.MatchString(input.Username) {
-		us.zap.Warn(utils.ErrBadRequest.Error(), zap.String("Invalid username", input.Username))
-		return fmt.Errorf("invalid username:%w", utils.ErrBadRequest)
+func (us *UserService) RegisterService(ctx context.Context, input *model.RegisterReq) (*model.User, error) {
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, us.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
 	}
-	if err := utils.ValidateUser(input); err != nil {
-		us.zap.Error(utils.ErrBadRequest.Error(), zap.Error(err))
-		return fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
-	}
-	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	_, span := tracing.Start(ctx, "bcrypt.hash")
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), us.bcryptCost)
+	span.End()
 	if err != nil {
-		us.zap.Error(utils.ErrInternal.Error(), zap.Error(err))
-		return fmt.Errorf("failed to hash password: %w", utils.ErrInternal)
+		utils.Logger(ctx, us.zap).Error(utils.ErrInternal.Error(), zap.Error(err))
+		return nil, fmt.Errorf("failed to hash password: %w", utils.ErrInternal)
 	}
 	newUser := &model.User{
 		UserID:    uuid.New(),
@@ -60,34 +71,167 @@ func (us *UserService) RegisterService(ctx context.Context, input *model.Registe
 		Balance:   0,
 		Name:      input.Username,
 	}
-	return us.repo.RegisterUserRepo(ctx, newUser)
+	if err := us.repo.RegisterUserRepo(ctx, newUser); err != nil {
+		return nil, err
+	}
+	metrics.Registrations.WithLabelValues(newUser.Role).Inc()
+	newUser.Password = ""
+	return newUser, nil
 }
 
-func (us *UserService) GetUserService(ctx context.Context, username string) (*model.UserResp, error) {
+func (us *UserService) GetUserService(ctx context.Context, username string) (*model.User, error) {
 	return us.repo.GetUserRepo(ctx, username)
 }
 
-func (us *UserService) LoginService(ctx context.Context, input *model.LoginReq) (string, error) {
-	if err := utils.ValidateLogin(input); err != nil {
-		us.zap.Error(utils.ErrBadRequest.Error(), zap.Error(err))
-		return "", fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+func (us *UserService) LoginService(ctx context.Context, input *model.LoginReq, clientIP string) (*model.LoginRes, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	res, err := us.login(ctx, input, clientIP)
+	metrics.ObserveLogin(metrics.LoginStepPassword, res, err)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (us *UserService) login(ctx context.Context, input *model.LoginReq, clientIP string) (*model.LoginRes, error) {
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, us.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
 	}
-	res, err := us.repo.LoginRepo(ctx, input.Username)
+	failures, err := us.guard.Check(ctx, input.Username, clientIP)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(res.Password), []byte(input.Password)); err != nil {
-		us.zap.Warn(utils.ErrInvalidPassword.Error())
-		return "", utils.ErrInvalidPassword
+	if err := us.guard.Delay(ctx, failures); err != nil {
+		return nil, err
+	}
+	res, err := us.repo.LoginRepo(ctx, input.Username)
+	if errors.Is(err, utils.ErrNotFound) {
+		comparePassword(ctx, us.dummyHash(), input.Password)
+		return nil, us.loginFailed(ctx, input.Username, clientIP)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := comparePassword(ctx, []byte(res.Password), input.Password); err != nil {
+		utils.Logger(ctx, us.zap).Warn(utils.ErrInvalidPassword.Error())
+		return nil, us.loginFailed(ctx, input.Username, clientIP)
+	}
+	if res.Status != model.UserStatusActive {
+		utils.Logger(ctx, us.zap).Warn("login for inactive account", zap.String("username", res.Username), zap.String("status", res.Status))
+		return nil, utils.ErrAccountSuspended
 	}
 	newClaims := &middleware.TokenClaims{
 		UserID:   res.UserID,
 		Username: res.Username,
 		Role:     res.Role,
 	}
+	// The failure counter is only cleared once the second factor passes, so
+	// a known password cannot be used to keep resetting TOTP guesses.
+	if res.TOTPEnabled {
+		challenge, err := us.jwtService.CreateChallengeToken(newClaims)
+		if err != nil {
+			return nil, err
+		}
+		return &model.LoginRes{
+			Username:          res.Username,
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+	if err := us.guard.Succeed(ctx, input.Username); err != nil {
+		return nil, err
+	}
 	token, err := us.jwtService.CreateToken(newClaims)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return &model.LoginRes{Username: res.Username, Token: token}, nil
+}
+
+// comparePassword wraps the bcrypt comparison in a span, since it is usually
+// the slowest step of a login.
+func comparePassword(ctx context.Context, hash []byte, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	defer span.End()
+	return bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
+
+func (us *UserService) loginFailed(ctx context.Context, username, clientIP string) error {
+	if err := us.guard.Fail(ctx, username, clientIP); err != nil {
+		return err
+	}
+	return utils.ErrInvalidCredentials
+}
+
+func (us *UserService) UpdateUserService(ctx context.Context, username string, input *model.UpdateUserReq) (*model.User, error) {
+	ctxValue, err := us.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Validate(input); err != nil {
+		utils.Logger(ctx, us.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
+	}
+	patch := userPatch(input)
+	if err := patch.Err(); err != nil {
+		utils.Logger(ctx, us.zap).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", utils.ErrBadRequest, err)
 	}
-	return token, nil
+	if patch.Empty() {
+		return nil, fmt.Errorf("no fields to update: %w", utils.ErrBadRequest)
+	}
+	return us.repo.UpdateUserRepo(ctx, ctxValue.UserID, patch)
+}
+
+func (us *UserService) DeleteUserService(ctx context.Context, username string) error {
+	ctxValue, err := us.checkOwner(ctx, username)
+	if err != nil {
+		return err
+	}
+	utils.Logger(ctx, us.zap).Info("deleting user", zap.String("user_id", ctxValue.UserID.String()))
+	keys, err := us.repo.DeleteUserRepo(ctx, ctxValue.UserID)
+	if err != nil {
+		return err
+	}
+	// The account is gone once the rows are; a file that cannot be removed
+	// now is orphaned and logged rather than failing the request.
+	for _, key := range keys {
+		if err := us.store.Delete(ctx, key); err != nil {
+			utils.Logger(ctx, us.zap).Error("failed to delete driver document file", zap.String("storage_key", key), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (us *UserService) ExportUserService(ctx context.Context, username string) (*model.UserExport, error) {
+	ctxValue, err := us.checkOwner(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return us.repo.ExportUserRepo(ctx, ctxValue.UserID)
+}
+
+func (us *UserService) checkOwner(ctx context.Context, username string) (*utils.ContextValues, error) {
+	ctxValue, err := utils.CheckContextValue(ctx)
+	if err != nil {
+		utils.Logger(ctx, us.zap).Error(utils.ErrUnauthorized.Error(), zap.Error(err))
+		return nil, err
+	}
+	if ctxValue.Username != username {
+		utils.Logger(ctx, us.zap).Error(utils.ErrForbidden.Error(), zap.String("forbidden", username))
+		return nil, fmt.Errorf("not allowed to access: %w", utils.ErrForbidden)
+	}
+	return ctxValue, nil
+}
+
+// userColumns lists the user columns a PATCH may write and whether they can
+// be cleared with null.
+var userColumns = map[string]bool{
+	"name":  false,
+	"phone": true,
+}
+
+func userPatch(input *model.UpdateUserReq) *utils.Patch {
+	patch := utils.NewPatch(userColumns)
+	utils.PatchField(patch, "name", input.Name)
+	utils.PatchField(patch, "phone", input.Phone)
+	return patch
 }
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/gofood-clone/middleware"
+	"github.com/bagasadiii/gofood-clone/model"
+	"github.com/bagasadiii/gofood-clone/repository/memory"
+	"github.com/bagasadiii/gofood-clone/storage"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery"
+
+type userFixture struct {
+	service  *UserService
+	users    *memory.UserRepo
+	attempts *memory.LoginAttemptRepo
+	audit    *memory.AuditRepo
+}
+
+func newUserFixture(t *testing.T) *userFixture {
+	t.Helper()
+	db := memory.NewDB()
+	f := &userFixture{
+		users:    memory.NewUserRepo(db),
+		attempts: memory.NewLoginAttemptRepo(db),
+		audit:    memory.NewAuditRepo(db),
+	}
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.NewNop()
+	jwt := middleware.NewJWTService([]byte("service-test-secret-key-32-bytes"), time.Hour, f.users, logger)
+	guard := NewLoginGuard(f.attempts, f.audit, logger)
+	f.service = NewUserService(f.users, guard, store, logger, jwt, bcrypt.MinCost)
+	return f
+}
+
+func (f *userFixture) register(t *testing.T, username string) *model.User {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{
+		UserID:    uuid.New(),
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  string(hashed),
+		Role:      "customer",
+		CreatedAt: time.Now(),
+		Name:      username,
+	}
+	if err := f.users.RegisterUserRepo(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (f *userFixture) failures(t *testing.T, scope, subject string) int {
+	t.Helper()
+	attempt, err := f.attempts.GetLoginAttemptRepo(context.Background(), scope, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attempt.Failures
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	f := newUserFixture(t)
+	f.register(t, "alice")
+
+	_, err := f.service.LoginService(ctx, &model.LoginReq{Username: "alice", Password: "wrong"}, "203.0.113.7")
+	if !errors.Is(err, utils.ErrInvalidCredentials) {
+		t.Fatalf("wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if got := f.failures(t, model.LoginScopeUsername, "alice"); got != 1 {
+		t.Errorf("username failures = %d, want 1", got)
+	}
+
+	// Usernames are counted case-insensitively, so varying the case does
+	// not get around the limit.
+	_, err = f.service.LoginService(ctx, &model.LoginReq{Username: " Alice", Password: "wrong"}, "203.0.113.7")
+	if !errors.Is(err, utils.ErrInvalidCredentials) {
+		t.Fatalf("wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if got := f.failures(t, model.LoginScopeUsername, "alice"); got != 2 {
+		t.Errorf("username failures = %d, want 2", got)
+	}
+
+	res, err := f.service.LoginService(ctx, &model.LoginReq{Username: "alice", Password: testPassword}, "203.0.113.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Token == "" || res.TwoFactorRequired {
+		t.Errorf("login = %+v, want a token", res)
+	}
+	// Success clears the username counter but not the IP one.
+	if got := f.failures(t, model.LoginScopeUsername, "alice"); got != 0 {
+		t.Errorf("username failures after success = %d, want 0", got)
+	}
+	if got := f.failures(t, model.LoginScopeIP, "203.0.113.7"); got != 2 {
+		t.Errorf("IP failures after success = %d, want 2", got)
+	}
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	f := newUserFixture(t)
+	_, err := f.service.LoginService(context.Background(), &model.LoginReq{Username: "nobody", Password: "secret"}, "")
+	if !errors.Is(err, utils.ErrInvalidCredentials) {
+		t.Fatalf("unknown user = %v, want the same error as a wrong password", err)
+	}
+	if got := f.failures(t, model.LoginScopeUsername, "nobody"); got != 1 {
+		t.Errorf("failures for unknown user = %d, want 1", got)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the progressive login delay")
+	}
+	ctx := context.Background()
+	f := newUserFixture(t)
+	f.register(t, "alice")
+
+	for i := 0; i < maxUsernameFailures; i++ {
+		_, err := f.service.LoginService(ctx, &model.LoginReq{Username: "alice", Password: "wrong"}, "203.0.113.7")
+		if !errors.Is(err, utils.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d = %v", i+1, err)
+		}
+	}
+
+	// Locked out even with the right password.
+	_, err := f.service.LoginService(ctx, &model.LoginReq{Username: "alice", Password: testPassword}, "198.51.100.1")
+	if !errors.Is(err, utils.ErrTooManyAttempts) {
+		t.Fatalf("login while locked = %v, want ErrTooManyAttempts", err)
+	}
+
+	logs := f.audit.Logs()
+	if len(logs) != 1 || logs[0].Action != "login.lockout" || logs[0].Subject != "username:alice" || logs[0].IP != "203.0.113.7" {
+		t.Errorf("audit logs = %+v, want one username lockout", logs)
+	}
+}
+
+func TestLoginValidation(t *testing.T) {
+	f := newUserFixture(t)
+	_, err := f.service.LoginService(context.Background(), &model.LoginReq{Username: "alice"}, "")
+	if !errors.Is(err, utils.ErrBadRequest) {
+		t.Errorf("missing password = %v, want ErrBadRequest", err)
+	}
+	if got := f.failures(t, model.LoginScopeUsername, "alice"); got != 0 {
+		t.Errorf("invalid request counted as %d failures", got)
+	}
+}
+
+func TestUpdateUserService(t *testing.T) {
+	f := newUserFixture(t)
+	alice := f.register(t, "alice")
+	ctx := utils.WithContextValues(context.Background(), utils.ContextValues{UserID: alice.UserID, Username: "alice", Role: "customer"})
+
+	got, err := f.service.UpdateUserService(ctx, "alice", &model.UpdateUserReq{Phone: utils.Some("081234567890")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Phone != "081234567890" || got.Name != "alice" {
+		t.Errorf("after setting phone = %+v", got)
+	}
+
+	// Absent fields are left alone and null clears a nullable one.
+	got, err = f.service.UpdateUserService(ctx, "alice", &model.UpdateUserReq{
+		Name:  utils.Some("Alice Liddell"),
+		Phone: utils.Optional[string]{Set: true, Null: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Alice Liddell" || got.Phone != "" {
+		t.Errorf("after clearing phone = %+v", got)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		input    model.UpdateUserReq
+		want     error
+	}{
+		{"empty patch", "alice", model.UpdateUserReq{}, utils.ErrBadRequest},
+		{"name cannot be cleared", "alice", model.UpdateUserReq{Name: utils.Optional[string]{Set: true, Null: true}}, utils.ErrBadRequest},
+		{"invalid phone", "alice", model.UpdateUserReq{Phone: utils.Some("12345")}, utils.ErrBadRequest},
+		{"someone else", "bob", model.UpdateUserReq{Name: utils.Some("Bob")}, utils.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.UpdateUserService(ctx, tt.username, &tt.input)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UpdateUserService = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	stored, err := f.users.GetUserRepo(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Alice Liddell" || stored.Phone != "" {
+		t.Errorf("rejected updates changed the user: %+v", stored)
+	}
+
+	if _, err := f.service.UpdateUserService(context.Background(), "alice", &model.UpdateUserReq{Name: utils.Some("x")}); !errors.Is(err, utils.ErrUnauthorized) {
+		t.Errorf("unauthenticated update = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagasadiii/gofood-clone/tracing"
+	"github.com/bagasadiii/gofood-clone/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LocalStorage keeps files on the local disk below root.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+func (ls *LocalStorage) Save(ctx context.Context, key string, r io.Reader) (_ int64, err error) {
+	_, span := tracing.Start(ctx, "storage.Save", attribute.String("storage.key", key))
+	defer func() { tracing.End(span, err) }()
+	path, err := ls.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", utils.ErrInternal)
+	}
+	// Write to a temporary file first so a failed upload never leaves a
+	// partial file behind the final key.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", utils.ErrInternal)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to write file: %w", utils.ErrInternal)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", utils.ErrInternal)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to store file: %w", utils.ErrInternal)
+	}
+	return n, nil
+}
+
+func (ls *LocalStorage) Open(ctx context.Context, key string) (_ io.ReadCloser, err error) {
+	_, span := tracing.Start(ctx, "storage.Open", attribute.String("storage.key", key))
+	defer func() { tracing.End(span, err) }()
+	path, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("file not found: %w", utils.ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", utils.ErrInternal)
+	}
+	return f, nil
+}
+
+func (ls *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", utils.ErrInternal)
+	}
+	return nil
+}
+
+func (ls *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q: %w", key, utils.ErrBadRequest)
+	}
+	return filepath.Join(ls.root, clean), nil
+}
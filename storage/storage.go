@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Storage persists uploaded files under slash-separated keys.
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
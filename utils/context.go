@@ -3,16 +3,21 @@ package utils
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type ctxKey string
 
 const (
-	UserIDKey   ctxKey = "user_id_key"
-	UsernameKey ctxKey = "username_key"
-	RoleKey     ctxKey = "role_key"
+	UserIDKey    ctxKey = "user_id_key"
+	UsernameKey  ctxKey = "username_key"
+	RoleKey      ctxKey = "role_key"
+	RequestIDKey ctxKey = "request_id_key"
+	LoggerKey    ctxKey = "logger_key"
+	requestKey   ctxKey = "request_info_key"
 )
 
 type ContextValues struct {
@@ -43,3 +48,49 @@ func CheckContextValue(ctx context.Context) (*ContextValues, error) {
 		Role:     role,
 	}, nil
 }
+
+// WithContextValues returns a copy of ctx carrying v as the authenticated
+// caller, the inverse of CheckContextValue.
+func WithContextValues(ctx context.Context, v ContextValues) context.Context {
+	ctx = context.WithValue(ctx, UserIDKey, v.UserID)
+	ctx = context.WithValue(ctx, UsernameKey, v.Username)
+	return context.WithValue(ctx, RoleKey, v.Role)
+}
+
+// RequestID returns the ID assigned to r, falling back to the client header.
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(RequestIDKey).(string); ok {
+		return id
+	}
+	return r.Header.Get("X-Request-ID")
+}
+
+// WithLogger returns a copy of ctx carrying logger as the request-scoped logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, LoggerKey, logger)
+}
+
+// Logger returns the request-scoped logger stored in ctx, or fallback when
+// the context has none, e.g. outside an HTTP request.
+func Logger(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(LoggerKey).(*zap.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// RequestInfo is filled in while a request is handled so that middleware
+// running outside the authenticated subrouter can still see who made it.
+type RequestInfo struct {
+	UserID uuid.UUID
+}
+
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestKey, info)
+}
+
+// RequestInfoFrom returns the RequestInfo stored in ctx, or nil.
+func RequestInfoFrom(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestKey).(*RequestInfo)
+	return info
+}
@@ -3,39 +3,191 @@ package utils
 import (
 	"errors"
 	"net/http"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
 )
 
 var (
-	ErrNotFound         = errors.New("no data found")
-	ErrDatabase         = errors.New("database error")
-	ErrUniqueConstraint = errors.New("username or email already exists")
-	ErrInternal         = errors.New("internal error")
-	ErrInvalidPassword  = errors.New("invalid password")
-	ErrUnexpected       = errors.New("unexpected err")
-	ErrBadRequest       = errors.New("bad request")
-	ErrUnauthorized     = errors.New("unauthorized")
-	ErrValidation       = errors.New("validation error")
-	ErrForbidden        = errors.New("forbidden access")
+	ErrNotFound           = errors.New("no data found")
+	ErrDatabase           = errors.New("database error")
+	ErrUniqueConstraint   = errors.New("username or email already exists")
+	ErrInternal           = errors.New("internal error")
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrUnexpected         = errors.New("unexpected err")
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrValidation         = errors.New("validation error")
+	ErrForbidden          = errors.New("forbidden access")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTooManyAttempts    = errors.New("too many login attempts, try again later")
+	ErrAccountSuspended   = errors.New("account suspended")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrRateLimited        = errors.New("too many requests, slow down")
+	ErrRequestInProgress  = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyReused  = errors.New("idempotency key was already used for a different request")
+	ErrInsufficientFunds  = errors.New("insufficient wallet balance")
+	ErrSlotUnavailable    = errors.New("delivery slot unavailable")
+	ErrOrderState         = errors.New("order cannot do that in its current state")
 )
 
-func ErrCheck(err error) (int, error) {
-	switch {
-	case errors.Is(err, ErrNotFound):
-		return http.StatusNotFound, err
-	case errors.Is(err, ErrDatabase):
-		return http.StatusInternalServerError, err
-	case errors.Is(err, ErrUniqueConstraint):
-		return http.StatusBadRequest, err
-	case errors.Is(err, ErrInvalidPassword):
-		return http.StatusBadRequest, err
-	case errors.Is(err, ErrInternal):
-		return http.StatusInternalServerError, err
-	case errors.Is(err, ErrBadRequest):
-		return http.StatusBadRequest, err
-	case errors.Is(err, ErrForbidden):
-		return http.StatusForbidden, err
+// APIError is the body clients receive for every failed request. Code is a
+// stable machine-readable identifier; Message is meant for humans.
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries per-field failures and matches ErrValidation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msg := ErrValidation.Error()
+	for i, f := range e.Fields {
+		if i == 0 {
+			msg += ": "
+		} else {
+			msg += "; "
+		}
+		msg += f.Message
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// NewValidationError converts validator output into a ValidationError. Any
+// other error is returned unchanged.
+func NewValidationError(err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return &ValidationError{Fields: fields}
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "e164":
+		return fe.Field() + " must be a phone number in E.164 format"
+	case "idphone":
+		return fe.Field() + " must be an Indonesian mobile number such as 081234567890"
+	case "username":
+		return fe.Field() + " may only contain lowercase letters, digits and underscores"
+	case "license":
+		return fe.Field() + " must be 6 to 10 uppercase letters or digits"
+	case "min", "max", "gte", "gt", "lte", "lt":
+		return fe.Field() + " must be " + boundMessage(fe)
+	case "oneof":
+		return fe.Field() + " must be one of: " + fe.Param()
+	default:
+		return fe.Field() + " failed " + fe.Tag() + " validation"
+	}
+}
+
+func boundMessage(fe validator.FieldError) string {
+	var op string
+	switch fe.Tag() {
+	case "min", "gte":
+		op = "at least "
+	case "max", "lte":
+		op = "at most "
+	case "gt":
+		op = "greater than "
+	case "lt":
+		op = "less than "
+	}
+	switch fe.Kind() {
+	case reflect.String:
+		return op + fe.Param() + " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return op + fe.Param() + " items"
 	default:
-		return http.StatusInternalServerError, errors.New("unexpected error: " + err.Error())
+		return op + fe.Param()
 	}
 }
 
+var errorCodes = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrNotFound, http.StatusNotFound, "not_found"},
+	{ErrUniqueConstraint, http.StatusConflict, "already_exists"},
+	{ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{ErrInvalidPassword, http.StatusUnauthorized, "invalid_credentials"},
+	{ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{ErrAccountSuspended, http.StatusForbidden, "account_suspended"},
+	{ErrForbidden, http.StatusForbidden, "forbidden"},
+	{ErrTooManyAttempts, http.StatusTooManyRequests, "too_many_attempts"},
+	{ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed"},
+	{ErrRequestInProgress, http.StatusConflict, "request_in_progress"},
+	{ErrIdempotencyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{ErrInsufficientFunds, http.StatusPaymentRequired, "insufficient_funds"},
+	{ErrSlotUnavailable, http.StatusConflict, "slot_unavailable"},
+	{ErrOrderState, http.StatusConflict, "invalid_order_state"},
+	{ErrValidation, http.StatusBadRequest, "validation_failed"},
+	{ErrBadRequest, http.StatusBadRequest, "bad_request"},
+	{ErrDatabase, http.StatusInternalServerError, "database_error"},
+	{ErrInternal, http.StatusInternalServerError, "internal_error"},
+	{ErrUnexpected, http.StatusInternalServerError, "internal_error"},
+}
+
+// NewAPIError maps err onto its HTTP status and error code. Server-side
+// failures get a generic message so internal details are not leaked.
+func NewAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	res := &APIError{
+		Status:  http.StatusInternalServerError,
+		Code:    "internal_error",
+		Message: http.StatusText(http.StatusInternalServerError),
+	}
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			res.Status = ec.status
+			res.Code = ec.code
+			break
+		}
+	}
+	if res.Status < http.StatusInternalServerError {
+		res.Message = err.Error()
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		res.Fields = verr.Fields
+	}
+	return res
+}
@@ -2,23 +2,51 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
 type APIResp struct {
-	Code		int			`json:"code"`
-	Message	string	`json:"message"`
-	Data		any			`json:"data"`	
+	Code    int       `json:"code"`
+	Message string    `json:"message"`
+	Data    any       `json:"data,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
 }
 
 func JSONResponse(w http.ResponseWriter, statusCode int, data any) {
 	response := APIResp{
-			Code:  statusCode,
-			Message: http.StatusText(statusCode),
-			Data:    data,
+		Code:    statusCode,
+		Message: http.StatusText(statusCode),
+		Data:    data,
 	}
+	writeJSON(w, statusCode, response)
+}
+
+// ErrorResponse writes err as an APIError, tagged with the request ID.
+func ErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := *NewAPIError(err)
+	apiErr.RequestID = RequestID(r)
+	response := APIResp{
+		Code:    apiErr.Status,
+		Message: http.StatusText(apiErr.Status),
+		Error:   &apiErr,
+	}
+	writeJSON(w, apiErr.Status, response)
+}
 
+// DecodeJSON reads the request body into dst.
+func DecodeJSON(r *http.Request, dst any) error {
+	if r.Body == nil {
+		return fmt.Errorf("missing request body: %w", ErrBadRequest)
+	}
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return fmt.Errorf("invalid request body: %w", ErrBadRequest)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, response APIResp) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
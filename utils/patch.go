@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Optional is a field of a JSON merge-patch (RFC 7396) body. A field missing
+// from the body leaves Set false, an explicit null sets both Set and Null,
+// and any other value is decoded into Value.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+// Some returns an Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Set: true}
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// validationValue exposes the value to the validator as a pointer that is
+// nil for absent and null fields, so "omitnil" skips them.
+func (o Optional[T]) validationValue() any {
+	if !o.Set || o.Null {
+		return (*T)(nil)
+	}
+	return &o.Value
+}
+
+// Patch builds a single-row UPDATE from merge-patch fields. Only columns in
+// the allow-list can be written, and every update also stamps updated_at.
+type Patch struct {
+	columns map[string]bool
+	sets    []string
+	values  map[string]any
+	args    []any
+	where   []string
+	fields  []FieldError
+	err     error
+}
+
+// NewPatch returns a Patch that accepts the given columns. The map value
+// reports whether the column may be cleared with null.
+func NewPatch(columns map[string]bool) *Patch {
+	return &Patch{columns: columns}
+}
+
+// PatchField records field under column when it was present in the body.
+// Setting a non-nullable column to null is reported as a validation error.
+func PatchField[T any](p *Patch, column string, field Optional[T]) {
+	if !field.Set {
+		return
+	}
+	nullable, ok := p.columns[column]
+	if !ok {
+		p.err = fmt.Errorf("column %s is not patchable: %w", column, ErrInternal)
+		return
+	}
+	if field.Null {
+		if !nullable {
+			p.fields = append(p.fields, FieldError{
+				Field:   column,
+				Rule:    "required",
+				Message: column + " cannot be null",
+			})
+			return
+		}
+		p.set(column, nil)
+		return
+	}
+	p.set(column, field.Value)
+}
+
+func (p *Patch) set(column string, value any) {
+	if p.values == nil {
+		p.values = make(map[string]any)
+	}
+	p.values[column] = value
+	p.args = append(p.args, value)
+	p.sets = append(p.sets, fmt.Sprintf("%s = $%d", column, len(p.args)))
+}
+
+// Err returns the first problem found while collecting fields.
+func (p *Patch) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	if len(p.fields) > 0 {
+		return &ValidationError{Fields: p.fields}
+	}
+	return nil
+}
+
+// Empty reports whether no column will be written.
+func (p *Patch) Empty() bool {
+	return len(p.sets) == 0
+}
+
+// Changes returns the value written to each column, with nil for columns
+// being cleared. It lets stores that do not build SQL apply the patch.
+func (p *Patch) Changes() map[string]any {
+	return p.values
+}
+
+// Where restricts the update to rows where column equals value.
+func (p *Patch) Where(column string, value any) *Patch {
+	p.args = append(p.args, value)
+	p.where = append(p.where, fmt.Sprintf("%s = $%d", column, len(p.args)))
+	return p
+}
+
+// WhereNull restricts the update to rows where column is NULL.
+func (p *Patch) WhereNull(column string) *Patch {
+	p.where = append(p.where, column+" IS NULL")
+	return p
+}
+
+// SQL returns the UPDATE statement for table and its arguments. Call it after
+// every field and condition has been added.
+func (p *Patch) SQL(table, returning string) (string, []any) {
+	sets := append(p.sets[:len(p.sets):len(p.sets)], "updated_at = CURRENT_TIMESTAMP")
+	query := fmt.Sprintf("UPDATE %s SET %s", table, strings.Join(sets, ", "))
+	if len(p.where) > 0 {
+		query += " WHERE " + strings.Join(p.where, " AND ")
+	}
+	if returning != "" {
+		query += " RETURNING " + returning
+	}
+	return query, p.args
+}
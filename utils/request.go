@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+const clientIPKey ctxKey = "client_ip_key"
+
+// WithClientIP records the resolved client address for ClientIP.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIP returns the client address resolved by the ClientIP middleware, or
+// the address of the directly connected peer when it has not run.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
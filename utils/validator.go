@@ -1,59 +1,69 @@
 package utils
 
 import (
-	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
 
-	"github.com/bagasadiii/gofood-clone/model"
 	"github.com/go-playground/validator/v10"
 )
 
-var validation = validator.New()
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	licensePattern  = regexp.MustCompile(`^[A-Z0-9]{6,10}$`)
+	// Indonesian mobile numbers: 08xx, 628xx or +628xx followed by 7-12 digits.
+	idPhonePattern = regexp.MustCompile(`^(\+62|62|0)8[1-9][0-9]{6,11}$`)
+)
+
+var validation = newValidator()
 
-func ValidateUser(user *model.RegisterReq) error {
-	err := validation.Struct(user)
-	if err != nil {
-		var errMsg []string
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg = append(errMsg, fmt.Sprintf("Field '%s' is %s", err.Field(), err.Tag()))
+// newValidator registers the project's custom rules and reports fields by
+// their JSON names so field errors match the request body the client sent.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
 		}
-		return fmt.Errorf("%v: %s", ErrValidation, strings.Join(errMsg, "\n"))
-	}
-	return nil
+		return name
+	})
+	v.RegisterValidation("username", matchPattern(usernamePattern))
+	v.RegisterValidation("license", matchPattern(licensePattern))
+	v.RegisterValidation("idphone", matchPattern(idPhonePattern))
+	v.RegisterCustomTypeFunc(optionalValue, Optional[string]{}, Optional[int64]{}, Optional[int]{})
+	return v
 }
 
-func ValidateLogin(data *model.LoginReq) error {
-	err := validation.Struct(data)
-	if err != nil {
-		var errMsg []string
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg = append(errMsg, fmt.Sprintf("Field '%s' is %s", err.Field(), err.Tag()))
-		}
-		return fmt.Errorf("%v: %s", ErrValidation, strings.Join(errMsg, "\n"))
+func optionalValue(field reflect.Value) any {
+	if o, ok := field.Interface().(interface{ validationValue() any }); ok {
+		return o.validationValue()
 	}
 	return nil
 }
 
-func ValidateMerchant(merchant *model.Merchant) error {
-	err := validation.Struct(merchant)
-	if err != nil {
-		var errMsg []string
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg = append(errMsg, fmt.Sprintf("Field '%s' is %s", err.Field(), err.Tag()))
-		}
-		return fmt.Errorf("%v: \n%s", ErrValidation, strings.Join(errMsg, "\n"))
+// ValidationPattern returns the regular expression behind a custom
+// validation tag such as "username", for documenting the rule.
+func ValidationPattern(tag string) (string, bool) {
+	switch tag {
+	case "username":
+		return usernamePattern.String(), true
+	case "license":
+		return licensePattern.String(), true
+	case "idphone":
+		return idPhonePattern.String(), true
 	}
-	return nil
+	return "", false
 }
 
-func ValidateDriver(driver *model.Driver) error {
-	err := validation.Struct(driver)
-	if err != nil {
-		var errMsg []string
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg = append(errMsg, fmt.Sprintf("Field '%s' is %s", err.Field(), err.Tag()))
-		}
-		return fmt.Errorf("%v: %s", ErrValidation, strings.Join(errMsg, "\n"))
+func matchPattern(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
 	}
-	return nil
+}
+
+// Validate checks data against its validate tags and returns a
+// *ValidationError listing every failing field.
+func Validate[T any](data *T) error {
+	return NewValidationError(validation.Struct(data))
 }